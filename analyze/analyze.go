@@ -0,0 +1,315 @@
+package analyze
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gopatchy/artmap/capture"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/espnet"
+	"github.com/gopatchy/artnet"
+	"github.com/gopatchy/sacn"
+)
+
+// startCodePriority is the sACN start code of per-address priority frames
+const startCodePriority = 0xDD
+
+// Source is the traffic of one sender on one universe: an IP and ArtNet
+// physical port, or an sACN CID
+type Source struct {
+	Universe    config.Universe
+	IP          net.IP
+	Physical    uint8  // ArtNet only
+	Name        string // sACN source name
+	Frames      uint64
+	First       time.Time
+	Last        time.Time
+	Lost        uint64 // frames skipped by the sequence number
+	Reordered   uint64 // frames with a sequence number at or before the previous one
+	MinLength   int
+	MaxLength   int
+	MinPriority uint8 // sACN only
+	MaxPriority uint8
+	PerAddress  uint64 // sACN per-address priority (0xDD) frames, not counted in Frames
+	SyncAddr    uint16 // sACN synchronization universe of the last frame, 0 for none
+	ArtSync     bool   // the source's IP sent ArtSync
+
+	seq    uint8
+	seqSet bool
+}
+
+// FPS returns the average frame rate between the source's first and last frame
+func (s *Source) FPS() float64 {
+	if d := s.Last.Sub(s.First); s.Frames > 1 && d > 0 {
+		return float64(s.Frames-1) / d.Seconds()
+	}
+	return 0
+}
+
+// Sync is a sender of synchronization packets: ArtSync from an IP, or sACN
+// synchronization on a universe
+type Sync struct {
+	Protocol config.Protocol
+	IP       net.IP
+	Universe uint16 // sACN synchronization universe
+	Packets  uint64
+}
+
+// Report summarizes a capture
+type Report struct {
+	Packets uint64 // UDP datagrams
+	Other   uint64 // datagrams that are not ArtDmx, ArtSync, sACN or ESP Net data
+	First   time.Time
+	Last    time.Time
+	Sources []*Source // sorted by universe, then IP
+	Syncs   []*Sync
+}
+
+type sourceKey struct {
+	u        config.Universe
+	ip       string
+	physical uint8
+	cid      [16]byte
+}
+
+type syncKey struct {
+	proto    config.Protocol
+	ip       string
+	universe uint16
+}
+
+// Analyze reads every packet of a capture with the same parsers artmap receives with
+func Analyze(r *capture.Reader) (*Report, error) {
+	rep := &Report{}
+	sources := map[sourceKey]*Source{}
+	syncs := map[syncKey]*Sync{}
+	sync := func(k syncKey, ip net.IP) {
+		s := syncs[k]
+		if s == nil {
+			s = &Sync{Protocol: k.proto, IP: ip, Universe: k.universe}
+			syncs[k] = s
+		}
+		s.Packets++
+	}
+	frame := func(k sourceKey, ts time.Time, ip net.IP, length int) *Source {
+		s := sources[k]
+		if s == nil {
+			s = &Source{Universe: k.u, IP: ip, Physical: k.physical, First: ts, MinLength: length}
+			sources[k] = s
+		}
+		s.Frames++
+		s.Last = ts
+		s.MinLength = min(s.MinLength, length)
+		s.MaxLength = max(s.MaxLength, length)
+		return s
+	}
+
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rep.Packets++
+		if rep.First.IsZero() {
+			rep.First = p.Time
+		}
+		rep.Last = p.Time
+		ip := p.Src.IP
+
+		if op, pkt, err := artnet.ParsePacket(p.Payload); err == nil {
+			switch op {
+			case artnet.OpDmx:
+				dmx := pkt.(*artnet.DMXPacket)
+				s := frame(sourceKey{u: config.ArtNetUniverse(dmx.Universe), ip: ip.String(), physical: dmx.Physical}, p.Time, ip, int(min(dmx.Length, 512)))
+				if dmx.Sequence != 0 {
+					s.sequence(dmx.Sequence, true)
+				}
+			case artnet.OpSync:
+				sync(syncKey{proto: config.ProtocolArtNet, ip: ip.String()}, ip)
+			default:
+				rep.Other++
+			}
+			continue
+		}
+
+		if u, ok := sacnSync(p.Payload); ok {
+			sync(syncKey{proto: config.ProtocolSACN, ip: ip.String(), universe: u}, ip)
+			continue
+		}
+		if pkt, err := sacn.ParsePacket(p.Payload); err == nil {
+			data, ok := pkt.(*sacn.DataPacket)
+			if !ok {
+				rep.Other++
+				continue
+			}
+			k := sourceKey{u: config.SACNUniverse(data.Universe), ip: ip.String(), cid: data.CID}
+			if p.Payload[125] == startCodePriority {
+				if s := sources[k]; s != nil {
+					s.PerAddress++
+				}
+				continue
+			}
+			s := frame(k, p.Time, ip, data.DataLen)
+			s.Name = data.SourceName
+			if s.Frames == 1 || data.Priority < s.MinPriority {
+				s.MinPriority = data.Priority
+			}
+			s.MaxPriority = max(s.MaxPriority, data.Priority)
+			s.SyncAddr = binary.BigEndian.Uint16(p.Payload[109:111])
+			s.sequence(data.Sequence, false)
+			continue
+		}
+
+		if pkt, err := espnet.ParseDataPacket(p.Payload); err == nil {
+			frame(sourceKey{u: config.Universe{Protocol: config.ProtocolESPNet, Number: uint16(pkt.Universe)}, ip: ip.String()}, p.Time, ip, min(len(pkt.Data), 512))
+			continue
+		}
+		rep.Other++
+	}
+
+	artSync := map[string]bool{}
+	for _, s := range syncs {
+		rep.Syncs = append(rep.Syncs, s)
+		if s.Protocol == config.ProtocolArtNet {
+			artSync[s.IP.String()] = true
+		}
+	}
+	for _, s := range sources {
+		s.ArtSync = s.Universe.Protocol == config.ProtocolArtNet && artSync[s.IP.String()]
+		rep.Sources = append(rep.Sources, s)
+	}
+	sort.Slice(rep.Sources, func(i, j int) bool {
+		a, b := rep.Sources[i], rep.Sources[j]
+		if c := a.Universe.Compare(b.Universe); c != 0 {
+			return c < 0
+		}
+		if c := strings.Compare(a.IP.String(), b.IP.String()); c != 0 {
+			return c < 0
+		}
+		if a.Physical != b.Physical {
+			return a.Physical < b.Physical
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(rep.Syncs, func(i, j int) bool {
+		a, b := rep.Syncs[i], rep.Syncs[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Universe != b.Universe {
+			return a.Universe < b.Universe
+		}
+		return a.IP.String() < b.IP.String()
+	})
+	return rep, nil
+}
+
+// sequence counts frames lost or reordered by sequence number. ArtNet numbers
+// run 1-255 (0 disables them); sACN numbers run 0-255 and, as in E1.31, a frame
+// up to 20 behind the previous one is out of order rather than a wrap.
+func (s *Source) sequence(seq uint8, artNet bool) {
+	prev, set := s.seq, s.seqSet
+	s.seq, s.seqSet = seq, true
+	if !set {
+		return
+	}
+	step := int(seq) - int(prev)
+	if artNet {
+		step = (step + 255) % 255
+	} else {
+		step = (step + 256) % 256
+	}
+	switch {
+	case step == 0 || (!artNet && step > 256-20) || (artNet && step > 255-20):
+		s.Reordered++
+	default:
+		s.Lost += uint64(step - 1)
+	}
+}
+
+// sacnSync returns the synchronization universe of an E1.31 synchronization packet
+func sacnSync(b []byte) (uint16, bool) {
+	if len(b) < 49 || [12]byte(b[4:16]) != sacn.PacketIdentifier {
+		return 0, false
+	}
+	if binary.BigEndian.Uint32(b[18:22]) != sacn.VectorRootE131Extended || binary.BigEndian.Uint32(b[40:44]) != 1 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(b[45:47]), true
+}
+
+// Write prints the report as a table of sources per universe followed by the synchronization senders
+func (rep *Report) Write(w io.Writer) error {
+	fmt.Fprintf(w, "%d packets over %s, %d not DMX\n\n", rep.Packets, rep.Last.Sub(rep.First).Round(time.Millisecond), rep.Other)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UNIVERSE\tSOURCE\tFRAMES\tFPS\tLOST\tREORDERED\tLENGTH\tPRIORITY\tSYNC")
+	for _, s := range rep.Sources {
+		src := s.IP.String()
+		switch s.Universe.Protocol {
+		case config.ProtocolArtNet:
+			if s.Physical != 0 {
+				src += fmt.Sprintf(" physical %d", s.Physical)
+			}
+		case config.ProtocolSACN:
+			src += fmt.Sprintf(" %q", s.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%d\t%d\t%s\t%s\t%s\n", s.Universe, src, s.Frames, s.FPS(), s.Lost, s.Reordered,
+			span(s.MinLength, s.MaxLength), s.priority(), s.sync())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(rep.Syncs) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SYNC\tSOURCE\tPACKETS")
+	for _, s := range rep.Syncs {
+		name := "artsync"
+		if s.Protocol == config.ProtocolSACN {
+			name = config.SACNUniverse(s.Universe).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", name, s.IP, s.Packets)
+	}
+	return tw.Flush()
+}
+
+func (s *Source) priority() string {
+	if s.Universe.Protocol != config.ProtocolSACN {
+		return "-"
+	}
+	p := span(int(s.MinPriority), int(s.MaxPriority))
+	if s.PerAddress > 0 {
+		p += " +0xDD"
+	}
+	return p
+}
+
+func (s *Source) sync() string {
+	switch {
+	case s.ArtSync:
+		return "artsync"
+	case s.SyncAddr != 0:
+		return config.SACNUniverse(s.SyncAddr).String()
+	}
+	return "-"
+}
+
+func span(lo, hi int) string {
+	if lo == hi {
+		return fmt.Sprint(lo)
+	}
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
@@ -0,0 +1,258 @@
+package anomaly
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+)
+
+type Kind string
+
+const (
+	// RateDrop means a source is sending well below its established frame rate
+	RateDrop Kind = "rate_drop"
+	// Erratic means a source's frame intervals vary far more than their mean
+	Erratic Kind = "erratic"
+)
+
+const (
+	// minBaselineFPS ignores sources that only send occasionally, e.g. on change
+	minBaselineFPS = 10
+	// warmup is how many evaluations a stream needs before rate drops are reported
+	warmup = 5
+	// minSamples is how many intervals a window needs before jitter is judged
+	minSamples = 10
+	// forgetAfter drops streams that have been silent this long
+	forgetAfter = 30 * time.Second
+)
+
+// Event reports an anomaly being raised (Active) or cleared on one source's universe
+type Event struct {
+	Time        time.Time       `json:"time"`
+	Universe    config.Universe `json:"universe"`
+	Source      string          `json:"source"`
+	Kind        Kind            `json:"kind"`
+	Active      bool            `json:"active"`
+	ExpectedFPS float64         `json:"expected_fps"`
+	ObservedFPS float64         `json:"observed_fps"`
+	Jitter      float64         `json:"jitter"`
+}
+
+type key struct {
+	u  config.Universe
+	ip string
+}
+
+type stream struct {
+	windowStart time.Time
+	last        time.Time
+	count       int
+	sum         float64
+	sumSq       float64
+	baseline    float64
+	evaluations int
+	active      map[Kind]*Event
+}
+
+// Detector compares each source's observed input frame rate against its own
+// established baseline and raises events when it drops sharply or becomes erratic
+type Detector struct {
+	mu        sync.Mutex
+	dropRatio float64
+	maxJitter float64
+	streams   map[key]*stream
+	onEvent   func(Event)
+	clock     clock.Clock
+}
+
+// New returns a detector raising RateDrop below dropRatio of the baseline rate and
+// Erratic above maxJitter (interval standard deviation / mean); 0 disables either check
+func New(dropRatio, maxJitter float64) *Detector {
+	if dropRatio <= 0 && maxJitter <= 0 {
+		return nil
+	}
+	return &Detector{
+		dropRatio: dropRatio,
+		maxJitter: maxJitter,
+		streams:   map[key]*stream{},
+		clock:     clock.Real,
+	}
+}
+
+// SetClock replaces the clock frame intervals and windows are measured with; call before use
+func (d *Detector) SetClock(c clock.Clock) {
+	if d != nil {
+		d.clock = c
+	}
+}
+
+// SetOnEvent registers a callback for raised and cleared events; it must be set before use
+func (d *Detector) SetOnEvent(fn func(Event)) {
+	if d != nil {
+		d.onEvent = fn
+	}
+}
+
+func (d *Detector) Record(u config.Universe, ip net.IP) {
+	if d == nil {
+		return
+	}
+	now := d.clock.Now()
+	k := key{u, ip.String()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := d.streams[k]
+	if s == nil {
+		s = &stream{windowStart: now, active: map[Kind]*Event{}}
+		d.streams[k] = s
+	} else {
+		interval := now.Sub(s.last).Seconds()
+		s.sum += interval
+		s.sumSq += interval * interval
+	}
+	s.last = now
+	s.count++
+}
+
+// Evaluate closes the current window of every stream and raises or clears events;
+// it should be called about once per second
+func (d *Detector) Evaluate() {
+	if d == nil {
+		return
+	}
+	now := d.clock.Now()
+
+	var events []Event
+	d.mu.Lock()
+	for k, s := range d.streams {
+		if now.Sub(s.last) > forgetAfter {
+			for kind := range s.active {
+				events = append(events, d.clear(s, kind, now))
+			}
+			delete(d.streams, k)
+			continue
+		}
+		events = append(events, d.evaluate(k, s, now)...)
+	}
+	d.mu.Unlock()
+
+	if d.onEvent != nil {
+		for _, e := range events {
+			d.onEvent(e)
+		}
+	}
+}
+
+func (d *Detector) evaluate(k key, s *stream, now time.Time) []Event {
+	elapsed := now.Sub(s.windowStart).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+	fps := float64(s.count) / elapsed
+
+	jitter := 0.0
+	if n := float64(s.count - 1); n >= minSamples {
+		mean := s.sum / n
+		variance := max(0, s.sumSq/n-mean*mean)
+		if mean > 0 {
+			jitter = math.Sqrt(variance) / mean
+		}
+	}
+
+	s.windowStart = now
+	s.count = 0
+	s.sum = 0
+	s.sumSq = 0
+	s.evaluations++
+
+	var events []Event
+	check := func(kind Kind, bad bool) {
+		_, active := s.active[kind]
+		switch {
+		case bad && !active:
+			e := &Event{
+				Time:        now,
+				Universe:    k.u,
+				Source:      k.ip,
+				Kind:        kind,
+				Active:      true,
+				ExpectedFPS: s.baseline,
+				ObservedFPS: fps,
+				Jitter:      jitter,
+			}
+			s.active[kind] = e
+			events = append(events, *e)
+		case bad:
+			s.active[kind].ObservedFPS = fps
+			s.active[kind].Jitter = jitter
+		case active:
+			e := d.clear(s, kind, now)
+			e.ObservedFPS = fps
+			e.Jitter = jitter
+			events = append(events, e)
+		}
+	}
+
+	established := s.evaluations > warmup && s.baseline >= minBaselineFPS
+	if d.dropRatio > 0 {
+		check(RateDrop, established && fps < s.baseline*d.dropRatio)
+	}
+	if d.maxJitter > 0 {
+		check(Erratic, established && jitter > d.maxJitter)
+	}
+
+	// Track the baseline slowly while degraded so a lasting rate change is eventually accepted
+	alpha := 0.1
+	if len(s.active) > 0 {
+		alpha = 0.01
+	}
+	if s.baseline == 0 {
+		s.baseline = fps
+	} else {
+		s.baseline += alpha * (fps - s.baseline)
+	}
+
+	return events
+}
+
+func (d *Detector) clear(s *stream, kind Kind, now time.Time) Event {
+	e := *s.active[kind]
+	delete(s.active, kind)
+	e.Time = now
+	e.Active = false
+	return e
+}
+
+// Active returns the currently raised anomalies sorted by universe and source
+func (d *Detector) Active() []Event {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	var result []Event
+	for _, s := range d.streams {
+		for _, e := range s.active {
+			result = append(result, *e)
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if c := a.Universe.Compare(b.Universe); c != 0 {
+			return c < 0
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Kind < b.Kind
+	})
+	return result
+}
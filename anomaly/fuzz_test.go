@@ -0,0 +1,96 @@
+package anomaly
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+)
+
+func TestRateDrop(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	d := New(0.5, 0)
+	d.SetClock(clk)
+	var events []Event
+	d.SetOnEvent(func(e Event) { events = append(events, e) })
+
+	u := config.ArtNetUniverse(1)
+	src := net.IPv4(10, 0, 0, 9)
+	// send runs one evaluation window of fps evenly spaced frames
+	send := func(fps int) {
+		for range fps {
+			clk.Advance(time.Second / time.Duration(fps))
+			d.Record(u, src)
+		}
+		d.Evaluate()
+	}
+
+	for range warmup + 2 {
+		send(40)
+	}
+	if len(events) != 0 {
+		t.Fatalf("events during a steady rate: %v", events)
+	}
+
+	send(5)
+	if len(events) != 1 || events[0].Kind != RateDrop || !events[0].Active {
+		t.Fatalf("after the drop got %v, want one active rate_drop", events)
+	}
+	if active := d.Active(); len(active) != 1 || active[0].Universe != u || active[0].Source != src.String() {
+		t.Fatalf("active = %v", active)
+	}
+
+	send(40)
+	if len(events) != 2 || events[1].Kind != RateDrop || events[1].Active {
+		t.Fatalf("after recovery got %v, want the rate_drop cleared", events)
+	}
+	if active := d.Active(); len(active) != 0 {
+		t.Fatalf("still active after recovery: %v", active)
+	}
+}
+
+func TestErratic(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	d := New(0, 0.5)
+	d.SetClock(clk)
+	u := config.SACNUniverse(3)
+	src := net.IPv4(10, 0, 0, 9)
+
+	// send runs one window of 40 frames, alternating short and long gaps when erratic
+	send := func(erratic bool) {
+		for i := range 40 {
+			gap := 25 * time.Millisecond
+			if erratic {
+				gap = []time.Duration{5 * time.Millisecond, 45 * time.Millisecond}[i%2]
+			}
+			clk.Advance(gap)
+			d.Record(u, src)
+		}
+		d.Evaluate()
+	}
+
+	for range warmup + 2 {
+		send(false)
+	}
+	if active := d.Active(); len(active) != 0 {
+		t.Fatalf("steady source flagged: %v", active)
+	}
+	send(true)
+	if active := d.Active(); len(active) != 1 || active[0].Kind != Erratic {
+		t.Fatalf("erratic source: active = %v", active)
+	}
+}
+
+func TestForget(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	d := New(0.5, 0)
+	d.SetClock(clk)
+	d.Record(config.ArtNetUniverse(1), net.IPv4(10, 0, 0, 9))
+	clk.Advance(forgetAfter + time.Second)
+	d.Evaluate()
+	if len(d.streams) != 0 {
+		t.Fatalf("silent stream kept")
+	}
+}
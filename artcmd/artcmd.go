@@ -0,0 +1,155 @@
+package artcmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artnet"
+)
+
+const (
+	// OpCommand carries text commands as "Key=Value&" pairs
+	OpCommand uint16 = 0x2400
+
+	// ESTAAll addresses a command to every manufacturer's equipment
+	ESTAAll uint16 = 0xFFFF
+
+	// headerLen covers ID, OpCode, ProtVer, EstaMan and Length
+	headerLen = 16
+	maxData   = 512
+
+	// recentCommands is how many received commands are kept for the API
+	recentCommands = 50
+)
+
+var errShort = errors.New("packet too short")
+
+// Command is one key/value pair of an ArtCommand
+type Command struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Parse returns the manufacturer code and text of an ArtCommand packet
+func Parse(data []byte) (uint16, string, error) {
+	if len(data) < headerLen {
+		return 0, "", errShort
+	}
+	if !bytes.Equal(data[:8], artnet.ID[:]) || binary.LittleEndian.Uint16(data[8:]) != OpCommand {
+		return 0, "", fmt.Errorf("not an ArtCommand")
+	}
+	esta := binary.BigEndian.Uint16(data[12:])
+	// The data field is at most 512 bytes including its terminating null
+	n := min(int(binary.BigEndian.Uint16(data[14:])), maxData-1, len(data)-headerLen)
+	text, _, _ := strings.Cut(string(data[headerLen:headerLen+n]), "\x00")
+	return esta, text, nil
+}
+
+// Build returns an ArtCommand packet for manufacturer esta carrying text
+func Build(esta uint16, text string) ([]byte, error) {
+	if len(text) >= maxData {
+		return nil, fmt.Errorf("command text is %d bytes (max %d)", len(text), maxData-1)
+	}
+	if strings.Contains(text, "\x00") {
+		return nil, fmt.Errorf("command text contains a null byte")
+	}
+	buf := make([]byte, headerLen+len(text)+1)
+	copy(buf, artnet.ID[:])
+	binary.LittleEndian.PutUint16(buf[8:], OpCommand)
+	binary.BigEndian.PutUint16(buf[10:], artnet.ProtocolVersion)
+	binary.BigEndian.PutUint16(buf[12:], esta)
+	binary.BigEndian.PutUint16(buf[14:], uint16(len(text)+1))
+	copy(buf[headerLen:], text)
+	return buf, nil
+}
+
+// Commands splits "Key=Value&Key=Value&" text into its pairs, skipping empty entries
+func Commands(text string) []Command {
+	var result []Command
+	for _, part := range strings.Split(text, "&") {
+		key, value, _ := strings.Cut(part, "=")
+		if key = strings.TrimSpace(key); key != "" {
+			result = append(result, Command{Key: key, Value: value})
+		}
+	}
+	return result
+}
+
+// Received is an ArtCommand packet as reported by the API
+type Received struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	ESTA     uint16    `json:"esta"`
+	Text     string    `json:"text"`
+	Commands []Command `json:"commands"`
+}
+
+// Handler keeps recently received ArtCommands and runs the action registered
+// for each key. Keys match case-insensitively, as Art-Net specifies.
+type Handler struct {
+	actions map[string]func(src *net.UDPAddr, value string)
+	forward func(src *net.UDPAddr, esta uint16, data []byte)
+
+	mu     sync.Mutex
+	recent []Received
+}
+
+func NewHandler() *Handler {
+	return &Handler{actions: map[string]func(*net.UDPAddr, string){}}
+}
+
+// On registers fn for commands with key; it must be called before Handle is in use
+func (h *Handler) On(key string, fn func(src *net.UDPAddr, value string)) {
+	h.actions[strings.ToLower(key)] = fn
+}
+
+// SetForward registers fn to be called with every well-formed ArtCommand
+// packet received, whatever its manufacturer, e.g. to pass it on to
+// downstream nodes; it must be called before Handle is in use
+func (h *Handler) SetForward(fn func(src *net.UDPAddr, esta uint16, data []byte)) {
+	h.forward = fn
+}
+
+// Handle takes an incoming ArtCommand packet. Actions only run for commands
+// addressed to all manufacturers, since artmap has no ESTA code of its own.
+func (h *Handler) Handle(src *net.UDPAddr, data []byte) {
+	esta, text, err := Parse(data)
+	if err != nil {
+		return
+	}
+	rec := Received{Time: time.Now(), Source: src.IP.String(), ESTA: esta, Text: text, Commands: Commands(text)}
+
+	h.mu.Lock()
+	h.recent = append(h.recent, rec)
+	if len(h.recent) > recentCommands {
+		h.recent = h.recent[len(h.recent)-recentCommands:]
+	}
+	h.mu.Unlock()
+
+	if h.forward != nil {
+		h.forward(src, esta, data)
+	}
+	if esta != ESTAAll {
+		return
+	}
+	for _, c := range rec.Commands {
+		if fn := h.actions[strings.ToLower(c.Key)]; fn != nil {
+			fn(src, c.Value)
+		}
+	}
+}
+
+// Recent returns the received commands, oldest first
+func (h *Handler) Recent() []Received {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := make([]Received, len(h.recent))
+	copy(result, h.recent)
+	return result
+}
@@ -0,0 +1,46 @@
+package artcmd
+
+import (
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	pkt, _ := Build(ESTAAll, "SwoutText=Playback&SwinText=Record&")
+	f.Add(pkt)
+	f.Add(pkt[:headerLen])
+	f.Add(pkt[:headerLen+3])
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		esta, text, err := Parse(data)
+		if err != nil {
+			return
+		}
+		if len(text) >= maxData {
+			t.Fatalf("text is %d bytes", len(text))
+		}
+		rebuilt, err := Build(esta, text)
+		if err != nil {
+			t.Fatalf("build failed for parsed text %q: %v", text, err)
+		}
+		esta2, text2, err := Parse(rebuilt)
+		if err != nil || esta2 != esta || text2 != text {
+			t.Fatalf("roundtrip mismatch: %d %q -> %d %q (%v)", esta, text, esta2, text2, err)
+		}
+	})
+}
+
+func FuzzCommands(f *testing.F) {
+	f.Add("SwoutText=Playback&SwinText=Record&")
+	f.Add("ClearTargets&")
+	f.Add("Message=a=b&&")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, c := range Commands(text) {
+			if c.Key == "" {
+				t.Fatalf("empty key in %q", text)
+			}
+		}
+	})
+}
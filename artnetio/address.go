@@ -0,0 +1,166 @@
+package artnetio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/gopatchy/artnet"
+)
+
+// OpAddress is the opcode of ArtAddress, a controller reprogramming a node
+const OpAddress uint16 = 0x6000
+
+// ArtAddress commands handled by Discovery; the rest are logged and ignored
+const (
+	AddressNone      uint8 = 0x00
+	AddressLedNormal uint8 = 0x02
+	AddressLedMute   uint8 = 0x03
+	AddressLedLocate uint8 = 0x04
+)
+
+// Indicator is the front panel indicator state reported in ArtPollReply Status1
+type Indicator uint8
+
+const (
+	IndicatorUnknown Indicator = 0
+	IndicatorLocate  Indicator = 1
+	IndicatorMute    Indicator = 2
+	IndicatorNormal  Indicator = 3
+)
+
+func (i Indicator) String() string {
+	switch i {
+	case IndicatorLocate:
+		return "locate"
+	case IndicatorMute:
+		return "mute"
+	case IndicatorNormal:
+		return "normal"
+	}
+	return "unknown"
+}
+
+// AddressPacket is an ArtAddress packet. A switch with bit 7 set programs the
+// low bits, 0x00 resets it to the configured value and 0x7f leaves it alone;
+// empty names are left unchanged.
+type AddressPacket struct {
+	NetSwitch uint8
+	BindIndex uint8
+	ShortName string
+	LongName  string
+	SwIn      [4]uint8
+	SwOut     [4]uint8
+	SubSwitch uint8
+	Command   uint8
+}
+
+// ParseAddress parses a raw ArtAddress packet
+func ParseAddress(data []byte) (*AddressPacket, error) {
+	if len(data) < 107 || !bytes.Equal(data[:8], artnet.ID[:]) {
+		return nil, fmt.Errorf("not an Art-Net packet")
+	}
+	if op := binary.LittleEndian.Uint16(data[8:10]); op != OpAddress {
+		return nil, fmt.Errorf("opcode 0x%04x is not ArtAddress", op)
+	}
+	pkt := &AddressPacket{
+		NetSwitch: data[12],
+		BindIndex: data[13],
+		ShortName: cString(data[14:32]),
+		LongName:  cString(data[32:96]),
+		SubSwitch: data[104],
+		Command:   data[106],
+	}
+	copy(pkt.SwIn[:], data[96:100])
+	copy(pkt.SwOut[:], data[100:104])
+	return pkt, nil
+}
+
+// BuildAddress builds a raw ArtAddress packet
+func BuildAddress(pkt *AddressPacket) []byte {
+	data := make([]byte, 107)
+	copy(data, artnet.ID[:])
+	binary.LittleEndian.PutUint16(data[8:10], OpAddress)
+	binary.BigEndian.PutUint16(data[10:12], protocolVersion)
+	data[12] = pkt.NetSwitch
+	data[13] = pkt.BindIndex
+	copy(data[14:31], pkt.ShortName)
+	copy(data[32:95], pkt.LongName)
+	copy(data[96:100], pkt.SwIn[:])
+	copy(data[100:104], pkt.SwOut[:])
+	data[104] = pkt.SubSwitch
+	data[106] = pkt.Command
+	return data
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// HandleAddress applies an ArtAddress from src and answers with the updated
+// ArtPollReplies. Port addresses come from the mappings, so SwIn and SwOut
+// are not programmable.
+func (d *Discovery) HandleAddress(src *net.UDPAddr, pkt *AddressPacket) {
+	d.identMu.Lock()
+	if pkt.ShortName != "" {
+		d.shortName = pkt.ShortName
+		log.Printf("[artnet] address src=%s short_name=%q", src.IP, pkt.ShortName)
+	}
+	if pkt.LongName != "" {
+		d.longName = pkt.LongName
+		log.Printf("[artnet] address src=%s long_name=%q", src.IP, pkt.LongName)
+	}
+	netSwitch, netOK := programSwitch(pkt.NetSwitch, 0x7f, d.netSwitch, d.configSwitch.net)
+	subSwitch, subOK := programSwitch(pkt.SubSwitch, 0x0f, d.subSwitch, d.configSwitch.sub)
+	if netOK || subOK {
+		// Programming either switch fixes both; resetting returns to the config
+		d.fixedSwitch = pkt.NetSwitch&0x80 != 0 || pkt.SubSwitch&0x80 != 0 || d.configSwitch.fixed
+		d.netSwitch, d.subSwitch = netSwitch, subSwitch
+		log.Printf("[artnet] address src=%s fixed_switch=%v net_switch=%d sub_switch=%d", src.IP, d.fixedSwitch, netSwitch, subSwitch)
+		if d.fixedSwitch {
+			d.logSwitched()
+		}
+	}
+	switch pkt.Command {
+	case AddressNone:
+	case AddressLedNormal:
+		d.indicator = IndicatorNormal
+	case AddressLedMute:
+		d.indicator = IndicatorMute
+	case AddressLedLocate:
+		d.indicator = IndicatorLocate
+	default:
+		log.Printf("[artnet] address src=%s unsupported command=0x%02x", src.IP, pkt.Command)
+	}
+	if pkt.Command >= AddressLedNormal && pkt.Command <= AddressLedLocate {
+		log.Printf("[artnet] address src=%s indicator=%s", src.IP, d.indicator)
+	}
+	d.identMu.Unlock()
+
+	for i := range 4 {
+		if pkt.SwIn[i] != 0x7f || pkt.SwOut[i] != 0x7f {
+			log.Printf("[artnet] address src=%s port switches not programmable; ports follow the mappings", src.IP)
+			break
+		}
+	}
+	d.reply(src)
+}
+
+// programSwitch returns the new value of a switch: 0x7f keeps cur, 0x00
+// resets to def and bit 7 programs the masked low bits
+func programSwitch(v, mask, cur, def uint8) (uint8, bool) {
+	switch {
+	case v == 0x7f:
+		return cur, false
+	case v == 0:
+		return def, true
+	case v&0x80 != 0:
+		return v & mask, true
+	}
+	return cur, false
+}
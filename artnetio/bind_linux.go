@@ -0,0 +1,10 @@
+//go:build linux
+
+package artnetio
+
+import "syscall"
+
+// bindToDevice pins a socket's egress to the named interface
+func bindToDevice(fd uintptr, name string) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+}
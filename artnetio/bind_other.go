@@ -0,0 +1,9 @@
+//go:build !linux
+
+package artnetio
+
+// bindToDevice does nothing: only Linux pins sockets to a device, and binding
+// to the interface address alone still selects the source address
+func bindToDevice(fd uintptr, name string) error {
+	return nil
+}
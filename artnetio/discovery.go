@@ -0,0 +1,573 @@
+package artnetio
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artnet"
+)
+
+// ReplyMode selects where ArtPollReply packets are sent
+type ReplyMode string
+
+const (
+	// ReplyUnicast answers each poll at the poller's observed source address and port
+	ReplyUnicast ReplyMode = "unicast"
+	// ReplyBroadcast answers each poll on the broadcast address at port 6454
+	ReplyBroadcast ReplyMode = "broadcast"
+)
+
+func ParseReplyMode(s string) (ReplyMode, error) {
+	switch m := ReplyMode(s); m {
+	case ReplyUnicast, ReplyBroadcast:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid poll reply mode %q (expected unicast or broadcast)", s)
+}
+
+type Discovery struct {
+	sender        *Sender
+	receiver      *Receiver
+	replyMode     ReplyMode
+	fixedIP       bool
+	nodes         map[string]*artnet.Node
+	sources       map[string]*replySource
+	replyStats    ReplyStats
+	nodesMu       sync.RWMutex
+	localIP       [4]byte
+	localMAC      [6]byte
+	broadcast     net.IP
+	unicast       []*net.UDPAddr
+	pollRanges    []PollRange // targets of the broadcast polls, nil for an untargeted poll
+	identMu       sync.RWMutex
+	shortName     string
+	longName      string
+	inputUnivs    []artnet.Universe
+	outputUnivs   []artnet.Universe
+	fixedSwitch   bool
+	netSwitch     uint8
+	subSwitch     uint8
+	configSwitch  switches // the switches ArtAddress resets to
+	indicator     Indicator
+	done          chan struct{}
+	onChange      func(*artnet.Node)
+	onDiscover    func(addr *net.UDPAddr, outputs []artnet.Universe)
+	report        func() string
+	reports       atomic.Uint32
+	lastPollHeard time.Time
+	replyInterval time.Duration
+	answered      map[string]time.Time // poller IP -> when its last poll was answered
+	throttled     atomic.Uint64
+	pollMu        sync.Mutex
+	clock         clock.Clock
+}
+
+func NewDiscovery(sender *Sender, localIP, broadcast net.IP, localMAC net.HardwareAddr, shortName, longName string, inputUnivs, outputUnivs []artnet.Universe) *Discovery {
+	d := &Discovery{
+		sender:      sender,
+		replyMode:   ReplyUnicast,
+		nodes:       map[string]*artnet.Node{},
+		sources:     map[string]*replySource{},
+		answered:    map[string]time.Time{},
+		broadcast:   broadcast,
+		shortName:   shortName,
+		longName:    longName,
+		inputUnivs:  inputUnivs,
+		outputUnivs: outputUnivs,
+		done:        make(chan struct{}),
+		clock:       clock.Real,
+	}
+	if ip4 := localIP.To4(); ip4 != nil {
+		copy(d.localIP[:], ip4)
+	}
+	if len(localMAC) == 6 {
+		copy(d.localMAC[:], localMAC)
+	}
+	return d
+}
+
+func (d *Discovery) Start() {
+	go d.pollLoop()
+}
+
+func (d *Discovery) Stop() {
+	close(d.done)
+}
+
+func (d *Discovery) SetReceiver(r *Receiver) {
+	d.receiver = r
+}
+
+// SetClock replaces the clock driving polls and node expiry; call before Start
+func (d *Discovery) SetClock(c clock.Clock) {
+	d.clock = c
+}
+
+// SetPollTargets adds unicast addresses polled every cycle, reaching nodes on
+// routed subnets that broadcast polls never cross. Replies from them are
+// trusted: they may announce another IP (behind NAT) and are never damped.
+// Call before Start.
+func (d *Discovery) SetPollTargets(addrs []*net.UDPAddr) {
+	d.unicast = addrs
+}
+
+// SetPollUniverses targets the broadcast polls at the Port-Addresses of
+// universes, so only the nodes artmap outputs to reply; with none, every node
+// is polled. Call before Start.
+func (d *Discovery) SetPollUniverses(universes []artnet.Universe) {
+	d.pollRanges = PollRanges(universes)
+}
+
+// polled reports whether ip is a unicast poll target
+func (d *Discovery) polled(ip net.IP) bool {
+	for _, addr := range d.unicast {
+		if addr.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReport registers fn to supply the text of the NodeReport in our
+// ArtPollReplies; call before Start
+func (d *Discovery) SetReport(fn func() string) {
+	d.report = fn
+}
+
+// SetSwitches fixes the NetSwitch and SubSwitch advertised in every
+// ArtPollReply, for consoles that group nodes by them, instead of taking them
+// from the universes of each reply. Only universes in that net and sub-net can
+// be advertised; the rest are logged and left out. Call before Start.
+func (d *Discovery) SetSwitches(net, sub uint8) {
+	d.identMu.Lock()
+	defer d.identMu.Unlock()
+	d.fixedSwitch, d.netSwitch, d.subSwitch = true, net, sub
+	d.configSwitch = switches{fixed: true, net: net, sub: sub}
+	d.logSwitched()
+}
+
+// switches are the NetSwitch and SubSwitch advertised when fixed
+type switches struct {
+	fixed    bool
+	net, sub uint8
+}
+
+// logSwitched logs the universes the fixed switches leave out; identMu must be held
+func (d *Discovery) logSwitched() {
+	for _, u := range slices.Concat(d.inputUnivs, d.outputUnivs) {
+		if u.Net() != d.netSwitch || u.SubNet() != d.subSwitch {
+			log.Printf("[artnet] not advertising universe=%s outside net_switch=%d sub_switch=%d", u, d.netSwitch, d.subSwitch)
+		}
+	}
+}
+
+// switched returns the universes in the net and sub-net
+func switched(universes []artnet.Universe, net, sub uint8) []artnet.Universe {
+	var result []artnet.Universe
+	for _, u := range universes {
+		if u.Net() == net && u.SubNet() == sub {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// SetReplyInterval limits ArtPollReplies to one set per interval for each
+// polling controller: a controller's first poll is answered at once, and
+// polls it sends within interval of an answered one are ignored. Zero answers
+// every poll. Call before Start.
+func (d *Discovery) SetReplyInterval(interval time.Duration) {
+	d.replyInterval = interval
+}
+
+func (d *Discovery) SetReplyMode(m ReplyMode) {
+	d.replyMode = m
+}
+
+// SetLocalIP pins the address advertised in ArtPollReply instead of choosing
+// the local address on the subnet each poll arrived from
+func (d *Discovery) SetLocalIP(ip net.IP, mac net.HardwareAddr) {
+	d.identMu.Lock()
+	defer d.identMu.Unlock()
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(d.localIP[:], ip4)
+	}
+	if len(mac) == 6 {
+		copy(d.localMAC[:], mac)
+	}
+	d.fixedIP = true
+}
+
+// UpdateInterface replaces the broadcast address and fallback identity after
+// a network change; a pinned SetLocalIP identity is kept
+func (d *Discovery) UpdateInterface(localIP, broadcast net.IP, localMAC net.HardwareAddr) {
+	d.identMu.Lock()
+	defer d.identMu.Unlock()
+	d.broadcast = broadcast
+	if d.fixedIP {
+		return
+	}
+	d.localIP = [4]byte{}
+	if ip4 := localIP.To4(); ip4 != nil {
+		copy(d.localIP[:], ip4)
+	}
+	d.localMAC = [6]byte{}
+	if len(localMAC) == 6 {
+		copy(d.localMAC[:], localMAC)
+	}
+}
+
+func (d *Discovery) broadcastIP() net.IP {
+	d.identMu.RLock()
+	defer d.identMu.RUnlock()
+	return d.broadcast
+}
+
+// replyIdentity returns the IP and MAC to advertise to a poller at src
+func (d *Discovery) replyIdentity(src *net.UDPAddr) ([4]byte, [6]byte) {
+	d.identMu.RLock()
+	ip, mac, fixed := d.localIP, d.localMAC, d.fixedIP
+	d.identMu.RUnlock()
+	if fixed {
+		return ip, mac
+	}
+	if localIP, localMAC, ok := InterfaceFor(src.IP); ok {
+		copy(ip[:], localIP)
+		if len(localMAC) == 6 {
+			copy(mac[:], localMAC)
+		}
+	}
+	return ip, mac
+}
+
+func (d *Discovery) SetOnChange(fn func(*artnet.Node)) {
+	d.onChange = fn
+}
+
+// SetOnDiscover registers fn to be called, from its own goroutine, with a node's
+// address and the output universes it announces for the first time; call before Start
+func (d *Discovery) SetOnDiscover(fn func(addr *net.UDPAddr, outputs []artnet.Universe)) {
+	d.onDiscover = fn
+}
+
+func (d *Discovery) pollLoop() {
+	d.sendPolls()
+
+	ticker := d.clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	cleanupTicker := d.clock.NewTicker(30 * time.Second)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C():
+			d.sendPolls()
+		case <-cleanupTicker.C():
+			d.cleanup()
+		}
+	}
+}
+
+func (d *Discovery) sendPolls() {
+	d.pollMu.Lock()
+	defer d.pollMu.Unlock()
+
+	// Another controller's broadcast polls do not reach routed nodes, so these always go out
+	for _, addr := range d.unicast {
+		if err := d.sender.SendPoll(addr); err != nil {
+			log.Printf("[artnet] poll error: dst=%s err=%v", addr, err)
+		}
+	}
+	if d.clock.Now().Sub(d.lastPollHeard) < 15*time.Second {
+		return
+	}
+	bcast := d.broadcastIP()
+	if bcast == nil {
+		return
+	}
+	addr := &net.UDPAddr{IP: bcast, Port: artnet.Port}
+	if len(d.pollRanges) == 0 {
+		d.sender.SendPoll(addr)
+		return
+	}
+	for _, r := range d.pollRanges {
+		d.sender.SendRaw(addr, BuildTargetedPoll(r))
+	}
+}
+
+func (d *Discovery) cleanup() {
+	d.nodesMu.Lock()
+	defer d.nodesMu.Unlock()
+
+	now := d.clock.Now()
+	cutoff := now.Add(-60 * time.Second)
+	for ip, node := range d.nodes {
+		if node.LastSeen.Before(cutoff) {
+			delete(d.nodes, ip)
+		}
+	}
+	for ip, s := range d.sources {
+		if s.stale(now) {
+			delete(d.sources, ip)
+		}
+	}
+
+	d.pollMu.Lock()
+	for ip, t := range d.answered {
+		if now.Sub(t) >= d.replyInterval {
+			delete(d.answered, ip)
+		}
+	}
+	d.pollMu.Unlock()
+}
+
+func (d *Discovery) HandlePollReply(src *net.UDPAddr, pkt *artnet.PollReplyPacket) {
+	d.identMu.RLock()
+	localIP := d.localIP
+	d.identMu.RUnlock()
+	if src.IP.Equal(net.IP(localIP[:])) {
+		return
+	}
+
+	d.nodesMu.Lock()
+	defer d.nodesMu.Unlock()
+
+	ip := src.IP.String()
+	now := d.clock.Now()
+
+	source := d.sources[ip]
+	if source == nil {
+		source = newReplySource(now)
+		d.sources[ip] = source
+	}
+	if !source.allow(now) {
+		d.replyStats.RateLimited++
+		return
+	}
+	polled := d.polled(src.IP)
+	if err := checkReply(src, pkt, polled); err != nil {
+		d.replyStats.Rejected++
+		if !source.warned {
+			source.warned = true
+			log.Printf("[artnet] pollreply rejected: src=%s err=%v", src.IP, err)
+		}
+		return
+	}
+	d.replyStats.Accepted++
+
+	// Universes a node announces are kept until it expires, so only additions change routing
+	node, exists := d.nodes[ip]
+	inputs, outputs := pkt.InputUniverses(), pkt.OutputUniverses()
+	if !polled && (!exists || addsUniverses(node.Inputs, inputs) || addsUniverses(node.Outputs, outputs)) {
+		ignore, damped := source.change(now)
+		if damped {
+			log.Printf("[artnet] pollreply damped: src=%s universes changed more than %d times in %s, ignoring changes for %s", src.IP, flapLimit, flapWindow, holdDown)
+		}
+		if ignore {
+			d.replyStats.Damped++
+			if !exists {
+				return
+			}
+			inputs, outputs = nil, nil
+		}
+	}
+
+	if !exists {
+		node = &artnet.Node{
+			IP:   src.IP,
+			Port: pkt.Port,
+		}
+		d.nodes[ip] = node
+	}
+
+	node.ShortName = pkt.GetShortName()
+	node.LongName = pkt.GetLongName()
+	node.MAC = pkt.MACAddr()
+	node.LastSeen = now
+
+	for _, u := range inputs {
+		if !containsUniverse(node.Inputs, u) {
+			node.Inputs = append(node.Inputs, u)
+		}
+	}
+	var added []artnet.Universe
+	for _, u := range outputs {
+		if !containsUniverse(node.Outputs, u) {
+			node.Outputs = append(node.Outputs, u)
+			added = append(added, u)
+		}
+	}
+	if len(added) > 0 && d.onDiscover != nil {
+		go d.onDiscover(&net.UDPAddr{IP: node.IP, Port: int(node.Port)}, added)
+	}
+
+	if d.onChange != nil {
+		d.onChange(node)
+	}
+}
+
+func (d *Discovery) HandlePoll(src *net.UDPAddr) {
+	d.pollMu.Lock()
+	now := d.clock.Now()
+	d.lastPollHeard = now
+	key := src.IP.String()
+	if d.replyInterval > 0 {
+		if last, ok := d.answered[key]; ok && now.Sub(last) < d.replyInterval {
+			d.pollMu.Unlock()
+			d.throttled.Add(1)
+			return
+		}
+		d.answered[key] = now
+	}
+	d.pollMu.Unlock()
+	d.reply(src)
+}
+
+// reply sends the ArtPollReplies to src, or broadcasts them in ReplyBroadcast mode
+func (d *Discovery) reply(src *net.UDPAddr) {
+	if d.receiver == nil {
+		return
+	}
+	dst := src
+	if d.replyMode == ReplyBroadcast {
+		dst = &net.UDPAddr{IP: d.broadcastIP(), Port: artnet.Port}
+	}
+	ip, mac := d.replyIdentity(src)
+	for _, pkt := range d.pollReplies(ip, mac) {
+		d.receiver.SendTo(pkt, dst)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pollReplies builds the ArtPollReplies answering one poll: input then output
+// ports grouped by net and sub-net, four to a packet. The packets differ only
+// in their ports, switches and BindIndex (1, 2, ...), so a console sees one
+// node; with fixed switches a node without ports still gets one reply.
+func (d *Discovery) pollReplies(ip [4]byte, mac [6]byte) [][]byte {
+	d.identMu.RLock()
+	shortName, longName, indicator := d.shortName, d.longName, d.indicator
+	fixed, netSwitch, subSwitch := d.fixedSwitch, d.netSwitch, d.subSwitch
+	d.identMu.RUnlock()
+	inputs, outputs := d.inputUnivs, d.outputUnivs
+	if fixed {
+		inputs, outputs = switched(inputs, netSwitch, subSwitch), switched(outputs, netSwitch, subSwitch)
+	}
+
+	var pkts [][]byte
+	add := func(universes []artnet.Universe, isInput bool) {
+		groups := map[uint16][]artnet.Universe{}
+		for _, u := range universes {
+			key := uint16(u.Net())<<8 | uint16(u.SubNet())<<4
+			groups[key] = append(groups[key], u)
+		}
+		keys := make([]uint16, 0, len(groups))
+		for k := range groups {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		for _, key := range keys {
+			univs := groups[key]
+			sort.Slice(univs, func(i, j int) bool { return univs[i] < univs[j] })
+			for i := 0; i < len(univs); i += 4 {
+				chunk := univs[i:min(i+4, len(univs))]
+				pkts = append(pkts, artnet.BuildPollReplyPacket(ip, mac, shortName, longName, chunk, isInput))
+			}
+		}
+	}
+	add(inputs, true)
+	add(outputs, false)
+	if len(pkts) == 0 && fixed {
+		pkts = append(pkts, artnet.BuildPollReplyPacket(ip, mac, shortName, longName, nil, false))
+	}
+
+	var report string
+	if d.report != nil {
+		report = fmt.Sprintf("#0001 [%04d] %s", d.reports.Add(1)%10000, d.report())
+	}
+	for i, pkt := range pkts {
+		if fixed {
+			pkt[18], pkt[19] = netSwitch, subSwitch
+		}
+		if indicator != IndicatorUnknown {
+			pkt[23] = pkt[23]&^0xC0 | byte(indicator)<<6 // Status1 indicator state
+		}
+		pkt[211] = byte(i + 1) // BindIndex
+		if d.report != nil {
+			setNodeReport(pkt, report)
+		}
+	}
+	return pkts
+}
+
+// setNodeReport writes report into the NodeReport field of an ArtPollReply
+func setNodeReport(pkt []byte, report string) {
+	field := pkt[108:172]
+	clear(field)
+	copy(field[:len(field)-1], report)
+}
+
+func (d *Discovery) GetNodesForUniverse(universe artnet.Universe) []*artnet.Node {
+	d.nodesMu.RLock()
+	defer d.nodesMu.RUnlock()
+
+	var result []*artnet.Node
+	for _, node := range d.nodes {
+		for _, u := range node.Outputs {
+			if u == universe {
+				result = append(result, node)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (d *Discovery) GetAllNodes() []*artnet.Node {
+	d.nodesMu.RLock()
+	defer d.nodesMu.RUnlock()
+
+	result := make([]*artnet.Node, 0, len(d.nodes))
+	for _, node := range d.nodes {
+		result = append(result, node)
+	}
+	return result
+}
+
+// ReplyStats returns counts of the ArtPollReplies received so far
+func (d *Discovery) ReplyStats() ReplyStats {
+	d.nodesMu.RLock()
+	defer d.nodesMu.RUnlock()
+	stats := d.replyStats
+	stats.PollsThrottled = d.throttled.Load()
+	return stats
+}
+
+// addsUniverses reports whether announced has a universe not in known
+func addsUniverses(known, announced []artnet.Universe) bool {
+	for _, u := range announced {
+		if !containsUniverse(known, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUniverse(slice []artnet.Universe, val artnet.Universe) bool {
+	for _, v := range slice {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
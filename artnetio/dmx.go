@@ -0,0 +1,51 @@
+package artnetio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DMXFault is a way an ArtDmx packet breaks the specification
+type DMXFault string
+
+const (
+	ZeroLength DMXFault = "zero_length" // no channels
+	OddLength  DMXFault = "odd_length"  // the length must be even
+	TooLong    DMXFault = "too_long"    // more than 512 channels
+	Truncated  DMXFault = "truncated"   // fewer data bytes than the length
+)
+
+// DMXMode decides what happens to malformed ArtDmx packets
+type DMXMode string
+
+const (
+	// Lenient drops zero-length frames, keeps the channels a truncated frame
+	// does carry and accepts odd and over-long lengths
+	Lenient DMXMode = "lenient"
+	// Strict drops every malformed frame
+	Strict DMXMode = "strict"
+)
+
+func ParseDMXMode(s string) (DMXMode, error) {
+	switch m := DMXMode(s); m {
+	case Lenient, Strict:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid dmx frame mode %q (want lenient or strict)", s)
+}
+
+// CheckDMX returns the fault of a raw ArtDmx packet of at least 18 bytes, or "" if it is well formed
+func CheckDMX(data []byte) DMXFault {
+	length := int(binary.BigEndian.Uint16(data[16:18]))
+	switch {
+	case length == 0:
+		return ZeroLength
+	case len(data)-18 < min(length, 512):
+		return Truncated
+	case length > 512:
+		return TooLong
+	case length%2 != 0:
+		return OddLength
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+package artnetio
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/input"
+	"github.com/gopatchy/artnet"
+)
+
+func FuzzPollReply(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 5, 0x36, 0x19, 0, 0, 4, 0x80, 0x80, 0x40, 0x40, 1, 2, 3, 4}, uint8(1), uint16(100))
+	f.Add([]byte{10, 0, 0, 9, 0x36, 0x19, 0, 0, 4, 0x80}, uint8(40), uint16(1000))
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0x80, 0x10, 9}, uint8(200), uint16(10))
+
+	f.Fuzz(func(t *testing.T, spec []byte, replies uint8, stepMS uint16) {
+		var b [17]byte
+		copy(b[:], spec)
+		pkt := &artnet.PollReplyPacket{
+			IPAddress:  [4]byte{b[0], b[1], b[2], b[3]},
+			Port:       uint16(b[4]) | uint16(b[5])<<8,
+			NetSwitch:  b[6],
+			SubSwitch:  b[7],
+			NumPortsLo: b[8],
+			PortTypes:  [4]byte{b[9], b[10], b[11], b[12]},
+			SwOut:      [4]byte{b[13], b[14], b[15], b[16]},
+		}
+		src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: artnet.Port}
+		valid := checkReply(src, pkt, false) == nil
+
+		fake := clock.NewFake(time.Unix(1000, 0))
+		d := NewDiscovery(nil, net.IPv4(10, 0, 0, 1), nil, nil, "artmap", "artmap", nil, nil)
+		d.SetClock(fake)
+
+		var dampedAt time.Time
+		var frozen int
+		for i := 0; i < int(replies); i++ {
+			// Each reply also claims one more universe, so after settling the node flaps
+			pkt.SwOut[0] = uint8(i % 16)
+			pkt.SubSwitch = (b[7] + uint8(i/16)) & 0x0F
+			if b[7] > 0x0F {
+				pkt.SubSwitch = b[7]
+			}
+			d.HandlePollReply(src, pkt)
+			fake.Advance(time.Duration(stepMS) * time.Millisecond)
+
+			node := d.nodes[src.IP.String()]
+			if node == nil {
+				continue
+			}
+			for _, u := range node.Outputs {
+				if u.Net() != pkt.NetSwitch&0x7F {
+					t.Fatalf("output %d outside announced net %d", u, pkt.NetSwitch)
+				}
+			}
+			if s := d.sources[src.IP.String()]; s != nil && fake.Now().Before(s.dampedUntil) {
+				if dampedAt.IsZero() {
+					dampedAt, frozen = s.dampedUntil, len(node.Outputs)
+				} else if dampedAt == s.dampedUntil && len(node.Outputs) != frozen {
+					t.Fatalf("outputs changed from %d to %d while damped", frozen, len(node.Outputs))
+				}
+			}
+		}
+
+		st := d.ReplyStats()
+		if st.Accepted+st.RateLimited+st.Rejected != uint64(replies) {
+			t.Fatalf("stats %+v do not add up to %d replies", st, replies)
+		}
+		if !valid && pkt.NumPortsLo > 4 && st.Accepted > 0 {
+			t.Fatalf("accepted replies with num_ports=%d", pkt.NumPortsLo)
+		}
+		if st.RateLimited > 0 && stepMS >= 1000/replyRate {
+			t.Fatalf("rate limited replies %d apart", time.Duration(stepMS)*time.Millisecond)
+		}
+	})
+}
+
+func FuzzNzs(f *testing.F) {
+	f.Add(uint16(1), uint8(0x17), []byte("hello"))
+	f.Add(uint16(0x7fff), uint8(0xcc), make([]byte, 600))
+
+	f.Fuzz(func(t *testing.T, universe uint16, startCode uint8, data []byte) {
+		pkt := BuildNzs(artnet.Universe(universe), 0, startCode, data)
+		got, err := ParseNzs(pkt)
+		if startCode == 0 || len(data) == 0 {
+			if err == nil {
+				t.Fatalf("expected an error for start code %d and %d bytes", startCode, len(data))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if got.Universe != artnet.Universe(universe) || got.StartCode != startCode {
+			t.Fatalf("got universe %d start code %d", got.Universe, got.StartCode)
+		}
+		if !bytes.Equal(got.Data, data[:min(len(data), 512)]) {
+			t.Fatalf("data differs")
+		}
+		if _, err := ParseNzs(pkt[:len(pkt)-1]); err == nil {
+			t.Fatalf("expected an error for a truncated packet")
+		}
+	})
+}
+
+func FuzzAddress(f *testing.F) {
+	f.Add("artmap", "artmap proxy", uint8(0x81), uint8(0x7f), uint8(AddressLedLocate))
+	f.Add("", "", uint8(0), uint8(0), uint8(AddressNone))
+
+	f.Fuzz(func(t *testing.T, shortName, longName string, netSwitch, subSwitch, command uint8) {
+		name := func(s string, n int) string {
+			s = strings.ReplaceAll(s, "\x00", "")
+			return s[:min(len(s), n)]
+		}
+		shortName, longName = name(shortName, 17), name(longName, 63)
+		in := &AddressPacket{NetSwitch: netSwitch, ShortName: shortName, LongName: longName, SubSwitch: subSwitch, Command: command}
+		pkt := BuildAddress(in)
+		got, err := ParseAddress(pkt)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if *got != *in {
+			t.Fatalf("got %+v, want %+v", got, in)
+		}
+		if _, err := ParseAddress(pkt[:len(pkt)-1]); err == nil {
+			t.Fatalf("expected an error for a truncated packet")
+		}
+	})
+}
+
+func FuzzIPProg(f *testing.F) {
+	f.Add([]byte{0x80 | 0x02, 0, 10, 0, 0, 9, 255, 255, 255, 0, 0x19, 0x36, 10, 0, 0, 1})
+	f.Add([]byte{0})
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		data := append(append([]byte{}, artnet.ID[:]...), 0x00, 0xf8, 0, 14, 0, 0)
+		data = append(data, body...)
+		pkt, err := ParseIPProg(data)
+		if len(data) < 24 {
+			if err == nil {
+				t.Fatalf("expected an error for %d bytes", len(data))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if (pkt.Gateway != nil) != (len(data) >= 30) {
+			t.Fatalf("gateway %v from %d bytes", pkt.Gateway, len(data))
+		}
+		reply := BuildIPProgReply(pkt.IP, pkt.Mask, pkt.Gateway)
+		if !bytes.Equal(reply[16:20], pkt.IP) || !bytes.Equal(reply[20:24], pkt.Mask) {
+			t.Fatalf("reply %x does not carry ip %s mask %s", reply, pkt.IP, pkt.Mask)
+		}
+	})
+}
+
+func FuzzTrigger(f *testing.F) {
+	f.Add(uint16(TriggerOEMAll), uint8(1), uint8(12), []byte{})
+	f.Add(uint16(0x00ff), uint8(3), uint8(0), []byte("show"))
+
+	f.Fuzz(func(t *testing.T, oem uint16, key, subKey uint8, data []byte) {
+		data = data[:min(len(data), 512)]
+		pkt := BuildTrigger(&TriggerPacket{OEM: oem, Key: key, SubKey: subKey, Data: data})
+		got, err := ParseTrigger(pkt)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if got.OEM != oem || got.Key != key || got.SubKey != subKey || len(got.Data) != 512 {
+			t.Fatalf("got %+v", got)
+		}
+		if !bytes.Equal(got.Data[:len(data)], data) {
+			t.Fatalf("data differs")
+		}
+		if _, err := ParseTrigger(pkt[:17]); err == nil {
+			t.Fatalf("expected an error for a truncated packet")
+		}
+	})
+}
+
+func FuzzPollRanges(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 16})
+	f.Add([]byte{0, 1, 0, 3, 0, 5, 0, 7, 0, 9, 0, 11, 0, 13, 0, 15, 0, 17, 0, 19})
+	f.Add([]byte{0x7f, 0xff, 0, 0, 0x7f, 0xff})
+
+	f.Fuzz(func(t *testing.T, spec []byte) {
+		var universes []artnet.Universe
+		for i := 0; i+1 < len(spec); i += 2 {
+			universes = append(universes, artnet.Universe(uint16(spec[i]&0x7F)<<8|uint16(spec[i+1])))
+		}
+		ranges := PollRanges(universes)
+		if len(ranges) > maxTargetedPolls {
+			t.Fatalf("got %d ranges, want at most %d", len(ranges), maxTargetedPolls)
+		}
+		for i, r := range ranges {
+			if r.Bottom > r.Top || i > 0 && r.Bottom <= ranges[i-1].Top+1 {
+				t.Fatalf("range %d %d-%d is empty, unsorted or touches the one before", i, r.Bottom, r.Top)
+			}
+		}
+		for _, u := range universes {
+			covered := false
+			for _, r := range ranges {
+				covered = covered || r.Bottom <= u && u <= r.Top
+			}
+			if !covered {
+				t.Fatalf("universe %d not covered by %v", u, ranges)
+			}
+		}
+
+		for _, r := range ranges {
+			op, pkt, err := artnet.ParsePacket(BuildTargetedPoll(r))
+			if err != nil || op != artnet.OpPoll {
+				t.Fatalf("targeted poll did not parse as ArtPoll: op=0x%04x err=%v", op, err)
+			}
+			if _, ok := pkt.(*artnet.PollPacket); !ok {
+				t.Fatalf("targeted poll parsed as %T", pkt)
+			}
+		}
+	})
+}
+
+func TestRebind(t *testing.T) {
+	// Rebinding listens on the configured address again, so take a fixed port
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.LocalAddr().(*net.UDPAddr)
+	probe.Close()
+
+	r, err := NewReceiver(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebound := make(chan error, 1)
+	r.SetOnRebind(func(err error) { rebound <- err })
+	frames := make(chan input.Frame, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, func(f input.Frame) {
+		select {
+		case frames <- f:
+		default:
+		}
+	})
+
+	old := r.Conn()
+	r.Rebind()
+	select {
+	case <-rebound:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no rebind after Rebind")
+	}
+	if r.Conn() == old {
+		t.Fatal("socket not replaced")
+	}
+	if got := r.LocalAddr().Port; got != addr.Port {
+		t.Fatalf("rebound to port %d, want %d", got, addr.Port)
+	}
+
+	send, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer send.Close()
+	data := make([]byte, 512)
+	data[0] = 255
+	if _, err := send.Write(artnet.BuildDMXPacket(artnet.Universe(1), 1, data)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-frames:
+		if f.Data[0] != 255 {
+			t.Fatalf("channel 1 = %d, want 255", f.Data[0])
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no frame after rebind")
+	}
+
+	cancel()
+	select {
+	case <-rebound:
+		t.Fatal("rebind after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,120 @@
+package artnetio
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gopatchy/artnet"
+)
+
+const (
+	// replyBurst is how many ArtPollReplies a source may send at once, enough for a
+	// large node answering one poll with a reply per four ports
+	replyBurst = 64
+	// replyRate is how many replies per second a source regains after a burst
+	replyRate = 8
+
+	// settleWindow is how long after a source is first heard its universe list may
+	// change freely, while its replies to the first polls arrive
+	settleWindow = 15 * time.Second
+	// a source whose universes change more than flapLimit times within flapWindow
+	// is damped: new universes from it are ignored for holdDown
+	flapLimit  = 3
+	flapWindow = 60 * time.Second
+	holdDown   = 60 * time.Second
+)
+
+// ReplyStats counts incoming ArtPollReply packets by what discovery did with them
+type ReplyStats struct {
+	Accepted    uint64 `json:"accepted"`
+	RateLimited uint64 `json:"rate_limited"`
+	Rejected    uint64 `json:"rejected"`
+	Damped      uint64 `json:"damped"` // accepted, but the universes they would have added were ignored
+
+	PollsThrottled uint64 `json:"polls_throttled"` // polls left unanswered by the reply interval
+}
+
+// checkReply rejects replies no real node sends: impossible port counts or
+// universes, a zero port, or a node IP other than the one the packet came from
+// unless the source is routed, where NAT may hide the announced address
+func checkReply(src *net.UDPAddr, pkt *artnet.PollReplyPacket, routed bool) error {
+	if pkt.NumPortsHi != 0 || pkt.NumPortsLo > 4 {
+		return fmt.Errorf("num_ports=%d", uint16(pkt.NumPortsHi)<<8|uint16(pkt.NumPortsLo))
+	}
+	if pkt.NetSwitch > 0x7F || pkt.SubSwitch > 0x0F {
+		return fmt.Errorf("net=%d subnet=%d out of range", pkt.NetSwitch, pkt.SubSwitch)
+	}
+	for i := 0; i < int(pkt.NumPortsLo); i++ {
+		if pkt.PortTypes[i]&artnet.PortTypeInput != 0 && pkt.SwIn[i] > 0x0F {
+			return fmt.Errorf("port %d input universe %d out of range", i+1, pkt.SwIn[i])
+		}
+		if pkt.PortTypes[i]&artnet.PortTypeOutput != 0 && pkt.SwOut[i] > 0x0F {
+			return fmt.Errorf("port %d output universe %d out of range", i+1, pkt.SwOut[i])
+		}
+	}
+	if pkt.Port == 0 {
+		return fmt.Errorf("port=0")
+	}
+	if ip := pkt.IP(); !routed && !ip.Equal(net.IPv4zero) && !ip.Equal(src.IP) {
+		return fmt.Errorf("claims ip=%s", ip)
+	}
+	return nil
+}
+
+// replySource is the guard state of one source address, kept across node expiry
+// so a node that keeps vanishing and reappearing still counts as flapping
+type replySource struct {
+	tokens      float64
+	refilled    time.Time
+	firstSeen   time.Time
+	lastSeen    time.Time
+	changes     []time.Time
+	dampedUntil time.Time
+	warned      bool // a rejected reply has been logged
+}
+
+func newReplySource(now time.Time) *replySource {
+	return &replySource{tokens: replyBurst, refilled: now, firstSeen: now}
+}
+
+// allow takes a token for one reply at now, reporting false when the source is over its rate
+func (s *replySource) allow(now time.Time) bool {
+	s.tokens = min(replyBurst, s.tokens+now.Sub(s.refilled).Seconds()*replyRate)
+	s.refilled = now
+	s.lastSeen = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// change records that a reply at now would change the source's universes. It
+// reports whether the change must be ignored, and whether damping just began.
+func (s *replySource) change(now time.Time) (ignore, damped bool) {
+	if now.Before(s.dampedUntil) {
+		return true, false
+	}
+	if now.Sub(s.firstSeen) < settleWindow {
+		return false, false
+	}
+	recent := s.changes[:0]
+	for _, t := range s.changes {
+		if now.Sub(t) < flapWindow {
+			recent = append(recent, t)
+		}
+	}
+	s.changes = append(recent, now)
+	if len(s.changes) > flapLimit {
+		s.changes = nil
+		s.dampedUntil = now.Add(holdDown)
+		return true, true
+	}
+	return false, false
+}
+
+// stale reports whether the source can be forgotten at now
+func (s *replySource) stale(now time.Time) bool {
+	return now.Sub(s.lastSeen) > flapWindow && !now.Before(s.dampedUntil)
+}
@@ -0,0 +1,109 @@
+package artnetio
+
+import (
+	"fmt"
+	"net"
+)
+
+// InterfaceFor returns the local IPv4 address and interface MAC on the subnet containing ip
+func InterfaceFor(ip net.IP) (net.IP, net.HardwareAddr, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, nil, false
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, false
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			if ipnet.Contains(ip4) {
+				return ipnet.IP.To4(), iface.HardwareAddr, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// ResolveInterface finds an interface by name, by one of its IPv4 addresses
+// ("10.0.0.5") or by a subnet one of its addresses falls in ("10.0.0.0/24").
+// Selectors resolve to net interfaces rather than pcap devices: artmap has no
+// pcap receiver, so Windows users type the adapter's friendly name instead of
+// an npcap GUID.
+func ResolveInterface(selector string) (*net.Interface, error) {
+	if iface, err := net.InterfaceByName(selector); err == nil {
+		return iface, nil
+	}
+
+	var match func(*net.IPNet) bool
+	if ip := net.ParseIP(selector); ip != nil {
+		match = func(n *net.IPNet) bool { return n.IP.Equal(ip) }
+	} else if _, subnet, err := net.ParseCIDR(selector); err == nil {
+		match = func(n *net.IPNet) bool { return subnet.Contains(n.IP) }
+	} else {
+		return nil, fmt.Errorf("no interface matches %q", selector)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && match(ipnet) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface matches %q", selector)
+}
+
+// InterfaceForTargets returns the up interface whose subnets contain the most
+// of ips, or nil if none contains any
+func InterfaceForTargets(ips []net.IP) *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var best *net.Interface
+	bestCount := 0
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		count := 0
+		for _, ip := range ips {
+			for _, addr := range addrs {
+				if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil && ipnet.Contains(ip) {
+					count++
+					break
+				}
+			}
+		}
+		if count > bestCount {
+			best, bestCount = &ifaces[i], count
+		}
+	}
+	return best
+}
@@ -0,0 +1,129 @@
+package artnetio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artnet"
+)
+
+const inputResendInterval = 10 * time.Second
+
+// BuildInputPacket builds an ArtInput packet; disabled[i] turns off the node's input port i+1
+func BuildInputPacket(bindIndex uint8, disabled [4]bool) []byte {
+	buf := make([]byte, 20)
+	copy(buf, artnet.ID[:])
+	binary.LittleEndian.PutUint16(buf[8:], artnet.OpInput)
+	binary.BigEndian.PutUint16(buf[10:], artnet.ProtocolVersion)
+	buf[13] = bindIndex
+	binary.BigEndian.PutUint16(buf[14:], 4)
+	for i, d := range disabled {
+		if d {
+			buf[16+i] = 0x01
+		}
+	}
+	return buf
+}
+
+func (s *Sender) SendInput(addr *net.UDPAddr, bindIndex uint8, disabled [4]bool) error {
+	return s.SendRaw(addr, BuildInputPacket(bindIndex, disabled))
+}
+
+// NodeInput is the desired input state of one node (or bound sub-device)
+type NodeInput struct {
+	Address   string  `json:"address"`
+	BindIndex uint8   `json:"bind_index"`
+	Disabled  [4]bool `json:"disabled"`
+}
+
+// InputControl keeps node inputs in their desired state, resending
+// periodically because nodes re-enable their inputs after a power cycle
+type InputControl struct {
+	sender *Sender
+
+	mu     sync.Mutex
+	inputs map[string]NodeInput
+
+	done chan struct{}
+}
+
+func NewInputControl(sender *Sender) *InputControl {
+	return &InputControl{
+		sender: sender,
+		inputs: map[string]NodeInput{},
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *InputControl) Start() {
+	go c.loop()
+}
+
+func (c *InputControl) Stop() {
+	close(c.done)
+}
+
+// Set records the desired state for a node and sends it immediately
+func (c *InputControl) Set(in NodeInput) error {
+	addr, err := inputAddr(in.Address)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.inputs[fmt.Sprintf("%s/%d", addr.IP, in.BindIndex)] = in
+	c.mu.Unlock()
+	return c.sender.SendInput(addr, in.BindIndex, in.Disabled)
+}
+
+// List returns the desired node input states sorted by address
+func (c *InputControl) List() []NodeInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]NodeInput, 0, len(c.inputs))
+	for _, in := range c.inputs {
+		result = append(result, in)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Address != result[j].Address {
+			return result[i].Address < result[j].Address
+		}
+		return result[i].BindIndex < result[j].BindIndex
+	})
+	return result
+}
+
+func (c *InputControl) loop() {
+	ticker := time.NewTicker(inputResendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		for _, in := range c.List() {
+			addr, err := inputAddr(in.Address)
+			if err != nil {
+				continue
+			}
+			if err := c.sender.SendInput(addr, in.BindIndex, in.Disabled); err != nil {
+				log.Printf("[artnet] input send error: dst=%s err=%v", addr, err)
+			}
+		}
+	}
+}
+
+func inputAddr(s string) (*net.UDPAddr, error) {
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid node address %q", s)
+	}
+	return &net.UDPAddr{IP: ip, Port: artnet.Port}, nil
+}
@@ -0,0 +1,130 @@
+package artnetio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gopatchy/artnet"
+)
+
+// Opcodes of ArtIpProg, a configuration tool reading or setting a node's IP
+// settings, and of the node's ArtIpProgReply
+const (
+	OpIPProg      uint16 = 0xF800
+	OpIPProgReply uint16 = 0xF900
+)
+
+// ArtIpProg command bits; the others only apply with IPProgEnable set
+const (
+	IPProgEnable  uint8 = 0x80
+	IPProgDHCP    uint8 = 0x40
+	IPProgGateway uint8 = 0x08
+	IPProgReset   uint8 = 0x04
+	IPProgIP      uint8 = 0x02
+	IPProgMask    uint8 = 0x01
+)
+
+// IPProgPacket is an ArtIpProg packet; Gateway is nil from pre Art-Net 4 tools
+type IPProgPacket struct {
+	Command uint8
+	IP      net.IP
+	Mask    net.IPMask
+	Gateway net.IP
+}
+
+// Programs reports whether the packet asks to change the settings rather than read them
+func (p *IPProgPacket) Programs() bool {
+	return p.Command&IPProgEnable != 0 && p.Command&^IPProgEnable != 0
+}
+
+// ParseIPProg parses a raw ArtIpProg packet
+func ParseIPProg(data []byte) (*IPProgPacket, error) {
+	if len(data) < 24 || !bytes.Equal(data[:8], artnet.ID[:]) {
+		return nil, fmt.Errorf("not an Art-Net packet")
+	}
+	if op := binary.LittleEndian.Uint16(data[8:10]); op != OpIPProg {
+		return nil, fmt.Errorf("opcode 0x%04x is not ArtIpProg", op)
+	}
+	pkt := &IPProgPacket{
+		Command: data[14],
+		IP:      net.IP(bytes.Clone(data[16:20])),
+		Mask:    net.IPMask(bytes.Clone(data[20:24])),
+	}
+	if len(data) >= 30 {
+		pkt.Gateway = net.IP(bytes.Clone(data[26:30]))
+	}
+	return pkt, nil
+}
+
+// BuildIPProgReply builds a raw ArtIpProgReply reporting static settings
+func BuildIPProgReply(ip net.IP, mask net.IPMask, gateway net.IP) []byte {
+	pkt := make([]byte, 34)
+	copy(pkt, artnet.ID[:])
+	binary.LittleEndian.PutUint16(pkt[8:10], OpIPProgReply)
+	binary.BigEndian.PutUint16(pkt[10:12], protocolVersion)
+	copy(pkt[16:20], ip.To4())
+	copy(pkt[20:24], mask)
+	binary.BigEndian.PutUint16(pkt[24:26], uint16(artnet.Port))
+	copy(pkt[28:32], gateway.To4())
+	return pkt
+}
+
+// HandleIPProg answers an ArtIpProg with the address, mask and gateway of the
+// interface facing src. The host owns its network settings, so requests to
+// change them are refused and answered with the settings unchanged.
+func (d *Discovery) HandleIPProg(src *net.UDPAddr, pkt *IPProgPacket) {
+	if pkt.Programs() {
+		log.Printf("[artnet] ipprog src=%s command=0x%02x refused: the host's network settings are not programmable", src.IP, pkt.Command)
+	}
+	if d.receiver == nil {
+		return
+	}
+	ip, _ := d.replyIdentity(src)
+	local := net.IP(ip[:])
+	d.receiver.SendTo(BuildIPProgReply(local, maskFor(local), defaultGateway()), src)
+}
+
+// maskFor returns the netmask of the interface address ip, or nil
+func maskFor(ip net.IP) net.IPMask {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return ipnet.Mask
+		}
+	}
+	return nil
+}
+
+// defaultGateway returns the IPv4 default route's gateway from the Linux
+// routing table, or nil elsewhere or without one
+func defaultGateway() net.IP {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Iface Destination Gateway ..., addresses in little-endian hex
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != 4 {
+			continue
+		}
+		return net.IPv4(gw[3], gw[2], gw[1], gw[0])
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package artnetio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gopatchy/artnet"
+)
+
+// OpNzs is the opcode of ArtNzs, DMX512 data with a non-zero start code
+const OpNzs uint16 = 0x5100
+
+// NzsPacket is an ArtNzs packet: alternate start code data such as text or
+// vendor-specific packets, laid out like ArtDmx with the start code in place
+// of the physical port
+type NzsPacket struct {
+	Sequence  uint8
+	StartCode uint8
+	Universe  artnet.Universe
+	Data      []byte
+}
+
+// ParseNzs parses a raw ArtNzs packet
+func ParseNzs(data []byte) (*NzsPacket, error) {
+	if len(data) < 18 || !bytes.Equal(data[:8], artnet.ID[:]) {
+		return nil, fmt.Errorf("not an Art-Net packet")
+	}
+	if op := binary.LittleEndian.Uint16(data[8:10]); op != OpNzs {
+		return nil, fmt.Errorf("opcode 0x%04x is not ArtNzs", op)
+	}
+	pkt := &NzsPacket{
+		Sequence:  data[12],
+		StartCode: data[13],
+		Universe:  artnet.Universe(binary.LittleEndian.Uint16(data[14:16])),
+	}
+	if pkt.StartCode == 0 {
+		return nil, fmt.Errorf("start code is zero")
+	}
+	length := int(binary.BigEndian.Uint16(data[16:18]))
+	if length < 1 || length > 512 {
+		return nil, fmt.Errorf("invalid length %d", length)
+	}
+	if len(data)-18 < length {
+		return nil, fmt.Errorf("truncated: %d of %d data bytes", len(data)-18, length)
+	}
+	pkt.Data = bytes.Clone(data[18 : 18+length])
+	return pkt, nil
+}
+
+// BuildNzs builds a raw ArtNzs packet
+func BuildNzs(universe artnet.Universe, sequence, startCode uint8, data []byte) []byte {
+	data = data[:min(len(data), 512)]
+	pkt := make([]byte, 18+len(data))
+	copy(pkt, artnet.ID[:])
+	binary.LittleEndian.PutUint16(pkt[8:10], OpNzs)
+	binary.BigEndian.PutUint16(pkt[10:12], protocolVersion)
+	pkt[12] = sequence
+	pkt[13] = startCode
+	binary.LittleEndian.PutUint16(pkt[14:16], uint16(universe))
+	binary.BigEndian.PutUint16(pkt[16:18], uint16(len(data)))
+	copy(pkt[18:], data)
+	return pkt
+}
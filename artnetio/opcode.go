@@ -0,0 +1,66 @@
+package artnetio
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/gopatchy/artnet"
+)
+
+// protocolVersion is the lowest Art-Net protocol version a packet may carry
+const protocolVersion = 14
+
+// standardOpCodes are the opcodes defined by the Art-Net 4 specification
+var standardOpCodes = map[uint16]bool{
+	0x2000: true, // ArtPoll
+	0x2100: true, // ArtPollReply
+	0x2300: true, // ArtDiagData
+	0x2400: true, // ArtCommand
+	0x2700: true, // ArtDataRequest
+	0x2800: true, // ArtDataReply
+	0x5000: true, // ArtDmx
+	0x5100: true, // ArtNzs
+	0x5200: true, // ArtSync
+	0x6000: true, // ArtAddress
+	0x7000: true, // ArtInput
+	0x8000: true, // ArtTodRequest
+	0x8100: true, // ArtTodData
+	0x8200: true, // ArtTodControl
+	0x8300: true, // ArtRdm
+	0x8400: true, // ArtRdmSub
+	0x9000: true, // ArtMedia
+	0x9100: true, // ArtMediaPatch
+	0x9200: true, // ArtMediaControl
+	0x9300: true, // ArtMediaContrlReply
+	0x9700: true, // ArtTimeCode
+	0x9800: true, // ArtTimeSync
+	0x9900: true, // ArtTrigger
+	0x9A00: true, // ArtDirectory
+	0x9B00: true, // ArtDirectoryReply
+	0xA010: true, // ArtVideoSetup
+	0xA020: true, // ArtVideoPalette
+	0xA040: true, // ArtVideoData
+	0xF000: true, // ArtMacMaster
+	0xF100: true, // ArtMacSlave
+	0xF200: true, // ArtFirmwareMaster
+	0xF300: true, // ArtFirmwareReply
+	0xF400: true, // ArtFileTnMaster
+	0xF500: true, // ArtFileFnMaster
+	0xF600: true, // ArtFileFnReply
+	0xF800: true, // ArtIpProg
+	0xF900: true, // ArtIpProgReply
+}
+
+// UnknownOpCode returns the opcode of a well-formed Art-Net packet (header and
+// protocol version 14 or later) whose opcode the specification does not
+// define, such as a vendor extension
+func UnknownOpCode(data []byte) (uint16, bool) {
+	if len(data) < 12 || !bytes.Equal(data[:8], artnet.ID[:]) {
+		return 0, false
+	}
+	op := binary.LittleEndian.Uint16(data[8:10])
+	if standardOpCodes[op] || binary.BigEndian.Uint16(data[10:12]) < protocolVersion {
+		return 0, false
+	}
+	return op, true
+}
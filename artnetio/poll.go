@@ -0,0 +1,64 @@
+package artnetio
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"github.com/gopatchy/artnet"
+)
+
+// PollTargeted is the ArtPoll flag asking only nodes with a Port-Address
+// between the poll's bottom and top targets to reply (Art-Net 4)
+const PollTargeted uint8 = 0x20
+
+// pollLen is the length of an Art-Net 4 ArtPoll, with target Port-Addresses
+const pollLen = 22
+
+// maxTargetedPolls bounds the polls sent per interval; ranges beyond it are
+// merged across their smallest gaps
+const maxTargetedPolls = 8
+
+// PollRange is an inclusive span of Port-Addresses a targeted poll covers
+type PollRange struct {
+	Bottom artnet.Universe
+	Top    artnet.Universe
+}
+
+// BuildTargetedPoll builds an ArtPoll answered only by nodes with a
+// Port-Address in r; nodes older than Art-Net 4 ignore the targets and reply anyway
+func BuildTargetedPoll(r PollRange) []byte {
+	pkt := make([]byte, pollLen)
+	copy(pkt, artnet.BuildPollPacket())
+	pkt[12] |= PollTargeted
+	binary.BigEndian.PutUint16(pkt[14:16], uint16(r.Top))
+	binary.BigEndian.PutUint16(pkt[16:18], uint16(r.Bottom))
+	return pkt
+}
+
+// PollRanges covers universes with at most maxTargetedPolls ranges, joining
+// consecutive Port-Addresses and then the ranges closest to each other
+func PollRanges(universes []artnet.Universe) []PollRange {
+	sorted := slices.Clone(universes)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var ranges []PollRange
+	for _, u := range sorted {
+		if n := len(ranges); n > 0 && u == ranges[n-1].Top+1 {
+			ranges[n-1].Top = u
+			continue
+		}
+		ranges = append(ranges, PollRange{Bottom: u, Top: u})
+	}
+	for len(ranges) > maxTargetedPolls {
+		closest := 0
+		for i := 1; i < len(ranges)-1; i++ {
+			if ranges[i+1].Bottom-ranges[i].Top < ranges[closest+1].Bottom-ranges[closest].Top {
+				closest = i
+			}
+		}
+		ranges[closest].Top = ranges[closest+1].Top
+		ranges = slices.Delete(ranges, closest+1, closest+2)
+	}
+	return ranges
+}
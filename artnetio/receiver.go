@@ -0,0 +1,289 @@
+// Package artnetio owns artmap's Art-Net sockets and node behaviour:
+// receiving with taps, size checks and rebinding, paced sending, discovery and
+// the Art-Net 4 packets github.com/gopatchy/artnet does not cover. ArtDmx,
+// ArtPoll and ArtPollReply are still encoded and parsed by that package.
+package artnetio
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/datagram"
+	"github.com/gopatchy/artmap/input"
+	"github.com/gopatchy/artnet"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// rebindErrors consecutive read errors mean the socket is dead (interface
+	// bounce, address removed) rather than a transient failure
+	rebindErrors = 10
+	rebindDelay  = time.Second
+)
+
+type Receiver struct {
+	addr     *net.UDPAddr
+	conn     atomic.Pointer[net.UDPConn]
+	handler  artnet.Handler
+	tap      func(src, dst *net.UDPAddr, data []byte)
+	other    func(src *net.UDPAddr, opCode uint16, data []byte)
+	dmxMode  DMXMode
+	onFault  func(src *net.UDPAddr, pkt *artnet.DMXPacket, fault DMXFault)
+	emit     func(input.Frame)
+	ignore   func(src *net.UDPAddr) bool
+	onSize   func(src *net.UDPAddr, n int, fault datagram.Fault)
+	forced   atomic.Bool
+	onRebind func(err error)
+	done     chan struct{}
+}
+
+func NewReceiver(addr *net.UDPAddr, handler artnet.Handler) (*Receiver, error) {
+	conn, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		addr:    addr,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	r.conn.Store(conn)
+	return r, nil
+}
+
+func listen(addr *net.UDPAddr) (*net.UDPConn, error) {
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Report the destination address so frames can be told broadcast from
+	// unicast; without it they are of unknown cast
+	ipv4.NewPacketConn(conn).SetControlMessage(ipv4.FlagDst, true)
+	return conn, nil
+}
+
+// SetTap registers a function called with every raw packet received or sent
+// on the receiver socket; it must be set before Start
+func (r *Receiver) SetTap(fn func(src, dst *net.UDPAddr, data []byte)) {
+	r.tap = fn
+}
+
+// SetOtherHandler registers a function called with packets whose opcode the
+// artnet.Handler does not cover (e.g. ArtTodData, ArtRdm); data is only valid
+// during the call. It must be set before Start
+func (r *Receiver) SetOtherHandler(fn func(src *net.UDPAddr, opCode uint16, data []byte)) {
+	r.other = fn
+}
+
+// SetDMXMode sets how malformed ArtDmx packets are handled (lenient by
+// default) and registers a function called with each one before it is dropped
+// or repaired; it must be set before Start
+func (r *Receiver) SetDMXMode(mode DMXMode, onFault func(src *net.UDPAddr, pkt *artnet.DMXPacket, fault DMXFault)) {
+	r.dmxMode = mode
+	r.onFault = onFault
+}
+
+// SetOnRebind registers a function called after the socket is re-created
+// following persistent read errors; it must be set before Start
+func (r *Receiver) SetOnRebind(fn func(err error)) {
+	r.onRebind = fn
+}
+
+// SetOnSize registers a function called with each datagram dropped for being
+// larger or smaller than any Art-Net packet; it must be set before Start
+func (r *Receiver) SetOnSize(fn func(src *net.UDPAddr, n int, fault datagram.Fault)) {
+	r.onSize = fn
+}
+
+func (r *Receiver) Start() {
+	go r.loop()
+}
+
+// SetIgnore registers a function deciding which ArtDmx senders to drop, such
+// as our own broadcasts received back; it must be set before Start
+func (r *Receiver) SetIgnore(fn func(src *net.UDPAddr) bool) {
+	r.ignore = fn
+}
+
+// Run makes the receiver an input.Source: it starts it, emitting ArtDmx frames
+// instead of passing them to the handler, and stops it when ctx is done
+func (r *Receiver) Run(ctx context.Context, emit func(input.Frame)) error {
+	r.emit = emit
+	r.Start()
+	<-ctx.Done()
+	r.Stop()
+	return nil
+}
+
+func (r *Receiver) Stop() {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	r.conn.Load().Close()
+}
+
+func (r *Receiver) Conn() *net.UDPConn {
+	return r.conn.Load()
+}
+
+func (r *Receiver) LocalAddr() *net.UDPAddr {
+	return r.conn.Load().LocalAddr().(*net.UDPAddr)
+}
+
+func (r *Receiver) SendTo(data []byte, addr *net.UDPAddr) error {
+	if r.tap != nil {
+		r.tap(r.LocalAddr(), addr, data)
+	}
+	_, err := r.conn.Load().WriteToUDP(data, addr)
+	return err
+}
+
+// Rebind re-creates the socket, e.g. after the address it is bound to has changed
+func (r *Receiver) Rebind() {
+	r.forced.Store(true)
+	r.conn.Load().Close()
+}
+
+func (r *Receiver) loop() {
+	buf := datagram.Buffer(datagram.ArtNetMax)
+	errors := 0
+	var conn *net.UDPConn
+	var pc *ipv4.PacketConn
+
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		if c := r.conn.Load(); c != conn {
+			conn, pc = c, ipv4.NewPacketConn(c)
+		}
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, cm, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			errors++
+			if errors >= rebindErrors || r.forced.Swap(false) {
+				r.rebind(err)
+				errors = 0
+			}
+			continue
+		}
+		errors = 0
+		src := from.(*net.UDPAddr)
+		dst := r.LocalAddr()
+		if cm != nil {
+			dst = &net.UDPAddr{IP: cm.Dst, Port: dst.Port}
+		}
+
+		if r.tap != nil {
+			r.tap(src, dst, buf[:n])
+		}
+		if fault := datagram.Check(n, datagram.ArtNetMin, datagram.ArtNetMax); fault != "" {
+			if r.onSize != nil {
+				r.onSize(src, n, fault)
+			}
+			continue
+		}
+		r.handle(src, input.CastOf(dst.IP), buf[:n])
+	}
+}
+
+// rebind replaces the socket, retrying until the address can be bound again or the receiver stops
+func (r *Receiver) rebind(cause error) {
+	r.conn.Load().Close()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-time.After(rebindDelay):
+		}
+		conn, err := listen(r.addr)
+		if err != nil {
+			continue
+		}
+		r.conn.Store(conn)
+		// Stop may have closed the old socket while this one was being bound
+		select {
+		case <-r.done:
+			conn.Close()
+			return
+		default:
+		}
+		if r.onRebind != nil {
+			r.onRebind(cause)
+		}
+		return
+	}
+}
+
+// handle dispatches one packet; cast is how it was delivered, "" if unknown
+func (r *Receiver) handle(src *net.UDPAddr, cast config.Cast, data []byte) {
+	opCode, pkt, err := artnet.ParsePacket(data)
+	if err != nil {
+		return
+	}
+
+	switch opCode {
+	case artnet.OpDmx:
+		dmx, ok := pkt.(*artnet.DMXPacket)
+		switch {
+		case !ok || !r.checkDMX(src, dmx, data):
+		case r.ignore != nil && r.ignore(src):
+		case r.emit != nil:
+			r.emit(input.Frame{Universe: config.ArtNetUniverse(dmx.Universe), Src: src, Physical: int(dmx.Physical),
+				Cast: cast, Sequence: dmx.Sequence, Length: int(min(dmx.Length, 512)), Data: dmx.Data})
+		default:
+			r.handler.HandleDMX(src, dmx)
+		}
+	case artnet.OpPoll:
+		if poll, ok := pkt.(*artnet.PollPacket); ok {
+			r.handler.HandlePoll(src, poll)
+		}
+	case artnet.OpPollReply:
+		if reply, ok := pkt.(*artnet.PollReplyPacket); ok {
+			r.handler.HandlePollReply(src, reply)
+		}
+	default:
+		if r.other != nil {
+			r.other(src, opCode, data)
+		}
+	}
+}
+
+// checkDMX reports whether a DMX packet should be delivered, repairing a
+// truncated one to the channels it carries when lenient
+func (r *Receiver) checkDMX(src *net.UDPAddr, dmx *artnet.DMXPacket, data []byte) bool {
+	fault := CheckDMX(data)
+	if fault == "" {
+		return true
+	}
+	if r.onFault != nil {
+		r.onFault(src, dmx, fault)
+	}
+	switch {
+	case r.dmxMode == Strict || fault == ZeroLength:
+		return false
+	case fault == Truncated:
+		// The parser leaves the data of a truncated frame all zero
+		n := copy(dmx.Data[:], data[18:])
+		dmx.Length = uint16(n)
+		return n > 0
+	}
+	return true
+}
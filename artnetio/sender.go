@@ -0,0 +1,222 @@
+package artnetio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gopatchy/artmap/artcmd"
+	"github.com/gopatchy/artnet"
+)
+
+// egress is a socket bound to one local interface address, used for destinations in its subnet
+type egress struct {
+	conn   *net.UDPConn
+	subnet *net.IPNet
+}
+
+type Sender struct {
+	conn      *net.UDPConn
+	egress    []egress
+	sequences map[artnet.Universe]uint8
+	seqMu     sync.Mutex
+	tap       func(src, dst *net.UDPAddr, data []byte)
+
+	spacing    time.Duration
+	broadcasts map[string]bool
+	paceMu     sync.Mutex
+	nextSend   map[string]time.Time // earliest time of the next packet per broadcast address
+}
+
+func NewSender() (*Sender, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	return NewSenderFromConn(conn), nil
+}
+
+func NewSenderFromConn(conn *net.UDPConn) *Sender {
+	return &Sender{
+		conn:      conn,
+		sequences: map[artnet.Universe]uint8{},
+	}
+}
+
+// AddInterface opens a socket on each IPv4 address of the named interface;
+// packets to a destination inside one of its subnets are sent from that socket
+// instead of the unbound default. It must be called before the sender is in use.
+func (s *Sender) AddInterface(name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return err
+	}
+
+	added := 0
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		conn, err := listenOnInterface(name, ipnet.IP)
+		if err != nil {
+			return err
+		}
+		s.egress = append(s.egress, egress{conn: conn, subnet: ipnet})
+		added++
+	}
+	if added == 0 {
+		return fmt.Errorf("interface %s has no IPv4 address", name)
+	}
+	return nil
+}
+
+func listenOnInterface(name string, ip net.IP) (*net.UDPConn, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			// Pin the egress device where permitted; the address binding alone
+			// still selects the right source address when it isn't
+			c.Control(func(fd uintptr) {
+				bindToDevice(fd, name)
+			})
+			return nil
+		},
+	}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, err
+	}
+	return pc.(*net.UDPConn), nil
+}
+
+// connFor picks the egress socket whose subnet contains dst, or the default socket
+func (s *Sender) connFor(dst *net.UDPAddr) *net.UDPConn {
+	for _, e := range s.egress {
+		if e.subnet.Contains(dst.IP) || dst.IP.Equal(subnetBroadcast(e.subnet)) {
+			return e.conn
+		}
+	}
+	return s.conn
+}
+
+func subnetBroadcast(n *net.IPNet) net.IP {
+	ip4 := n.IP.To4()
+	if ip4 == nil || len(n.Mask) != 4 {
+		return nil
+	}
+	bcast := make(net.IP, 4)
+	for i := range bcast {
+		bcast[i] = ip4[i] | ^n.Mask[i]
+	}
+	return bcast
+}
+
+// SetBroadcastPacing spaces packets to each broadcast address at least spacing
+// apart, so many universes falling back to broadcast don't leave back-to-back
+// and overrun Wi-Fi bridges. The limited broadcast and the broadcast address
+// of each egress subnet are paced along with broadcasts. It must be called
+// before the sender is in use.
+func (s *Sender) SetBroadcastPacing(spacing time.Duration, broadcasts []*net.UDPAddr) {
+	s.spacing = spacing
+	s.broadcasts = map[string]bool{net.IPv4bcast.String(): true}
+	for _, addr := range broadcasts {
+		s.broadcasts[addr.IP.String()] = true
+	}
+	for _, e := range s.egress {
+		if b := subnetBroadcast(e.subnet); b != nil {
+			s.broadcasts[b.String()] = true
+		}
+	}
+	s.nextSend = map[string]time.Time{}
+}
+
+// pace blocks until the slot reserved for the next packet to broadcast address ip
+func (s *Sender) pace(ip string) {
+	s.paceMu.Lock()
+	now := time.Now()
+	slot := s.nextSend[ip]
+	if slot.Before(now) {
+		slot = now
+	}
+	s.nextSend[ip] = slot.Add(s.spacing)
+	s.paceMu.Unlock()
+	if wait := slot.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// SetTap registers a function called with every raw packet sent
+func (s *Sender) SetTap(fn func(src, dst *net.UDPAddr, data []byte)) {
+	s.tap = fn
+}
+
+func (s *Sender) SendDMX(addr *net.UDPAddr, universe artnet.Universe, data []byte) error {
+	s.seqMu.Lock()
+	seq := s.sequences[universe]
+	seq++
+	if seq == 0 {
+		seq = 1
+	}
+	s.sequences[universe] = seq
+	s.seqMu.Unlock()
+
+	return s.SendRaw(addr, artnet.BuildDMXPacket(universe, seq, data))
+}
+
+// SendCommand sends text, such as "SwoutText=Playback&", as an ArtCommand to
+// equipment of manufacturer esta (artcmd.ESTAAll for everyone)
+func (s *Sender) SendCommand(addr *net.UDPAddr, esta uint16, text string) error {
+	pkt, err := artcmd.Build(esta, text)
+	if err != nil {
+		return err
+	}
+	return s.SendRaw(addr, pkt)
+}
+
+// SendNzs sends alternate start code data as an ArtNzs packet
+func (s *Sender) SendNzs(addr *net.UDPAddr, universe artnet.Universe, startCode uint8, data []byte) error {
+	return s.SendRaw(addr, BuildNzs(universe, 0, startCode, data))
+}
+
+func (s *Sender) SendPoll(addr *net.UDPAddr) error {
+	return s.SendRaw(addr, artnet.BuildPollPacket())
+}
+
+func (s *Sender) SendRaw(addr *net.UDPAddr, data []byte) error {
+	if s.spacing > 0 && s.broadcasts[addr.IP.String()] {
+		s.pace(addr.IP.String())
+	}
+	conn := s.connFor(addr)
+	if s.tap != nil {
+		s.tap(conn.LocalAddr().(*net.UDPAddr), addr, data)
+	}
+	_, err := conn.WriteToUDP(data, addr)
+	return err
+}
+
+func (s *Sender) Close() error {
+	for _, e := range s.egress {
+		e.conn.Close()
+	}
+	return s.conn.Close()
+}
+
+func (s *Sender) LocalAddr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// LocalAddrs returns the local address of every socket the sender sends from
+func (s *Sender) LocalAddrs() []*net.UDPAddr {
+	result := []*net.UDPAddr{s.LocalAddr()}
+	for _, e := range s.egress {
+		result = append(result, e.conn.LocalAddr().(*net.UDPAddr))
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package artnetio
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// SyncTimeout is how long a source stays in synchronous mode after its last
+// ArtSync, as set by the Art-Net 4 specification
+const SyncTimeout = 4 * time.Second
+
+// SyncSources tracks which ArtNet sources are in synchronous mode: a source
+// enters it with an ArtSync, and leaves it when it sends none for SyncTimeout
+type SyncSources struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func NewSyncSources() *SyncSources {
+	return &SyncSources{last: map[string]time.Time{}}
+}
+
+// Sync records an ArtSync from ip
+func (s *SyncSources) Sync(ip net.IP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[ip.String()] = time.Now()
+}
+
+// Synced reports whether ip is in synchronous mode, and whether it has just
+// left it, which is reported once
+func (s *SyncSources) Synced(ip net.IP) (synced, lapsed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := ip.String()
+	last, ok := s.last[key]
+	if !ok {
+		return false, false
+	}
+	if time.Since(last) < SyncTimeout {
+		return true, false
+	}
+	delete(s.last, key)
+	return false, true
+}
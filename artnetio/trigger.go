@@ -0,0 +1,53 @@
+package artnetio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gopatchy/artnet"
+)
+
+// OpTrigger is the opcode of ArtTrigger, a console firing a macro, soft key or show
+const OpTrigger uint16 = 0x9900
+
+// TriggerOEMAll addresses an ArtTrigger to every manufacturer's nodes
+const TriggerOEMAll uint16 = 0xFFFF
+
+// TriggerPacket is an ArtTrigger packet; Data is only meaningful to the
+// manufacturer named by OEM
+type TriggerPacket struct {
+	OEM    uint16
+	Key    uint8
+	SubKey uint8
+	Data   []byte
+}
+
+// ParseTrigger parses a raw ArtTrigger packet
+func ParseTrigger(data []byte) (*TriggerPacket, error) {
+	if len(data) < 18 || !bytes.Equal(data[:8], artnet.ID[:]) {
+		return nil, fmt.Errorf("not an Art-Net packet")
+	}
+	if op := binary.LittleEndian.Uint16(data[8:10]); op != OpTrigger {
+		return nil, fmt.Errorf("opcode 0x%04x is not ArtTrigger", op)
+	}
+	return &TriggerPacket{
+		OEM:    binary.BigEndian.Uint16(data[14:16]),
+		Key:    data[16],
+		SubKey: data[17],
+		Data:   bytes.Clone(data[18:min(len(data), 18+512)]),
+	}, nil
+}
+
+// BuildTrigger builds a raw ArtTrigger packet
+func BuildTrigger(pkt *TriggerPacket) []byte {
+	data := make([]byte, 18+512)
+	copy(data, artnet.ID[:])
+	binary.LittleEndian.PutUint16(data[8:10], OpTrigger)
+	binary.BigEndian.PutUint16(data[10:12], protocolVersion)
+	binary.BigEndian.PutUint16(data[14:16], pkt.OEM)
+	data[16] = pkt.Key
+	data[17] = pkt.SubKey
+	copy(data[18:], pkt.Data)
+	return data
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Role is the level of API access granted to a credential
+type Role int
+
+const (
+	RoleNone Role = iota
+	// RoleRead may call GET endpoints (status, dmx, metrics, ...)
+	RoleRead
+	// RoleAdmin may additionally change state (channels, snapshots, capture, ...)
+	RoleAdmin
+)
+
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case "read":
+		return RoleRead, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("invalid role %q (expected read or admin)", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// File is the TOML credentials file loaded by Load
+type File struct {
+	Tokens []struct {
+		Token string `toml:"token"`
+		Role  string `toml:"role"`
+	} `toml:"token"`
+	Users []struct {
+		Name     string `toml:"name"`
+		Password string `toml:"password"`
+		Role     string `toml:"role"`
+	} `toml:"user"`
+}
+
+type credential struct {
+	digest [sha256.Size]byte
+	role   Role
+}
+
+// Authenticator checks bearer tokens and basic auth users against a credentials file
+type Authenticator struct {
+	tokens []credential
+	users  map[string]credential
+}
+
+func Load(path string) (*Authenticator, error) {
+	var f File
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{users: map[string]credential{}}
+	for i, t := range f.Tokens {
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", i, err)
+		}
+		if t.Token == "" {
+			return nil, fmt.Errorf("token %d: token is required", i)
+		}
+		a.tokens = append(a.tokens, credential{digest: sha256.Sum256([]byte(t.Token)), role: role})
+	}
+	for i, u := range f.Users {
+		role, err := ParseRole(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %w", i, err)
+		}
+		if u.Name == "" || u.Password == "" {
+			return nil, fmt.Errorf("user %d: name and password are required", i)
+		}
+		a.users[u.Name] = credential{digest: sha256.Sum256([]byte(u.Password)), role: role}
+	}
+	if len(a.tokens) == 0 && len(a.users) == 0 {
+		return nil, fmt.Errorf("no tokens or users defined")
+	}
+	return a, nil
+}
+
+// Authenticate returns the role granted by the request's credentials
+func (a *Authenticator) Authenticate(r *http.Request) Role {
+	if user, pass, ok := r.BasicAuth(); ok {
+		cred, found := a.users[user]
+		digest := sha256.Sum256([]byte(pass))
+		if found && subtle.ConstantTimeCompare(digest[:], cred.digest[:]) == 1 {
+			return cred.role
+		}
+		return RoleNone
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return RoleNone
+	}
+	digest := sha256.Sum256([]byte(token))
+	role := RoleNone
+	for _, cred := range a.tokens {
+		if subtle.ConstantTimeCompare(digest[:], cred.digest[:]) == 1 {
+			role = cred.role
+		}
+	}
+	return role
+}
+
+// Required returns the role needed for a request: reads need RoleRead, anything else RoleAdmin
+func Required(r *http.Request) Role {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleRead
+	}
+	return RoleAdmin
+}
+
+// Middleware rejects requests without sufficient credentials; a nil Authenticator allows everything
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := a.Authenticate(r)
+		if role == RoleNone {
+			w.Header().Set("WWW-Authenticate", `Basic realm="artmap"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if role < Required(r) {
+			http.Error(w, "admin role required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCredentials = `
+[[token]]
+token = "reader-token"
+role = "read"
+
+[[token]]
+token = "admin-token"
+role = "admin"
+
+[[user]]
+name = "op"
+password = "secret"
+role = "admin"
+`
+
+func load(t *testing.T, content string) (*Authenticator, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := load(t, testCredentials)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method string
+		setup  func(r *http.Request)
+		want   int
+	}{
+		{http.MethodGet, func(r *http.Request) {}, http.StatusUnauthorized},
+		{http.MethodGet, func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{http.MethodGet, func(r *http.Request) { r.Header.Set("Authorization", "Bearer reader-token") }, http.StatusOK},
+		{http.MethodPost, func(r *http.Request) { r.Header.Set("Authorization", "Bearer reader-token") }, http.StatusForbidden},
+		{http.MethodPost, func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-token") }, http.StatusOK},
+		{http.MethodPost, func(r *http.Request) { r.SetBasicAuth("op", "secret") }, http.StatusOK},
+		{http.MethodGet, func(r *http.Request) { r.SetBasicAuth("op", "wrong") }, http.StatusUnauthorized},
+		{http.MethodGet, func(r *http.Request) { r.SetBasicAuth("nobody", "secret") }, http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/artmap/api/status", nil)
+		tt.setup(r)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("case %d: %s got %d, want %d", i, tt.method, w.Code, tt.want)
+		}
+	}
+
+	var nilAuth *Authenticator
+	w := httptest.NewRecorder()
+	nilAuth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("nil authenticator rejected a request: %d", w.Code)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"[[token]]\ntoken = \"x\"\nrole = \"owner\"\n",
+		"[[token]]\nrole = \"read\"\n",
+		"[[user]]\nname = \"op\"\nrole = \"read\"\n",
+	} {
+		if _, err := load(t, content); err == nil {
+			t.Errorf("loaded %q without error", content)
+		}
+	}
+}
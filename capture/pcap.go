@@ -0,0 +1,92 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+// linkTypeIPv4 frames each record as a bare IPv4 datagram (LINKTYPE_IPV4)
+const linkTypeIPv4 = 228
+
+const (
+	fileHeaderLen   = 24
+	recordHeaderLen = 16
+	ipHeaderLen     = 20
+	udpHeaderLen    = 8
+)
+
+func writeFileHeader(w io.Writer) error {
+	var hdr [fileHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], 0xa1b23c4d) // nanosecond timestamps
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:24], linkTypeIPv4)
+	_, err := w.Write(hdr[:])
+	return err
+}
+
+// encodeRecord builds a pcap record wrapping payload in synthesized IPv4 and UDP headers
+func encodeRecord(ts time.Time, src, dst *net.UDPAddr, payload []byte) []byte {
+	ip := encodeIPv4UDP(src, dst, payload)
+	buf := make([]byte, recordHeaderLen, recordHeaderLen+len(ip))
+
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(ts.Nanosecond()))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(len(ip)))
+	binary.LittleEndian.PutUint32(buf[12:16], uint32(len(ip)))
+
+	return append(buf, ip...)
+}
+
+// encodeIPv4UDP wraps payload in synthesized IPv4 and UDP headers
+func encodeIPv4UDP(src, dst *net.UDPAddr, payload []byte) []byte {
+	ipLen := ipHeaderLen + udpHeaderLen + len(payload)
+	ip := make([]byte, ipLen)
+
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(ipLen))
+	ip[8] = 64
+	ip[9] = 17 // UDP
+	copy(ip[12:16], addrIP(src))
+	copy(ip[16:20], addrIP(dst))
+	binary.BigEndian.PutUint16(ip[10:12], checksum(ip[:ipHeaderLen]))
+
+	udp := ip[ipHeaderLen:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(addrPort(src)))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(addrPort(dst)))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderLen+len(payload)))
+	copy(udp[udpHeaderLen:], payload)
+
+	return ip
+}
+
+func addrIP(a *net.UDPAddr) net.IP {
+	if a == nil {
+		return net.IPv4zero.To4()
+	}
+	if ip4 := a.IP.To4(); ip4 != nil {
+		return ip4
+	}
+	return net.IPv4zero.To4()
+}
+
+func addrPort(a *net.UDPAddr) int {
+	if a == nil {
+		return 0
+	}
+	return a.Port
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	blockSectionHeader  = 0x0A0D0D0A
+	blockInterfaceDesc  = 0x00000001
+	blockEnhancedPacket = 0x00000006
+
+	optionEnd       = 0
+	optionTSResol   = 9
+	byteOrderMagic  = 0x1A2B3C4D
+	pcapngSnapLen   = 65535
+	tsResolNanosecs = 9
+)
+
+// PcapngWriter writes packets with synthesized IPv4/UDP headers as a pcapng
+// stream with a single LINKTYPE_IPV4 interface and nanosecond timestamps
+type PcapngWriter struct {
+	w io.Writer
+}
+
+func NewPcapngWriter(w io.Writer) (*PcapngWriter, error) {
+	pw := &PcapngWriter{w: w}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:6], 1)
+	binary.LittleEndian.PutUint16(shb[6:8], 0)
+	binary.LittleEndian.PutUint64(shb[8:16], ^uint64(0)) // section length unknown
+	if err := pw.writeBlock(blockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	idb := make([]byte, 8, 20)
+	binary.LittleEndian.PutUint16(idb[0:2], linkTypeIPv4)
+	binary.LittleEndian.PutUint32(idb[4:8], pcapngSnapLen)
+	idb = appendOption(idb, optionTSResol, []byte{tsResolNanosecs})
+	idb = appendOption(idb, optionEnd, nil)
+	if err := pw.writeBlock(blockInterfaceDesc, idb); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WritePacket writes payload as a UDP datagram from src to dst captured at ts
+func (pw *PcapngWriter) WritePacket(ts time.Time, src, dst *net.UDPAddr, payload []byte) error {
+	ip := encodeIPv4UDP(src, dst, payload)
+	nanos := uint64(ts.UnixNano())
+
+	body := make([]byte, 20, 20+len(ip)+3)
+	binary.LittleEndian.PutUint32(body[0:4], 0) // interface id
+	binary.LittleEndian.PutUint32(body[4:8], uint32(nanos>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(nanos))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(ip)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(ip)))
+	body = append(body, ip...)
+	body = pad32(body)
+	return pw.writeBlock(blockEnhancedPacket, body)
+}
+
+func (pw *PcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	buf := make([]byte, 8, total)
+	binary.LittleEndian.PutUint32(buf[0:4], blockType)
+	binary.LittleEndian.PutUint32(buf[4:8], total)
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+	_, err := pw.w.Write(buf)
+	return err
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad32(b)
+}
+
+func pad32(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,390 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	blockSimplePacket = 0x00000003
+
+	maxRecordLen = 1 << 20
+)
+
+// Link types understood by the reader besides LINKTYPE_IPV4
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+// etherBroadcast is the Ethernet broadcast address
+var etherBroadcast = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// sllBroadcast is the packet type of a Linux cooked capture frame sent to the
+// link-layer broadcast address
+const sllBroadcast = 1
+
+var ErrBadMagic = errors.New("not a pcap or pcapng capture")
+
+// Packet is one UDP datagram read from a capture
+type Packet struct {
+	Time    time.Time
+	Src     *net.UDPAddr
+	Dst     *net.UDPAddr
+	Payload []byte
+	// Broadcast is set when the link layer says the frame was broadcast,
+	// which tells directed broadcasts of any subnet from unicast
+	Broadcast bool
+}
+
+// iface is a pcapng interface: its link type and timestamp units
+type iface struct {
+	link  uint16
+	tsPow int // timestamps count 10^-tsPow or, if tsBin, 2^-tsPow seconds
+	tsBin bool
+}
+
+// Reader reads the UDP datagrams of a pcap or pcapng capture, such as one
+// taken with tcpdump or Wireshark at a venue. Records that are not complete
+// IPv4 or IPv6 UDP datagrams (other protocols, fragments, unknown link types)
+// are skipped.
+type Reader struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	ng     bool
+	link   uint16 // pcap only
+	nanos  bool   // pcap only
+	ifaces []iface
+	hdr    [recordHeaderLen]byte
+}
+
+// NewReader detects the capture format from its header and returns a reader for its packets
+func NewReader(r io.Reader) (*Reader, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(magic[:]) == blockSectionHeader {
+		cr := &Reader{r: r, ng: true}
+		if err := cr.readSectionHeader(); err != nil {
+			return nil, err
+		}
+		return cr, nil
+	}
+
+	cr := &Reader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(magic[:]) {
+		case 0xa1b2c3d4:
+			cr.order = order
+		case 0xa1b23c4d:
+			cr.order, cr.nanos = order, true
+		}
+	}
+	if cr.order == nil {
+		return nil, ErrBadMagic
+	}
+	var hdr [fileHeaderLen - 4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("truncated pcap header")
+	}
+	cr.link = uint16(cr.order.Uint32(hdr[16:20]))
+	return cr, nil
+}
+
+// Next returns the next UDP datagram, or io.EOF at the end of the capture
+func (r *Reader) Next() (Packet, error) {
+	for {
+		ts, link, frame, err := r.nextFrame()
+		if err != nil {
+			return Packet{}, err
+		}
+		if p, ok := decodeFrame(link, frame); ok {
+			p.Time = ts
+			return p, nil
+		}
+	}
+}
+
+// nextFrame returns the next captured link-layer frame
+func (r *Reader) nextFrame() (time.Time, uint16, []byte, error) {
+	if !r.ng {
+		if _, err := io.ReadFull(r.r, r.hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return time.Time{}, 0, nil, fmt.Errorf("truncated pcap record header")
+			}
+			return time.Time{}, 0, nil, err
+		}
+		sec, frac := int64(r.order.Uint32(r.hdr[0:4])), int64(r.order.Uint32(r.hdr[4:8]))
+		n := r.order.Uint32(r.hdr[8:12])
+		if n > maxRecordLen {
+			return time.Time{}, 0, nil, fmt.Errorf("pcap record length %d too large", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			return time.Time{}, 0, nil, fmt.Errorf("truncated pcap record")
+		}
+		if !r.nanos {
+			frac *= 1000
+		}
+		return time.Unix(sec, frac), r.link, data, nil
+	}
+
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return time.Time{}, 0, nil, err
+		}
+		switch blockType {
+		case blockSectionHeader:
+			r.ifaces = nil
+			if err := r.parseSectionHeader(body); err != nil {
+				return time.Time{}, 0, nil, err
+			}
+		case blockInterfaceDesc:
+			if len(body) < 8 {
+				return time.Time{}, 0, nil, fmt.Errorf("truncated pcapng interface block")
+			}
+			r.ifaces = append(r.ifaces, r.parseInterface(body))
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return time.Time{}, 0, nil, fmt.Errorf("truncated pcapng packet block")
+			}
+			id := r.order.Uint32(body[0:4])
+			if id >= uint32(len(r.ifaces)) {
+				continue
+			}
+			n := r.order.Uint32(body[12:16])
+			if uint64(n) > uint64(len(body)-20) {
+				return time.Time{}, 0, nil, fmt.Errorf("pcapng packet length %d exceeds its block", n)
+			}
+			ticks := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+			return r.ifaces[id].time(ticks), r.ifaces[id].link, body[20 : 20+n], nil
+		case blockSimplePacket:
+			if len(body) < 4 || len(r.ifaces) == 0 {
+				continue
+			}
+			n := min(int(r.order.Uint32(body[0:4])), len(body)-4)
+			return time.Time{}, r.ifaces[0].link, body[4 : 4+n], nil
+		}
+	}
+}
+
+// readSectionHeader reads the rest of a section header block whose type was already read
+func (r *Reader) readSectionHeader() error {
+	var b [8]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		return fmt.Errorf("truncated pcapng section header")
+	}
+	if r.order = sectionOrder(b[4:8]); r.order == nil {
+		return ErrBadMagic
+	}
+	total := r.order.Uint32(b[0:4])
+	if total < 28 || total > maxRecordLen || total%4 != 0 {
+		return fmt.Errorf("bad pcapng section header length %d", total)
+	}
+	rest := make([]byte, total-12)
+	if _, err := io.ReadFull(r.r, rest); err != nil {
+		return fmt.Errorf("truncated pcapng section header")
+	}
+	return nil
+}
+
+// parseSectionHeader takes the byte order of a new section from its header block body
+func (r *Reader) parseSectionHeader(body []byte) error {
+	if len(body) < 16 {
+		return fmt.Errorf("truncated pcapng section header")
+	}
+	if r.order = sectionOrder(body[0:4]); r.order == nil {
+		return fmt.Errorf("bad pcapng byte-order magic")
+	}
+	return nil
+}
+
+// sectionOrder returns the byte order a pcapng byte-order magic is written in, or nil if it is not one
+func sectionOrder(bom []byte) binary.ByteOrder {
+	switch {
+	case binary.LittleEndian.Uint32(bom) == byteOrderMagic:
+		return binary.LittleEndian
+	case binary.BigEndian.Uint32(bom) == byteOrderMagic:
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// readBlock returns the type and body of the next pcapng block
+func (r *Reader) readBlock() (uint32, []byte, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated pcapng block header")
+		}
+		return 0, nil, err
+	}
+	blockType := r.order.Uint32(b[0:4])
+	total := r.order.Uint32(b[4:8])
+	if blockType == blockSectionHeader {
+		// A new section may switch byte order, so its length is read from its own magic
+		var bom [4]byte
+		if _, err := io.ReadFull(r.r, bom[:]); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header")
+		}
+		order := sectionOrder(bom[:])
+		if order == nil {
+			return 0, nil, fmt.Errorf("bad pcapng byte-order magic")
+		}
+		total = order.Uint32(b[4:8])
+		if total < 28 || total > maxRecordLen || total%4 != 0 {
+			return 0, nil, fmt.Errorf("bad pcapng block length %d", total)
+		}
+		body := make([]byte, total-8)
+		copy(body, bom[:])
+		if _, err := io.ReadFull(r.r, body[4:]); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng block")
+		}
+		return blockType, body[:total-12], nil
+	}
+	if total < 12 || total > maxRecordLen || total%4 != 0 {
+		return 0, nil, fmt.Errorf("bad pcapng block length %d", total)
+	}
+	body := make([]byte, total-8)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block")
+	}
+	return blockType, body[:total-12], nil
+}
+
+// parseInterface reads an interface description block body, defaulting to microsecond timestamps
+func (r *Reader) parseInterface(body []byte) iface {
+	ifc := iface{link: r.order.Uint16(body[0:2]), tsPow: 6}
+	opts := body[8:]
+	for len(opts) >= 4 {
+		code, n := r.order.Uint16(opts[0:2]), int(r.order.Uint16(opts[2:4]))
+		if code == optionEnd || 4+n > len(opts) {
+			break
+		}
+		if code == optionTSResol && n >= 1 {
+			ifc.tsPow, ifc.tsBin = int(opts[4]&0x7F), opts[4]&0x80 != 0
+		}
+		opts = opts[min(len(opts), 4+(n+3)&^3):]
+	}
+	return ifc
+}
+
+func (ifc iface) time(ticks uint64) time.Time {
+	if ifc.tsBin {
+		return time.Unix(0, int64(float64(ticks)/math.Exp2(float64(ifc.tsPow))*1e9))
+	}
+	nanos := ticks
+	for p := ifc.tsPow; p < 9; p++ {
+		nanos *= 10
+	}
+	for p := ifc.tsPow; p > 9; p-- {
+		nanos /= 10
+	}
+	return time.Unix(0, int64(nanos))
+}
+
+// decodeFrame extracts a UDP datagram from a link-layer frame
+func decodeFrame(link uint16, b []byte) (Packet, bool) {
+	var etherType uint16
+	var broadcast bool
+	switch link {
+	case linkTypeNull:
+		if len(b) < 4 {
+			return Packet{}, false
+		}
+		b = b[4:]
+	case linkTypeEthernet:
+		if len(b) < 14 {
+			return Packet{}, false
+		}
+		broadcast = bytes.Equal(b[:6], etherBroadcast)
+		etherType, b = binary.BigEndian.Uint16(b[12:14]), b[14:]
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(b) >= 4 {
+			etherType, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(b) < 16 {
+			return Packet{}, false
+		}
+		broadcast = binary.BigEndian.Uint16(b[0:2]) == sllBroadcast
+		etherType, b = binary.BigEndian.Uint16(b[14:16]), b[16:]
+	case linkTypeSLL2:
+		if len(b) < 20 {
+			return Packet{}, false
+		}
+		broadcast = uint16(b[10]) == sllBroadcast
+		etherType, b = binary.BigEndian.Uint16(b[0:2]), b[20:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+	default:
+		return Packet{}, false
+	}
+	if etherType != 0 && etherType != 0x0800 && etherType != 0x86DD {
+		return Packet{}, false
+	}
+	p, ok := decodeIP(b)
+	p.Broadcast = broadcast
+	return p, ok
+}
+
+// decodeIP extracts a UDP datagram from an unfragmented IPv4 or IPv6 packet
+func decodeIP(b []byte) (Packet, bool) {
+	if len(b) < 1 {
+		return Packet{}, false
+	}
+	var src, dst net.IP
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < ipHeaderLen {
+			return Packet{}, false
+		}
+		hdrLen, total := int(b[0]&0x0F)*4, int(binary.BigEndian.Uint16(b[2:4]))
+		if hdrLen < ipHeaderLen || total < hdrLen || total > len(b) || b[9] != 17 {
+			return Packet{}, false
+		}
+		if binary.BigEndian.Uint16(b[6:8])&0x3FFF != 0 {
+			return Packet{}, false // fragment
+		}
+		src, dst = net.IP(b[12:16]), net.IP(b[16:20])
+		b = b[hdrLen:total]
+	case 6:
+		if len(b) < 40 || b[6] != 17 {
+			return Packet{}, false
+		}
+		n := int(binary.BigEndian.Uint16(b[4:6]))
+		if 40+n > len(b) {
+			return Packet{}, false
+		}
+		src, dst = net.IP(b[8:24]), net.IP(b[24:40])
+		b = b[40 : 40+n]
+	default:
+		return Packet{}, false
+	}
+
+	if len(b) < udpHeaderLen {
+		return Packet{}, false
+	}
+	n := int(binary.BigEndian.Uint16(b[4:6]))
+	if n < udpHeaderLen || n > len(b) {
+		return Packet{}, false
+	}
+	return Packet{
+		Src:     &net.UDPAddr{IP: src, Port: int(binary.BigEndian.Uint16(b[0:2]))},
+		Dst:     &net.UDPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(b[2:4]))},
+		Payload: b[udpHeaderLen:n],
+	}, true
+}
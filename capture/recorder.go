@@ -0,0 +1,143 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Status describes the current capture for the API
+type Status struct {
+	Active   bool   `json:"active"`
+	File     string `json:"file,omitempty"`
+	Packets  uint64 `json:"packets"`
+	Bytes    int64  `json:"bytes"`
+	MaxBytes int64  `json:"max_bytes"`
+	MaxFiles int    `json:"max_files"`
+}
+
+// Recorder writes sent and received packets to a pcap file, rotating it once
+// it reaches maxBytes and keeping at most maxFiles old files (path.1, path.2, ...)
+type Recorder struct {
+	mu       sync.Mutex
+	maxBytes int64
+	maxFiles int
+	path     string
+	file     *os.File
+	w        *bufio.Writer
+	size     int64
+	packets  uint64
+	flushed  time.Time
+}
+
+func New(maxBytes int64, maxFiles int) *Recorder {
+	return &Recorder{maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// Start begins capturing to path, replacing any capture in progress
+func (r *Recorder) Start(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeLocked()
+	r.path = path
+	r.packets = 0
+	return r.openLocked()
+}
+
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeLocked()
+}
+
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Status{
+		Active:   r.file != nil,
+		File:     r.path,
+		Packets:  r.packets,
+		Bytes:    r.size,
+		MaxBytes: r.maxBytes,
+		MaxFiles: r.maxFiles,
+	}
+}
+
+// Packet records one UDP payload; it is a no-op while no capture is active
+func (r *Recorder) Packet(src, dst *net.UDPAddr, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+
+	rec := encodeRecord(time.Now(), src, dst, data)
+	if r.maxBytes > 0 && r.size+int64(len(rec)) > r.maxBytes && r.size > fileHeaderLen {
+		if err := r.rotateLocked(); err != nil {
+			return
+		}
+	}
+
+	n, err := r.w.Write(rec)
+	r.size += int64(n)
+	if err != nil {
+		r.closeLocked()
+		return
+	}
+	r.packets++
+
+	if time.Since(r.flushed) >= time.Second {
+		r.w.Flush()
+		r.flushed = time.Now()
+	}
+}
+
+func (r *Recorder) openLocked() error {
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := writeFileHeader(w); err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.w = w
+	r.size = fileHeaderLen
+	return nil
+}
+
+func (r *Recorder) closeLocked() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file = nil
+	r.w = nil
+	return err
+}
+
+func (r *Recorder) rotateLocked() error {
+	if err := r.closeLocked(); err != nil {
+		return err
+	}
+	if r.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	}
+	return r.openLocked()
+}
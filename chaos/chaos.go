@@ -0,0 +1,196 @@
+// Package chaos injects faults into outgoing packets for resilience testing.
+// It must never be enabled during a show.
+package chaos
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reorderTimeout bounds how long a held packet waits for a later one to overtake it
+const reorderTimeout = 100 * time.Millisecond
+
+// Config holds fault probabilities in percent (0-100)
+type Config struct {
+	Drop      float64
+	Delay     float64
+	DelayBy   time.Duration
+	Duplicate float64
+	Reorder   float64
+}
+
+// ParseConfig parses "drop=5,delay=10,delay-ms=50,duplicate=2,reorder=3"
+func ParseConfig(spec string) (Config, error) {
+	c := Config{DelayBy: 50 * time.Millisecond}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid chaos option %q (expected key=value)", part)
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid chaos value %q", part)
+		}
+		if key != "delay-ms" && n > 100 {
+			return Config{}, fmt.Errorf("chaos percentage %q exceeds 100", part)
+		}
+		switch key {
+		case "drop":
+			c.Drop = n
+		case "delay":
+			c.Delay = n
+		case "delay-ms":
+			c.DelayBy = time.Duration(n * float64(time.Millisecond))
+		case "duplicate":
+			c.Duplicate = n
+		case "reorder":
+			c.Reorder = n
+		default:
+			return Config{}, fmt.Errorf("unknown chaos option %q (expected drop, delay, delay-ms, duplicate or reorder)", key)
+		}
+	}
+	return c, nil
+}
+
+func (c Config) String() string {
+	return fmt.Sprintf("drop=%g%% delay=%g%%(%s) duplicate=%g%% reorder=%g%%", c.Drop, c.Delay, c.DelayBy, c.Duplicate, c.Reorder)
+}
+
+// Stats counts faults injected for one destination
+type Stats struct {
+	Dropped    uint64
+	Delayed    uint64
+	Duplicated uint64
+	Reordered  uint64
+}
+
+type held struct {
+	send  func() error
+	done  func(error)
+	timer *time.Timer
+}
+
+// Injector applies faults to sends for selected destinations; a nil Injector sends unchanged
+type Injector struct {
+	cfg   Config
+	dests map[string]bool
+
+	mu    sync.Mutex
+	held  map[string]*held
+	stats map[string]*Stats
+}
+
+// New returns an injector for cfg limited to dests (all destinations if empty)
+func New(cfg Config, dests []net.IP) *Injector {
+	inj := &Injector{
+		cfg:   cfg,
+		held:  map[string]*held{},
+		stats: map[string]*Stats{},
+	}
+	if len(dests) > 0 {
+		inj.dests = map[string]bool{}
+		for _, ip := range dests {
+			inj.dests[ip.String()] = true
+		}
+	}
+	return inj
+}
+
+// Send calls send for a packet to dst, possibly dropping, delaying, duplicating
+// or holding it back behind the next packet. done receives the result of each
+// real send; it is not called for dropped packets.
+func (inj *Injector) Send(dst *net.UDPAddr, send func() error, done func(error)) {
+	if inj == nil || (inj.dests != nil && !inj.dests[dst.IP.String()]) {
+		done(send())
+		return
+	}
+	key := dst.String()
+
+	inj.mu.Lock()
+	st := inj.stats[key]
+	if st == nil {
+		st = &Stats{}
+		inj.stats[key] = st
+	}
+
+	switch {
+	case roll(inj.cfg.Drop):
+		st.Dropped++
+		inj.mu.Unlock()
+		return
+
+	case roll(inj.cfg.Delay):
+		st.Delayed++
+		inj.mu.Unlock()
+		time.AfterFunc(inj.cfg.DelayBy, func() { done(send()) })
+		return
+
+	case roll(inj.cfg.Reorder) && inj.held[key] == nil:
+		st.Reordered++
+		h := &held{send: send, done: done}
+		h.timer = time.AfterFunc(reorderTimeout, func() { inj.release(key, h) })
+		inj.held[key] = h
+		inj.mu.Unlock()
+		return
+	}
+
+	duplicate := roll(inj.cfg.Duplicate)
+	if duplicate {
+		st.Duplicated++
+	}
+	h := inj.held[key]
+	delete(inj.held, key)
+	inj.mu.Unlock()
+
+	done(send())
+	if duplicate {
+		done(send())
+	}
+	if h != nil {
+		// Removed from held under the lock, so release will not also send it
+		h.timer.Stop()
+		h.done(h.send())
+	}
+}
+
+// release sends a held packet that no later packet overtook in time
+func (inj *Injector) release(key string, h *held) {
+	inj.mu.Lock()
+	if inj.held[key] != h {
+		inj.mu.Unlock()
+		return
+	}
+	delete(inj.held, key)
+	inj.mu.Unlock()
+	h.done(h.send())
+}
+
+// SwapStats returns faults injected per destination since the last call
+func (inj *Injector) SwapStats() map[string]Stats {
+	if inj == nil {
+		return nil
+	}
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	result := make(map[string]Stats, len(inj.stats))
+	for key, st := range inj.stats {
+		if *st != (Stats{}) {
+			result[key] = *st
+		}
+	}
+	inj.stats = map[string]*Stats{}
+	return result
+}
+
+func roll(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
@@ -0,0 +1,138 @@
+package coalesce
+
+import (
+	"log"
+	"maps"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Source is the frame length of one ArtDmx source on a universe as reported by the API
+type Source struct {
+	Universe  config.Universe   `json:"universe"`
+	IP        string            `json:"ip"`
+	Physical  uint8             `json:"physical"`            // the console's physical port
+	Length    int               `json:"length"`              // channels in the last frame
+	Short     uint64            `json:"short_frames"`        // frames shorter than 512 channels so far
+	Held      bool              `json:"held"`                // channels past short frames keep their last values
+	Malformed map[string]uint64 `json:"malformed,omitempty"` // malformed frames so far by fault
+}
+
+type key struct {
+	u        config.Universe
+	ip       string
+	physical uint8
+}
+
+type entry struct {
+	length    int
+	short     uint64
+	malformed map[string]uint64
+	last      time.Time
+	data      [512]byte // the last coalesced frame, kept only for held universes
+}
+
+// Coalescer tracks the frame length of each ArtDmx source, a console's physical
+// ports counting as separate sources. Channels past a short frame read as 0, or
+// for universes set to hold, keep the values the source last sent for them, so
+// a console that only sends its low channels does not blank the rest.
+type Coalescer struct {
+	mu      sync.Mutex
+	hold    map[config.Universe]bool
+	sources map[key]*entry
+}
+
+func New(hold map[config.Universe]bool) *Coalescer {
+	return &Coalescer{hold: hold, sources: map[key]*entry{}}
+}
+
+// Fill records a frame of length channels from physical port physical of ip on
+// u, filling the channels past length from the source's previous frames if u
+// holds short frames
+func (c *Coalescer) Fill(u config.Universe, ip net.IP, physical uint8, length int, data *[512]byte) {
+	length = min(max(length, 0), 512)
+	k := key{u: u, ip: ip.String(), physical: physical}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.sources[k]
+	if e == nil {
+		e = &entry{}
+		c.sources[k] = e
+	}
+	if length < 512 {
+		if e.short == 0 {
+			mode := config.ShortFramesZero
+			if c.hold[u] {
+				mode = config.ShortFramesHold
+			}
+			log.Printf("[artnet] short frames: src=%s universe=%s physical=%d length=%d mode=%s", ip, u, physical, length, mode)
+		}
+		e.short++
+	}
+	e.length = length
+	e.last = time.Now()
+	if c.hold[u] {
+		copy(data[length:], e.data[length:])
+		e.data = *data
+	}
+}
+
+// Malformed counts a malformed frame from a source, logging the first of each
+// fault; mode says whether it was dropped or repaired
+func (c *Coalescer) Malformed(u config.Universe, ip net.IP, physical uint8, fault string, length int, mode string) {
+	k := key{u: u, ip: ip.String(), physical: physical}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.sources[k]
+	if e == nil {
+		e = &entry{}
+		c.sources[k] = e
+	}
+	if e.malformed == nil {
+		e.malformed = map[string]uint64{}
+	}
+	if e.malformed[fault] == 0 {
+		log.Printf("[artnet] malformed frames: src=%s universe=%s physical=%d fault=%s length=%d mode=%s", ip, u, physical, fault, length, mode)
+	}
+	e.malformed[fault]++
+	e.last = time.Now()
+}
+
+// Sources returns every source seen, sorted by universe, IP and physical port
+func (c *Coalescer) Sources() []Source {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]Source, 0, len(c.sources))
+	for k, e := range c.sources {
+		result = append(result, Source{Universe: k.u, IP: k.ip, Physical: k.physical, Length: e.length, Short: e.short, Held: c.hold[k.u],
+			Malformed: maps.Clone(e.malformed)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].Universe.Compare(result[j].Universe); c != 0 {
+			return c < 0
+		}
+		if result[i].IP != result[j].IP {
+			return result[i].IP < result[j].IP
+		}
+		return result[i].Physical < result[j].Physical
+	})
+	return result
+}
+
+// Expire forgets sources silent for maxAge, so a returning source starts from zeros
+func (c *Coalescer) Expire(maxAge time.Duration) {
+	cutoff := time.Now().Add(-maxAge)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.sources {
+		if e.last.Before(cutoff) {
+			delete(c.sources, k)
+		}
+	}
+}
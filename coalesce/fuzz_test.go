@@ -0,0 +1,54 @@
+package coalesce
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+func FuzzFill(f *testing.F) {
+	f.Add([]byte{0, 2, 0, 4, 1, 0}, true)
+	f.Add([]byte{1, 255, 0, 16, 2, 0}, false)
+	f.Add([]byte{2, 0, 7, 255, 0, 1}, true)
+
+	f.Fuzz(func(t *testing.T, frames []byte, hold bool) {
+		u := config.Universe{Protocol: config.ProtocolArtNet, Number: 1}
+		c := New(map[config.Universe]bool{u: hold})
+		ip := net.IPv4(10, 0, 0, 1)
+
+		var prev [512]byte
+		for i := 0; i+1 < len(frames); i += 2 {
+			length := int(frames[i]) << 2
+			if frames[i] == 255 {
+				length = 512
+			}
+			var data [512]byte
+			for ch := range min(length, 512) {
+				data[ch] = frames[i+1]
+			}
+			c.Fill(u, ip, 0, length, &data)
+
+			for ch, v := range data {
+				var want byte
+				switch {
+				case ch < length:
+					want = frames[i+1]
+				case hold:
+					want = prev[ch]
+				}
+				if v != want {
+					t.Fatalf("frame %d length %d hold=%v: channel %d = %d, want %d", i/2, length, hold, ch+1, v, want)
+				}
+			}
+			prev = data
+		}
+
+		if n := len(frames) / 2; n > 0 {
+			sources := c.Sources()
+			if len(sources) != 1 || sources[0].Held != hold {
+				t.Fatalf("sources %+v", sources)
+			}
+		}
+	})
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/BurntSushi/toml"
+	"github.com/gopatchy/artmap/transform"
+	"github.com/gopatchy/artnet"
 )
 
 // Protocol specifies the output protocol
@@ -14,16 +20,51 @@ type Protocol string
 const (
 	ProtocolArtNet Protocol = "artnet"
 	ProtocolSACN   Protocol = "sacn"
+	ProtocolESPNet Protocol = "espnet"
+	ProtocolUART   Protocol = "uart"
 )
 
+// OutputOnly reports whether the protocol can only be a destination
+func (p Protocol) OutputOnly() bool {
+	return p == ProtocolESPNet || p == ProtocolUART
+}
+
 // Universe represents a DMX universe with its protocol
 type Universe struct {
 	Protocol Protocol `json:"protocol"`
 	Number   uint16   `json:"number"`
+	Device   string   `json:"device,omitempty"` // serial device path for uart
+}
+
+// ArtNetUniverse returns the universe for an ArtNet port-address
+func ArtNetUniverse(u artnet.Universe) Universe {
+	return Universe{Protocol: ProtocolArtNet, Number: uint16(u)}
+}
+
+// SACNUniverse returns the universe for an sACN universe number
+func SACNUniverse(n uint16) Universe {
+	return Universe{Protocol: ProtocolSACN, Number: n}
+}
+
+// ArtNet returns the universe number as an ArtNet port-address
+func (u Universe) ArtNet() artnet.Universe {
+	return artnet.Universe(u.Number)
+}
+
+// Compare orders universes by protocol, then number, then device
+func (u Universe) Compare(v Universe) int {
+	return cmp.Or(
+		cmp.Compare(u.Protocol, v.Protocol),
+		cmp.Compare(u.Number, v.Number),
+		cmp.Compare(u.Device, v.Device),
+	)
 }
 
 func NewUniverse(proto Protocol, num any) (Universe, error) {
-	n, err := toUint16(num, proto)
+	if proto == ProtocolUART {
+		return makeUARTUniverse(num)
+	}
+	n, err := toNumber(num, proto)
 	if err != nil {
 		return Universe{}, err
 	}
@@ -39,13 +80,70 @@ func ParseUniverse(s string) (Universe, error) {
 }
 
 func (u Universe) String() string {
-	if u.Protocol == ProtocolSACN {
-		return fmt.Sprintf("sacn:%d", u.Number)
+	switch u.Protocol {
+	case ProtocolSACN:
+		return "sacn:" + u.numberString()
+	case ProtocolESPNet:
+		return "espnet:" + u.numberString()
+	case ProtocolUART:
+		return "uart:" + u.Device
+	}
+	return "artnet:" + u.numberString()
+}
+
+// ArtNetFormat selects how String writes ArtNet universes; all forms parse
+type ArtNetFormat int
+
+const (
+	ArtNetDotted  ArtNetFormat = iota // net.subnet.universe, e.g. 1.10.3
+	ArtNetHex                         // 15-bit port-address in hex, e.g. 0x1A3
+	ArtNetDecimal                     // 15-bit port-address, e.g. 419
+)
+
+var artnetFormat = ArtNetDotted
+
+// SetArtNetFormat changes the ArtNet universe notation used by String;
+// call before any universes are formatted
+func SetArtNetFormat(f ArtNetFormat) {
+	artnetFormat = f
+}
+
+// ParseArtNetFormat parses "dotted", "hex" or "decimal"
+func ParseArtNetFormat(s string) (ArtNetFormat, error) {
+	switch s {
+	case "dotted":
+		return ArtNetDotted, nil
+	case "hex":
+		return ArtNetHex, nil
+	case "decimal":
+		return ArtNetDecimal, nil
+	}
+	return 0, fmt.Errorf("unknown universe format %q (dotted, hex or decimal)", s)
+}
+
+func (u Universe) numberString() string {
+	if u.Protocol == ProtocolUART {
+		return u.Device
+	}
+	if u.Protocol == ProtocolSACN || u.Protocol == ProtocolESPNet {
+		return strconv.Itoa(int(u.Number))
+	}
+	switch artnetFormat {
+	case ArtNetHex:
+		return fmt.Sprintf("0x%X", u.Number)
+	case ArtNetDecimal:
+		return strconv.Itoa(int(u.Number))
 	}
 	net := (u.Number >> 8) & 0x7F
 	subnet := (u.Number >> 4) & 0x0F
 	universe := u.Number & 0x0F
-	return fmt.Sprintf("artnet:%d.%d.%d", net, subnet, universe)
+	return fmt.Sprintf("%d.%d.%d", net, subnet, universe)
+}
+
+// Offset returns the universe n positions after u in the same protocol. It does
+// not check the range, so spans must have passed CheckSpan.
+func (u Universe) Offset(n int) Universe {
+	return Universe{Protocol: u.Protocol, Number: u.Number + uint16(n), Device: u.Device}
 }
 
 func (u *Universe) UnmarshalTOML(data any) error {
@@ -67,18 +165,18 @@ func (u *Universe) UnmarshalTOML(data any) error {
 	}
 }
 
-func toUint16(v any, proto Protocol) (uint16, error) {
+func toNumber(v any, proto Protocol) (int, error) {
 	switch n := v.(type) {
 	case int:
-		return uint16(n), nil
+		return n, nil
 	case int64:
-		return uint16(n), nil
+		return int(n), nil
 	case uint16:
-		return n, nil
+		return int(n), nil
 	case uint:
-		return uint16(n), nil
+		return int(n), nil
 	case float64:
-		return uint16(n), nil
+		return int(n), nil
 	case string:
 		return parseUniverseNumber(n, proto)
 	default:
@@ -86,45 +184,452 @@ func toUint16(v any, proto Protocol) (uint16, error) {
 	}
 }
 
-func makeUniverse(proto Protocol, n uint16) (Universe, error) {
+// UniverseRange returns the lowest and highest universe numbers of proto,
+// or false for protocols without universe numbers
+func UniverseRange(proto Protocol) (first, last int, ok bool) {
 	switch proto {
 	case ProtocolArtNet:
-		if n > 0x7FFF {
-			return Universe{}, fmt.Errorf("artnet universe %d out of range (max 32767)", n)
-		}
+		return 0, 0x7FFF, true
 	case ProtocolSACN:
-		if n < 1 || n > 63999 {
-			return Universe{}, fmt.Errorf("sacn universe %d out of range (1-63999)", n)
-		}
-	default:
+		return 1, 63999, true
+	case ProtocolESPNet:
+		return 0, 255, true
+	}
+	return 0, 0, false
+}
+
+func makeUniverse(proto Protocol, n int) (Universe, error) {
+	first, last, ok := UniverseRange(proto)
+	if !ok {
 		return Universe{}, fmt.Errorf("unknown protocol: %s", proto)
 	}
-	return Universe{Protocol: proto, Number: n}, nil
+	if n < first || n > last {
+		return Universe{}, fmt.Errorf("%s universe %d out of range (%d-%d)", proto, n, first, last)
+	}
+	return Universe{Protocol: proto, Number: uint16(n)}, nil
+}
+
+// CheckSpan reports whether count consecutive universes starting at first all
+// exist, naming the expansion and the first universe past the protocol's range if not
+func CheckSpan(first Universe, count int) error {
+	lo, hi, ok := UniverseRange(first.Protocol)
+	if !ok {
+		if count > 1 {
+			return fmt.Errorf("%s has no universe numbers to expand %d universes over", first.Protocol, count)
+		}
+		return nil
+	}
+	start := int(first.Number)
+	end := start + count - 1
+	if start < lo || end > hi {
+		return fmt.Errorf("%d universes from %s reach %d, beyond %s's %d-%d", count, first, end, first.Protocol, lo, hi)
+	}
+	return nil
+}
+
+// makeUARTUniverse names a serial device, e.g. "/dev/ttyAMA0"; uart has no universe numbers
+func makeUARTUniverse(v any) (Universe, error) {
+	device, ok := v.(string)
+	if !ok || !strings.HasPrefix(device, "/") || strings.Contains(device, ":") {
+		return Universe{}, fmt.Errorf("uart universe must be a device path like /dev/ttyAMA0")
+	}
+	return Universe{Protocol: ProtocolUART, Device: device}, nil
 }
 
 // Config represents the application configuration
 type Config struct {
-	Targets  []Target  `toml:"target" json:"targets"`
-	Mappings []Mapping `toml:"mapping" json:"mappings"`
+	Version    int         `toml:"version" json:"version"`
+	Profile    string      `toml:"profile" json:"profile,omitempty"` // profile active at startup
+	Targets    []Target    `toml:"target" json:"targets"`
+	Mappings   []Mapping   `toml:"mapping" json:"mappings"`
+	Outputs    []Output    `toml:"output" json:"outputs"`
+	Inputs     []Input     `toml:"input" json:"inputs,omitempty"`
+	Statics    []Static    `toml:"static" json:"statics"`
+	Hue        []Hue       `toml:"hue" json:"hue,omitempty"`
+	NodeInputs []NodeInput `toml:"node_input" json:"node_inputs,omitempty"`
+	Domains    []Domain    `toml:"domain" json:"domains,omitempty"`
+	Groups     []Group     `toml:"group" json:"groups,omitempty"`
+	Sources    []Source    `toml:"source" json:"sources,omitempty"`
+	Pipelines  []Pipeline  `toml:"pipeline" json:"pipelines,omitempty"`
+	Triggers   []Trigger   `toml:"trigger" json:"triggers,omitempty"`
+	Warnings   []string    `toml:"-" json:"-"`
 }
 
 // Target represents a target address for an output universe
 type Target struct {
 	Universe Universe `toml:"universe" json:"universe"`
 	Address  string   `toml:"address" json:"address"`
+	Probe    string   `toml:"probe" json:"probe,omitempty"` // artpoll or icmp to watch the target's health
+}
+
+// Output holds per-destination-universe settings
+type Output struct {
+	Universe Universe       `toml:"universe" json:"universe"`
+	MaxHz    float64        `toml:"max_hz" json:"max_hz,omitempty"`
+	Defaults map[string]int `toml:"defaults" json:"defaults,omitempty"`
+	Mirror   *Universe      `toml:"mirror" json:"mirror,omitempty"`     // also sent on this universe of the other protocol
+	Priority int            `toml:"priority" json:"priority,omitempty"` // sACN priority of the sACN side (default 100)
+	Fallback []string       `toml:"fallback" json:"fallback,omitempty"` // artnet destination stages tried in order
+	Label    string         `toml:"label" json:"label,omitempty"`       // production name shown in logs, status and metrics
+}
+
+// Stages of an ArtNet output's fallback chain
+const (
+	FallbackDiscovered = "discovered" // nodes found by ArtPoll
+	FallbackStatic     = "static"     // the [[target]] address
+	FallbackBroadcast  = "broadcast"  // the primary broadcast address
+	FallbackDrop       = "drop"       // send nothing
+)
+
+// DefaultFallback is the chain of ArtNet outputs without a fallback setting
+var DefaultFallback = []string{FallbackStatic, FallbackDiscovered}
+
+// validateFallback checks that chain lists known stages once each, with drop only last
+func validateFallback(chain []string) error {
+	seen := map[string]bool{}
+	for i, stage := range chain {
+		switch stage {
+		case FallbackDiscovered, FallbackStatic, FallbackBroadcast:
+		case FallbackDrop:
+			if i != len(chain)-1 {
+				return fmt.Errorf("fallback stage drop must be last")
+			}
+		default:
+			return fmt.Errorf("unknown fallback stage %q (discovered, static, broadcast or drop)", stage)
+		}
+		if seen[stage] {
+			return fmt.Errorf("duplicate fallback stage %q", stage)
+		}
+		seen[stage] = true
+	}
+	return nil
+}
+
+// sacnUniverse returns the sACN side of the output, if it has one
+func (o *Output) sacnUniverse() (Universe, bool) {
+	if o.Universe.Protocol == ProtocolSACN {
+		return o.Universe, true
+	}
+	if o.Mirror != nil && o.Mirror.Protocol == ProtocolSACN {
+		return *o.Mirror, true
+	}
+	return Universe{}, false
+}
+
+// DefaultValues returns the parsed defaults keyed by 0-indexed channel
+func (o *Output) DefaultValues() (map[int]byte, error) {
+	return parseChannelValues(o.Defaults)
+}
+
+// Input holds per-source-universe settings
+type Input struct {
+	Universe    Universe `toml:"universe" json:"universe"`
+	SampleMS    int      `toml:"sample_ms" json:"sample_ms,omitempty"`       // hold outputs fed by the universe this long when its input starts
+	ShortFrames string   `toml:"short_frames" json:"short_frames,omitempty"` // channels past a short ArtDmx frame: zero (default) or hold
+	Label       string   `toml:"label" json:"label,omitempty"`               // production name shown in logs, status and metrics
+	Sources     []string `toml:"sources" json:"sources,omitempty"`           // sACN only: source IPs joined with source-specific multicast; others are dropped
+}
+
+const (
+	ShortFramesZero = "zero" // channels past the received length read as 0
+	ShortFramesHold = "hold" // channels past the received length keep the source's last values
+)
+
+// Source is an extra input run alongside the ArtNet and sACN receivers
+type Source struct {
+	Type     string   `toml:"type" json:"type"`                 // replay or generator
+	File     string   `toml:"file" json:"file,omitempty"`       // replay: artmap recording whose input frames are played
+	Loop     bool     `toml:"loop" json:"loop,omitempty"`       // replay: start again after the last frame
+	Universe Universe `toml:"universe" json:"universe"`         // generator: input universe the pattern is sent on
+	Pattern  string   `toml:"pattern" json:"pattern,omitempty"` // generator: chase (default) or ramp
+	Hz       int      `toml:"hz" json:"hz,omitempty"`           // generator: frames per second (default 40)
+}
+
+const (
+	SourceReplay    = "replay"
+	SourceGenerator = "generator"
+
+	PatternChase = "chase" // one channel at full, stepping through the universe
+	PatternRamp  = "ramp"  // every channel at the same level, rising and wrapping
+)
+
+// Rate returns the generator's frame rate
+func (s *Source) Rate() int {
+	if s.Hz == 0 {
+		return 40
+	}
+	return s.Hz
+}
+
+// Group is a named level group whose master scales the channels its mappings write
+type Group struct {
+	Name   string `toml:"name" json:"name"`
+	Master *int   `toml:"master" json:"master,omitempty"` // level at startup, 0-255 (default 255)
+}
+
+// Level returns the group's master level at startup
+func (g *Group) Level() byte {
+	if g.Master == nil {
+		return 255
+	}
+	return byte(*g.Master)
+}
+
+// MaxGroups is how many level groups a config may declare
+const MaxGroups = 64
+
+// GrandMaster names the master that scales every group, so no group may use it
+const GrandMaster = "grand"
+
+// Static is a fixed input frame injected at startup, as if received from Universe
+type Static struct {
+	Universe Universe       `toml:"universe" json:"universe"`
+	Channels map[string]int `toml:"channels" json:"channels,omitempty"`
+}
+
+// Data returns the static frame with unlisted channels at zero
+func (s *Static) Data() ([512]byte, error) {
+	var data [512]byte
+	values, err := parseChannelValues(s.Channels)
+	if err != nil {
+		return data, err
+	}
+	for ch, v := range values {
+		data[ch] = v
+	}
+	return data, nil
+}
+
+// Hue is a Philips Hue Entertainment streaming output. Each entertainment
+// channel takes three consecutive DMX channels (R, G, B) starting at From.
+type Hue struct {
+	Bridge              string `toml:"bridge" json:"bridge"`
+	BridgeID            string `toml:"bridge_id" json:"bridge_id"`       // the name in the bridge's certificate
+	CAFile              string `toml:"ca_file" json:"ca_file,omitempty"` // Hue root CA the certificate must chain to
+	ApplicationKey      string `toml:"application_key" json:"-"`
+	ClientKey           string `toml:"client_key" json:"-"`
+	EntertainmentConfig string `toml:"entertainment_config" json:"entertainment_config"`
+	From                ToAddr `toml:"from" json:"from"`
+	Channels            []int  `toml:"channels" json:"channels"`
+}
+
+// NodeInput disables DMX input ports on a downstream ArtNet node with ArtInput,
+// for nodes that would otherwise loop their input back into the network
+type NodeInput struct {
+	Address   string `toml:"address" json:"address"`
+	BindIndex int    `toml:"bind_index" json:"bind_index"`
+	Disable   []int  `toml:"disable" json:"disable"` // 1-indexed input ports
+}
+
+// Disabled returns the per-port disable flags
+func (n *NodeInput) Disabled() [4]bool {
+	var d [4]bool
+	for _, port := range n.Disable {
+		d[port-1] = true
+	}
+	return d
+}
+
+// Domain is an isolated routing domain with its own ArtNet listener, mappings
+// and targets. Its input never reaches other mappings and its outputs only go
+// to its own targets, so two stages can share one process.
+type Domain struct {
+	Name         string    `toml:"name" json:"name"`
+	ArtNetListen string    `toml:"artnet_listen" json:"artnet_listen"`
+	Targets      []Target  `toml:"target" json:"targets"`
+	Mappings     []Mapping `toml:"mapping" json:"mappings"`
+}
+
+// Normalize converts the domain's mappings to normalized form
+func (d *Domain) Normalize() []NormalizedMapping {
+	c := Config{Mappings: d.Mappings}
+	return c.Normalize()
+}
+
+// validate checks a domain on its own; listener clashes are checked by Load
+func (d *Domain) validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := net.ResolveUDPAddr("udp4", d.ArtNetListen); err != nil || d.ArtNetListen == "" {
+		return fmt.Errorf("invalid artnet_listen %q", d.ArtNetListen)
+	}
+	targets := map[Universe]bool{}
+	for i, t := range d.Targets {
+		if t.Address == "" {
+			return fmt.Errorf("target %d: address is required", i)
+		}
+		if t.Universe.Protocol != ProtocolArtNet && t.Universe.Protocol != ProtocolSACN {
+			return fmt.Errorf("target %d: domains output artnet or sacn only", i)
+		}
+		targets[t.Universe] = true
+	}
+	for i := range d.Mappings {
+		m := &d.Mappings[i]
+		if err := validateMapping(m); err != nil {
+			return fmt.Errorf("mapping %d: %w", i, err)
+		}
+		if m.From.Universe.Protocol != ProtocolArtNet {
+			return fmt.Errorf("mapping %d: domains take artnet input from their own listener only", i)
+		}
+		if m.From.Wildcard {
+			return fmt.Errorf("mapping %d: wildcards are not supported in domains", i)
+		}
+		if m.Profile != "" {
+			return fmt.Errorf("mapping %d: profiles are not supported in domains", i)
+		}
+		// Every output needs a unicast target; broadcast or multicast would reach other domains
+		for j := 0; j < m.From.Span(); j++ {
+			if u := m.To.Universe.Offset(j); !targets[u] {
+				return fmt.Errorf("mapping %d: no target for %s", i, u)
+			}
+		}
+	}
+	return nil
+}
+
+// parseChannelValues converts a TOML channel = value table to 0-indexed channels
+func parseChannelValues(m map[string]int) (map[int]byte, error) {
+	result := map[int]byte{}
+	for k, v := range m {
+		ch, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel %q", k)
+		}
+		if ch < 1 || ch > 512 {
+			return nil, fmt.Errorf("channel %d must be 1-512", ch)
+		}
+		if v < 0 || v > 255 {
+			return nil, fmt.Errorf("value for channel %d must be 0-255", ch)
+		}
+		result[ch-1] = byte(v)
+	}
+	return result, nil
 }
 
 // Mapping represents a single channel mapping rule
 type Mapping struct {
-	From FromAddr `toml:"from" json:"from"`
-	To   ToAddr   `toml:"to" json:"to"`
+	From       FromAddr    `toml:"from" json:"from"`
+	To         ToAddr      `toml:"to" json:"to"`
+	DelayMS    int         `toml:"delay_ms" json:"delay_ms,omitempty"`
+	Resample   string      `toml:"resample" json:"resample,omitempty"`
+	ToPixels   int         `toml:"to_pixels" json:"to_pixels,omitempty"`
+	PixelSize  int         `toml:"pixel_size" json:"pixel_size,omitempty"`
+	RGBW       string      `toml:"rgbw" json:"rgbw,omitempty"`
+	RGBWMatrix [][]float64 `toml:"rgbw_matrix" json:"rgbw_matrix,omitempty"`
+	Profile    string      `toml:"profile" json:"profile,omitempty"` // empty = part of every profile
+	Offset     int         `toml:"offset" json:"offset,omitempty"`   // added to universe numbers passed through a wildcard to
+	Group      string      `toml:"group" json:"group,omitempty"`     // level group whose master scales the written channels
+	// FromPhysical limits the mapping to ArtDmx frames sent from this physical
+	// port of the console, for consoles sending one universe out of several ports
+	FromPhysical *int `toml:"from_physical" json:"from_physical,omitempty"`
+	// FromCast limits the mapping to frames delivered by unicast, broadcast or
+	// multicast, e.g. to ignore a console's broadcasts while taking its unicast
+	FromCast string `toml:"from_cast" json:"from_cast,omitempty"`
+	Label    string `toml:"label" json:"label,omitempty"` // production name shown in logs
+	// NZS decides what happens to ArtNzs (non-zero start code) packets on the
+	// source universe: drop (default) or pass them unchanged to the destination universe
+	NZS string `toml:"nzs" json:"nzs,omitempty"`
+	// Stages replaces the resample and rgbw options with an ordered transform
+	// list; only set on the mappings a [[pipeline]] expands to
+	Stages   []Stage `toml:"-" json:"stages,omitempty"`
+	Pipeline string  `toml:"-" json:"pipeline,omitempty"` // the [[pipeline]] the mapping came from
 }
 
-// FromAddr represents a source universe address with channel range
+const (
+	NZSDrop = "drop"
+	NZSPass = "pass"
+)
+
+// Cast is how an input frame was delivered, told by its destination address
+type Cast string
+
+const (
+	CastUnicast   Cast = "unicast"
+	CastBroadcast Cast = "broadcast"
+	CastMulticast Cast = "multicast"
+)
+
+func (m *Mapping) physical() *uint8 {
+	if m.FromPhysical == nil {
+		return nil
+	}
+	p := uint8(*m.FromPhysical)
+	return &p
+}
+
+// stages returns the mapping's transforms in order: its pipeline stages, or
+// resampling followed by RGBW conversion
+func (m *Mapping) stages() []Stage {
+	if len(m.Stages) > 0 {
+		return m.Stages
+	}
+	var stages []Stage
+	if m.Resample != "" {
+		stages = append(stages, Stage{Resample: m.Resample, ToPixels: m.ToPixels, PixelSize: m.PixelSize})
+	}
+	if m.RGBW != "" {
+		stages = append(stages, Stage{RGBW: m.RGBW, RGBWMatrix: m.RGBWMatrix})
+	}
+	return stages
+}
+
+// Transform builds the channel transform for the mapping, or nil for a plain copy.
+func (m *Mapping) Transform() (transform.Transform, error) {
+	stages := m.stages()
+	if len(stages) == 0 {
+		return nil, nil
+	}
+	if len(m.From.Ranges) != 1 {
+		return nil, fmt.Errorf("transforms require a single from channel range")
+	}
+
+	var chain transform.Chain
+	count := m.From.Count()
+	for _, s := range stages {
+		t, err := s.transform(count)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, t)
+		count = t.OutputCount()
+	}
+
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// OutputCount returns the number of destination channels the mapping writes
+func (m *Mapping) OutputCount() int {
+	if t, err := m.Transform(); err == nil && t != nil {
+		return t.OutputCount()
+	}
+	return m.From.Count()
+}
+
+// ChannelRange is an inclusive span of channels
+type ChannelRange struct {
+	Start int `json:"start"` // 1-indexed
+	End   int `json:"end"`   // 1-indexed
+}
+
+func (r ChannelRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// FromAddr represents a source universe address with one or more channel ranges.
+// UniverseCount > 1 selects a block of consecutive universes starting at Universe.
+// Wildcard ("sacn:*") matches every received universe of Universe.Protocol.
 type FromAddr struct {
-	Universe     Universe `json:"universe"`
-	ChannelStart int      `json:"channel_start"` // 1-indexed
-	ChannelEnd   int      `json:"channel_end"`   // 1-indexed
+	Universe      Universe       `json:"universe"`
+	UniverseCount int            `json:"universe_count,omitempty"`
+	Wildcard      bool           `json:"wildcard,omitempty"`
+	Ranges        []ChannelRange `json:"ranges"`
 }
 
 func (a *FromAddr) UnmarshalTOML(data any) error {
@@ -136,8 +641,7 @@ func (a *FromAddr) UnmarshalTOML(data any) error {
 		return err
 	}
 	a.Universe = u
-	a.ChannelStart = 1
-	a.ChannelEnd = 512
+	a.Ranges = []ChannelRange{{Start: 1, End: 512}}
 	return nil
 }
 
@@ -148,19 +652,84 @@ func (a *FromAddr) parse(s string) error {
 	}
 
 	universeStr, channelSpec := splitAddr(rest)
-	u, err := NewUniverse(proto, universeStr)
-	if err != nil {
-		return err
+	a.UniverseCount = 0
+	a.Wildcard = false
+	if universeStr == "*" {
+		a.Universe = Universe{Protocol: proto}
+		a.Wildcard = true
+	} else if idx := strings.Index(universeStr, "-"); idx > 0 {
+		first, err := NewUniverse(proto, universeStr[:idx])
+		if err != nil {
+			return err
+		}
+		last, err := NewUniverse(proto, universeStr[idx+1:])
+		if err != nil {
+			return err
+		}
+		if first.Number > last.Number {
+			return fmt.Errorf("universe range start %s > end %s", first, last)
+		}
+		a.Universe = first
+		if last.Number > first.Number {
+			a.UniverseCount = int(last.Number-first.Number) + 1
+		}
+	} else {
+		u, err := NewUniverse(proto, universeStr)
+		if err != nil {
+			return err
+		}
+		a.Universe = u
 	}
-	a.Universe = u
 
 	if channelSpec == "" {
-		a.ChannelStart = 1
-		a.ChannelEnd = 512
+		a.Ranges = []ChannelRange{{Start: 1, End: 512}}
 		return nil
 	}
 
-	return parseChannelRange(channelSpec, &a.ChannelStart, &a.ChannelEnd)
+	a.Ranges = nil
+	for _, spec := range strings.Split(channelSpec, ",") {
+		var r ChannelRange
+		if err := parseChannelRange(strings.TrimSpace(spec), &r.Start, &r.End); err != nil {
+			return err
+		}
+		a.Ranges = append(a.Ranges, r)
+	}
+	return nil
+}
+
+// ParseChannelAddr parses a single channel address like "artnet:0.0.1:57" (1-indexed channel)
+func ParseChannelAddr(s string) (Universe, int, error) {
+	_, rest, err := splitProtoPrefix(strings.TrimSpace(s))
+	if err != nil {
+		return Universe{}, 0, err
+	}
+	if _, ch := splitAddr(rest); ch == "" {
+		return Universe{}, 0, fmt.Errorf("address %q has no channel", s)
+	}
+	var a ToAddr
+	if err := a.parse(s); err != nil {
+		return Universe{}, 0, err
+	}
+	if a.ChannelStart < 1 || a.ChannelStart > 512 {
+		return Universe{}, 0, fmt.Errorf("channel %d out of range (1-512)", a.ChannelStart)
+	}
+	return a.Universe, a.ChannelStart, nil
+}
+
+// ParseChannels parses a comma-separated list of 1-indexed channels and ranges, e.g. "1-3,10"
+func ParseChannels(spec string) ([]ChannelRange, error) {
+	var ranges []ChannelRange
+	for _, part := range strings.Split(spec, ",") {
+		var r ChannelRange
+		if err := parseChannelRange(strings.TrimSpace(part), &r.Start, &r.End); err != nil {
+			return nil, err
+		}
+		if r.Start < 1 || r.End > 512 {
+			return nil, fmt.Errorf("channel range %s out of bounds (1-512)", r)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
 }
 
 func parseChannelRange(spec string, start, end *int) error {
@@ -195,22 +764,57 @@ func parseChannelRange(spec string, start, end *int) error {
 }
 
 func (a FromAddr) String() string {
-	if a.ChannelStart == 1 && a.ChannelEnd == 512 {
-		return a.Universe.String()
+	universe := a.Universe.String()
+	if a.Wildcard {
+		universe = string(a.Universe.Protocol) + ":*"
+	}
+	if a.UniverseCount > 1 {
+		universe += "-" + a.Universe.Offset(a.UniverseCount-1).numberString()
+	}
+	if len(a.Ranges) == 1 && a.Ranges[0].Start == 1 && a.Ranges[0].End == 512 {
+		return universe
+	}
+	parts := make([]string, len(a.Ranges))
+	for i, r := range a.Ranges {
+		parts[i] = r.String()
 	}
-	if a.ChannelStart == a.ChannelEnd {
-		return fmt.Sprintf("%s:%d", a.Universe, a.ChannelStart)
+	return fmt.Sprintf("%s:%s", universe, strings.Join(parts, ","))
+}
+
+// MarshalJSON adds channel_start and channel_end for single-range addresses,
+// the fields status consumers read before ranges existed
+func (a FromAddr) MarshalJSON() ([]byte, error) {
+	type plain FromAddr
+	v := struct {
+		plain
+		ChannelStart int `json:"channel_start,omitempty"`
+		ChannelEnd   int `json:"channel_end,omitempty"`
+	}{plain: plain(a)}
+	if len(a.Ranges) == 1 {
+		v.ChannelStart = a.Ranges[0].Start
+		v.ChannelEnd = a.Ranges[0].End
 	}
-	return fmt.Sprintf("%s:%d-%d", a.Universe, a.ChannelStart, a.ChannelEnd)
+	return json.Marshal(v)
+}
+
+// Span returns the number of universes the address covers
+func (a *FromAddr) Span() int {
+	return max(a.UniverseCount, 1)
 }
 
 func (a *FromAddr) Count() int {
-	return a.ChannelEnd - a.ChannelStart + 1
+	n := 0
+	for _, r := range a.Ranges {
+		n += r.End - r.Start + 1
+	}
+	return n
 }
 
-// ToAddr represents a destination universe address with starting channel
+// ToAddr represents a destination universe address with starting channel.
+// Wildcard ("artnet:*") passes the source universe number through, plus the mapping's offset.
 type ToAddr struct {
 	Universe     Universe `json:"universe"`
+	Wildcard     bool     `json:"wildcard,omitempty"`
 	ChannelStart int      `json:"channel_start"` // 1-indexed
 }
 
@@ -234,11 +838,16 @@ func (a *ToAddr) parse(s string) error {
 	}
 
 	universeStr, channelSpec := splitAddr(rest)
-	u, err := NewUniverse(proto, universeStr)
-	if err != nil {
-		return err
+	a.Wildcard = universeStr == "*"
+	if a.Wildcard {
+		a.Universe = Universe{Protocol: proto}
+	} else {
+		u, err := NewUniverse(proto, universeStr)
+		if err != nil {
+			return err
+		}
+		a.Universe = u
 	}
-	a.Universe = u
 
 	if channelSpec == "" {
 		a.ChannelStart = 1
@@ -258,10 +867,14 @@ func (a *ToAddr) parse(s string) error {
 }
 
 func (a ToAddr) String() string {
+	universe := a.Universe.String()
+	if a.Wildcard {
+		universe = string(a.Universe.Protocol) + ":*"
+	}
 	if a.ChannelStart == 1 {
-		return a.Universe.String()
+		return universe
 	}
-	return fmt.Sprintf("%s:%d", a.Universe, a.ChannelStart)
+	return fmt.Sprintf("%s:%d", universe, a.ChannelStart)
 }
 
 func splitProtoPrefix(s string) (Protocol, string, error) {
@@ -271,7 +884,13 @@ func splitProtoPrefix(s string) (Protocol, string, error) {
 	if strings.HasPrefix(s, "sacn:") {
 		return ProtocolSACN, s[5:], nil
 	}
-	return "", "", fmt.Errorf("address %q must start with 'artnet:' or 'sacn:' prefix", s)
+	if strings.HasPrefix(s, "espnet:") {
+		return ProtocolESPNet, s[7:], nil
+	}
+	if strings.HasPrefix(s, "uart:") {
+		return ProtocolUART, s[5:], nil
+	}
+	return "", "", fmt.Errorf("address %q must start with 'artnet:', 'sacn:', 'espnet:' or 'uart:' prefix", s)
 }
 
 func splitAddr(s string) (universe, channel string) {
@@ -281,10 +900,10 @@ func splitAddr(s string) (universe, channel string) {
 	return s, ""
 }
 
-func parseUniverseNumber(s string, proto Protocol) (uint16, error) {
+func parseUniverseNumber(s string, proto Protocol) (int, error) {
 	if strings.Contains(s, ".") {
-		if proto == ProtocolSACN {
-			return 0, fmt.Errorf("sACN universes cannot use net.subnet.universe format")
+		if proto != ProtocolArtNet {
+			return 0, fmt.Errorf("%s universes cannot use net.subnet.universe format", proto)
 		}
 		parts := strings.Split(s, ".")
 		if len(parts) != 3 {
@@ -302,83 +921,706 @@ func parseUniverseNumber(s string, proto Protocol) (uint16, error) {
 		if err != nil {
 			return 0, fmt.Errorf("invalid universe: %w", err)
 		}
-		return uint16(net&0x7F)<<8 | uint16(subnet&0x0F)<<4 | uint16(universe&0x0F), nil
+		if net < 0 || net > 0x7F || subnet < 0 || subnet > 0x0F || universe < 0 || universe > 0x0F {
+			return 0, fmt.Errorf("invalid universe %s: net must be 0-127, subnet and universe 0-15", s)
+		}
+		return net<<8 | subnet<<4 | universe, nil
+	}
+
+	if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		u, err := strconv.ParseUint(hex, 16, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid universe: %s", s)
+		}
+		return int(u), nil
 	}
 
 	u, err := strconv.Atoi(s)
 	if err != nil {
 		return 0, fmt.Errorf("invalid universe: %s", s)
 	}
-	return uint16(u), nil
+	return u, nil
 }
 
 // Load loads configuration from a TOML file
 func Load(path string) (*Config, error) {
 	var cfg Config
 
-	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+	warnings, err := decodeFile(path, &cfg)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	cfg.Warnings = warnings
 
 	// Validate targets
 	for i, t := range cfg.Targets {
 		if t.Address == "" {
 			return nil, fmt.Errorf("target %d: address is required", i)
 		}
+		if t.Universe.Protocol == ProtocolUART {
+			return nil, fmt.Errorf("target %d: uart outputs have no network target", i)
+		}
+		switch t.Probe {
+		case "", "artpoll", "icmp":
+		default:
+			return nil, fmt.Errorf("target %d: invalid probe %q (expected artpoll or icmp)", i, t.Probe)
+		}
+	}
+
+	seenOutputs := map[Universe]bool{}
+	for i, o := range cfg.Outputs {
+		if seenOutputs[o.Universe] {
+			return nil, fmt.Errorf("output %d: duplicate universe %s", i, o.Universe)
+		}
+		seenOutputs[o.Universe] = true
+		if o.MaxHz < 0 {
+			return nil, fmt.Errorf("output %d: max_hz must not be negative", i)
+		}
+		if _, err := o.DefaultValues(); err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		if o.Mirror != nil {
+			if !gatewayable(o.Universe.Protocol) || !gatewayable(o.Mirror.Protocol) || o.Mirror.Protocol == o.Universe.Protocol {
+				return nil, fmt.Errorf("output %d: mirror must send an artnet universe to sacn or a sacn universe to artnet", i)
+			}
+		}
+		if o.Fallback != nil {
+			if o.Universe.Protocol != ProtocolArtNet {
+				return nil, fmt.Errorf("output %d: fallback only applies to artnet outputs", i)
+			}
+			if len(o.Fallback) == 0 {
+				return nil, fmt.Errorf("output %d: fallback must list at least one stage", i)
+			}
+			if err := validateFallback(o.Fallback); err != nil {
+				return nil, fmt.Errorf("output %d: %w", i, err)
+			}
+		}
+		if o.Priority != 0 {
+			if _, ok := o.sacnUniverse(); !ok {
+				return nil, fmt.Errorf("output %d: priority needs a sacn universe or mirror", i)
+			}
+			if o.Priority < 1 || o.Priority > 200 {
+				return nil, fmt.Errorf("output %d: priority must be 1-200", i)
+			}
+		}
+	}
+
+	seenInputs := map[Universe]bool{}
+	for i, in := range cfg.Inputs {
+		if in.Universe.Protocol.OutputOnly() {
+			return nil, fmt.Errorf("input %d: %s is output-only", i, in.Universe.Protocol)
+		}
+		if seenInputs[in.Universe] {
+			return nil, fmt.Errorf("input %d: duplicate universe %s", i, in.Universe)
+		}
+		seenInputs[in.Universe] = true
+		if in.SampleMS < 0 || in.SampleMS > 10000 {
+			return nil, fmt.Errorf("input %d: sample_ms must be 0-10000", i)
+		}
+		switch in.ShortFrames {
+		case "", ShortFramesZero:
+		case ShortFramesHold:
+			if in.Universe.Protocol != ProtocolArtNet {
+				return nil, fmt.Errorf("input %d: short_frames applies to artnet universes only", i)
+			}
+		default:
+			return nil, fmt.Errorf("input %d: short_frames must be zero or hold", i)
+		}
+		if len(in.Sources) > 0 && in.Universe.Protocol != ProtocolSACN {
+			return nil, fmt.Errorf("input %d: sources applies to sacn universes only", i)
+		}
+		for _, src := range in.Sources {
+			if ip := net.ParseIP(src); ip == nil || ip.To4() == nil || ip.IsMulticast() || ip.IsUnspecified() {
+				return nil, fmt.Errorf("input %d: source %q must be a unicast IPv4 address", i, src)
+			}
+		}
+	}
+
+	if err := cfg.validateLabels(); err != nil {
+		return nil, err
+	}
+
+	for i, st := range cfg.Statics {
+		if st.Universe.Protocol.OutputOnly() {
+			return nil, fmt.Errorf("static %d: %s is output-only", i, st.Universe.Protocol)
+		}
+		if _, err := st.Data(); err != nil {
+			return nil, fmt.Errorf("static %d: %w", i, err)
+		}
+	}
+
+	for i, src := range cfg.Sources {
+		switch src.Type {
+		case SourceReplay:
+			if src.File == "" {
+				return nil, fmt.Errorf("source %d: replay needs a file", i)
+			}
+		case SourceGenerator:
+			if src.Universe.Protocol == "" || src.Universe.Protocol.OutputOnly() {
+				return nil, fmt.Errorf("source %d: generator needs an artnet or sacn universe", i)
+			}
+			switch src.Pattern {
+			case "", PatternChase, PatternRamp:
+			default:
+				return nil, fmt.Errorf("source %d: pattern must be chase or ramp", i)
+			}
+			if src.Hz < 0 || src.Hz > 100 {
+				return nil, fmt.Errorf("source %d: hz must be 1-100", i)
+			}
+		default:
+			return nil, fmt.Errorf("source %d: type must be replay or generator", i)
+		}
+	}
+
+	for i, h := range cfg.Hue {
+		if h.Bridge == "" || h.BridgeID == "" || h.ApplicationKey == "" || h.ClientKey == "" || h.EntertainmentConfig == "" {
+			return nil, fmt.Errorf("hue %d: bridge, bridge_id, application_key, client_key and entertainment_config are required", i)
+		}
+		if h.From.Universe.Protocol.OutputOnly() {
+			return nil, fmt.Errorf("hue %d: %s is output-only", i, h.From.Universe.Protocol)
+		}
+		if len(h.Channels) == 0 || len(h.Channels) > 20 {
+			return nil, fmt.Errorf("hue %d: channels must list 1-20 entertainment channel ids", i)
+		}
+		for _, id := range h.Channels {
+			if id < 0 || id > 255 {
+				return nil, fmt.Errorf("hue %d: channel id %d out of range (0-255)", i, id)
+			}
+		}
+		if h.From.ChannelStart < 1 || h.From.ChannelStart+3*len(h.Channels)-1 > 512 {
+			return nil, fmt.Errorf("hue %d: from channels exceed 512", i)
+		}
+	}
+
+	for i, n := range cfg.NodeInputs {
+		if net.ParseIP(n.Address).To4() == nil {
+			return nil, fmt.Errorf("node_input %d: invalid address %q", i, n.Address)
+		}
+		if n.BindIndex < 0 || n.BindIndex > 255 {
+			return nil, fmt.Errorf("node_input %d: bind_index must be 0-255", i)
+		}
+		for _, port := range n.Disable {
+			if port < 1 || port > 4 {
+				return nil, fmt.Errorf("node_input %d: port %d out of range (1-4)", i, port)
+			}
+		}
+	}
+
+	if len(cfg.Groups) > MaxGroups {
+		return nil, fmt.Errorf("at most %d groups are supported", MaxGroups)
+	}
+	groups := map[string]bool{}
+	for i, g := range cfg.Groups {
+		switch {
+		case g.Name == "":
+			return nil, fmt.Errorf("group %d: name is required", i)
+		case g.Name == GrandMaster:
+			return nil, fmt.Errorf("group %d: %q is reserved for the grand master", i, GrandMaster)
+		case groups[g.Name]:
+			return nil, fmt.Errorf("group %d: duplicate name %q", i, g.Name)
+		case g.Master != nil && (*g.Master < 0 || *g.Master > 255):
+			return nil, fmt.Errorf("group %d: master must be 0-255", i)
+		}
+		groups[g.Name] = true
+	}
+
+	for i := range cfg.Mappings {
+		if err := validateMapping(&cfg.Mappings[i]); err != nil {
+			return nil, fmt.Errorf("mapping %d: %w", i, err)
+		}
+		if g := cfg.Mappings[i].Group; g != "" && !groups[g] {
+			return nil, fmt.Errorf("mapping %d: unknown group %q", i, g)
+		}
 	}
 
-	for i, m := range cfg.Mappings {
-		if m.From.ChannelStart < 1 || m.From.ChannelStart > 512 {
-			return nil, fmt.Errorf("mapping %d: from channel start must be 1-512", i)
+	if err := cfg.expandPipelines(groups); err != nil {
+		return nil, err
+	}
+
+	names, listens := map[string]bool{}, map[string]bool{}
+	for i := range cfg.Domains {
+		d := &cfg.Domains[i]
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("domain %d: %w", i, err)
 		}
-		if m.From.ChannelEnd < 1 || m.From.ChannelEnd > 512 {
-			return nil, fmt.Errorf("mapping %d: from channel end must be 1-512", i)
+		if names[d.Name] {
+			return nil, fmt.Errorf("domain %d: duplicate name %q", i, d.Name)
 		}
-		if m.From.ChannelStart > m.From.ChannelEnd {
-			return nil, fmt.Errorf("mapping %d: from channel start > end", i)
+		if listens[d.ArtNetListen] {
+			return nil, fmt.Errorf("domain %d: artnet_listen %s is used by another domain", i, d.ArtNetListen)
 		}
-		if m.To.ChannelStart < 1 || m.To.ChannelStart > 512 {
-			return nil, fmt.Errorf("mapping %d: to channel must be 1-512", i)
+		names[d.Name] = true
+		listens[d.ArtNetListen] = true
+	}
+
+	// A mirror must be the only source of its universe
+	written := map[Universe]bool{}
+	for _, m := range cfg.Mappings {
+		if m.From.Wildcard {
+			continue
+		}
+		for i := 0; i < m.From.Span(); i++ {
+			written[m.To.Universe.Offset(i)] = true
+		}
+	}
+	mirrored := map[Universe]bool{}
+	for i, o := range cfg.Outputs {
+		if o.Mirror == nil {
+			continue
 		}
-		toEnd := m.To.ChannelStart + m.From.Count() - 1
-		if toEnd > 512 {
-			return nil, fmt.Errorf("mapping %d: to channels exceed 512", i)
+		if written[*o.Mirror] || seenOutputs[*o.Mirror] || mirrored[*o.Mirror] {
+			return nil, fmt.Errorf("output %d: mirror %s is already an output universe", i, *o.Mirror)
 		}
+		mirrored[*o.Mirror] = true
+	}
+
+	profiles := cfg.Profiles()
+	if cfg.Profile == "" && len(profiles) > 0 {
+		cfg.Profile = profiles[0]
+	}
+	if cfg.Profile != "" && !slices.Contains(profiles, cfg.Profile) {
+		return nil, fmt.Errorf("profile %q has no mappings", cfg.Profile)
+	}
+	if err := cfg.validateTriggers(); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
 
-// NormalizedMapping is a processed mapping ready for the remapper
+// validateMapping checks one mapping's addresses, options and universe block.
+// A wildcard to with fixed from universes is resolved to its first universe here.
+func validateMapping(m *Mapping) error {
+	if m.From.Universe.Protocol.OutputOnly() {
+		return fmt.Errorf("%s is output-only", m.From.Universe.Protocol)
+	}
+	if err := validateWildcard(m); err != nil {
+		return err
+	}
+	for _, r := range m.From.Ranges {
+		if r.Start < 1 || r.Start > 512 {
+			return fmt.Errorf("from channel start must be 1-512")
+		}
+		if r.End < 1 || r.End > 512 {
+			return fmt.Errorf("from channel end must be 1-512")
+		}
+		if r.Start > r.End {
+			return fmt.Errorf("from channel start > end")
+		}
+	}
+	if m.To.ChannelStart < 1 || m.To.ChannelStart > 512 {
+		return fmt.Errorf("to channel must be 1-512")
+	}
+	if _, err := m.Transform(); err != nil {
+		return err
+	}
+	toEnd := m.To.ChannelStart + m.OutputCount() - 1
+	if toEnd > 512 {
+		return fmt.Errorf("to channels exceed 512")
+	}
+	if m.DelayMS < 0 || m.DelayMS > 10000 {
+		return fmt.Errorf("delay_ms must be 0-10000")
+	}
+	if m.Profile != strings.TrimSpace(m.Profile) {
+		return fmt.Errorf("invalid profile name %q", m.Profile)
+	}
+	if span := m.From.Span(); span > 1 && !m.From.Wildcard {
+		if m.To.Universe.Protocol == ProtocolUART {
+			return fmt.Errorf("uart destination cannot take a universe block")
+		}
+		if err := CheckSpan(m.To.Universe, span); err != nil {
+			return fmt.Errorf("from %s: expanding %w", m.From, err)
+		}
+	}
+	if p := m.FromPhysical; p != nil {
+		if *p < 0 || *p > 255 {
+			return fmt.Errorf("from_physical must be 0-255")
+		}
+		if m.From.Universe.Protocol != ProtocolArtNet {
+			return fmt.Errorf("from_physical requires an artnet source")
+		}
+		if m.From.Wildcard || m.To.Wildcard || m.From.Span() > 1 {
+			return fmt.Errorf("from_physical requires a single source universe")
+		}
+	}
+	switch Cast(m.FromCast) {
+	case "":
+	case CastUnicast, CastBroadcast, CastMulticast:
+		if m.From.Wildcard || m.To.Wildcard || m.From.Span() > 1 {
+			return fmt.Errorf("from_cast requires a single source universe")
+		}
+	default:
+		return fmt.Errorf("from_cast must be unicast, broadcast or multicast")
+	}
+	switch m.NZS {
+	case "", NZSDrop:
+	case NZSPass:
+		if m.From.Universe.Protocol != ProtocolArtNet || m.To.Universe.Protocol != ProtocolArtNet {
+			return fmt.Errorf("nzs = pass requires artnet source and destination")
+		}
+		if m.From.Wildcard || m.To.Wildcard || m.From.Span() > 1 {
+			return fmt.Errorf("nzs = pass requires a single source universe")
+		}
+	default:
+		return fmt.Errorf("nzs must be pass or drop")
+	}
+	return nil
+}
+
+// validateWildcard checks wildcard addresses and the offset, and resolves a
+// wildcard to whose from is a fixed universe or block
+func validateWildcard(m *Mapping) error {
+	if !m.To.Wildcard {
+		if m.From.Wildcard {
+			return fmt.Errorf("wildcard from requires a wildcard to")
+		}
+		if m.Offset != 0 {
+			return fmt.Errorf("offset requires a wildcard to")
+		}
+		return nil
+	}
+	if !gatewayable(m.From.Universe.Protocol) || !gatewayable(m.To.Universe.Protocol) {
+		return fmt.Errorf("wildcards only pass artnet and sacn universes through")
+	}
+	if m.From.Wildcard {
+		if m.From.Universe.Protocol == m.To.Universe.Protocol {
+			return fmt.Errorf("wildcard mapping cannot send %s back to %s", m.From.Universe.Protocol, m.To.Universe.Protocol)
+		}
+		return nil
+	}
+	first := int(m.From.Universe.Number) + m.Offset
+	to, err := makeUniverse(m.To.Universe.Protocol, first)
+	if err != nil {
+		return fmt.Errorf("from %s with offset %+d: to %w", m.From, m.Offset, err)
+	}
+	if err := CheckSpan(to, m.From.Span()); err != nil {
+		return fmt.Errorf("from %s with offset %+d: expanding %w", m.From, m.Offset, err)
+	}
+	m.To.Universe.Number = to.Number
+	return nil
+}
+
+// NormalizedMapping is a processed mapping ready for the remapper.
+// Span > 1 applies the mapping to Span consecutive universes, shifting To by the same offset.
+// A non-nil Transform converts the Count source channels instead of copying them.
+// Wildcard mappings only give the From and To protocols and are applied via Resolve.
 type NormalizedMapping struct {
-	From       Universe
-	FromChan   int // 0-indexed
-	To         Universe
-	ToChan     int // 0-indexed
-	Count      int
+	From      Universe
+	FromChan  int // 0-indexed
+	To        Universe
+	ToChan    int // 0-indexed
+	Count     int
+	Span      int
+	Delay     time.Duration
+	Transform transform.Transform
+	Index     int // position of the source [[mapping]] in the config
+	Wildcard  bool
+	Offset    int
+	Group     string // level group, empty for none
+	Physical  *uint8 // ArtDmx physical port source frames must come from, nil for any
+	Cast      Cast   // delivery source frames must arrive by, empty for any
+}
+
+// OutputCount returns the number of destination channels written
+func (m NormalizedMapping) OutputCount() int {
+	if m.Transform != nil {
+		return m.Transform.OutputCount()
+	}
+	return m.Count
+}
+
+// Expand returns one single-universe mapping per universe in the span
+func (m NormalizedMapping) Expand() []NormalizedMapping {
+	if m.Span <= 1 {
+		return []NormalizedMapping{m}
+	}
+	result := make([]NormalizedMapping, m.Span)
+	for i := range result {
+		result[i] = m
+		result[i].From = m.From.Offset(i)
+		result[i].To = m.To.Offset(i)
+		result[i].Span = 0
+	}
+	return result
+}
+
+// Resolve returns the wildcard mapping applied to source universe src, reporting
+// false if src is another protocol. The error names a src the mapping applies to
+// whose destination would be beyond the output protocol's range.
+func (m NormalizedMapping) Resolve(src Universe) (NormalizedMapping, bool, error) {
+	if !m.Wildcard || src.Protocol != m.From.Protocol {
+		return NormalizedMapping{}, false, nil
+	}
+	to, err := makeUniverse(m.To.Protocol, int(src.Number)+m.Offset)
+	if err != nil {
+		return NormalizedMapping{}, false, fmt.Errorf("from %s with offset %+d: to %w", src, m.Offset, err)
+	}
+	m.From, m.To = src, to
+	m.Wildcard, m.Offset = false, 0
+	return m, true, nil
+}
+
+// Wildcard reports whether any mapping takes every received universe of proto
+func (c *Config) Wildcard(proto Protocol) bool {
+	for _, m := range c.Mappings {
+		if m.From.Wildcard && m.From.Universe.Protocol == proto {
+			return true
+		}
+	}
+	return false
 }
 
-// Normalize converts config mappings to normalized form (0-indexed channels)
+// Profiles returns the mapping profile names in config order
+func (c *Config) Profiles() []string {
+	var result []string
+	for _, m := range c.Mappings {
+		if m.Profile != "" && !slices.Contains(result, m.Profile) {
+			result = append(result, m.Profile)
+		}
+	}
+	return result
+}
+
+// Normalize converts the mappings of the startup profile to normalized form
 func (c *Config) Normalize() []NormalizedMapping {
-	result := make([]NormalizedMapping, len(c.Mappings))
+	return c.NormalizeProfile(c.Profile)
+}
+
+// NormalizeProfile converts the shared mappings and those of the named profile
+// to normalized form (0-indexed channels). Channel lists are packed sequentially
+// at the destination, one entry per range.
+func (c *Config) NormalizeProfile(profile string) []NormalizedMapping {
+	var result []NormalizedMapping
 	for i, m := range c.Mappings {
-		result[i] = NormalizedMapping{
-			From:     m.From.Universe,
-			FromChan: m.From.ChannelStart - 1,
-			To:       m.To.Universe,
-			ToChan:   m.To.ChannelStart - 1,
-			Count:    m.From.Count(),
+		if m.Profile != "" && m.Profile != profile {
+			continue
+		}
+		toChan := m.To.ChannelStart - 1
+		if t, _ := m.Transform(); t != nil {
+			result = append(result, NormalizedMapping{
+				From:      m.From.Universe,
+				FromChan:  m.From.Ranges[0].Start - 1,
+				To:        m.To.Universe,
+				ToChan:    toChan,
+				Count:     t.InputCount(),
+				Span:      m.From.UniverseCount,
+				Delay:     time.Duration(m.DelayMS) * time.Millisecond,
+				Transform: t,
+				Index:     i,
+				Wildcard:  m.From.Wildcard,
+				Offset:    m.Offset,
+				Group:     m.Group,
+				Physical:  m.physical(),
+				Cast:      Cast(m.FromCast),
+			})
+			continue
+		}
+		for _, r := range m.From.Ranges {
+			count := r.End - r.Start + 1
+			result = append(result, NormalizedMapping{
+				From:     m.From.Universe,
+				FromChan: r.Start - 1,
+				To:       m.To.Universe,
+				ToChan:   toChan,
+				Count:    count,
+				Span:     m.From.UniverseCount,
+				Delay:    time.Duration(m.DelayMS) * time.Millisecond,
+				Index:    i,
+				Wildcard: m.From.Wildcard,
+				Offset:   m.Offset,
+				Group:    m.Group,
+				Physical: m.physical(),
+				Cast:     Cast(m.FromCast),
+			})
+			toChan += count
+		}
+	}
+	return result
+}
+
+// MinIntervals returns the minimum time between frames for rate-limited output universes
+func (c *Config) MinIntervals() map[Universe]time.Duration {
+	result := map[Universe]time.Duration{}
+	for _, o := range c.Outputs {
+		if o.MaxHz > 0 {
+			result[o.Universe] = time.Duration(float64(time.Second) / o.MaxHz)
+		}
+	}
+	return result
+}
+
+// DefaultValues returns per-channel defaults (0-indexed) for output universes that declare them
+func (c *Config) DefaultValues() map[Universe]map[int]byte {
+	result := map[Universe]map[int]byte{}
+	for _, o := range c.Outputs {
+		if values, _ := o.DefaultValues(); len(values) > 0 {
+			result[o.Universe] = values
+		}
+	}
+	return result
+}
+
+// SamplePeriods returns how long outputs are held when input on a source universe starts
+func (c *Config) SamplePeriods() map[Universe]time.Duration {
+	result := map[Universe]time.Duration{}
+	for _, in := range c.Inputs {
+		if in.SampleMS > 0 {
+			result[in.Universe] = time.Duration(in.SampleMS) * time.Millisecond
+		}
+	}
+	return result
+}
+
+// HeldShortFrames returns the input universes whose short ArtDmx frames keep the channels past their length
+func (c *Config) HeldShortFrames() map[Universe]bool {
+	result := map[Universe]bool{}
+	for _, in := range c.Inputs {
+		if in.ShortFrames == ShortFramesHold {
+			result[in.Universe] = true
 		}
 	}
 	return result
 }
 
+// NZSRoutes returns the destination universes ArtNzs packets on each source
+// universe are passed to
+func (c *Config) NZSRoutes() map[Universe][]Universe {
+	result := map[Universe][]Universe{}
+	for _, m := range c.Mappings {
+		if m.NZS == NZSPass && !slices.Contains(result[m.From.Universe], m.To.Universe) {
+			result[m.From.Universe] = append(result[m.From.Universe], m.To.Universe)
+		}
+	}
+	return result
+}
+
+// SACNSources returns the source IPs of sACN input universes restricted to
+// source-specific multicast joins
+func (c *Config) SACNSources() map[uint16][]net.IP {
+	result := map[uint16][]net.IP{}
+	for _, in := range c.Inputs {
+		if in.Universe.Protocol != ProtocolSACN {
+			continue
+		}
+		for _, src := range in.Sources {
+			result[in.Universe.Number] = append(result[in.Universe.Number], net.ParseIP(src).To4())
+		}
+	}
+	return result
+}
+
+// validateLabels checks that every universe label names one universe, also
+// once reduced to its LabelKey, and that an input and output of the same
+// universe agree on it
+func (c *Config) validateLabels() error {
+	labels := map[Universe]string{}
+	keys := map[string]Universe{}
+	check := func(what string, i int, u Universe, label string) error {
+		if label == "" {
+			return nil
+		}
+		key := LabelKey(label)
+		switch {
+		case key == "":
+			return fmt.Errorf("%s %d: label %q needs a letter or digit", what, i, label)
+		case labels[u] != "" && labels[u] != label:
+			return fmt.Errorf("%s %d: label %q differs from %s's label %q", what, i, label, u, labels[u])
+		}
+		if other, ok := keys[key]; ok && other != u {
+			return fmt.Errorf("%s %d: label %q is already used by %s", what, i, label, other)
+		}
+		labels[u], keys[key] = label, u
+		return nil
+	}
+	for i, in := range c.Inputs {
+		if err := check("input", i, in.Universe, in.Label); err != nil {
+			return err
+		}
+	}
+	for i, o := range c.Outputs {
+		if err := check("output", i, o.Universe, o.Label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LabelKey reduces a label to lowercase words joined by underscores, e.g.
+// "FOH Truss (L)" to foh_truss_l, for comparison and metric names
+func LabelKey(label string) string {
+	words := strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+	return strings.Join(words, "_")
+}
+
+// Labels returns the label of every labelled input and output universe
+func (c *Config) Labels() map[Universe]string {
+	result := map[Universe]string{}
+	for _, in := range c.Inputs {
+		if in.Label != "" {
+			result[in.Universe] = in.Label
+		}
+	}
+	for _, o := range c.Outputs {
+		if o.Label != "" {
+			result[o.Universe] = o.Label
+		}
+	}
+	return result
+}
+
+// Fallbacks returns the fallback chain of ArtNet outputs that set one
+func (c *Config) Fallbacks() map[Universe][]string {
+	result := map[Universe][]string{}
+	for _, o := range c.Outputs {
+		if len(o.Fallback) > 0 {
+			result[o.Universe] = o.Fallback
+		}
+	}
+	return result
+}
+
+// Mirrors returns the universe each mirrored output is also sent to
+func (c *Config) Mirrors() map[Universe]Universe {
+	result := map[Universe]Universe{}
+	for _, o := range c.Outputs {
+		if o.Mirror != nil {
+			result[o.Universe] = *o.Mirror
+		}
+	}
+	return result
+}
+
+// SACNPriorities returns the priority of outputs that set one, keyed by sACN universe number
+func (c *Config) SACNPriorities() map[uint16]uint8 {
+	result := map[uint16]uint8{}
+	for _, o := range c.Outputs {
+		if u, ok := o.sacnUniverse(); ok && o.Priority > 0 {
+			result[u.Number] = uint8(o.Priority)
+		}
+	}
+	return result
+}
+
+// gatewayable reports whether p carries universes that can be bridged between artnet and sacn
+func gatewayable(p Protocol) bool {
+	return p == ProtocolArtNet || p == ProtocolSACN
+}
+
 // SACNSourceUniverses returns sACN universe numbers that need input
 func (c *Config) SACNSourceUniverses() []uint16 {
 	seen := make(map[uint16]bool)
 	for _, m := range c.Mappings {
-		if m.From.Universe.Protocol == ProtocolSACN {
-			seen[m.From.Universe.Number] = true
+		if m.From.Universe.Protocol == ProtocolSACN && !m.From.Wildcard {
+			for i := 0; i < m.From.Span(); i++ {
+				seen[m.From.Universe.Offset(i).Number] = true
+			}
+		}
+	}
+	for _, h := range c.Hue {
+		if h.From.Universe.Protocol == ProtocolSACN {
+			seen[h.From.Universe.Number] = true
 		}
 	}
 	result := make([]uint16, 0, len(seen))
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -10,9 +11,17 @@ func FuzzParseUniverse(f *testing.F) {
 	f.Add("artnet:127.15.15")
 	f.Add("artnet:0")
 	f.Add("artnet:32767")
+	f.Add("artnet:0x1A3")
+	f.Add("artnet:0X7fff")
 	f.Add("sacn:1")
 	f.Add("sacn:63999")
 	f.Add("sacn:100")
+	f.Add("espnet:0")
+	f.Add("espnet:255")
+	f.Add("espnet:256")
+	f.Add("uart:/dev/ttyAMA0")
+	f.Add("uart:ttyAMA0")
+	f.Add("uart:")
 	f.Add("")
 	f.Add("invalid")
 	f.Add("artnet:")
@@ -21,6 +30,10 @@ func FuzzParseUniverse(f *testing.F) {
 	f.Add("artnet:-1")
 	f.Add("sacn:0")
 	f.Add("sacn:64000")
+	f.Add("sacn:70000")
+	f.Add("artnet:65536")
+	f.Add("artnet:0.0.16")
+	f.Add("artnet:128.0.0")
 
 	f.Fuzz(func(t *testing.T, input string) {
 		u, err := ParseUniverse(input)
@@ -52,6 +65,14 @@ func FuzzFromAddrParse(f *testing.F) {
 	f.Add("artnet:0.0.0:513")
 	f.Add("artnet:0.0.0:-1")
 	f.Add("artnet:0.0.0:abc")
+	f.Add("artnet:0.0.1:1,5,9-12,100")
+	f.Add("sacn:1:1,")
+	f.Add("sacn:1:10-20,1-5")
+	f.Add("sacn:101-164")
+	f.Add("artnet:1.0.0-1.3.15:1-10")
+	f.Add("sacn:164-101")
+	f.Add("sacn:*")
+	f.Add("artnet:*:1-10")
 
 	f.Fuzz(func(t *testing.T, input string) {
 		var addr FromAddr
@@ -59,11 +80,15 @@ func FuzzFromAddrParse(f *testing.F) {
 		if err != nil {
 			return
 		}
-		if addr.ChannelStart > addr.ChannelEnd {
-			t.Fatalf("ChannelStart > ChannelEnd: %d > %d", addr.ChannelStart, addr.ChannelEnd)
+		for _, r := range addr.Ranges {
+			if r.Start > r.End {
+				t.Fatalf("Start > End: %d > %d", r.Start, r.End)
+			}
 		}
-		if addr.ChannelStart < 1 || addr.ChannelEnd > 512 {
-			return
+		for _, r := range addr.Ranges {
+			if r.Start < 1 || r.End > 512 {
+				return
+			}
 		}
 		s := addr.String()
 		var addr2 FromAddr
@@ -73,12 +98,51 @@ func FuzzFromAddrParse(f *testing.F) {
 	})
 }
 
+func TestFromAddrJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		start int
+		end   int
+	}{
+		{"artnet:0.0.1", 1, 512},
+		{"artnet:0.0.1:50-100", 50, 100},
+		{"artnet:0.0.1:1,5,9-12", 0, 0},
+	}
+	for _, tt := range tests {
+		var addr FromAddr
+		if err := addr.parse(tt.input); err != nil {
+			t.Fatalf("parse %q: %v", tt.input, err)
+		}
+		data, err := json.Marshal(addr)
+		if err != nil {
+			t.Fatalf("marshal %q: %v", tt.input, err)
+		}
+		var got struct {
+			ChannelStart int            `json:"channel_start"`
+			ChannelEnd   int            `json:"channel_end"`
+			Ranges       []ChannelRange `json:"ranges"`
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if got.ChannelStart != tt.start || got.ChannelEnd != tt.end {
+			t.Errorf("%q: channel_start/channel_end = %d/%d, want %d/%d", tt.input, got.ChannelStart, got.ChannelEnd, tt.start, tt.end)
+		}
+		if len(got.Ranges) != len(addr.Ranges) {
+			t.Errorf("%q: %d ranges, want %d", tt.input, len(got.Ranges), len(addr.Ranges))
+		}
+	}
+}
+
 func FuzzToAddrParse(f *testing.F) {
 	f.Add("artnet:0.0.0")
 	f.Add("artnet:0.0.1:1")
 	f.Add("artnet:0.0.1:512")
 	f.Add("sacn:1:100")
 	f.Add("sacn:100")
+	f.Add("uart:/dev/ttyAMA0:10")
+	f.Add("artnet:*")
+	f.Add("sacn:*:10")
 	f.Add("")
 	f.Add("artnet:0.0.0:0")
 	f.Add("artnet:0.0.0:1-100")
@@ -108,15 +172,18 @@ func FuzzParseUniverseNumber(f *testing.F) {
 	f.Add("32767", string(ProtocolArtNet))
 	f.Add("1", string(ProtocolSACN))
 	f.Add("63999", string(ProtocolSACN))
+	f.Add("255", string(ProtocolESPNet))
 	f.Add("", string(ProtocolArtNet))
 	f.Add("invalid", string(ProtocolArtNet))
 	f.Add("0.0", string(ProtocolArtNet))
 	f.Add("0.0.0.0", string(ProtocolArtNet))
 	f.Add("-1", string(ProtocolArtNet))
+	f.Add("0x1A3", string(ProtocolArtNet))
+	f.Add("0x", string(ProtocolSACN))
 
 	f.Fuzz(func(t *testing.T, input string, protoStr string) {
 		proto := Protocol(protoStr)
-		if proto != ProtocolArtNet && proto != ProtocolSACN {
+		if proto != ProtocolArtNet && proto != ProtocolSACN && proto != ProtocolESPNet {
 			return
 		}
 		_, _ = parseUniverseNumber(input, proto)
@@ -151,3 +218,34 @@ func FuzzParseChannelRange(f *testing.F) {
 		}
 	})
 }
+
+func FuzzCheckSpan(f *testing.F) {
+	f.Add("sacn:63990", 10)
+	f.Add("sacn:63990", 11)
+	f.Add("artnet:127.15.0", 16)
+	f.Add("artnet:127.15.0", 17)
+	f.Add("espnet:250", 6)
+	f.Add("uart:ttyAMA0", 1)
+	f.Add("uart:ttyAMA0", 2)
+
+	f.Fuzz(func(t *testing.T, input string, count int) {
+		u, err := ParseUniverse(input)
+		if err != nil || count < 1 || count > 1<<16 {
+			return
+		}
+		err = CheckSpan(u, count)
+		if u.Protocol == ProtocolUART {
+			if (err == nil) != (count == 1) {
+				t.Fatalf("CheckSpan(%s, %d) = %v", u, count, err)
+			}
+			return
+		}
+		_, hi, _ := UniverseRange(u.Protocol)
+		if (err == nil) != (int(u.Number)+count-1 <= hi) {
+			t.Fatalf("CheckSpan(%s, %d) = %v", u, count, err)
+		}
+		if err == nil && int(u.Offset(count-1).Number) != int(u.Number)+count-1 {
+			t.Fatalf("Offset(%d) of %s = %s", count-1, u, u.Offset(count-1))
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CurrentVersion is the config schema version written by this release
+const CurrentVersion = 2
+
+// migrations upgrade a raw config from the keyed version to the next one
+var migrations = map[int]func(raw map[string]any) []string{
+	1: migrateV1,
+}
+
+// decodeFile decodes a config file, upgrading older schema versions in memory
+func decodeFile(path string, cfg *Config) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if v, ok := raw["version"]; ok {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("version must be an integer")
+		}
+		version = int(n)
+	}
+	if version < 1 || version > CurrentVersion {
+		return nil, fmt.Errorf("unsupported config version %d (max %d)", version, CurrentVersion)
+	}
+
+	var warnings []string
+	for v := version; v < CurrentVersion; v++ {
+		warnings = append(warnings, migrations[v](raw)...)
+	}
+
+	if len(warnings) == 0 {
+		_, err := toml.Decode(string(data), cfg)
+		cfg.Version = CurrentVersion
+		return nil, err
+	}
+
+	warnings = append(warnings, fmt.Sprintf("config migrated from version %d to %d in memory; update the file and set version = %d", version, CurrentVersion, CurrentVersion))
+	raw["version"] = int64(CurrentVersion)
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return nil, err
+	}
+	if _, err := toml.Decode(buf.String(), cfg); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// migrateV1 adds the artnet: prefix to universe addresses written before protocol prefixes existed
+func migrateV1(raw map[string]any) []string {
+	var warnings []string
+	fix := func(section string, i int, table map[string]any, key string) {
+		var s string
+		switch v := table[key].(type) {
+		case string:
+			if _, _, err := splitProtoPrefix(v); err == nil {
+				return
+			}
+			s = v
+		case int64:
+			s = fmt.Sprintf("%d", v)
+		default:
+			return
+		}
+		table[key] = "artnet:" + s
+		warnings = append(warnings, fmt.Sprintf("%s %d: %s %q assumed to be %q", section, i, key, s, table[key]))
+	}
+
+	for i, t := range tables(raw["target"]) {
+		fix("target", i, t, "universe")
+	}
+	for i, m := range tables(raw["mapping"]) {
+		fix("mapping", i, m, "from")
+		fix("mapping", i, m, "to")
+	}
+	return warnings
+}
+
+func tables(v any) []map[string]any {
+	switch t := v.(type) {
+	case []map[string]any:
+		return t
+	case []any:
+		result := make([]map[string]any, 0, len(t))
+		for _, item := range t {
+			if m, ok := item.(map[string]any); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	}
+	return nil
+}
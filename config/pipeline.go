@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/gopatchy/artmap/transform"
+)
+
+// Pipeline declares a chain as one unit: a source selector, transform stages
+// applied in order and outputs that each get a copy of the result. A universe
+// block or wildcard in from reuses the chain across universes, and outputs
+// shared with other pipelines or mappings merge as mappings do. Load expands
+// each pipeline into one mapping per output.
+type Pipeline struct {
+	Name    string   `toml:"name" json:"name"`
+	From    FromAddr `toml:"from" json:"from"`
+	Stages  []Stage  `toml:"stage" json:"stages,omitempty"`
+	To      []ToAddr `toml:"to" json:"to"`
+	DelayMS int      `toml:"delay_ms" json:"delay_ms,omitempty"`
+	Offset  int      `toml:"offset" json:"offset,omitempty"` // added to universe numbers passed through a wildcard to
+	Profile string   `toml:"profile" json:"profile,omitempty"`
+	Group   string   `toml:"group" json:"group,omitempty"`
+}
+
+// Stage is one transform of a pipeline: exactly one of resample, rgbw or curve
+type Stage struct {
+	Resample   string      `toml:"resample" json:"resample,omitempty"`
+	ToPixels   int         `toml:"to_pixels" json:"to_pixels,omitempty"`
+	PixelSize  int         `toml:"pixel_size" json:"pixel_size,omitempty"`
+	RGBW       string      `toml:"rgbw" json:"rgbw,omitempty"`
+	RGBWMatrix [][]float64 `toml:"rgbw_matrix" json:"rgbw_matrix,omitempty"`
+	Curve      string      `toml:"curve" json:"curve,omitempty"`
+	Gamma      float64     `toml:"gamma" json:"gamma,omitempty"`
+}
+
+// transform builds the stage for count input channels
+func (s *Stage) transform(count int) (transform.Transform, error) {
+	kinds := 0
+	for _, set := range []bool{s.Resample != "", s.RGBW != "", s.Curve != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("a stage must set exactly one of resample, rgbw or curve")
+	}
+
+	switch {
+	case s.Resample != "":
+		pixelSize := s.PixelSize
+		if pixelSize == 0 {
+			pixelSize = 3
+		}
+		if count%pixelSize != 0 {
+			return nil, fmt.Errorf("from channel count %d is not a multiple of pixel_size %d", count, pixelSize)
+		}
+		if s.ToPixels < 1 {
+			return nil, fmt.Errorf("resample requires to_pixels")
+		}
+		return transform.NewResample(transform.ResampleMode(s.Resample), count/pixelSize, s.ToPixels, pixelSize)
+	case s.RGBW != "":
+		if count%3 != 0 {
+			return nil, fmt.Errorf("rgbw input channel count %d is not a multiple of 3", count)
+		}
+		return transform.NewRGBW(transform.RGBWMode(s.RGBW), count/3, s.RGBWMatrix)
+	}
+	gamma := s.Gamma
+	if gamma == 0 {
+		gamma = 2.2
+	}
+	return transform.NewCurve(transform.CurveMode(s.Curve), count, gamma)
+}
+
+// mapping returns the pipeline's mapping to one output
+func (p *Pipeline) mapping(to ToAddr) Mapping {
+	return Mapping{
+		From:     p.From,
+		To:       to,
+		DelayMS:  p.DelayMS,
+		Offset:   p.Offset,
+		Profile:  p.Profile,
+		Group:    p.Group,
+		Label:    p.Name,
+		Stages:   p.Stages,
+		Pipeline: p.Name,
+	}
+}
+
+// expandPipelines validates the pipelines and appends their mappings
+func (c *Config) expandPipelines(groups map[string]bool) error {
+	names := map[string]bool{}
+	for i := range c.Pipelines {
+		p := &c.Pipelines[i]
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("pipeline %d: name is required", i)
+		case names[p.Name]:
+			return fmt.Errorf("pipeline %d: duplicate name %q", i, p.Name)
+		case len(p.To) == 0:
+			return fmt.Errorf("pipeline %q: to must list at least one output", p.Name)
+		case p.Group != "" && !groups[p.Group]:
+			return fmt.Errorf("pipeline %q: unknown group %q", p.Name, p.Group)
+		}
+		names[p.Name] = true
+		for _, to := range p.To {
+			m := p.mapping(to)
+			if err := validateMapping(&m); err != nil {
+				return fmt.Errorf("pipeline %q to %s: %w", p.Name, to, err)
+			}
+			c.Mappings = append(c.Mappings, m)
+		}
+	}
+	return nil
+}
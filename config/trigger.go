@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Trigger actions
+const (
+	TriggerProfile  = "profile"  // switch to Profile, fading over FadeMS
+	TriggerBlackout = "blackout" // hold every output channel at zero
+	TriggerRestore  = "restore"  // end a blackout
+	TriggerSnapshot = "snapshot" // recall Snapshot
+	TriggerStandby  = "standby"  // change over to the --standby-config, fading over FadeMS
+	TriggerRevert   = "revert"   // change back to the running config, fading over FadeMS
+)
+
+// Trigger binds an ArtTrigger key and sub-key, such as a console macro, to an
+// action. Keys are 0 (ASCII), 1 (macro), 2 (soft key) and 3 (show); the
+// sub-key is the character, macro, soft key or show number.
+type Trigger struct {
+	Key      int    `toml:"key" json:"key"`
+	SubKey   int    `toml:"sub_key" json:"sub_key"`
+	Action   string `toml:"action" json:"action"`
+	Profile  string `toml:"profile" json:"profile,omitempty"`
+	FadeMS   int    `toml:"fade_ms" json:"fade_ms,omitempty"`
+	Snapshot string `toml:"snapshot" json:"snapshot,omitempty"`
+}
+
+// TriggerKey identifies the ArtTrigger packets a Trigger responds to
+type TriggerKey struct {
+	Key, SubKey uint8
+}
+
+// validateTriggers checks the triggers once the profiles are known
+func (c *Config) validateTriggers() error {
+	seen := map[TriggerKey]bool{}
+	for i, t := range c.Triggers {
+		if t.Key < 0 || t.Key > 255 || t.SubKey < 0 || t.SubKey > 255 {
+			return fmt.Errorf("trigger %d: key and sub_key must be 0-255", i)
+		}
+		k := TriggerKey{Key: uint8(t.Key), SubKey: uint8(t.SubKey)}
+		if seen[k] {
+			return fmt.Errorf("trigger %d: key %d sub_key %d is already bound", i, t.Key, t.SubKey)
+		}
+		seen[k] = true
+		switch t.Action {
+		case TriggerProfile:
+			if !slices.Contains(c.Profiles(), t.Profile) {
+				return fmt.Errorf("trigger %d: profile %q has no mappings", i, t.Profile)
+			}
+			if t.FadeMS < 0 || t.FadeMS > 600000 {
+				return fmt.Errorf("trigger %d: fade_ms must be 0-600000", i)
+			}
+		case TriggerSnapshot:
+			if t.Snapshot == "" {
+				return fmt.Errorf("trigger %d: snapshot needs a snapshot name", i)
+			}
+		case TriggerStandby, TriggerRevert:
+			if t.FadeMS < 0 || t.FadeMS > 600000 {
+				return fmt.Errorf("trigger %d: fade_ms must be 0-600000", i)
+			}
+		case TriggerBlackout, TriggerRestore:
+		default:
+			return fmt.Errorf("trigger %d: action must be profile, blackout, restore, snapshot, standby or revert", i)
+		}
+	}
+	return nil
+}
+
+// TriggerActions returns the triggers by the key and sub-key they respond to
+func (c *Config) TriggerActions() map[TriggerKey]Trigger {
+	result := map[TriggerKey]Trigger{}
+	for _, t := range c.Triggers {
+		result[TriggerKey{Key: uint8(t.Key), SubKey: uint8(t.SubKey)}] = t
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package datagram
+
+import (
+	"log"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Largest and smallest datagrams of each protocol. ArtTodData with a full
+// block of 200 UIDs is the largest Art-Net 4 packet and the ID and opcode the
+// least that can be parsed; sACN universe discovery with 512 universes is the
+// largest E1.31 packet and sACN sync the smallest.
+const (
+	ArtNetMax = 1228
+	ArtNetMin = 10
+	SACNMax   = 1144
+	SACNMin   = 49
+)
+
+// Fault is why a datagram was dropped before parsing
+type Fault string
+
+const (
+	// Oversized datagrams are larger than the protocol allows; the receive
+	// buffer has one spare byte, so they arrive truncated and are not parsed
+	Oversized Fault = "oversized"
+	// Undersized datagrams are too short to hold a packet header
+	Undersized Fault = "undersized"
+)
+
+// Buffer returns a receive buffer one byte larger than maxSize, so a datagram
+// filling it is known to have been truncated
+func Buffer(maxSize int) []byte {
+	return make([]byte, maxSize+1)
+}
+
+// Check returns the fault of a datagram of n bytes read into a Buffer(maxSize)
+func Check(n, minSize, maxSize int) Fault {
+	switch {
+	case n > maxSize:
+		return Oversized
+	case n < minSize:
+		return Undersized
+	}
+	return ""
+}
+
+// Stats are the datagrams dropped from one source as reported by the API
+type Stats struct {
+	Protocol   string `json:"protocol"`
+	IP         string `json:"ip"`
+	Oversized  uint64 `json:"oversized"`
+	Undersized uint64 `json:"undersized"`
+}
+
+type key struct {
+	protocol string
+	ip       string
+}
+
+// Counter counts dropped datagrams per protocol and source IP
+type Counter struct {
+	mu      sync.Mutex
+	sources map[key]*Stats
+}
+
+func NewCounter() *Counter {
+	return &Counter{sources: map[key]*Stats{}}
+}
+
+// Add counts a datagram of n bytes from ip, logging the first of each fault per source
+func (c *Counter) Add(protocol string, ip net.IP, n int, fault Fault) {
+	k := key{protocol: protocol, ip: ip.String()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.sources[k]
+	if s == nil {
+		s = &Stats{Protocol: protocol, IP: k.ip}
+		c.sources[k] = s
+	}
+	count := &s.Undersized
+	if fault == Oversized {
+		count = &s.Oversized
+	}
+	if *count == 0 {
+		log.Printf("[%s] %s datagrams: src=%s bytes=%d", protocol, fault, ip, n)
+	}
+	*count++
+}
+
+// Stats returns every source with dropped datagrams, sorted by protocol and IP
+func (c *Counter) Stats() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]Stats, 0, len(c.sources))
+	for _, s := range c.sources {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
@@ -0,0 +1,86 @@
+package debuglog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// maxDiffChannels caps how many changed channels are listed on one line
+const maxDiffChannels = 32
+
+type diffKey struct {
+	dir string
+	u   config.Universe
+}
+
+type diffState struct {
+	data   [512]byte
+	logged time.Time
+	seen   bool
+}
+
+// Differ reports which channels changed since the last logged frame of each
+// universe, logging each universe at most once per interval. Changes made while
+// rate-limited accumulate into the next diff, so none are lost.
+type Differ struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[diffKey]*diffState
+}
+
+func NewDiffer(interval time.Duration) *Differ {
+	return &Differ{
+		interval: interval,
+		last:     map[diffKey]*diffState{},
+	}
+}
+
+// Diff returns a description of the changed channels, e.g. "changed=2 10:0→255 11:0→128",
+// or false if nothing changed since the first frame or the universe was logged less than an interval ago
+func (d *Differ) Diff(dir string, u config.Universe, data [512]byte) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := diffKey{dir, u}
+	st := d.last[key]
+	if st == nil {
+		st = &diffState{}
+		d.last[key] = st
+	}
+
+	now := time.Now()
+	if now.Sub(st.logged) < d.interval {
+		return "", false
+	}
+
+	s, n := formatDiff(st.data, data)
+	if n == 0 && st.seen {
+		return "", false
+	}
+	st.data = data
+	st.seen = true
+	st.logged = now
+	return s, true
+}
+
+func formatDiff(old, cur [512]byte) (string, int) {
+	var b strings.Builder
+	n := 0
+	for i := range cur {
+		if old[i] == cur[i] {
+			continue
+		}
+		n++
+		if n <= maxDiffChannels {
+			fmt.Fprintf(&b, " %d:%d→%d", i+1, old[i], cur[i])
+		}
+	}
+	if n > maxDiffChannels {
+		fmt.Fprintf(&b, " +%d more", n-maxDiffChannels)
+	}
+	return fmt.Sprintf("changed=%d%s", n, b.String()), n
+}
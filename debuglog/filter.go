@@ -0,0 +1,110 @@
+package debuglog
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Filter selects which packets are logged by universe and/or IP; a bare --debug matches everything
+type Filter struct {
+	enabled   bool
+	universes map[config.Universe]bool
+	ips       map[string]bool
+}
+
+func (f *Filter) IsBoolFlag() bool {
+	return true
+}
+
+func (f *Filter) String() string {
+	if f == nil || !f.enabled {
+		return "false"
+	}
+	if len(f.universes) == 0 && len(f.ips) == 0 {
+		return "true"
+	}
+	var parts []string
+	for u := range f.universes {
+		parts = append(parts, u.String())
+	}
+	for ip := range f.ips {
+		parts = append(parts, ip)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (f *Filter) Set(s string) error {
+	f.universes = nil
+	f.ips = nil
+
+	switch s {
+	case "", "true":
+		f.enabled = true
+		return nil
+	case "false":
+		f.enabled = false
+		return nil
+	}
+
+	universes := map[config.Universe]bool{}
+	ips := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if ip := net.ParseIP(item); ip != nil {
+			ips[ip.String()] = true
+			continue
+		}
+		u, err := config.ParseUniverse(item)
+		if err != nil {
+			return fmt.Errorf("invalid debug filter %q: expected universe or IP", item)
+		}
+		universes[u] = true
+	}
+
+	f.enabled = true
+	if len(universes) > 0 {
+		f.universes = universes
+	}
+	if len(ips) > 0 {
+		f.ips = ips
+	}
+	return nil
+}
+
+func (f *Filter) Enabled() bool {
+	return f != nil && f.enabled
+}
+
+// Match reports whether a packet for universe u to/from ip should be logged
+func (f *Filter) Match(u config.Universe, ip net.IP) bool {
+	if !f.Enabled() {
+		return false
+	}
+	if f.universes != nil && !f.universes[u] {
+		return false
+	}
+	return f.matchIP(ip)
+}
+
+// MatchIP reports whether a packet without a universe (e.g. ArtPoll) should be logged
+func (f *Filter) MatchIP(ip net.IP) bool {
+	if !f.Enabled() || f.universes != nil {
+		return false
+	}
+	return f.matchIP(ip)
+}
+
+func (f *Filter) matchIP(ip net.IP) bool {
+	if f.ips == nil {
+		return true
+	}
+	return ip != nil && f.ips[ip.String()]
+}
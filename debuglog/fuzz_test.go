@@ -0,0 +1,114 @@
+package debuglog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzFilterSet(f *testing.F) {
+	f.Add("true")
+	f.Add("false")
+	f.Add("")
+	f.Add("sacn:5")
+	f.Add("artnet:0.0.1,sacn:5")
+	f.Add("10.0.0.5")
+	f.Add("sacn:1,10.0.0.5,artnet:1.2.3")
+	f.Add("invalid")
+	f.Add(",,")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		var filter Filter
+		if err := filter.Set(input); err != nil {
+			return
+		}
+		s := filter.String()
+		var filter2 Filter
+		if err := filter2.Set(s); err != nil {
+			t.Fatalf("roundtrip failed: %q -> %q: %v", input, s, err)
+		}
+		if filter2.String() != s {
+			t.Fatalf("roundtrip mismatch: %q != %q", filter2.String(), s)
+		}
+	})
+}
+
+func FuzzFormatDiff(f *testing.F) {
+	f.Add([]byte{}, []byte{255})
+	f.Add([]byte{1, 2, 3}, []byte{1, 0, 3})
+
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		var old, cur [512]byte
+		copy(old[:], a)
+		copy(cur[:], b)
+		want := 0
+		for i := range cur {
+			if old[i] != cur[i] {
+				want++
+			}
+		}
+		s, n := formatDiff(old, cur)
+		if n != want {
+			t.Fatalf("count %d, want %d: %s", n, want, s)
+		}
+	})
+}
+
+func FuzzWriterRotate(f *testing.F) {
+	f.Add(uint16(100), uint16(1000), uint8(3))
+	f.Add(uint16(0), uint16(50), uint8(0))
+	f.Add(uint16(30), uint16(5), uint8(1))
+
+	f.Fuzz(func(t *testing.T, maxBytes, lines uint16, maxFiles uint8) {
+		if lines > ringLines || maxFiles > 8 {
+			return
+		}
+		path := filepath.Join(t.TempDir(), "debug.log")
+		w, err := NewWriter(path, int64(maxBytes), int(maxFiles))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < int(lines); i++ {
+			w.Printf("[->sacn] line=%d", i)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Lines are never split across files and the newest are in path, in order
+		var got []string
+		for i := int(maxFiles); i >= 0; i-- {
+			name := path
+			if i > 0 {
+				name = fmt.Sprintf("%s.%d", path, i)
+			}
+			data, err := os.ReadFile(name)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if maxBytes > 0 && len(data) > int(maxBytes) && strings.Count(string(data), "\n") > 1 {
+				t.Fatalf("%s is %d bytes, over %d", name, len(data), maxBytes)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+				if line != "" {
+					got = append(got, line)
+				}
+			}
+		}
+		for i := 1; i < len(got); i++ {
+			var a, b int
+			fmt.Sscanf(got[i-1][strings.Index(got[i-1], "line="):], "line=%d", &a)
+			fmt.Sscanf(got[i][strings.Index(got[i], "line="):], "line=%d", &b)
+			if b != a+1 {
+				t.Fatalf("line %d follows line %d", b, a)
+			}
+		}
+		if maxBytes == 0 && len(got) != int(lines) {
+			t.Fatalf("expected %d lines, got %d", lines, len(got))
+		}
+	})
+}
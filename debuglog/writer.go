@@ -0,0 +1,171 @@
+package debuglog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ringLines is how many lines may wait for the writer before the oldest are dropped
+const ringLines = 4096
+
+// Writer writes debug lines from a background goroutine so the packet path
+// never waits on I/O. Lines wait in a fixed-size ring; when the writer falls
+// behind the oldest are overwritten and a "[debug] dropped=N" line marks the gap.
+// Lines go to the standard logger's output, or to a file rotated once it
+// reaches maxBytes keeping at most maxFiles old files (path.1, path.2, ...).
+type Writer struct {
+	mu      sync.Mutex
+	ring    [ringLines]string
+	head    int
+	n       int
+	dropped uint64
+
+	wake chan struct{}
+	done chan struct{}
+	exit chan struct{}
+
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	w        *bufio.Writer
+	size     int64
+}
+
+// NewWriter starts a writer to path, or to the standard logger's output if path is empty
+func NewWriter(path string, maxBytes int64, maxFiles int) (*Writer, error) {
+	w := &Writer{
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		exit:     make(chan struct{}),
+		path:     path,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles,
+	}
+	if path != "" {
+		if err := w.open(); err != nil {
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// Printf queues a line stamped with the current time; it never blocks on output
+func (w *Writer) Printf(format string, args ...any) {
+	line := time.Now().Format("15:04:05.000000 ") + fmt.Sprintf(format, args...) + "\n"
+
+	w.mu.Lock()
+	if w.n == ringLines {
+		w.head = (w.head + 1) % ringLines
+		w.n--
+		w.dropped++
+	}
+	w.ring[(w.head+w.n)%ringLines] = line
+	w.n++
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close writes the queued lines and closes the file
+func (w *Writer) Close() error {
+	close(w.done)
+	<-w.exit
+	if w.file == nil {
+		return nil
+	}
+	err := w.w.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *Writer) run() {
+	defer close(w.exit)
+	var batch []string
+	for {
+		select {
+		case <-w.wake:
+		case <-w.done:
+			w.write(w.take(batch[:0]))
+			return
+		}
+		batch = w.take(batch[:0])
+		w.write(batch)
+	}
+}
+
+// take moves the queued lines into batch, led by a note of any dropped since the last batch
+func (w *Writer) take(batch []string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dropped > 0 {
+		batch = append(batch, fmt.Sprintf("%s[debug] dropped=%d lines, writer behind\n", time.Now().Format("15:04:05.000000 "), w.dropped))
+		w.dropped = 0
+	}
+	for i := 0; i < w.n; i++ {
+		j := (w.head + i) % ringLines
+		batch = append(batch, w.ring[j])
+		w.ring[j] = ""
+	}
+	w.head, w.n = 0, 0
+	return batch
+}
+
+func (w *Writer) write(batch []string) {
+	if w.file == nil {
+		out := log.Writer()
+		for _, line := range batch {
+			io.WriteString(out, line)
+		}
+		return
+	}
+	for _, line := range batch {
+		if w.maxBytes > 0 && w.size+int64(len(line)) > w.maxBytes && w.size > 0 {
+			if err := w.rotate(); err != nil {
+				log.Printf("[debug] rotate error, writing to log instead: file=%s err=%v", w.path, err)
+				return
+			}
+		}
+		n, _ := w.w.WriteString(line)
+		w.size += int64(n)
+	}
+	w.w.Flush()
+}
+
+func (w *Writer) open() error {
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.w = bufio.NewWriter(f)
+	w.size = 0
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	w.w.Flush()
+	w.file.Close()
+	w.file = nil
+	if w.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles))
+		for i := w.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
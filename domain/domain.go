@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/artnetio"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/remap"
+	"github.com/gopatchy/artmap/sacnio"
+	"github.com/gopatchy/artnet"
+	"github.com/gopatchy/sacn"
+)
+
+// Stats is a domain's traffic since startup, reported by the API
+type Stats struct {
+	Name         string `json:"name"`
+	ArtNetListen string `json:"artnet_listen"`
+	Mappings     int    `json:"mappings"`
+	Received     uint64 `json:"received"`
+	Ignored      uint64 `json:"ignored"`
+	Sent         uint64 `json:"sent"`
+	Errors       uint64 `json:"errors"`
+}
+
+// Domain routes one config domain with its own listener, engine and sockets.
+// It shares no state with the main routing or other domains: input is only
+// read from its listener and output is only unicast to its targets.
+type Domain struct {
+	cfg         config.Domain
+	receiver    *artnetio.Receiver
+	artSender   *artnetio.Sender
+	sacnSender  *sacnio.Sender
+	engine      *remap.Engine
+	artTargets  map[uint16][]*net.UDPAddr
+	sacnTargets map[uint16][]*net.UDPAddr
+	sources     map[artnet.Universe]bool
+	sendOnInput bool
+	due         *remap.DueTimer // nil unless sending on input with deferred outputs
+	done        chan struct{}
+
+	received atomic.Uint64
+	ignored  atomic.Uint64
+	sent     atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// New opens the domain's listener and senders
+func New(cfg config.Domain) (*Domain, error) {
+	d := &Domain{
+		cfg:         cfg,
+		engine:      remap.NewEngine(cfg.Normalize()),
+		artTargets:  map[uint16][]*net.UDPAddr{},
+		sacnTargets: map[uint16][]*net.UDPAddr{},
+		sources:     map[artnet.Universe]bool{},
+		done:        make(chan struct{}),
+	}
+	for _, u := range d.engine.SourceUniverses(config.ProtocolArtNet) {
+		d.sources[u.ArtNet()] = true
+	}
+	for _, t := range cfg.Targets {
+		port := artnet.Port
+		if t.Universe.Protocol == config.ProtocolSACN {
+			port = sacn.Port
+		}
+		addr, err := resolveTarget(t.Address, port)
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Address, err)
+		}
+		if t.Universe.Protocol == config.ProtocolSACN {
+			d.sacnTargets[t.Universe.Number] = append(d.sacnTargets[t.Universe.Number], addr)
+		} else {
+			d.artTargets[t.Universe.Number] = append(d.artTargets[t.Universe.Number], addr)
+		}
+	}
+
+	listen, err := net.ResolveUDPAddr("udp4", cfg.ArtNetListen)
+	if err != nil {
+		return nil, err
+	}
+	d.receiver, err = artnetio.NewReceiver(listen, d)
+	if err != nil {
+		return nil, err
+	}
+	d.artSender, err = artnetio.NewSender()
+	if err != nil {
+		d.receiver.Stop()
+		return nil, err
+	}
+	d.sacnSender, err = sacnio.NewSender("artmap "+cfg.Name, "", 0)
+	if err != nil {
+		d.receiver.Stop()
+		d.artSender.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func resolveTarget(s string, defaultPort int) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		s = net.JoinHostPort(s, strconv.Itoa(defaultPort))
+	}
+	return net.ResolveUDPAddr("udp4", s)
+}
+
+// Name returns the domain name
+func (d *Domain) Name() string {
+	return d.cfg.Name
+}
+
+// Start receives input and sends dirty outputs at hz (0 = on input)
+func (d *Domain) Start(hz int) {
+	d.sendOnInput = hz == 0
+	if hz == 0 && d.engine.HasDeferredOutputs() {
+		d.due = remap.NewDueTimer(d.engine.NextDue, d.flush, d.done)
+	}
+	d.receiver.Start()
+	if hz == 0 {
+		return
+	}
+	interval := time.Second / time.Duration(hz)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+				d.flush()
+			}
+		}
+	}()
+}
+
+// Stop closes the domain's sockets
+func (d *Domain) Stop() {
+	close(d.done)
+	d.receiver.Stop()
+	d.artSender.Close()
+	d.sacnSender.Close()
+}
+
+// Stats returns the domain's traffic counters
+func (d *Domain) Stats() Stats {
+	return Stats{
+		Name:         d.cfg.Name,
+		ArtNetListen: d.cfg.ArtNetListen,
+		Mappings:     len(d.cfg.Mappings),
+		Received:     d.received.Load(),
+		Ignored:      d.ignored.Load(),
+		Sent:         d.sent.Load(),
+		Errors:       d.errors.Load(),
+	}
+}
+
+// HandleDMX implements artnet.Handler
+func (d *Domain) HandleDMX(src *net.UDPAddr, pkt *artnet.DMXPacket) {
+	if !d.sources[pkt.Universe] {
+		d.ignored.Add(1)
+		return
+	}
+	d.received.Add(1)
+	d.engine.Remap(config.ArtNetUniverse(pkt.Universe), pkt.Data)
+	if d.sendOnInput {
+		d.flush()
+		d.due.Wake()
+	}
+}
+
+// HandlePoll implements artnet.Handler; domains do not take part in discovery
+func (d *Domain) HandlePoll(src *net.UDPAddr, pkt *artnet.PollPacket) {}
+
+// HandlePollReply implements artnet.Handler
+func (d *Domain) HandlePollReply(src *net.UDPAddr, pkt *artnet.PollReplyPacket) {}
+
+func (d *Domain) flush() {
+	for _, out := range d.engine.GetDirtyOutputs() {
+		u := out.Universe.Number
+		switch out.Universe.Protocol {
+		case config.ProtocolArtNet:
+			for _, dst := range d.artTargets[u] {
+				d.record(dst, d.artSender.SendDMX(dst, out.Universe.ArtNet(), out.Data[:]))
+			}
+		case config.ProtocolSACN:
+			for _, dst := range d.sacnTargets[u] {
+				d.record(dst, d.sacnSender.SendDMXUnicast(dst, u, out.Data[:]))
+			}
+		}
+	}
+}
+
+func (d *Domain) record(dst *net.UDPAddr, err error) {
+	if err != nil {
+		if d.errors.Add(1) == 1 {
+			log.Printf("[domain] send error: name=%s dst=%s err=%v", d.cfg.Name, dst, err)
+		}
+		return
+	}
+	d.sent.Add(1)
+}
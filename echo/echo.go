@@ -0,0 +1,68 @@
+package echo
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Filter recognizes artmap's own transmissions received back: ArtNet
+// broadcasts reach our own listener and sACN multicast is looped back to
+// local sockets, so mappings that also read what they send would feed back.
+// ArtNet is matched by a local address and a port we send from; sACN by our CID.
+type Filter struct {
+	mu    sync.RWMutex
+	ports map[int]bool
+	cids  map[[16]byte]bool
+
+	ips atomic.Pointer[map[string]bool]
+}
+
+func New() *Filter {
+	f := &Filter{ports: map[int]bool{}, cids: map[[16]byte]bool{}}
+	f.Refresh()
+	return f
+}
+
+// AddSender registers the local addresses of a socket ArtNet is sent from
+func (f *Filter) AddSender(addrs ...*net.UDPAddr) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, a := range addrs {
+		f.ports[a.Port] = true
+	}
+}
+
+// AddCID registers the CID our sACN is sent with
+func (f *Filter) AddCID(cid [16]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cids[cid] = true
+}
+
+// Refresh reloads the local interface addresses, e.g. after they change
+func (f *Filter) Refresh() {
+	ips := map[string]bool{}
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			ips[ipnet.IP.String()] = true
+		}
+	}
+	f.ips.Store(&ips)
+}
+
+// ArtNet reports whether an ArtNet packet from src was sent by us
+func (f *Filter) ArtNet(src *net.UDPAddr) bool {
+	f.mu.RLock()
+	own := f.ports[src.Port]
+	f.mu.RUnlock()
+	return own && (*f.ips.Load())[src.IP.String()]
+}
+
+// SACN reports whether an sACN packet with cid was sent by us
+func (f *Filter) SACN(cid [16]byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cids[cid]
+}
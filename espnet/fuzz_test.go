@@ -0,0 +1,31 @@
+package espnet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzParseDataPacket(f *testing.F) {
+	f.Add(BuildDataPacket(0, make([]byte, 512)))
+	f.Add(BuildDataPacket(255, []byte{1, 2, 3}))
+	f.Add([]byte("ESDD"))
+	f.Add([]byte("ESDD\x00\x00\x01\xff\xff"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		pkt, err := ParseDataPacket(input)
+		if err != nil {
+			return
+		}
+		if len(pkt.Data) > 512 {
+			return
+		}
+		rebuilt, err := ParseDataPacket(BuildDataPacket(pkt.Universe, pkt.Data))
+		if err != nil {
+			t.Fatalf("re-parse failed: %v", err)
+		}
+		if rebuilt.Universe != pkt.Universe || !bytes.Equal(rebuilt.Data, pkt.Data) {
+			t.Fatalf("roundtrip mismatch: %+v != %+v", rebuilt, pkt)
+		}
+	})
+}
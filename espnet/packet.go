@@ -0,0 +1,63 @@
+package espnet
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Port is the UDP port ESP Net devices listen on
+const Port = 3333
+
+const (
+	headerLen = 9
+
+	// DataTypeDMX marks an uncompressed DMX payload
+	DataTypeDMX = 1
+)
+
+var dataID = [4]byte{'E', 'S', 'D', 'D'}
+
+var (
+	ErrInvalidHeader = errors.New("not an ESP Net data packet")
+	ErrTruncated     = errors.New("truncated ESP Net data packet")
+)
+
+// DataPacket is an ESP Net DMX data (ESDD) packet
+type DataPacket struct {
+	Universe  uint8
+	StartCode uint8
+	DataType  uint8
+	Data      []byte
+}
+
+// BuildDataPacket encodes an uncompressed DMX frame for universe with a zero start code
+func BuildDataPacket(universe uint8, data []byte) []byte {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	pkt := make([]byte, headerLen+len(data))
+	copy(pkt, dataID[:])
+	pkt[4] = universe
+	pkt[5] = 0 // start code
+	pkt[6] = DataTypeDMX
+	binary.BigEndian.PutUint16(pkt[7:], uint16(len(data)))
+	copy(pkt[headerLen:], data)
+	return pkt
+}
+
+// ParseDataPacket decodes an ESP Net data packet
+func ParseDataPacket(b []byte) (*DataPacket, error) {
+	if len(b) < headerLen || [4]byte(b[:4]) != dataID {
+		return nil, ErrInvalidHeader
+	}
+	size := int(binary.BigEndian.Uint16(b[7:]))
+	if len(b) < headerLen+size {
+		return nil, ErrTruncated
+	}
+	return &DataPacket{
+		Universe:  b[4],
+		StartCode: b[5],
+		DataType:  b[6],
+		Data:      b[headerLen : headerLen+size],
+	}, nil
+}
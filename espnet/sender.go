@@ -0,0 +1,34 @@
+package espnet
+
+import "net"
+
+type Sender struct {
+	conn *net.UDPConn
+	tap  func(src, dst *net.UDPAddr, data []byte)
+}
+
+func NewSender() (*Sender, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{conn: conn}, nil
+}
+
+// SetTap registers a function called with every raw packet sent
+func (s *Sender) SetTap(fn func(src, dst *net.UDPAddr, data []byte)) {
+	s.tap = fn
+}
+
+func (s *Sender) SendDMX(addr *net.UDPAddr, universe uint8, data []byte) error {
+	pkt := BuildDataPacket(universe, data)
+	if s.tap != nil {
+		s.tap(s.conn.LocalAddr().(*net.UDPAddr), addr, pkt)
+	}
+	_, err := s.conn.WriteToUDP(pkt, addr)
+	return err
+}
+
+func (s *Sender) Close() error {
+	return s.conn.Close()
+}
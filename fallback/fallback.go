@@ -0,0 +1,55 @@
+package fallback
+
+import (
+	"net"
+	"sync"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Destinations returns the addresses a fallback stage would send to, none if it has no candidates
+type Destinations func(stage string) []*net.UDPAddr
+
+// Select walks chain and returns the first stage with a healthy destination and
+// those destinations. It returns drop and nothing when every stage is exhausted.
+func Select(chain []string, dests Destinations, healthy func(*net.UDPAddr) bool) (string, []*net.UDPAddr) {
+	for _, stage := range chain {
+		if stage == config.FallbackDrop {
+			break
+		}
+		var result []*net.UDPAddr
+		for _, dst := range dests(stage) {
+			if healthy(dst) {
+				result = append(result, dst)
+			}
+		}
+		if len(result) > 0 {
+			return stage, result
+		}
+	}
+	return config.FallbackDrop, nil
+}
+
+// Tracker remembers the stage each output universe last sent through so
+// transitions along its chain can be logged once rather than per frame
+type Tracker struct {
+	mu      sync.Mutex
+	current map[config.Universe]string
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{current: map[config.Universe]string{}}
+}
+
+// Use records that u is sending through stage, returning the previous stage
+// ("" for the first frame) and whether it changed
+func (t *Tracker) Use(u config.Universe, stage string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev := t.current[u]
+	if prev == stage {
+		return prev, false
+	}
+	t.current[u] = stage
+	return prev, true
+}
@@ -0,0 +1,79 @@
+package fallback
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+func FuzzSelect(f *testing.F) {
+	f.Add([]byte{1, 0, 2}, byte(0b111), byte(0b111))
+	f.Add([]byte{1, 0, 2}, byte(0b101), byte(0b100))
+	f.Add([]byte{0, 1, 3}, byte(0b011), byte(0b010))
+	f.Add([]byte{}, byte(0), byte(0))
+
+	stages := []string{config.FallbackDiscovered, config.FallbackStatic, config.FallbackBroadcast, config.FallbackDrop}
+	addrs := []*net.UDPAddr{
+		{IP: net.IPv4(10, 0, 0, 1), Port: 6454},
+		{IP: net.IPv4(10, 0, 0, 2), Port: 6454},
+		{IP: net.IPv4(10, 255, 255, 255), Port: 6454},
+	}
+
+	f.Fuzz(func(t *testing.T, spec []byte, present, healthyBits byte) {
+		var chain []string
+		for _, b := range spec {
+			chain = append(chain, stages[int(b)%len(stages)])
+		}
+		dests := func(stage string) []*net.UDPAddr {
+			i := slices.Index(stages, stage)
+			if i < len(addrs) && present&(1<<i) != 0 {
+				return []*net.UDPAddr{addrs[i]}
+			}
+			return nil
+		}
+		healthy := func(dst *net.UDPAddr) bool {
+			i := slices.IndexFunc(addrs, func(a *net.UDPAddr) bool { return a == dst })
+			return healthyBits&(1<<i) != 0
+		}
+		usable := func(stage string) bool {
+			return slices.ContainsFunc(dests(stage), healthy)
+		}
+
+		stage, got := Select(chain, dests, healthy)
+
+		// Every stage walked before the selected one must have had nothing healthy
+		for _, s := range chain {
+			if s == stage || s == config.FallbackDrop {
+				break
+			}
+			if usable(s) {
+				t.Fatalf("chain %v: skipped usable stage %s for %s", chain, s, stage)
+			}
+		}
+		if stage == config.FallbackDrop {
+			if got != nil {
+				t.Fatalf("drop returned destinations %v", got)
+			}
+			return
+		}
+		if !slices.Contains(chain, stage) || len(got) == 0 {
+			t.Fatalf("chain %v: selected %s with %v", chain, stage, got)
+		}
+		for _, dst := range got {
+			if !healthy(dst) {
+				t.Fatalf("unhealthy destination %s returned", dst)
+			}
+		}
+
+		tracker := NewTracker()
+		u := config.ArtNetUniverse(1)
+		if prev, changed := tracker.Use(u, stage); prev != "" || !changed {
+			t.Fatalf("first use: prev %q changed %v", prev, changed)
+		}
+		if prev, changed := tracker.Use(u, stage); prev != stage || changed {
+			t.Fatalf("repeat use: prev %q changed %v", prev, changed)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package fanout
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// queueLen bounds the jobs waiting per worker; beyond it new jobs are dropped
+// rather than stalling the caller, since a late DMX frame is superseded anyway
+const queueLen = 256
+
+// Pool runs send jobs on a fixed set of workers. Jobs with the same key always
+// run on the same worker, so sends to one destination keep their order.
+// A nil Pool runs jobs inline.
+type Pool struct {
+	queues  []chan func()
+	dropped atomic.Uint64
+	wg      sync.WaitGroup
+	mu      sync.RWMutex // held for writing by Stop while it closes the queues
+	stopped bool
+}
+
+// New starts a pool with n workers; n <= 0 returns nil (send inline)
+func New(n int) *Pool {
+	if n <= 0 {
+		return nil
+	}
+	p := &Pool{queues: make([]chan func(), n)}
+	for i := range p.queues {
+		q := make(chan func(), queueLen)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range q {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues job on the worker owning key, reporting false if that worker's
+// queue is full or the pool has stopped
+func (p *Pool) Submit(key string, job func()) bool {
+	if p == nil {
+		job()
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return false
+	}
+	select {
+	case p.queues[h.Sum32()%uint32(len(p.queues))] <- job:
+		return true
+	default:
+		p.dropped.Add(1)
+		return false
+	}
+}
+
+// SwapDropped returns the number of jobs dropped since the last call
+func (p *Pool) SwapDropped() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.dropped.Swap(0)
+}
+
+// Stop waits for queued jobs to finish; later Submits are refused
+func (p *Pool) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,95 @@
+package fanout
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSubmitAfterStop(t *testing.T) {
+	p := New(2)
+	ran := make(chan struct{}, 1)
+	if !p.Submit("10.0.0.1:6454", func() { ran <- struct{}{} }) {
+		t.Fatalf("submit before stop refused")
+	}
+	p.Stop()
+	select {
+	case <-ran:
+	default:
+		t.Fatalf("queued job did not run before Stop returned")
+	}
+	if p.Submit("10.0.0.1:6454", func() { t.Errorf("job ran after stop") }) {
+		t.Fatalf("submit after stop accepted")
+	}
+	p.Stop()
+}
+
+func TestSubmitFull(t *testing.T) {
+	p := New(1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit("a", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	for i := range queueLen {
+		if !p.Submit("a", func() {}) {
+			t.Fatalf("submit %d dropped before the queue was full", i)
+		}
+	}
+	if p.Submit("a", func() { t.Errorf("dropped job ran") }) {
+		t.Fatalf("submit to a full queue accepted")
+	}
+	if n := p.SwapDropped(); n != 1 {
+		t.Fatalf("dropped = %d, want 1", n)
+	}
+	if n := p.SwapDropped(); n != 0 {
+		t.Fatalf("dropped not reset: %d", n)
+	}
+	close(release)
+	p.Stop()
+}
+
+func TestOrder(t *testing.T) {
+	p := New(4)
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	for i := range 50 {
+		for k := range 3 {
+			key := fmt.Sprintf("10.0.0.%d:6454", k)
+			if !p.Submit(key, func() {
+				mu.Lock()
+				seen[key] = append(seen[key], i)
+				mu.Unlock()
+			}) {
+				t.Fatalf("submit dropped")
+			}
+		}
+	}
+	p.Stop()
+
+	if len(seen) != 3 {
+		t.Fatalf("jobs ran for %d keys, want 3", len(seen))
+	}
+	for key, order := range seen {
+		if len(order) != 50 {
+			t.Fatalf("%s ran %d jobs, want 50", key, len(order))
+		}
+		for i, n := range order {
+			if n != i {
+				t.Fatalf("%s ran job %d at position %d", key, n, i)
+			}
+		}
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	var p *Pool
+	ran := false
+	if !p.Submit("a", func() { ran = true }) || !ran {
+		t.Fatalf("nil pool did not run the job inline")
+	}
+	p.Stop()
+}
@@ -0,0 +1,87 @@
+package flood
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	dropped  uint64
+	limiting bool
+}
+
+// Limiter enforces a per-source-IP packet rate with a token bucket holding one second of burst
+type Limiter struct {
+	mu      sync.Mutex
+	pps     float64
+	buckets map[string]*bucket
+}
+
+// New returns a limiter allowing pps packets per second per source; nil when pps is 0
+func New(pps int) *Limiter {
+	if pps <= 0 {
+		return nil
+	}
+	return &Limiter{
+		pps:     float64(pps),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Allow reports whether a packet from ip is within its rate; started is true
+// on the first drop after the source was last within its limit
+func (l *Limiter) Allow(ip net.IP) (allowed, started bool) {
+	if l == nil {
+		return true, false
+	}
+	now := time.Now()
+	key := ip.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.pps, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.pps, b.tokens+now.Sub(b.last).Seconds()*l.pps)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.limiting = false
+		return true, false
+	}
+	b.dropped++
+	started = !b.limiting
+	b.limiting = true
+	return false, started
+}
+
+// SwapDropped returns packets dropped per source IP since the last call, and
+// forgets sources that have been quiet for longer than idle
+func (l *Limiter) SwapDropped(idle time.Duration) map[string]uint64 {
+	if l == nil {
+		return nil
+	}
+	cutoff := time.Now().Add(-idle)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := map[string]uint64{}
+	for ip, b := range l.buckets {
+		if b.dropped > 0 {
+			result[ip] = b.dropped
+			b.dropped = 0
+		}
+		if b.last.Before(cutoff) {
+			delete(l.buckets, ip)
+		}
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package flood
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(10)
+	a, b := net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)
+
+	// One second of burst passes, then the source is limited
+	for i := range 10 {
+		if allowed, _ := l.Allow(a); !allowed {
+			t.Fatalf("packet %d within the burst dropped", i)
+		}
+	}
+	if allowed, started := l.Allow(a); allowed || !started {
+		t.Fatalf("first packet over the limit: allowed=%v started=%v", allowed, started)
+	}
+	if allowed, started := l.Allow(a); allowed || started {
+		t.Fatalf("second packet over the limit: allowed=%v started=%v", allowed, started)
+	}
+
+	// Sources have their own buckets
+	if allowed, _ := l.Allow(b); !allowed {
+		t.Fatalf("other source limited")
+	}
+
+	// Tokens refill at pps
+	time.Sleep(150 * time.Millisecond)
+	if allowed, _ := l.Allow(a); !allowed {
+		t.Fatalf("packet dropped after the bucket refilled")
+	}
+
+	dropped := l.SwapDropped(time.Hour)
+	if len(dropped) != 1 || dropped[a.String()] != 2 {
+		t.Fatalf("dropped = %v, want 2 from %s", dropped, a)
+	}
+	if dropped := l.SwapDropped(0); len(dropped) != 0 {
+		t.Fatalf("dropped counts not reset: %v", dropped)
+	}
+	if len(l.buckets) != 0 {
+		t.Fatalf("idle sources not forgotten: %d left", len(l.buckets))
+	}
+}
+
+func TestLimiterOff(t *testing.T) {
+	l := New(0)
+	if l != nil {
+		t.Fatalf("New(0) = %v, want nil", l)
+	}
+	for range 1000 {
+		if allowed, _ := l.Allow(net.IPv4(10, 0, 0, 1)); !allowed {
+			t.Fatalf("nil limiter dropped a packet")
+		}
+	}
+}
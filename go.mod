@@ -5,12 +5,17 @@ go 1.25.6
 require (
 	github.com/BurntSushi/toml v1.6.0
 	github.com/gopatchy/artnet v0.0.0-20260204180605-8f14a4f373c2
+	github.com/gopatchy/multicast v0.0.0-20260130233915-4278628690a3
 	github.com/gopatchy/sacn v0.0.0-20260130234631-9c2787a20064
+	github.com/pion/dtls/v3 v3.0.6
+	golang.org/x/net v0.49.0
+	golang.org/x/sys v0.40.0
+	golang.org/x/term v0.39.0
 )
 
 require (
 	github.com/google/gopacket v1.1.19 // indirect
-	github.com/gopatchy/multicast v0.0.0-20260130233915-4278628690a3 // indirect
-	golang.org/x/net v0.49.0 // indirect
-	golang.org/x/sys v0.40.0 // indirect
+	github.com/pion/logging v0.2.3 // indirect
+	github.com/pion/transport/v3 v3.0.7 // indirect
+	golang.org/x/crypto v0.47.0 // indirect
 )
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DestInfo describes send health for one destination address
+type DestInfo struct {
+	Addr        string    `json:"addr"`
+	Errors      uint64    `json:"errors"`
+	Consecutive int       `json:"consecutive"`
+	Healthy     bool      `json:"healthy"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+}
+
+type destState struct {
+	errors      uint64
+	consecutive int
+	unhealthy   bool
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Tracker counts send errors per destination and marks destinations that keep failing unhealthy
+type Tracker struct {
+	mu        sync.Mutex
+	threshold int
+	dests     map[string]*destState
+}
+
+func New(threshold int) *Tracker {
+	return &Tracker{
+		threshold: threshold,
+		dests:     map[string]*destState{},
+	}
+}
+
+// Healthy reports whether sends to addr should be attempted
+func (t *Tracker) Healthy(addr *net.UDPAddr) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.dests[addr.String()]
+	return d == nil || !d.unhealthy
+}
+
+// Record notes the result of a send. It returns the current run of consecutive
+// errors and whether the destination changed between healthy and unhealthy.
+func (t *Tracker) Record(addr *net.UDPAddr, err error) (consecutive int, changed bool) {
+	key := addr.String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d := t.dests[key]
+	if err == nil {
+		if d == nil {
+			return 0, false
+		}
+		changed = d.unhealthy
+		d.consecutive = 0
+		d.unhealthy = false
+		return 0, changed
+	}
+
+	if d == nil {
+		d = &destState{}
+		t.dests[key] = d
+	}
+	d.errors++
+	d.consecutive++
+	d.lastError = err.Error()
+	d.lastErrorAt = time.Now()
+	if !d.unhealthy && d.consecutive >= t.threshold {
+		d.unhealthy = true
+		changed = true
+	}
+	return d.consecutive, changed
+}
+
+// Retry gives unhealthy destinations matching ip (or all, if ip is nil) another
+// attempt; a single further error marks them unhealthy again
+func (t *Tracker) Retry(ip net.IP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, d := range t.dests {
+		if !d.unhealthy {
+			continue
+		}
+		if ip != nil {
+			host, _, _ := net.SplitHostPort(key)
+			if !ip.Equal(net.ParseIP(host)) {
+				continue
+			}
+		}
+		d.unhealthy = false
+		d.consecutive = t.threshold - 1
+	}
+}
+
+func (t *Tracker) GetAll() []DestInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]DestInfo, 0, len(t.dests))
+	for key, d := range t.dests {
+		result = append(result, DestInfo{
+			Addr:        key,
+			Errors:      d.errors,
+			Consecutive: d.consecutive,
+			Healthy:     !d.unhealthy,
+			LastError:   d.lastError,
+			LastErrorAt: d.lastErrorAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Addr < result[j].Addr })
+	return result
+}
@@ -0,0 +1,245 @@
+package hue
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+const (
+	streamPort       = 2100
+	streamInterval   = 20 * time.Millisecond
+	handshakeTimeout = 10 * time.Second
+	reconnectDelay   = 5 * time.Second
+)
+
+// Light is one Hue entertainment channel fed from three consecutive DMX channels (R, G, B)
+type Light struct {
+	ID      byte
+	DMXChan int // 0-indexed start channel
+}
+
+// Output streams DMX-derived colors to one Hue entertainment configuration
+type Output struct {
+	bridge    string
+	appKey    string
+	clientKey []byte
+	configID  string
+	lights    []Light
+	client    *http.Client
+
+	mu    sync.Mutex
+	frame [512]byte
+	fresh bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates an output; clientKey is the hex PSK issued with the application
+// key. The bridge's certificate must name bridgeID and, unless roots is nil,
+// chain to roots (the Hue root CA).
+func New(bridge, bridgeID string, roots *x509.CertPool, appKey, clientKey, configID string, lights []Light) (*Output, error) {
+	psk, err := hex.DecodeString(clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client key: %w", err)
+	}
+	if len(configID) != 36 {
+		return nil, fmt.Errorf("invalid entertainment configuration id %q", configID)
+	}
+	return &Output{
+		bridge:    bridge,
+		appKey:    appKey,
+		clientKey: psk,
+		configID:  configID,
+		lights:    lights,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+			// The certificate names the bridge ID rather than its address, so
+			// the default hostname check is replaced by verifyBridge
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				VerifyConnection:   verifyBridge(bridgeID, roots),
+			}},
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+// verifyBridge accepts only a certificate whose common name is the bridge ID
+// and, with roots, that chains to them
+func verifyBridge(id string, roots *x509.CertPool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("bridge sent no certificate")
+		}
+		cert := cs.PeerCertificates[0]
+		if !strings.EqualFold(cert.Subject.CommonName, id) {
+			return fmt.Errorf("certificate is for bridge %q, not %q", cert.Subject.CommonName, id)
+		}
+		if roots == nil {
+			return nil
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+			return fmt.Errorf("bridge certificate: %w", err)
+		}
+		return nil
+	}
+}
+
+func (o *Output) Start() {
+	o.wg.Add(1)
+	go o.run()
+}
+
+func (o *Output) Stop() {
+	close(o.done)
+	o.wg.Wait()
+}
+
+// Update stores the latest source frame for the next stream message
+func (o *Output) Update(data [512]byte) {
+	o.mu.Lock()
+	o.frame = data
+	o.fresh = true
+	o.mu.Unlock()
+}
+
+func (o *Output) run() {
+	defer o.wg.Done()
+	for {
+		if err := o.stream(); err != nil {
+			log.Printf("[hue] bridge=%s error=%v", o.bridge, err)
+		}
+		select {
+		case <-o.done:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (o *Output) stream() error {
+	if err := o.setActive(true); err != nil {
+		return err
+	}
+	defer o.setActive(false)
+
+	conn, err := o.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	log.Printf("[hue] connected bridge=%s config=%s lights=%d", o.bridge, o.configID, len(o.lights))
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+
+	var seq byte
+	var frame [512]byte
+	for {
+		select {
+		case <-o.done:
+			return nil
+		case <-ticker.C:
+		}
+
+		// Resend the last frame even without new input; the bridge ends idle sessions
+		o.mu.Lock()
+		if o.fresh {
+			frame = o.frame
+			o.fresh = false
+		}
+		o.mu.Unlock()
+
+		if _, err := conn.Write(BuildMessage(o.configID, seq, o.lights, frame)); err != nil {
+			return err
+		}
+		seq++
+	}
+}
+
+// dial opens the DTLS session the stream is sent over, authenticated with the
+// application key as PSK identity and the client key as PSK
+func (o *Output) dial() (*dtls.Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(o.bridge, fmt.Sprint(streamPort)))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dtls.Dial("udp4", raddr, &dtls.Config{
+		PSK:             func([]byte) ([]byte, error) { return o.clientKey, nil },
+		PSKIdentityHint: []byte(o.appKey),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("dtls handshake: %w", err)
+	}
+	return conn, nil
+}
+
+// setActive starts or stops streaming mode on the entertainment configuration
+func (o *Output) setActive(active bool) error {
+	action := "stop"
+	if active {
+		action = "start"
+	}
+	url := fmt.Sprintf("https://%s/clip/v2/resource/entertainment_configuration/%s", o.bridge, o.configID)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBufferString(`{"action":"`+action+`"}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("hue-application-key", o.appKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s entertainment configuration: %s", action, resp.Status)
+	}
+	return nil
+}
+
+// BuildMessage encodes a HueStream v2 RGB message for the given lights
+func BuildMessage(configID string, seq byte, lights []Light, data [512]byte) []byte {
+	msg := make([]byte, 0, 52+7*len(lights))
+	msg = append(msg, "HueStream"...)
+	msg = append(msg, 0x02, 0x00, seq, 0x00, 0x00, 0x00, 0x00)
+	msg = append(msg, configID...)
+	for _, l := range lights {
+		msg = append(msg, l.ID)
+		for i := 0; i < 3; i++ {
+			var v byte
+			if ch := l.DMXChan + i; ch >= 0 && ch < 512 {
+				v = data[ch]
+			}
+			// Scale 8-bit DMX to 16-bit by repeating the byte (0xFF -> 0xFFFF)
+			msg = append(msg, v, v)
+		}
+	}
+	return msg
+}
@@ -0,0 +1,82 @@
+package input
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// broadcastRefresh is how long the directed broadcast addresses of the host's
+// interfaces are cached, so addresses added later are still recognised
+const broadcastRefresh = 10 * time.Second
+
+// fixedBroadcasts are broadcast on every network: the limited broadcast and
+// the directed broadcasts of the Art-Net primary and secondary networks
+var fixedBroadcasts = []net.IP{net.IPv4bcast, net.IPv4(2, 255, 255, 255), net.IPv4(10, 255, 255, 255)}
+
+var localBroadcasts struct {
+	mu  sync.Mutex
+	at  time.Time
+	ips []net.IP
+}
+
+// CastOf tells how a frame sent to dst was delivered; "" if dst is unknown
+func CastOf(dst net.IP) config.Cast {
+	switch {
+	case dst == nil || dst.IsUnspecified():
+		return ""
+	case dst.IsMulticast():
+		return config.CastMulticast
+	case isBroadcast(dst):
+		return config.CastBroadcast
+	}
+	return config.CastUnicast
+}
+
+func isBroadcast(ip net.IP) bool {
+	for _, b := range fixedBroadcasts {
+		if ip.Equal(b) {
+			return true
+		}
+	}
+	localBroadcasts.mu.Lock()
+	defer localBroadcasts.mu.Unlock()
+	if time.Since(localBroadcasts.at) > broadcastRefresh {
+		localBroadcasts.ips = directedBroadcasts()
+		localBroadcasts.at = time.Now()
+	}
+	for _, b := range localBroadcasts.ips {
+		if ip.Equal(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// directedBroadcasts returns the broadcast address of every IPv4 subnet of the
+// host's interfaces; point-to-point /31 and /32 subnets have none
+func directedBroadcasts() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var result []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP.To4()
+		if ones, bits := ipnet.Mask.Size(); ip == nil || bits != 32 || ones >= 31 {
+			continue
+		}
+		b := make(net.IP, 4)
+		for i := range b {
+			b[i] = ip[i] | ^ipnet.Mask[len(ipnet.Mask)-4+i]
+		}
+		result = append(result, b)
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package input
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/monitor"
+	"github.com/gopatchy/artmap/recording"
+)
+
+func TestFromConfig(t *testing.T) {
+	u := config.ArtNetUniverse(3)
+	src, err := FromConfig(config.Source{Type: config.SourceGenerator, Universe: u, Pattern: config.PatternRamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := src.(*Generator); !ok || g.Universe != u || g.Pattern != config.PatternRamp || g.Hz != 40 {
+		t.Fatalf("generator = %+v", src)
+	}
+	src, err = FromConfig(config.Source{Type: config.SourceReplay, File: "x.rec", Loop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := src.(*Replay); !ok || r.Path != "x.rec" || !r.Loop {
+		t.Fatalf("replay = %+v", src)
+	}
+	if _, err := FromConfig(config.Source{Type: "grpc"}); err == nil {
+		t.Fatal("unknown type accepted")
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	for _, pattern := range []string{config.PatternChase, config.PatternRamp} {
+		g := &Generator{Universe: config.ArtNetUniverse(1), Pattern: pattern, Hz: 200}
+		var frames []Frame
+		ctx, cancel := context.WithCancel(context.Background())
+		err := g.Run(ctx, func(f Frame) {
+			frames = append(frames, f)
+			if len(frames) == 3 {
+				cancel()
+			}
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		if len(frames) != 3 {
+			t.Fatalf("%s: %d frames after cancel, want 3", pattern, len(frames))
+		}
+		for step, f := range frames {
+			if f.Universe != g.Universe || f.Physical != NoPhysical || f.Length != 512 || f.Sequence != uint8(step) {
+				t.Fatalf("%s: frame %d = %+v", pattern, step, f)
+			}
+			want := f.Data[step] == 255
+			if pattern == config.PatternRamp {
+				want = f.Data[0] == byte(step) && f.Data[511] == byte(step)
+			}
+			if !want {
+				t.Fatalf("%s: frame %d data %v", pattern, step, f.Data[:4])
+			}
+		}
+	}
+}
+
+func writeRecording(t *testing.T, frames []recording.Frame) string {
+	path := filepath.Join(t.TempDir(), "in.rec")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := recording.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fr := range frames {
+		if err := w.WriteFrame(fr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	u := config.ArtNetUniverse(2)
+	path := writeRecording(t, []recording.Frame{
+		{Time: start, Direction: monitor.Input, Universe: u, Source: net.IPv4(10, 0, 0, 1), Data: []byte{1, 2}},
+		{Time: start.Add(10 * time.Millisecond), Direction: monitor.Output, Universe: u, Data: []byte{9}},
+		{Time: start.Add(20 * time.Millisecond), Direction: monitor.Input, Universe: u, Source: net.IPv4(10, 0, 0, 2), Data: []byte{3}},
+	})
+
+	var frames []Frame
+	began := time.Now()
+	if err := (&Replay{Path: path}).Run(context.Background(), func(f Frame) { frames = append(frames, f) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("%d frames, want the 2 input ones", len(frames))
+	}
+	if elapsed := time.Since(began); elapsed < 20*time.Millisecond {
+		t.Fatalf("replayed in %v, want the recorded 20ms", elapsed)
+	}
+	if f := frames[0]; !f.Src.IP.Equal(net.IPv4(10, 0, 0, 1)) || f.Length != 2 || f.Data[1] != 2 {
+		t.Fatalf("frame 0 = %+v", f)
+	}
+	if f := frames[1]; !f.Src.IP.Equal(net.IPv4(10, 0, 0, 2)) || f.Length != 1 || f.Data[0] != 3 {
+		t.Fatalf("frame 1 = src %v length %d", f.Src, f.Length)
+	}
+
+	// A looping replay runs until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	err := (&Replay{Path: path, Loop: true}).Run(ctx, func(Frame) {
+		if n++; n == 5 {
+			cancel()
+		}
+	})
+	if err != nil || n != 5 {
+		t.Fatalf("looping replay = %v after %d frames", err, n)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	if err := (&Replay{Path: filepath.Join(t.TempDir(), "missing.rec")}).Run(context.Background(), func(Frame) {}); err == nil {
+		t.Fatal("missing file accepted")
+	}
+	path := writeRecording(t, []recording.Frame{{Time: time.Unix(0, 0), Direction: monitor.Output, Universe: config.ArtNetUniverse(1)}})
+	if err := (&Replay{Path: path, Loop: true}).Run(context.Background(), func(Frame) {}); err == nil {
+		t.Fatal("recording without input frames accepted")
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sources := map[string]Source{
+		"gen":     &Generator{Universe: config.ArtNetUniverse(1), Hz: 100},
+		"missing": &Replay{Path: filepath.Join(t.TempDir(), "missing.rec")},
+	}
+	var mu sync.Mutex
+	results := map[string]error{}
+	emitted := make(chan struct{}, 1)
+	Run(ctx, sources, func(Frame) {
+		select {
+		case emitted <- struct{}{}:
+			cancel()
+		default:
+		}
+	}, func(name string, err error) {
+		mu.Lock()
+		results[name] = err
+		mu.Unlock()
+	})
+	if len(results) != 2 || results["gen"] != nil || results["missing"] == nil {
+		t.Fatalf("results = %v", results)
+	}
+}
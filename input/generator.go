@@ -0,0 +1,45 @@
+package input
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// generatorSrc is the source address of generated frames
+var generatorSrc = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// Generator sends a test pattern on a universe, e.g. to check fixtures without a console
+type Generator struct {
+	Universe config.Universe
+	Pattern  string // config.PatternChase or config.PatternRamp
+	Hz       int
+}
+
+func (g *Generator) Run(ctx context.Context, emit func(Frame)) error {
+	ticker := time.NewTicker(time.Second / time.Duration(g.Hz))
+	defer ticker.Stop()
+	for step := 0; ; step++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		emit(Frame{Universe: g.Universe, Src: generatorSrc, Physical: NoPhysical, Sequence: uint8(step), Length: 512, Data: g.frame(step)})
+	}
+}
+
+func (g *Generator) frame(step int) [512]byte {
+	var data [512]byte
+	switch g.Pattern {
+	case config.PatternRamp:
+		for i := range data {
+			data[i] = byte(step)
+		}
+	default:
+		data[step%len(data)] = 255
+	}
+	return data
+}
@@ -0,0 +1,57 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// NoPhysical is the physical port of frames from protocols without one
+const NoPhysical = -1
+
+// Frame is one DMX frame from an input source
+type Frame struct {
+	Universe config.Universe
+	Src      *net.UDPAddr
+	Physical int         // ArtDmx physical port, or NoPhysical
+	Cast     config.Cast // how the frame was delivered, "" if unknown
+	Sequence uint8
+	Length   int // channels the frame carried, before any filling
+	Data     [512]byte
+}
+
+// Source produces input frames until its context is done
+type Source interface {
+	// Run calls emit with every frame, from one goroutine, until ctx is done or
+	// the source fails; it returns nil once ctx is done
+	Run(ctx context.Context, emit func(Frame)) error
+}
+
+// FromConfig builds a source declared in a [[source]] section
+func FromConfig(s config.Source) (Source, error) {
+	switch s.Type {
+	case config.SourceReplay:
+		return &Replay{Path: s.File, Loop: s.Loop}, nil
+	case config.SourceGenerator:
+		return &Generator{Universe: s.Universe, Pattern: s.Pattern, Hz: s.Rate()}, nil
+	}
+	return nil, fmt.Errorf("unknown source type %q", s.Type)
+}
+
+// Run runs every source in its own goroutine, calling done with each one's
+// name and result as it returns, and waits for all of them. Frames from
+// different sources reach emit concurrently.
+func Run(ctx context.Context, sources map[string]Source, emit func(Frame), done func(name string, err error)) {
+	var wg sync.WaitGroup
+	for name, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done(name, src.Run(ctx, emit))
+		}()
+	}
+	wg.Wait()
+}
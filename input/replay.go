@@ -0,0 +1,75 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/gopatchy/artmap/monitor"
+	"github.com/gopatchy/artmap/recording"
+)
+
+// Replay plays the input frames of an artmap recording with their original timing
+type Replay struct {
+	Path string
+	Loop bool
+}
+
+func (r *Replay) Run(ctx context.Context, emit func(Frame)) error {
+	for {
+		n, err := r.play(ctx, emit)
+		if err == nil && n == 0 {
+			err = fmt.Errorf("%s: no input frames", r.Path)
+		}
+		if err != nil || !r.Loop || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// play emits the recording's input frames once, returning how many it emitted
+func (r *Replay) play(ctx context.Context, emit func(Frame)) (int, error) {
+	f, err := os.Open(r.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	rr, err := recording.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, err
+	}
+
+	var first time.Time
+	start := time.Now()
+	for n := 0; ; n++ {
+		rf, err := rr.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if rf.Direction != monitor.Input {
+			n--
+			continue
+		}
+		if first.IsZero() {
+			first = rf.Time
+		}
+		select {
+		case <-ctx.Done():
+			return n, nil
+		case <-time.After(time.Until(start.Add(rf.Time.Sub(first)))):
+		}
+		fr := Frame{Universe: rf.Universe, Src: &net.UDPAddr{IP: rf.Source}, Physical: NoPhysical, Length: len(rf.Data)}
+		if fr.Src.IP == nil {
+			fr.Src.IP = net.IPv4(127, 0, 0, 1)
+		}
+		copy(fr.Data[:], rf.Data)
+		emit(fr)
+	}
+}
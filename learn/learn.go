@@ -0,0 +1,65 @@
+package learn
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Store keeps the most recent frame received on each input universe
+type Store struct {
+	mu     sync.Mutex
+	frames map[config.Universe][512]byte
+}
+
+func New() *Store {
+	return &Store{
+		frames: map[config.Universe][512]byte{},
+	}
+}
+
+func (s *Store) Record(u config.Universe, data [512]byte) {
+	s.mu.Lock()
+	s.frames[u] = data
+	s.mu.Unlock()
+}
+
+// WriteTOML writes the captured frames as [[static]] config sections, omitting zero channels
+func (s *Store) WriteTOML(w io.Writer) error {
+	s.mu.Lock()
+	universes := make([]config.Universe, 0, len(s.frames))
+	for u := range s.frames {
+		universes = append(universes, u)
+	}
+	frames := make(map[config.Universe][512]byte, len(s.frames))
+	for u, data := range s.frames {
+		frames[u] = data
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(universes, config.Universe.Compare)
+
+	for i, u := range universes {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "[[static]]\nuniverse = %q\n[static.channels]\n", u.String()); err != nil {
+			return err
+		}
+		data := frames[u]
+		for ch, v := range data {
+			if v == 0 {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%d = %d\n", ch+1, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
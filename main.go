@@ -1,91 +1,442 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/gopatchy/artnet"
+	"github.com/gopatchy/artmap/analyze"
+	"github.com/gopatchy/artmap/anomaly"
+	"github.com/gopatchy/artmap/artcmd"
+	"github.com/gopatchy/artmap/artnetio"
+	"github.com/gopatchy/artmap/auth"
+	"github.com/gopatchy/artmap/capture"
+	"github.com/gopatchy/artmap/chaos"
+	"github.com/gopatchy/artmap/coalesce"
 	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/datagram"
+	"github.com/gopatchy/artmap/debuglog"
+	"github.com/gopatchy/artmap/domain"
+	"github.com/gopatchy/artmap/echo"
+	"github.com/gopatchy/artmap/espnet"
+	"github.com/gopatchy/artmap/fallback"
+	"github.com/gopatchy/artmap/fanout"
+	"github.com/gopatchy/artmap/flood"
+	"github.com/gopatchy/artmap/health"
+	"github.com/gopatchy/artmap/hue"
+	"github.com/gopatchy/artmap/input"
+	"github.com/gopatchy/artmap/learn"
+	"github.com/gopatchy/artmap/mdns"
+	"github.com/gopatchy/artmap/metrics"
+	"github.com/gopatchy/artmap/monitor"
+	"github.com/gopatchy/artmap/netwatch"
+	"github.com/gopatchy/artmap/output"
+	"github.com/gopatchy/artmap/privilege"
+	"github.com/gopatchy/artmap/probe"
+	"github.com/gopatchy/artmap/profile"
+	"github.com/gopatchy/artmap/rdm"
+	"github.com/gopatchy/artmap/recording"
 	"github.com/gopatchy/artmap/remap"
+	"github.com/gopatchy/artmap/sacnio"
+	"github.com/gopatchy/artmap/selftest"
 	"github.com/gopatchy/artmap/senders"
+	"github.com/gopatchy/artmap/shell"
+	"github.com/gopatchy/artmap/simulate"
+	"github.com/gopatchy/artmap/snapshot"
+	"github.com/gopatchy/artmap/state"
+	"github.com/gopatchy/artmap/topology"
+	"github.com/gopatchy/artmap/tracing"
+	"github.com/gopatchy/artmap/traffic"
+	"github.com/gopatchy/artmap/tui"
+	"github.com/gopatchy/artmap/uart"
+	"github.com/gopatchy/artmap/watchdog"
+	"github.com/gopatchy/artnet"
 	"github.com/gopatchy/sacn"
 )
 
 type App struct {
 	cfg          *config.Config
-	artReceiver  *artnet.Receiver
-	sacnReceiver *sacn.Receiver
-	artSender    *artnet.Sender
-	sacnSender   *sacn.Sender
-	discovery    *artnet.Discovery
-	engine       *remap.Engine
+	artReceiver  *artnetio.Receiver
+	sacnReceiver *sacnio.Receiver
+	artSender    *artnetio.Sender
+	sacnSender   *sacnio.Sender
+	espSender    *espnet.Sender
+	discovery    *artnetio.Discovery
+	profiles     *profile.Switcher
 	senders      *senders.UniverseSenders
+	health       *health.Tracker
+	tracer       *tracing.Tracer
+	metrics      *metrics.Collector
+	capture      *capture.Recorder
+	captureFile  string
+	captureDir   string
+	recording    *recording.File
+	broadcast    atomic.Pointer[net.UDPAddr]
+	learn        *learn.Store
+	learnFile    string
 	artTargets   map[uint16]*net.UDPAddr
+	passthrough  []*net.UDPAddr // ArtNet target addresses unknown opcodes are forwarded to, nil when off
+	passthroughs sync.Map       // opcodes already logged as passed through
+	fallbacks    map[config.Universe][]string
+	fallback     *fallback.Tracker
 	sacnTargets  map[uint16][]*net.UDPAddr
+	espTargets   map[uint16][]*net.UDPAddr
+	fanout       *fanout.Pool
+	chaos        *chaos.Injector
 	senderHz     int
-	debug        bool
+	due          *remap.DueTimer // flushes deferred outputs when sending on input, nil otherwise
+	allowLoops   bool
+	loops        sync.Map // "universe dst" of suppressed loops already logged
+	debug        *debuglog.Filter
+	differ       *debuglog.Differ
+	debugLog     *debuglog.Writer
+	monitor      *monitor.Monitor
+	snapshots    *snapshot.Store
+	coalesce     *coalesce.Coalescer
+	datagrams    *datagram.Counter
+	syncs        *artnetio.SyncSources // nil when ArtSync is ignored
+	nzsRoutes    map[config.Universe][]config.Universe
+	triggers     map[config.TriggerKey]config.Trigger
+	blackoutMu   sync.Mutex
+	blackedOut   bool
+	parkedBefore []remap.ParkedChannel // channels parked when the blackout started
+	traffic      *traffic.Counter
+	state        *state.File // nil when --state-file is empty
+	stateMu      sync.Mutex  // orders reading the state with writing it
+	flood        *flood.Limiter
+	anomalies    *anomaly.Detector
+	watchdog     *watchdog.Watchdog
+	floodPPS     int
+	hue          map[config.Universe][]*hue.Output
+	mirrors      map[config.Universe]config.Universe
+	labels       map[config.Universe]string
+	domains      []*domain.Domain
+	uarts        map[string]*uart.Output
+	outputs      *output.Registry
+	inputs       *artnetio.InputControl
+	probe        *probe.Prober
+	rdm          *rdm.Controller
+	artcmd       *artcmd.Handler
+	reload       chan *config.Config // the config to restart with
+	standby      *config.Config      // preloaded --standby-config, nil without one
 }
 
 func main() {
 	configPath := flag.String("config", "config.toml", "path to config file")
+	standbyConfig := flag.String("standby-config", "", "preload this config's mappings next to the running ones, for an instant changeover via /artmap/api/standby, 'artmap standby go' or a trigger; targets, outputs and listeners stay the running config's")
+	universeFormat := flag.String("universe-format", "dotted", "how ArtNet universes are written in logs and output: dotted (net.subnet.universe), hex (0x1A3) or decimal (419) port-address; all are accepted as input")
 	artnetListen := flag.String("artnet-listen", ":6454", "artnet listen address (empty to disable)")
 	artnetBroadcast := flag.String("artnet-broadcast", "auto", "artnet broadcast addresses (comma-separated, or 'auto')")
-	sacnInterface := flag.String("sacn-interface", "", "network interface for sACN multicast")
+	artnetPoll := flag.String("artnet-poll", "", "unicast addresses to ArtPoll every cycle, for nodes on routed subnets that broadcast polls do not reach (comma-separated); static artnet targets are always polled")
+	artnetPollReply := flag.String("artnet-poll-reply", "unicast", "where to answer ArtPoll: unicast (to the poller's source address and port) or broadcast")
+	pollReplyInterval := flag.Duration("poll-reply-interval", 0, "answer at most one ArtPoll per controller in this interval, e.g. 1s for consoles that poll several times a second; a controller's first poll is always answered at once (0 = answer every poll)")
+	artnetReplyIP := flag.String("artnet-reply-ip", "", "IP advertised in ArtPollReply (default: the local address on the poller's subnet)")
+	artnetSwitch := flag.String("artnet-switch", "", "NetSwitch.SubSwitch advertised in every ArtPollReply, e.g. 0.1 (default: from the universes of each reply); only universes in that net and sub-net are advertised")
+	probeInterval := flag.Duration("probe-interval", 5*time.Second, "how often targets with a probe setting are probed; down after three intervals without a reply")
+	broadcastPacing := flag.Duration("broadcast-pacing", 0, "minimum spacing between ArtNet packets to each broadcast address, e.g. 200us, for Wi-Fi bridges that drop back-to-back bursts (0 = off)")
+	artnetEgress := flag.String("artnet-egress", "", "interfaces to send ArtNet from (names, IPv4 addresses or subnets), comma-separated; each destination uses the interface whose subnet contains it")
+	sacnInterface := flag.String("sacn-interface", "", "network interface for sACN multicast: name, IPv4 address, subnet (see 'artmap interfaces') or 'auto'")
+	autoSubnet := flag.String("auto-subnet", "", "CIDR whose interface 'auto' selects for --artnet-broadcast and --sacn-interface (default: the interface on the configured targets' subnet)")
+	sacnGroupFilter := flag.Bool("sacn-group-filter", false, "drop received sACN for universes not in the config before parsing it, by destination multicast group (or framing-layer universe for unicast); cuts CPU on wires carrying many universes")
+	sacnBindPort := flag.Bool("sacn-bind-port", false, "send sACN from port 5568 instead of an ephemeral port")
+	dropPrivileges := flag.String("drop-privileges", "", "user to switch to once every socket is open, e.g. artmap; its groups must cover any UART devices (requires starting as root; a restart then runs as that user, so grant ports below 1024 with setcap)")
 	apiListen := flag.String("api-listen", ":8080", "HTTP API listen address (empty to disable)")
+	mdnsName := flag.String("mdns-name", "", "instance name advertised for the API over mDNS as _artmap._tcp (default artmap-<hostname>; 'off' to disable)")
+	apiAuth := flag.String("api-auth", "", "TOML file of API tokens and basic auth users with read or admin roles (empty = no authentication)")
+	apiTLSCert := flag.String("api-tls-cert", "", "serve the API over HTTPS with this PEM certificate (requires --api-tls-key)")
+	apiTLSKey := flag.String("api-tls-key", "", "PEM private key for --api-tls-cert")
+	apiClientCA := flag.String("api-client-ca", "", "require API clients to present a certificate signed by a CA in this PEM file (mTLS)")
+	apiCA := flag.String("api-ca", "", "PEM CA bundle the tui and shell commands use to verify an HTTPS API (default: system roots)")
+	apiClientCert := flag.String("api-client-cert", "", "PEM client certificate the tui and shell commands present for mTLS")
+	apiClientKey := flag.String("api-client-key", "", "PEM private key for --api-client-cert")
+	apiToken := flag.String("api-token", os.Getenv("ARTMAP_API_TOKEN"), "bearer token sent by the tui and shell commands (default $ARTMAP_API_TOKEN; basic auth can be given in --api-url)")
+	apiURL := flag.String("api-url", "", "API base URL used by the tui and shell commands (default: derived from --api-listen)")
+	conflictWindow := flag.Duration("conflict-window", 2*time.Second, "warn when different IPs send the same input universe within this window (0 = off)")
+	senderFrames := flag.Bool("sender-frames", false, "keep the last frame from each sender per universe for GET /artmap/api/senders?universe=")
+	artCommandForward := flag.Bool("artcommand-forward", false, "pass every received ArtCommand on to the ArtNet targets and discovered nodes, other than its sender (do not enable on two instances that target each other)")
+	artCommand := flag.Bool("artcommand", false, "act on ArtCommand packets to all manufacturers: ClearTargets, Reload and Message (ArtCommand is unauthenticated)")
+	artAddress := flag.Bool("artaddress", false, "accept ArtAddress packets renaming this node, reprogramming its net and sub-net switches and setting its indicator (ArtAddress is unauthenticated)")
+	inputMaxPPS := flag.Int("input-max-pps", 0, "drop inbound DMX from a source IP above this many packets per second (0 = unlimited)")
+	anomalyDrop := flag.Float64("anomaly-drop-ratio", 0.5, "report a source whose frame rate falls below this fraction of its usual rate (0 = off)")
+	anomalyJitter := flag.Float64("anomaly-jitter", 1.5, "report a source whose frame interval deviation exceeds this multiple of its mean interval (0 = off)")
+	outputWatchdog := flag.Duration("output-watchdog", 3*time.Second, "report an output universe with live input that has had no successful send for this long (0 = off)")
+	chaosSpec := flag.String("chaos", "", "FAULT INJECTION for testing only: percent of output packets to drop, delay, duplicate or reorder, e.g. drop=5,delay=10,delay-ms=50,duplicate=2,reorder=3")
+	chaosDst := flag.String("chaos-dst", "", "limit --chaos to these destination IPs, comma-separated (default: all)")
+	sendWorkers := flag.Int("send-workers", 4, "workers sending output packets in parallel, each destination always on the same worker (0 = send serially on the input goroutine)")
+	acceptOwn := flag.Bool("accept-own-packets", false, "remap ArtDmx and sACN that artmap sent itself and received back (broadcast, multicast loopback), e.g. for loopback testing; by default they are dropped so mappings can't feed back")
+	artnetSync := flag.Bool("artnet-sync", true, "honor ArtSync: hold ArtDmx from a source that sends ArtSync and remap its universes together when the next ArtSync arrives, until it sends none for 4s")
+	dmxFrames := flag.String("dmx-frames", "lenient", "malformed ArtDmx (zero, odd or over-long length, or fewer data bytes than the length): lenient drops zero-length frames and keeps the channels a truncated frame carries; strict drops every malformed frame")
+	artnetPassthrough := flag.Bool("artnet-passthrough", false, "forward Art-Net packets with opcodes the specification does not define (vendor extensions) to every ArtNet target address")
+	artnetTargetedPoll := flag.Bool("artnet-targeted-poll", true, "target broadcast ArtPolls at the Port-Addresses of the ArtNet output universes (Art-Net 4), including mirrors, so on large networks only the nodes artmap outputs to reply; off while an ArtNet wildcard destination is configured")
+	allowLoops := flag.Bool("allow-loops", false, "send an output to an IP even while that IP is feeding one of the output's source universes (normally suppressed so remapped data never echoes back to the console)")
 	senderHz := flag.Int("sender-hz", 40, "fixed sender rate in Hz (0 = send immediately on input)")
-	debug := flag.Bool("debug", false, "log incoming/outgoing dmx packets")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "collector URL that artmap's own packet path spans are posted to as OTLP JSON, e.g. http://localhost:4318; traces start in artmap and carry no W3C context (empty to disable)")
+	traceRatio := flag.Float64("trace-ratio", 0.01, "fraction of packets traced when --otlp-endpoint is set")
+	statsdAddr := flag.String("statsd", "", "StatsD/Graphite host:port for per-universe metrics (empty to disable)")
+	statsdPrefix := flag.String("statsd-prefix", "artmap", "metric name prefix for --statsd")
+	metricsInterval := flag.Duration("metrics-interval", 10*time.Second, "metrics export interval")
+	captureFile := flag.String("capture-file", "", "write all sent and received ArtNet/sACN packets to this pcap file from startup (POST /artmap/api/capture starts it at runtime)")
+	captureDir := flag.String("capture-dir", "", "directory POST /artmap/api/capture?file=<name> writes into; file must be a plain name (empty = the API can't choose a file)")
+	captureMaxMB := flag.Int("capture-max-mb", 100, "rotate the capture file after this many megabytes (0 = never)")
+	captureMaxFiles := flag.Int("capture-max-files", 5, "number of rotated capture files to keep")
+	recordFile := flag.String("record-file", "", "record input and output DMX frames to this artmap recording (convert with the export command)")
+	exportFormat := flag.String("export-format", "csv", "export command output format: csv or pcapng")
+	exportOut := flag.String("export-out", "", "export command output file (default stdout)")
+	simInput := flag.String("input", "", "simulate command input: a pcap/pcapng capture or an artmap recording")
+	simGolden := flag.String("golden", "", "simulate command golden recording of the expected outputs")
+	simTolerance := flag.Duration("simulate-tolerance", 100*time.Millisecond, "simulate command timing tolerance when matching golden output frames")
+	stateFile := flag.String("state-file", "", "file keeping master levels, parked channels and the active profile set through the API, restored on startup (empty = not kept)")
+	learnFile := flag.String("learn-file", "", "file written by POST /artmap/api/learn with captured input as [[static]] sections (empty = POST is refused)")
+	debug := &debuglog.Filter{}
+	flag.Var(debug, "debug", "log changed channels of incoming/outgoing dmx packets (optionally filtered: universes and/or IPs, comma-separated)")
+	debugInterval := flag.Duration("debug-interval", 250*time.Millisecond, "minimum interval between changed-channel debug lines per universe")
+	debugFile := flag.String("debug-file", "", "write --debug lines to this file instead of the log")
+	debugMaxMB := flag.Int("debug-max-mb", 100, "rotate the debug file after this many megabytes (0 = never)")
+	debugMaxFiles := flag.Int("debug-max-files", 5, "number of rotated debug files to keep")
 	flag.Parse()
 
+	command := ""
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	format, err := config.ParseArtNetFormat(*universeFormat)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	config.SetArtNetFormat(format)
+
+	// Commands that talk to a running instance don't need the config
+	if *apiURL == "" {
+		*apiURL = listenURL(*apiListen, *apiTLSCert != "")
+	}
+	switch command {
+	case "tui", "shell", "trace", "rdm", "profile", "masters", "transmit", "park", "release", "parked", "standby", "topology", "artcommand":
+		tlsConfig, err := auth.ClientTLSConfig(*apiCA, *apiClientCert, *apiClientKey)
+		if err != nil {
+			log.Fatalf("[%s] tls error: %v", command, err)
+		}
+		switch command {
+		case "tui":
+			err = tui.Run(*apiURL, *apiToken, tlsConfig)
+		case "shell":
+			err = shell.Run(*apiURL, *apiToken, tlsConfig, os.Stdin, os.Stdout)
+		default:
+			err = shell.Exec(*apiURL, *apiToken, tlsConfig, command+" "+strings.Join(flag.Args(), " "), os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("[%s] error: %v", command, err)
+		}
+		return
+	case "export":
+		if err := runExport(flag.Arg(0), *exportFormat, *exportOut); err != nil {
+			log.Fatalf("[export] error: %v", err)
+		}
+		return
+	case "analyze":
+		if err := runAnalyze(flag.Arg(0), os.Stdout); err != nil {
+			log.Fatalf("[analyze] error: %v", err)
+		}
+		return
+	case "interfaces":
+		if err := printInterfaces(os.Stdout); err != nil {
+			log.Fatalf("[interfaces] error: %v", err)
+		}
+		return
+	}
+
+	if *autoSubnet != "" {
+		if _, _, err := net.ParseCIDR(*autoSubnet); err != nil {
+			log.Fatalf("auto subnet error: %v", err)
+		}
+	}
+	if *sacnInterface != "" && *sacnInterface != "auto" {
+		iface, err := artnetio.ResolveInterface(*sacnInterface)
+		if err != nil {
+			log.Fatalf("sacn interface error: %v", err)
+		}
+		*sacnInterface = iface.Name
+	}
+
 	// Load config
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
 
-	log.Printf("[config] loaded mappings=%d", len(cfg.Mappings))
+	for _, w := range cfg.Warnings {
+		log.Printf("[config] warning: %s", w)
+	}
+
+	log.Printf("[config] loaded version=%d mappings=%d", cfg.Version, len(cfg.Mappings))
+
+	// Registered first so it runs after every other deferred cleanup
+	restart := false
+	defer func() {
+		if restart {
+			reexec()
+		}
+	}()
+
+	// Create one remapping engine per profile
+	profiles := profile.New(cfg)
+	statics := cfg.Statics
+	var standby *config.Config
+	if *standbyConfig != "" && command == "" {
+		if standby = loadStandby(*standbyConfig, cfg); standby != nil {
+			profiles.AddStandby(standby)
+			statics = append(slices.Clip(statics), standby.Statics...)
+		}
+	}
+	for _, st := range statics {
+		data, _ := st.Data()
+		profiles.Remap(st.Universe, data)
+	}
 
-	// Create remapping engine
-	engine := remap.NewEngine(cfg.Normalize())
+	switch command {
+	case "":
+	case "routes":
+		for _, r := range profiles.Active().Routes() {
+			fmt.Println(r)
+		}
+		return
+	case "selftest":
+		// Run once targets and broadcast addresses are resolved, before anything is bound
+	case "simulate":
+		if err := runSimulate(cfg, *simInput, *simGolden, *simTolerance, os.Stdout); err != nil {
+			log.Fatalf("[simulate] error: %v", err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command: %s", command)
+	}
 
 	// Log mappings
+	for _, g := range cfg.Groups {
+		log.Printf("[config]   group %s master=%d", g.Name, g.Level())
+	}
+	for _, in := range cfg.Inputs {
+		if in.SampleMS > 0 {
+			log.Printf("[config]   input %s sample_ms=%d", in.Universe, in.SampleMS)
+		}
+		if in.ShortFrames != "" {
+			log.Printf("[config]   input %s short_frames=%s", in.Universe, in.ShortFrames)
+		}
+		if in.Label != "" {
+			log.Printf("[config]   input %s label=%q", in.Universe, in.Label)
+		}
+	}
+	for _, o := range cfg.Outputs {
+		if o.MaxHz > 0 {
+			log.Printf("[config]   output %s max_hz=%g", o.Universe, o.MaxHz)
+		}
+		if len(o.Defaults) > 0 {
+			log.Printf("[config]   output %s defaults=%d channels", o.Universe, len(o.Defaults))
+		}
+		if o.Mirror != nil {
+			log.Printf("[config]   output %s mirror=%s", o.Universe, *o.Mirror)
+		}
+		if o.Priority > 0 {
+			log.Printf("[config]   output %s priority=%d", o.Universe, o.Priority)
+		}
+		if len(o.Fallback) > 0 {
+			log.Printf("[config]   output %s fallback=%s", o.Universe, strings.Join(o.Fallback, ","))
+		}
+		if o.Label != "" {
+			log.Printf("[config]   output %s label=%q", o.Universe, o.Label)
+		}
+	}
 	for _, m := range cfg.Mappings {
-		log.Printf("[config]   %s -> %s", m.From, m.To)
+		var opts []string
+		if m.Label != "" {
+			opts = append(opts, m.Label)
+		}
+		if t, _ := m.Transform(); t != nil {
+			opts = append(opts, t.Name())
+		}
+		if m.DelayMS > 0 {
+			opts = append(opts, fmt.Sprintf("delay %dms", m.DelayMS))
+		}
+		if m.Profile != "" {
+			opts = append(opts, "profile "+m.Profile)
+		}
+		if m.Offset != 0 {
+			opts = append(opts, fmt.Sprintf("offset %+d", m.Offset))
+		}
+		if m.Group != "" {
+			opts = append(opts, "group "+m.Group)
+		}
+		if m.FromPhysical != nil {
+			opts = append(opts, fmt.Sprintf("physical %d", *m.FromPhysical))
+		}
+		if m.FromCast != "" {
+			opts = append(opts, m.FromCast+" only")
+		}
+		if len(opts) > 0 {
+			log.Printf("[config]   %s -> %s (%s)", m.From, m.To, strings.Join(opts, ", "))
+		} else {
+			log.Printf("[config]   %s -> %s", m.From, m.To)
+		}
 	}
 
 	// Parse targets
 	artTargets := make(map[uint16]*net.UDPAddr)
 	sacnTargets := make(map[uint16][]*net.UDPAddr)
+	espTargets := make(map[uint16][]*net.UDPAddr)
 	pollTargets := make(map[string]*net.UDPAddr)
+	var targetAddrs []*net.UDPAddr
 	for _, t := range cfg.Targets {
 		addr, err := parseTargetAddr(t.Address, protocolPort(t.Universe.Protocol))
 		if err != nil {
 			log.Fatalf("target error: address=%q err=%v", t.Address, err)
 		}
+		targetAddrs = append(targetAddrs, addr)
 		switch t.Universe.Protocol {
 		case config.ProtocolArtNet:
 			artTargets[t.Universe.Number] = addr
 			pollTargets[addr.String()] = addr
 		case config.ProtocolSACN:
 			sacnTargets[t.Universe.Number] = append(sacnTargets[t.Universe.Number], addr)
+		case config.ProtocolESPNet:
+			espTargets[t.Universe.Number] = append(espTargets[t.Universe.Number], addr)
+		}
+		if t.Probe != "" {
+			log.Printf("[config]   target %s -> %s probe=%s", t.Universe, addr, t.Probe)
+		} else {
+			log.Printf("[config]   target %s -> %s", t.Universe, addr)
 		}
-		log.Printf("[config]   target %s -> %s", t.Universe, addr)
 	}
 
 	// Parse broadcast addresses
 	var broadcasts []*net.UDPAddr
 	if *artnetBroadcast != "" {
 		if *artnetBroadcast == "auto" {
-			broadcasts = detectBroadcastAddrs()
+			broadcasts = detectBroadcastAddrs(chooseAutoInterface(*autoSubnet, targetAddrs))
 		} else {
 			for _, addrStr := range strings.Split(*artnetBroadcast, ",") {
 				addrStr = strings.TrimSpace(addrStr)
@@ -97,39 +448,127 @@ func main() {
 			}
 		}
 		for _, addr := range broadcasts {
-			pollTargets[addr.String()] = addr
+			delete(pollTargets, addr.String())
 			log.Printf("[config]   broadcast %s", addr)
 		}
 	}
+	if *artnetPoll != "" {
+		for _, addrStr := range strings.Split(*artnetPoll, ",") {
+			addrStr = strings.TrimSpace(addrStr)
+			addr, err := parseTargetAddr(addrStr, artnet.Port)
+			if err != nil {
+				log.Fatalf("poll error: address=%q err=%v", addrStr, err)
+			}
+			pollTargets[addr.String()] = addr
+			log.Printf("[config]   poll %s", addr)
+		}
+	}
+
+	if *sacnInterface == "auto" {
+		*sacnInterface = ""
+		if iface := chooseAutoInterface(*autoSubnet, targetAddrs); iface != nil {
+			*sacnInterface = iface.Name
+		}
+		log.Printf("[sacn] auto interface=%q", *sacnInterface)
+	}
+
+	if command == "selftest" {
+		poll := append(slices.Clone(broadcasts), slices.Collect(maps.Values(pollTargets))...)
+		if err := runSelftest(cfg, *artnetListen, *sacnInterface, *apiListen, targetAddrs, poll, os.Stdout); err != nil {
+			log.Fatalf("[selftest] error: %v", err)
+		}
+		return
+	}
 
 	// Create ArtNet sender
-	artSender, err := artnet.NewSender()
+	artSender, err := artnetio.NewSender()
 	if err != nil {
 		log.Fatalf("artnet sender error: %v", err)
 	}
-	defer artSender.Close()
+	if *artnetEgress != "" {
+		for _, sel := range strings.Split(*artnetEgress, ",") {
+			iface, err := artnetio.ResolveInterface(strings.TrimSpace(sel))
+			if err != nil {
+				log.Fatalf("artnet egress error: %v", err)
+			}
+			if err := artSender.AddInterface(iface.Name); err != nil {
+				log.Fatalf("artnet egress error: interface=%s err=%v", iface.Name, err)
+			}
+			log.Printf("[artnet] egress interface=%s", iface.Name)
+		}
+	}
+	if *broadcastPacing > 0 {
+		artSender.SetBroadcastPacing(*broadcastPacing, broadcasts)
+		log.Printf("[artnet] broadcast pacing spacing=%s", *broadcastPacing)
+	}
+
+	// Create sACN receiver for all source universes; a wildcard alone joins no
+	// groups and only hears unicast sACN
+	var sacnReceiver *sacnio.Receiver
+	sacnUniverses := cfg.SACNSourceUniverses()
+	if len(sacnUniverses) > 0 || cfg.Wildcard(config.ProtocolSACN) {
+		var iface *net.Interface
+		if *sacnInterface != "" {
+			iface, _ = net.InterfaceByName(*sacnInterface)
+		}
+		sacnSources := cfg.SACNSources()
+		sacnReceiver, err = sacnio.NewMultiUniverseReceiver(iface, sacnUniverses, sacnSources)
+		if err != nil {
+			log.Fatalf("[sacn] failed to create receiver: %v", err)
+		}
+		for _, u := range slices.Sorted(maps.Keys(sacnSources)) {
+			log.Printf("[sacn] source-specific universe=%d sources=%v", u, sacnSources[u])
+		}
+	}
 
-	sacnSender, err := sacn.NewSender("artmap", *sacnInterface)
+	// Create sACN sender, sending from the receiver's port when bound to 5568
+	var sacnSender *sacnio.Sender
+	switch {
+	case *sacnBindPort && sacnReceiver != nil:
+		sacnSender, err = sacnio.NewSenderFromConn(sacnReceiver.UDPConn(), "artmap", *sacnInterface)
+	case *sacnBindPort:
+		sacnSender, err = sacnio.NewSender("artmap", *sacnInterface, sacn.Port)
+	default:
+		sacnSender, err = sacnio.NewSender("artmap", *sacnInterface, 0)
+	}
 	if err != nil {
 		log.Fatalf("sacn sender error: %v", err)
 	}
-	defer sacnSender.Close()
+	log.Printf("[sacn] sending from addr=%s", sacnSender.LocalAddr())
 
-	for _, u := range engine.DestSACNUniverses() {
-		sacnSender.RegisterUniverse(u)
+	mirrors := cfg.Mirrors()
+	sacnDests := profiles.DestUniverses(config.ProtocolSACN)
+	for _, m := range mirrors {
+		if m.Protocol == config.ProtocolSACN {
+			sacnDests = append(sacnDests, m)
+		}
+	}
+	for _, u := range sacnDests {
+		sacnSender.RegisterUniverse(u.Number)
+	}
+	for u, p := range cfg.SACNPriorities() {
+		sacnSender.SetPriority(u, p)
 	}
 	sacnSender.StartDiscovery()
 
+	// Create ESP Net sender
+	espSender, err := espnet.NewSender()
+	if err != nil {
+		log.Fatalf("espnet sender error: %v", err)
+	}
+
 	// Create discovery
-	destNums := engine.DestArtNetUniverses()
-	inputUnivs := make([]artnet.Universe, len(destNums))
-	for i, n := range destNums {
-		inputUnivs[i] = artnet.Universe(n)
+	var inputUnivs, outputUnivs []artnet.Universe
+	for _, u := range profiles.DestUniverses(config.ProtocolArtNet) {
+		inputUnivs = append(inputUnivs, u.ArtNet())
 	}
-	srcNums := engine.SourceArtNetUniverses()
-	outputUnivs := make([]artnet.Universe, len(srcNums))
-	for i, n := range srcNums {
-		outputUnivs[i] = artnet.Universe(n)
+	for _, m := range mirrors {
+		if m.Protocol == config.ProtocolArtNet && !slices.Contains(inputUnivs, m.ArtNet()) {
+			inputUnivs = append(inputUnivs, m.ArtNet())
+		}
+	}
+	for _, u := range profiles.SourceUniverses(config.ProtocolArtNet) {
+		outputUnivs = append(outputUnivs, u.ArtNet())
 	}
 
 	// Get local interface info for discovery
@@ -139,241 +578,2408 @@ func main() {
 		broadcastIP = broadcasts[0].IP
 		localIP, localMAC = detectLocalInterface(broadcastIP)
 	}
-	discovery := artnet.NewDiscovery(artSender, localIP, broadcastIP, localMAC, "artmap", "artmap", inputUnivs, outputUnivs)
+	discovery := artnetio.NewDiscovery(artSender, localIP, broadcastIP, localMAC, "artmap", "artmap", inputUnivs, outputUnivs)
+	replyMode, err := artnetio.ParseReplyMode(*artnetPollReply)
+	if err != nil {
+		log.Fatalf("artnet error: %v", err)
+	}
+	discovery.SetReplyMode(replyMode)
+	if *pollReplyInterval > 0 {
+		discovery.SetReplyInterval(*pollReplyInterval)
+		log.Printf("[artnet] poll reply interval=%s", *pollReplyInterval)
+	}
+	discovery.SetPollTargets(slices.SortedFunc(maps.Values(pollTargets), func(a, b *net.UDPAddr) int {
+		return strings.Compare(a.String(), b.String())
+	}))
+	if *artnetTargetedPoll {
+		// Wildcard destinations only resolve as input arrives, so no range can cover them
+		wildcard := slices.ContainsFunc(cfg.Mappings, func(m config.Mapping) bool {
+			return m.To.Wildcard && m.To.Universe.Protocol == config.ProtocolArtNet
+		})
+		switch {
+		case wildcard:
+			log.Printf("[artnet] targeted polls off: artnet wildcard destinations are configured")
+		case len(inputUnivs) > 0:
+			discovery.SetPollUniverses(inputUnivs)
+			log.Printf("[artnet] targeted polls universes=%d ranges=%d", len(inputUnivs), len(artnetio.PollRanges(inputUnivs)))
+		}
+	}
+	if *artnetReplyIP != "" {
+		ip := net.ParseIP(*artnetReplyIP)
+		if ip == nil || ip.To4() == nil {
+			log.Fatalf("artnet error: invalid reply ip %q", *artnetReplyIP)
+		}
+		_, mac, _ := artnetio.InterfaceFor(ip)
+		discovery.SetLocalIP(ip, mac)
+	}
+	if *artnetSwitch != "" {
+		netSwitch, subSwitch, err := parseSwitch(*artnetSwitch)
+		if err != nil {
+			log.Fatalf("artnet error: %v", err)
+		}
+		discovery.SetSwitches(netSwitch, subSwitch)
+		log.Printf("[artnet] identity net_switch=%d sub_switch=%d", netSwitch, subSwitch)
+	}
 
 	// Create app
 	app := &App{
 		cfg:         cfg,
 		artSender:   artSender,
 		sacnSender:  sacnSender,
+		espSender:   espSender,
 		discovery:   discovery,
-		engine:      engine,
+		profiles:    profiles,
+		mirrors:     mirrors,
+		labels:      cfg.Labels(),
 		senders:     senders.New(),
+		health:      health.New(5),
+		learn:       learn.New(),
+		learnFile:   *learnFile,
+		capture:     capture.New(int64(*captureMaxMB)<<20, *captureMaxFiles),
+		captureFile: *captureFile,
+		captureDir:  *captureDir,
 		artTargets:  artTargets,
+		fallbacks:   cfg.Fallbacks(),
+		fallback:    fallback.NewTracker(),
 		sacnTargets: sacnTargets,
+		espTargets:  espTargets,
 		senderHz:    *senderHz,
-		debug:       *debug,
+		allowLoops:  *allowLoops,
+		fanout:      fanout.New(*sendWorkers),
+		debug:       debug,
+		differ:      debuglog.NewDiffer(*debugInterval),
+		monitor:     monitor.New(),
+		snapshots:   snapshot.New(),
+		coalesce:    coalesce.New(cfg.HeldShortFrames()),
+		datagrams:   datagram.NewCounter(),
+		nzsRoutes:   cfg.NZSRoutes(),
+		triggers:    cfg.TriggerActions(),
+		traffic:     traffic.New(),
+		flood:       flood.New(*inputMaxPPS),
+		floodPPS:    *inputMaxPPS,
+		anomalies:   anomaly.New(*anomalyDrop, *anomalyJitter),
+		watchdog:    watchdog.New(*outputWatchdog),
+		artcmd:      artcmd.NewHandler(),
+		reload:      make(chan *config.Config, 1),
+		standby:     standby,
+	}
+
+	if len(broadcasts) > 0 {
+		app.broadcast.Store(broadcasts[0])
+	}
+	if *artnetPassthrough {
+		seen := map[string]bool{}
+		app.passthrough = []*net.UDPAddr{}
+		for _, addr := range artTargets {
+			if !seen[addr.String()] {
+				seen[addr.String()] = true
+				app.passthrough = append(app.passthrough, addr)
+			}
+		}
+		slices.SortFunc(app.passthrough, func(a, b *net.UDPAddr) int { return strings.Compare(a.String(), b.String()) })
+		log.Printf("[artnet] passthrough targets=%d", len(app.passthrough))
+	}
+	if *stateFile != "" {
+		app.state = state.NewFile(*stateFile)
+		app.restoreState()
+	}
+
+	// Probe targets that ask for it, so a dead gateway shows in /status before it is needed
+	if *probeInterval <= 0 {
+		log.Fatalf("[probe] error: --probe-interval must be positive")
+	}
+	prober := probe.New(*probeInterval, func(ip net.IP) error {
+		return artSender.SendPoll(&net.UDPAddr{IP: ip, Port: artnet.Port})
+	})
+	for _, t := range cfg.Targets {
+		if t.Probe == "" {
+			continue
+		}
+		addr, _ := parseTargetAddr(t.Address, protocolPort(t.Universe.Protocol))
+		prober.Add(addr.IP, t.Probe)
+	}
+	if prober.Len() > 0 {
+		prober.SetOnChange(func(ip net.IP, up bool) {
+			if up {
+				app.health.Retry(ip)
+			}
+		})
+		app.probe = prober
+	}
+
+	if *artCommandForward {
+		app.artcmd.SetForward(app.forwardCommand)
+		log.Printf("[artcommand] forwarding to downstream nodes")
+	}
+	if *artCommand {
+		app.artcmd.On("ClearTargets", func(src *net.UDPAddr, _ string) {
+			log.Printf("[artcommand] ClearTargets src=%s retrying unhealthy destinations", src.IP)
+			app.health.Retry(nil)
+		})
+		app.artcmd.On("Reload", func(src *net.UDPAddr, _ string) {
+			if !canReexec {
+				log.Printf("[artcommand] Reload src=%s ignored: restarting in place is not supported on %s", src.IP, runtime.GOOS)
+				return
+			}
+			next, err := config.Load(*configPath)
+			if err != nil {
+				log.Printf("[artcommand] Reload src=%s ignored: %v", src.IP, err)
+				return
+			}
+			log.Printf("[artcommand] Reload src=%s restarting", src.IP)
+			select {
+			case app.reload <- next:
+			default:
+			}
+		})
+		app.artcmd.On("Message", func(src *net.UDPAddr, text string) {
+			log.Printf("[artcommand] message src=%s text=%q", src.IP, text)
+		})
+		log.Printf("[artcommand] accepting ClearTargets, Reload and Message")
+	}
+
+	app.hue = map[config.Universe][]*hue.Output{}
+	for i, h := range cfg.Hue {
+		lights := make([]hue.Light, len(h.Channels))
+		for j, id := range h.Channels {
+			lights[j] = hue.Light{ID: byte(id), DMXChan: h.From.ChannelStart - 1 + 3*j}
+		}
+		var roots *x509.CertPool
+		if h.CAFile != "" {
+			pem, err := os.ReadFile(h.CAFile)
+			if err != nil {
+				log.Fatalf("hue %d error: %v", i, err)
+			}
+			roots = x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				log.Fatalf("hue %d error: no certificates in %s", i, h.CAFile)
+			}
+		} else {
+			log.Printf("[hue] warning: bridge=%s has no ca_file, so its certificate is only matched by bridge_id", h.Bridge)
+		}
+		out, err := hue.New(h.Bridge, h.BridgeID, roots, h.ApplicationKey, h.ClientKey, h.EntertainmentConfig, lights)
+		if err != nil {
+			log.Fatalf("hue %d error: %v", i, err)
+		}
+		out.Start()
+		app.hue[h.From.Universe] = append(app.hue[h.From.Universe], out)
+		log.Printf("[hue] bridge=%s from=%s lights=%d", h.Bridge, h.From, len(lights))
+	}
+
+	app.uarts = map[string]*uart.Output{}
+	for _, u := range profiles.DestUniverses(config.ProtocolUART) {
+		out := uart.New(u.Device)
+		out.Start()
+		app.uarts[u.Device] = out
+		log.Printf("[uart] device=%s", u.Device)
+	}
+
+	app.outputs = output.NewRegistry()
+	app.outputs.Register(config.ProtocolArtNet, &artnetDriver{a: app})
+	app.outputs.Register(config.ProtocolSACN, &sacnDriver{a: app})
+	app.outputs.Register(config.ProtocolESPNet, &espnetDriver{a: app})
+	app.outputs.Register(config.ProtocolUART, &uartDriver{a: app})
+
+	if *chaosSpec != "" {
+		chaosCfg, err := chaos.ParseConfig(*chaosSpec)
+		if err != nil {
+			log.Fatalf("[chaos] error: %v", err)
+		}
+		var dsts []net.IP
+		if *chaosDst != "" {
+			for _, s := range strings.Split(*chaosDst, ",") {
+				ip := net.ParseIP(strings.TrimSpace(s))
+				if ip == nil {
+					log.Fatalf("[chaos] error: invalid destination ip %q", s)
+				}
+				dsts = append(dsts, ip)
+			}
+		}
+		app.chaos = chaos.New(chaosCfg, dsts)
+		log.Printf("[chaos] WARNING: FAULT INJECTION ENABLED %s dst=%s -- do not use during a show", chaosCfg, cmp.Or(*chaosDst, "all"))
+	}
+
+	if *otlpEndpoint != "" {
+		app.tracer = tracing.New(*otlpEndpoint, "artmap", *traceRatio)
+		app.tracer.Start()
+		defer app.tracer.Stop()
+		log.Printf("[tracing] exporting endpoint=%s ratio=%g", *otlpEndpoint, *traceRatio)
+	}
+
+	if *statsdAddr != "" {
+		statsd, err := metrics.NewStatsD(*statsdAddr, *statsdPrefix)
+		if err != nil {
+			log.Fatalf("[metrics] statsd error: %v", err)
+		}
+		defer statsd.Close()
+		app.metrics = metrics.New(statsd)
+		app.metrics.Start(*metricsInterval)
+		profiles.Observe(func(u config.Universe, data [512]byte, changed remap.Mask) {
+			app.metrics.Inc("output."+app.metricKey(u)+".changed", uint64(changed.Count()))
+		})
+		defer app.metrics.Stop()
+		log.Printf("[metrics] exporting statsd=%s prefix=%s interval=%s", *statsdAddr, *statsdPrefix, *metricsInterval)
+	}
+
+	tap := func(src, dst *net.UDPAddr, data []byte) {
+		app.capture.Packet(src, dst, data)
+		app.traffic.Packet(src, dst, data)
+	}
+	artSender.SetTap(tap)
+	sacnSender.SetTap(tap)
+	espSender.SetTap(tap)
+	discovery.SetReport(app.traffic.Report)
+	if *recordFile != "" {
+		rec, err := recording.Create(*recordFile)
+		if err != nil {
+			log.Fatalf("[recording] error: file=%s err=%v", *recordFile, err)
+		}
+		app.recording = rec
+		log.Printf("[recording] writing file=%s", *recordFile)
+	}
+
+	if *captureFile != "" {
+		if err := app.capture.Start(*captureFile); err != nil {
+			log.Fatalf("[capture] error: file=%s err=%v", *captureFile, err)
+		}
+		log.Printf("[capture] writing file=%s", *captureFile)
+	}
+	defer app.capture.Stop()
+
+	if debug.Enabled() {
+		app.debugLog, err = debuglog.NewWriter(*debugFile, int64(*debugMaxMB)<<20, *debugMaxFiles)
+		if err != nil {
+			log.Fatalf("[debug] error: file=%s err=%v", *debugFile, err)
+		}
+		defer app.debugLog.Close()
+		if *debugFile != "" {
+			log.Printf("[debug] writing file=%s max_mb=%d max_files=%d", *debugFile, *debugMaxMB, *debugMaxFiles)
+		}
+	}
+
+	app.senders.SetKeepFrames(*senderFrames)
+	app.senders.SetConflictDetection(*conflictWindow, func(c senders.Conflict) {
+		if c.Active {
+			log.Printf("[conflict] WARNING multiple sources on input universe=%s%s sources=%s", c.Universe, app.label(c.Universe), strings.Join(c.Sources, ","))
+			app.metrics.Inc("input.conflicts", 1)
+		} else {
+			log.Printf("[conflict] cleared: universe=%s%s", c.Universe, app.label(c.Universe))
+		}
+	})
+	app.anomalies.SetOnEvent(func(e anomaly.Event) {
+		if e.Active {
+			log.Printf("[anomaly] %s src=%s universe=%s%s expected=%.1ffps observed=%.1ffps jitter=%.2f",
+				e.Kind, e.Source, e.Universe, app.label(e.Universe), e.ExpectedFPS, e.ObservedFPS, e.Jitter)
+			app.metrics.Inc("anomaly."+string(e.Kind), 1)
+		} else {
+			log.Printf("[anomaly] %s cleared: src=%s universe=%s%s observed=%.1ffps", e.Kind, e.Source, e.Universe, app.label(e.Universe), e.ObservedFPS)
+		}
+	})
+
+	app.watchdog.SetOnEvent(func(e watchdog.Event) {
+		if e.Active {
+			log.Printf("[watchdog] stalled: universe=%s%s reason=%s errors=%d", e.Universe, app.label(e.Universe), e.Reason, e.Errors)
+			app.metrics.Inc("watchdog.stalled", 1)
+		} else {
+			log.Printf("[watchdog] cleared: universe=%s%s", e.Universe, app.label(e.Universe))
+		}
+	})
+
+	// Retry a node as soon as it answers a poll again
+	discovery.SetOnChange(func(node *artnet.Node) {
+		app.health.Retry(node.IP)
+	})
+
+	// Send a node that appears the current look of its universes instead of
+	// leaving it dark until the next input frame. Only the new node gets it, so
+	// the fallback chain and other destinations of the universe are untouched.
+	discovery.SetOnDiscover(func(addr *net.UDPAddr, outputs []artnet.Universe) {
+		frames := app.profiles.Active().Frames()
+		for _, au := range outputs {
+			u := config.ArtNetUniverse(au)
+			data, ok := frames[u]
+			if !ok {
+				continue
+			}
+			log.Printf("[artnet] backfill node=%s universe=%s%s", addr.IP, u, app.label(u))
+			app.metrics.Inc("output.backfills", 1)
+			app.dispatch("[->artnet]", u, addr, func() error {
+				return app.artSender.SendDMX(addr, au, data[:])
+			})
+		}
+	})
+
+	// Input sources by name, run together once everything is wired up
+	sources := map[string]input.Source{}
+
+	// Our own packets received back, dropped unless --accept-own-packets
+	own := echo.New()
+	own.AddSender(artSender.LocalAddrs()...)
+	own.AddCID(sacnSender.CID())
+	ignoreArtNet := func(src *net.UDPAddr) bool {
+		if *acceptOwn || !own.ArtNet(src) {
+			return false
+		}
+		app.metrics.Inc("input.own_dropped", 1)
+		return true
+	}
+	ignoreSACN := func(cid [16]byte) bool {
+		if *acceptOwn || !own.SACN(cid) {
+			return false
+		}
+		app.metrics.Inc("input.own_dropped", 1)
+		return true
+	}
+
+	// Create ArtNet receiver if enabled
+	artnetBound := false
+	if *artnetListen != "" {
+		addr, err := parseListenAddr(*artnetListen)
+		if err != nil {
+			log.Fatalf("artnet listen error: %v", err)
+		}
+		artnetBound = addr.IP != nil && !addr.IP.IsUnspecified()
+		artReceiver, err := artnetio.NewReceiver(addr, app)
+		if err != nil {
+			log.Fatalf("artnet receiver error: %v", privilege.Explain(err, privilege.NetBindService))
+		}
+		app.artReceiver = artReceiver
+		discovery.SetReceiver(artReceiver)
+		// Nodes answer RDM to port 6454, so requests go out from the receiver socket
+		app.rdm = rdm.NewController(artReceiver.SendTo)
+		if *artnetSync {
+			app.syncs = artnetio.NewSyncSources()
+		}
+		artReceiver.SetOtherHandler(func(src *net.UDPAddr, opCode uint16, data []byte) {
+			if opCode == artnet.OpSync {
+				app.handleSync(src)
+				return
+			}
+			if opCode == artnetio.OpNzs {
+				app.handleNzs(src, data)
+				return
+			}
+			if opCode == artcmd.OpCommand {
+				app.artcmd.Handle(src, data)
+				return
+			}
+			if opCode == artnetio.OpTrigger {
+				app.handleTrigger(src, data)
+				return
+			}
+			if opCode == artnetio.OpIPProg {
+				app.handleIPProg(src, data)
+				return
+			}
+			if opCode == artnetio.OpAddress {
+				if *artAddress {
+					app.handleAddress(src, data)
+				}
+				return
+			}
+			if op, ok := artnetio.UnknownOpCode(data); ok {
+				app.passThrough(src, op, data)
+				return
+			}
+			app.rdm.Handle(src, opCode, data)
+		})
+		dmxMode, err := artnetio.ParseDMXMode(*dmxFrames)
+		if err != nil {
+			log.Fatalf("artnet error: %v", err)
+		}
+		artReceiver.SetDMXMode(dmxMode, func(src *net.UDPAddr, pkt *artnet.DMXPacket, fault artnetio.DMXFault) {
+			app.metrics.Inc("input.malformed."+string(fault), 1)
+			app.coalesce.Malformed(config.ArtNetUniverse(pkt.Universe), src.IP, pkt.Physical, string(fault), int(pkt.Length), string(dmxMode))
+		})
+		artReceiver.SetTap(app.capture.Packet)
+		artReceiver.SetIgnore(ignoreArtNet)
+		artReceiver.SetOnSize(app.droppedDatagram("artnet"))
+		artReceiver.SetOnRebind(func(err error) {
+			app.metrics.Inc("receiver.artnet.rebinds", 1)
+			log.Printf("[artnet] receiver rebound addr=%s after err=%v", addr, err)
+		})
+		sources["artnet"] = artReceiver
+		log.Printf("[artnet] listening addr=%s", addr)
+	}
+
+	// Start sACN receiver
+	if sacnReceiver != nil {
+		switch {
+		case *sacnGroupFilter && cfg.Wildcard(config.ProtocolSACN):
+			log.Printf("[sacn] warning: --sacn-group-filter is off because a mapping takes any sACN universe")
+		case *sacnGroupFilter:
+			sacnReceiver.SetGroupFilter(func() {
+				app.metrics.Inc("receiver.sacn.filtered", 1)
+			})
+		}
+		sacnReceiver.SetTap(app.capture.Packet)
+		sacnReceiver.SetIgnore(ignoreSACN)
+		sacnReceiver.SetOnSize(app.droppedDatagram("sacn"))
+		sacnReceiver.SetOnRebind(func(err error) {
+			app.metrics.Inc("receiver.sacn.rebinds", 1)
+			log.Printf("[sacn] receiver rebound universes=%v after err=%v", sacnUniverses, err)
+			if *sacnBindPort {
+				if err := sacnSender.SetConn(sacnReceiver.UDPConn()); err != nil {
+					log.Printf("[sacn] sender rebind error: %v", err)
+				}
+			}
+		})
+		app.sacnReceiver = sacnReceiver
+		sources["sacn"] = sacnReceiver
+		log.Printf("[sacn] listening universes=%v", sacnUniverses)
+	}
+
+	for i, sc := range cfg.Sources {
+		src, err := input.FromConfig(sc)
+		if err != nil {
+			log.Fatalf("source %d error: %v", i, err)
+		}
+		sources[fmt.Sprintf("%s%d", sc.Type, i)] = src
+		log.Printf("[input] source=%s%d type=%s", sc.Type, i, sc.Type)
+	}
+
+	// Send delayed and rate-limited frames as they come due when sending immediately on input
+	if *senderHz == 0 && profiles.HasDeferredOutputs() {
+		app.due = remap.NewDueTimer(profiles.NextDue, app.flushOutputs, nil)
+	}
+
+	inputCtx, stopInputs := context.WithCancel(context.Background())
+	inputsDone := make(chan struct{})
+	go func() {
+		defer close(inputsDone)
+		input.Run(inputCtx, sources, app.handleFrame, func(name string, err error) {
+			if err != nil {
+				log.Printf("[input] source=%s stopped err=%v", name, err)
+			}
+		})
+	}()
+
+	// Isolated routing domains, each with its own listener and sockets
+	for _, dc := range cfg.Domains {
+		d, err := domain.New(dc)
+		if err != nil {
+			log.Fatalf("[domain] error: name=%s err=%v", dc.Name, privilege.Explain(err, privilege.NetBindService))
+		}
+		d.Start(*senderHz)
+		app.domains = append(app.domains, d)
+		log.Printf("[domain] listening name=%s addr=%s mappings=%d targets=%d", dc.Name, dc.ArtNetListen, len(dc.Mappings), len(dc.Targets))
 	}
 
-	// Create ArtNet receiver if enabled
-	if *artnetListen != "" {
-		addr, err := parseListenAddr(*artnetListen)
-		if err != nil {
-			log.Fatalf("artnet listen error: %v", err)
+	app.inputs = artnetio.NewInputControl(artSender)
+	for _, n := range cfg.NodeInputs {
+		in := artnetio.NodeInput{Address: n.Address, BindIndex: uint8(n.BindIndex), Disabled: n.Disabled()}
+		if err := app.inputs.Set(in); err != nil {
+			log.Printf("[artnet] input send error: dst=%s err=%v", n.Address, err)
+		}
+		log.Printf("[artnet] node input addr=%s bind=%d disable=%v", n.Address, n.BindIndex, n.Disable)
+	}
+	app.inputs.Start()
+
+	// Follow interface address changes (cable re-plug, DHCP renew) without a restart
+	watcher := netwatch.New(2*time.Second, func() {
+		log.Printf("[net] interface addresses changed")
+		own.Refresh()
+		if *artnetBroadcast == "auto" {
+			if detected := detectBroadcastAddrs(chooseAutoInterface(*autoSubnet, targetAddrs)); len(detected) > 0 {
+				app.broadcast.Store(detected[0])
+			}
+		}
+		if bcast := app.broadcast.Load(); bcast != nil {
+			ip, mac := detectLocalInterface(bcast.IP)
+			discovery.UpdateInterface(ip, bcast.IP, mac)
+			log.Printf("[net] broadcast=%s local=%s", bcast, ip)
+		}
+		if artnetBound {
+			app.artReceiver.Rebind()
+		}
+		if app.sacnReceiver != nil {
+			app.sacnReceiver.Rebind()
+		}
+	})
+	watcher.Start()
+
+	// Start discovery only if we have ArtNet outputs
+	if len(inputUnivs) > 0 || len(artTargets) > 0 {
+		discovery.Start()
+	}
+
+	if app.probe != nil {
+		app.probe.Start()
+		log.Printf("[probe] probing targets=%d interval=%s", app.probe.Len(), *probeInterval)
+	}
+
+	// Start HTTP API server
+	if *apiListen != "" {
+		var authenticator *auth.Authenticator
+		if *apiAuth != "" {
+			authenticator, err = auth.Load(*apiAuth)
+			if err != nil {
+				log.Fatalf("[api] auth error: file=%s err=%v", *apiAuth, err)
+			}
+			log.Printf("[api] authentication enabled file=%s", *apiAuth)
+		}
+		var tlsConfig *tls.Config
+		if *apiTLSCert != "" || *apiTLSKey != "" {
+			tlsConfig, err = auth.ServerTLSConfig(*apiTLSCert, *apiTLSKey, *apiClientCA)
+			if err != nil {
+				log.Fatalf("[api] tls error: %v", err)
+			}
+		} else if *apiClientCA != "" {
+			log.Fatalf("[api] tls error: --api-client-ca requires --api-tls-cert and --api-tls-key")
+		}
+		// Bound here rather than in the server goroutine so the port is open before --drop-privileges
+		ln, err := net.Listen("tcp", *apiListen)
+		if err != nil {
+			log.Printf("[api] server error: %v", privilege.Explain(err, privilege.NetBindService))
+		}
+		go func() {
+			if ln == nil {
+				return
+			}
+			mux := http.NewServeMux()
+			mux.HandleFunc("/artmap/api/status", app.handleStatus)
+			mux.HandleFunc("/artmap/api/routes", app.handleRoutes)
+			mux.HandleFunc("/artmap/api/topology", app.handleTopology)
+			mux.HandleFunc("/artmap/api/trace", app.handleTrace)
+			mux.HandleFunc("/artmap/api/dmx", app.handleDMX)
+			mux.HandleFunc("/artmap/api/activity", app.handleActivity)
+			mux.HandleFunc("/artmap/api/channels", app.handleChannels)
+			mux.HandleFunc("/artmap/api/snapshots", app.handleSnapshots)
+			mux.HandleFunc("/artmap/api/learn", app.handleLearn)
+			mux.HandleFunc("/artmap/api/capture", app.handleCapture)
+			mux.HandleFunc("/artmap/api/inputs", app.handleInputs)
+			mux.HandleFunc("/artmap/api/rdm", app.handleRDM)
+			mux.HandleFunc("/artmap/api/profile", app.handleProfile)
+			mux.HandleFunc("/artmap/api/standby", app.handleStandby)
+			mux.HandleFunc("/artmap/api/masters", app.handleMasters)
+			mux.HandleFunc("/artmap/api/transmit", app.handleTransmit)
+			mux.HandleFunc("/artmap/api/senders", app.handleSenders)
+			mux.HandleFunc("/artmap/api/artcommand", app.handleArtCommand)
+			server := &http.Server{
+				Addr:      *apiListen,
+				Handler:   authenticator.Middleware(mux),
+				TLSConfig: tlsConfig,
+			}
+			var err error
+			if tlsConfig != nil {
+				log.Printf("[api] listening addr=%s tls=true mtls=%t", *apiListen, *apiClientCA != "")
+				err = server.ServeTLS(ln, "", "")
+			} else {
+				log.Printf("[api] listening addr=%s", *apiListen)
+				err = server.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Printf("[api] server error: %v", err)
+			}
+		}()
+	}
+
+	// Advertise the API so clients can find this instance without its address
+	var responder *mdns.Responder
+	if *apiListen != "" && *mdnsName != "off" {
+		_, portStr, _ := net.SplitHostPort(*apiListen)
+		port, _ := strconv.Atoi(portStr)
+		hostname, _ := os.Hostname()
+		name := *mdnsName
+		if name == "" {
+			name = "artmap-" + hostname
+		}
+		txt := []string{"path=/artmap/api", fmt.Sprintf("tls=%t", *apiTLSCert != ""), fmt.Sprintf("auth=%t", *apiAuth != "")}
+		responder, err = mdns.New(name, hostname, port, txt)
+		if err != nil {
+			log.Printf("[mdns] disabled: %v", err)
+		} else {
+			responder.Start()
+			log.Printf("[mdns] advertising name=%q service=%s port=%d", name, mdns.Service, port)
+		}
+	}
+
+	if *dropPrivileges != "" {
+		u, err := privilege.Drop(*dropPrivileges)
+		if err != nil {
+			log.Fatalf("[privilege] error: drop to user=%s: %v", *dropPrivileges, err)
+		}
+		log.Printf("[privilege] running as user=%s uid=%s gid=%s", u.Username, u.Uid, u.Gid)
+	}
+	if sacnReceiver != nil && *sacnInterface != "" && !privilege.Has(privilege.NetRaw) {
+		log.Printf("[privilege] warning: no %s, so sACN IGMP reports and query answers through pcap are off; the kernel's own membership reports still go out (grant it with: %s)",
+			privilege.NetRaw, privilege.Hint(privilege.NetRaw))
+	}
+
+	// Start stats printer
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			app.printStats()
+		}
+	}()
+
+	// Retry unhealthy destinations each discovery cycle
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			app.health.Retry(nil)
+		}
+	}()
+
+	// Check input frame rates for anomalies and outputs for stalls
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			app.anomalies.Evaluate()
+			app.watchdog.Evaluate(app.watchedOutputs())
+		}
+	}()
+
+	// Start sender expiration
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			app.senders.Expire(30 * time.Second)
+			app.coalesce.Expire(30 * time.Second)
+		}
+	}()
+
+	// Start fixed-rate sender
+	if *senderHz > 0 {
+		log.Printf("[sender] starting at %dHz", *senderHz)
+		go func() {
+			ticker := time.NewTicker(time.Second / time.Duration(*senderHz))
+			defer ticker.Stop()
+			for range ticker.C {
+				app.flushOutputs()
+			}
+		}()
+	}
+
+	// Wait for interrupt
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	var next *config.Config
+	select {
+	case <-sigChan:
+	case next = <-app.reload:
+		restart = true
+	}
+
+	log.Println("[main] shutting down")
+	// Inputs stop first so no Remap is in flight while the last frames go out
+	stopInputs()
+	<-inputsDone
+	if next != nil {
+		app.releaseOrphans(next)
+	}
+	for _, d := range app.domains {
+		d.Stop()
+	}
+	watcher.Stop()
+	responder.Stop()
+	discovery.Stop()
+	if app.probe != nil {
+		app.probe.Stop()
+	}
+	app.inputs.Stop()
+	app.fanout.Stop()
+	app.recording.Close()
+	for _, outs := range app.hue {
+		for _, out := range outs {
+			out.Stop()
+		}
+	}
+	if err := app.outputs.Close(); err != nil {
+		log.Printf("[main] close outputs error: %v", err)
+	}
+}
+
+// releaseOrphans sends a zero frame to every output universe that next no
+// longer outputs, so fixtures it fed don't hold their last look after a restart
+func (a *App) releaseOrphans(next *config.Config) {
+	kept := profile.New(next)
+	for _, proto := range []config.Protocol{config.ProtocolArtNet, config.ProtocolSACN, config.ProtocolESPNet, config.ProtocolUART} {
+		keep := kept.DestUniverses(proto)
+		for _, u := range a.profiles.DestUniverses(proto) {
+			if !slices.Contains(keep, u) {
+				log.Printf("[main] releasing universe=%s%s", u, a.label(u))
+				a.sendOutput(remap.Output{Universe: u})
+			}
+		}
+	}
+}
+
+// HandleDMX implements artnet.PacketHandler
+func (a *App) HandleDMX(src *net.UDPAddr, pkt *artnet.DMXPacket) {
+	a.handleFrame(input.Frame{Universe: config.ArtNetUniverse(pkt.Universe), Src: src, Physical: int(pkt.Physical),
+		Sequence: pkt.Sequence, Length: int(min(pkt.Length, 512)), Data: pkt.Data})
+}
+
+// handleFrame handles a frame from any input source
+func (a *App) handleFrame(f input.Frame) {
+	u := f.Universe
+	tag := "[<-" + string(u.Protocol) + "]"
+	if a.debug.Match(u, f.Src.IP) {
+		if diff, ok := a.differ.Diff("<-", u, f.Data); ok {
+			if f.Physical == input.NoPhysical {
+				a.debugLog.Printf("%s src=%s universe=%s seq=%d %s", tag, f.Src.IP, u, f.Sequence, diff)
+			} else {
+				a.debugLog.Printf("%s src=%s universe=%s seq=%d phys=%d len=%d %s",
+					tag, f.Src.IP, u, f.Sequence, f.Physical, f.Length, diff)
+			}
+		}
+	}
+	if u.Protocol == config.ProtocolArtNet && f.Physical != input.NoPhysical {
+		a.coalesce.Fill(u, f.Src.IP, uint8(f.Physical), f.Length, &f.Data)
+	}
+	a.receive(u, f.Src, f.Physical, f.Cast, f.Data)
+}
+
+// HandlePoll implements artnet.PacketHandler
+func (a *App) HandlePoll(src *net.UDPAddr, pkt *artnet.PollPacket) {
+	if a.debug.MatchIP(src.IP) {
+		a.debugLog.Printf("[<-artnet] poll src=%s", src.IP)
+	}
+	a.discovery.HandlePoll(src)
+}
+
+// passThrough forwards an Art-Net packet with an unknown opcode to every ArtNet
+// target address other than its source
+func (a *App) passThrough(src *net.UDPAddr, op uint16, data []byte) {
+	if a.passthrough == nil {
+		return
+	}
+	if _, logged := a.passthroughs.LoadOrStore(op, true); !logged {
+		log.Printf("[artnet] passthrough opcode=0x%04x src=%s targets=%d", op, src, len(a.passthrough))
+	}
+	pkt := bytes.Clone(data)
+	for _, dst := range a.passthrough {
+		if dst.IP.Equal(src.IP) {
+			continue
+		}
+		a.metrics.Inc("artnet.passthrough", 1)
+		if !a.fanout.Submit(dst.String(), func() { a.recordSend("[->artnet]", dst, a.artSender.SendRaw(dst, pkt)) }) {
+			a.metrics.Inc("output.queue_dropped", 1)
+		}
+	}
+}
+
+// forwardCommand passes a received ArtCommand on to every ArtNet target
+// address and discovered node other than its sender
+func (a *App) forwardCommand(src *net.UDPAddr, esta uint16, data []byte) {
+	dests := map[string]*net.UDPAddr{}
+	for _, dst := range a.artTargets {
+		dests[dst.String()] = dst
+	}
+	for _, node := range a.discovery.GetAllNodes() {
+		dst := &net.UDPAddr{IP: node.IP, Port: int(node.Port)}
+		dests[dst.String()] = dst
+	}
+	pkt := bytes.Clone(data)
+	for _, dst := range dests {
+		if dst.IP.Equal(src.IP) {
+			continue
+		}
+		a.metrics.Inc("artnet.artcommand_forwarded", 1)
+		if !a.fanout.Submit(dst.String(), func() { a.recordSend("[->artnet]", dst, a.artSender.SendRaw(dst, pkt)) }) {
+			a.metrics.Inc("output.queue_dropped", 1)
+		}
+	}
+	log.Printf("[artcommand] forwarded src=%s esta=0x%04x destinations=%d", src.IP, esta, len(dests))
+}
+
+// HandlePollReply implements artnet.PacketHandler
+func (a *App) HandlePollReply(src *net.UDPAddr, pkt *artnet.PollReplyPacket) {
+	if a.debug.MatchIP(src.IP) {
+		a.debugLog.Printf("[<-artnet] pollreply src=%s", src.IP)
+	}
+	a.discovery.HandlePollReply(src, pkt)
+	if a.probe != nil {
+		a.probe.Reply(src.IP, probe.MethodArtPoll)
+	}
+}
+
+// receive handles an input frame from either protocol; physical is the ArtDmx
+// physical port the frame was sent from, or input.NoPhysical, and cast how it
+// was delivered, "" if unknown
+func (a *App) receive(u config.Universe, src *net.UDPAddr, physical int, cast config.Cast, data [512]byte) {
+	if allowed, started := a.flood.Allow(src.IP); !allowed {
+		if started {
+			log.Printf("[flood] dropping src=%s universe=%s%s limit=%dpps", src.IP, u, a.label(u), a.floodPPS)
+		}
+		a.metrics.Inc("input.dropped", 1)
+		return
+	}
+
+	start := time.Now()
+	a.metrics.Inc("input."+a.metricKey(u)+".frames", 1)
+	if cast != "" {
+		a.metrics.Inc("input.cast."+string(cast), 1)
+	}
+	span := a.tracer.StartSpan("receive", tracing.KindConsumer)
+	if span != nil {
+		span.SetAttr("universe", u.String())
+		span.SetAttr("src", src.IP.String())
+		if physical != input.NoPhysical {
+			span.SetAttr("physical", strconv.Itoa(physical))
+		}
+		if cast != "" {
+			span.SetAttr("cast", string(cast))
+		}
+	}
+
+	a.senders.Record(u, src.IP, cast, &data)
+	a.anomalies.Record(u, src.IP)
+	a.monitor.Record(monitor.Input, u, data)
+	a.recording.Record(monitor.Input, u, src.IP, data)
+	a.learn.Record(u, data)
+	for _, out := range a.hue[u] {
+		out.Update(data)
+	}
+
+	remapSpan := span.Child("remap", tracing.KindInternal)
+	switch {
+	case physical == input.NoPhysical && cast == "":
+		a.profiles.Remap(u, data)
+	case physical != input.NoPhysical && a.synced(src.IP):
+		a.profiles.Buffer(u, uint8(physical), cast, data)
+	default:
+		a.profiles.RemapFiltered(u, physical, cast, data)
+	}
+	remapSpan.End()
+	a.metrics.Observe("remap", time.Since(start))
+
+	if a.senderHz == 0 {
+		a.sendOutputs(span, a.profiles.GetDirtyOutputs())
+		a.metrics.Observe("latency", time.Since(start))
+		a.due.Wake()
+	}
+	span.End()
+}
+
+// synced reports whether ArtDmx from ip is held for its next ArtSync. When ip
+// leaves synchronous mode the frames it left buffered are remapped first, so
+// they can't later overwrite newer ones.
+func (a *App) synced(ip net.IP) bool {
+	if a.syncs == nil {
+		return false
+	}
+	synced, lapsed := a.syncs.Synced(ip)
+	if lapsed {
+		log.Printf("[artnet] sync lapsed src=%s", ip)
+		a.profiles.Sync()
+	}
+	return synced
+}
+
+// droppedDatagram returns the receiver callback counting datagrams of the wrong size
+func (a *App) droppedDatagram(protocol string) func(src *net.UDPAddr, n int, fault datagram.Fault) {
+	return func(src *net.UDPAddr, n int, fault datagram.Fault) {
+		a.metrics.Inc("input.datagram."+string(fault), 1)
+		a.datagrams.Add(protocol, src.IP, n, fault)
+	}
+}
+
+// handleAddress passes an ArtAddress packet to discovery, which applies the parts it accepts
+func (a *App) handleAddress(src *net.UDPAddr, data []byte) {
+	pkt, err := artnetio.ParseAddress(data)
+	if err != nil {
+		a.metrics.Inc("input.address.malformed", 1)
+		return
+	}
+	a.metrics.Inc("input.address", 1)
+	a.discovery.HandleAddress(src, pkt)
+}
+
+// handleTrigger runs the action bound to an ArtTrigger key for all manufacturers
+func (a *App) handleTrigger(src *net.UDPAddr, data []byte) {
+	pkt, err := artnetio.ParseTrigger(data)
+	if err != nil {
+		a.metrics.Inc("input.trigger.malformed", 1)
+		return
+	}
+	// Triggers for one manufacturer's nodes carry that manufacturer's meaning
+	if pkt.OEM != artnetio.TriggerOEMAll {
+		return
+	}
+	t, ok := a.triggers[config.TriggerKey{Key: pkt.Key, SubKey: pkt.SubKey}]
+	if !ok {
+		a.metrics.Inc("input.trigger.unbound", 1)
+		return
+	}
+	a.metrics.Inc("input.trigger", 1)
+	log.Printf("[trigger] src=%s key=%d sub_key=%d action=%s", src.IP, pkt.Key, pkt.SubKey, t.Action)
+
+	switch t.Action {
+	case config.TriggerProfile:
+		fade := time.Duration(t.FadeMS) * time.Millisecond
+		if err := a.profiles.Switch(t.Profile, fade); err != nil {
+			log.Printf("[trigger] profile=%s error: %v", t.Profile, err)
+			return
+		}
+		log.Printf("[profile] switch name=%s fade=%s", t.Profile, fade)
+		a.saveState()
+		a.metrics.Inc("profile.switches", 1)
+	case config.TriggerBlackout:
+		a.blackout(true)
+	case config.TriggerRestore:
+		a.blackout(false)
+	case config.TriggerSnapshot:
+		if !a.recallSnapshot(t.Snapshot) {
+			log.Printf("[trigger] snapshot=%s not found", t.Snapshot)
+		}
+	case config.TriggerStandby, config.TriggerRevert:
+		if err := a.changeover(t.Action == config.TriggerStandby, time.Duration(t.FadeMS)*time.Millisecond); err != nil {
+			log.Printf("[trigger] %s error: %v", t.Action, err)
+		}
+	}
+}
+
+// blackout parks every output channel at zero, or ends a blackout and parks
+// again the channels that were parked before it
+func (a *App) blackout(on bool) {
+	a.blackoutMu.Lock()
+	defer a.blackoutMu.Unlock()
+	if on == a.blackedOut {
+		return
+	}
+	a.blackedOut = on
+
+	var universes []config.Universe
+	for _, p := range a.outputs.Protocols() {
+		universes = append(universes, a.profiles.DestUniverses(p)...)
+	}
+	if on {
+		a.parkedBefore = a.profiles.Parked()
+		zeros := make(map[int]byte, 512)
+		for ch := range 512 {
+			zeros[ch] = 0
+		}
+		for _, u := range universes {
+			a.profiles.Park(u, zeros)
+		}
+	} else {
+		for _, u := range universes {
+			a.profiles.Release(u, nil)
+		}
+		for _, p := range a.parkedBefore {
+			a.profiles.Park(p.Universe, map[int]byte{p.Channel - 1: p.Value})
+		}
+		a.parkedBefore = nil
+	}
+	log.Printf("[blackout] on=%t universes=%d", on, len(universes))
+	if a.senderHz == 0 {
+		a.flushOutputs()
+	}
+}
+
+// handleIPProg answers an ArtIpProg query through discovery, counting refused programming
+func (a *App) handleIPProg(src *net.UDPAddr, data []byte) {
+	pkt, err := artnetio.ParseIPProg(data)
+	if err != nil {
+		a.metrics.Inc("input.ipprog.malformed", 1)
+		return
+	}
+	a.metrics.Inc("input.ipprog", 1)
+	if pkt.Programs() {
+		a.metrics.Inc("input.ipprog.refused", 1)
+	}
+	a.discovery.HandleIPProg(src, pkt)
+}
+
+// handleNzs passes an ArtNzs packet to the destination universes of the
+// mappings with nzs = pass from its universe, and drops it otherwise
+func (a *App) handleNzs(src *net.UDPAddr, data []byte) {
+	pkt, err := artnetio.ParseNzs(data)
+	if err != nil {
+		a.metrics.Inc("input.nzs.malformed", 1)
+		return
+	}
+	u := config.ArtNetUniverse(pkt.Universe)
+	if a.debug.Match(u, src.IP) {
+		a.debugLog.Printf("[<-artnet] nzs src=%s universe=%s start=0x%02x len=%d", src.IP, u, pkt.StartCode, len(pkt.Data))
+	}
+	routes := a.nzsRoutes[u]
+	if len(routes) == 0 {
+		a.metrics.Inc("input.nzs.dropped", 1)
+		return
+	}
+	a.metrics.Inc("input.nzs.passed", 1)
+	for _, to := range routes {
+		_, dests := fallback.Select(a.fallbackChain(to), func(stage string) []*net.UDPAddr {
+			return a.artnetStage(to, stage)
+		}, a.health.Healthy)
+		for _, dst := range dests {
+			if dst.IP.Equal(src.IP) {
+				continue
+			}
+			if !a.fanout.Submit(dst.String(), func() {
+				a.recordSend("[->artnet]", dst, a.artSender.SendNzs(dst, to.ArtNet(), pkt.StartCode, pkt.Data))
+			}) {
+				a.metrics.Inc("output.queue_dropped", 1)
+			}
+		}
+	}
+}
+
+// handleSync remaps the frames held for ArtSync and, unless a sender timer
+// flushes them, sends the outputs they changed
+func (a *App) handleSync(src *net.UDPAddr) {
+	if a.syncs == nil {
+		return
+	}
+	if synced, _ := a.syncs.Synced(src.IP); !synced {
+		log.Printf("[artnet] sync started src=%s", src.IP)
+	}
+	a.syncs.Sync(src.IP)
+	a.metrics.Inc("input.artnet.syncs", 1)
+	a.profiles.Sync()
+	if a.senderHz == 0 {
+		span := a.tracer.StartSpan("sync", tracing.KindConsumer)
+		a.sendOutputs(span, a.profiles.GetDirtyOutputs())
+		span.End()
+		a.due.Wake()
+	}
+}
+
+// flushOutputs sends dirty outputs from a timer or an API change, tracing the
+// send as its own root span
+func (a *App) flushOutputs() {
+	defer a.due.Wake()
+	outputs := a.profiles.GetDirtyOutputs()
+	if len(outputs) == 0 {
+		return
+	}
+	span := a.tracer.StartSpan("flush", tracing.KindInternal)
+	a.sendOutputs(span, outputs)
+	span.End()
+}
+
+func (a *App) sendOutputs(parent *tracing.Span, outputs []remap.Output) {
+	for _, out := range outputs {
+		a.sendTraced(parent, out)
+		if mirror, ok := a.mirrors[out.Universe]; ok {
+			a.sendTraced(parent, remap.Output{Universe: mirror, Data: out.Data})
+		}
+	}
+}
+
+// sendTraced sends one output under its own span and records it
+func (a *App) sendTraced(parent *tracing.Span, out remap.Output) {
+	span := parent.Child("send", tracing.KindProducer)
+	if span != nil {
+		span.SetAttr("universe", out.Universe.String())
+	}
+	start := time.Now()
+	a.monitor.Record(monitor.Output, out.Universe, out.Data)
+	a.recording.Record(monitor.Output, out.Universe, nil, out.Data)
+	a.sendOutput(out)
+	span.End()
+	key := "output." + a.metricKey(out.Universe)
+	a.metrics.Inc(key+".frames", 1)
+	a.metrics.Observe(key+".send", time.Since(start))
+}
+
+// sendOutput hands one output frame to the driver of its protocol
+func (a *App) sendOutput(out remap.Output) {
+	a.outputs.Send(out.Universe, out.Data)
+}
+
+// outputFrame returns what every driver needs for one frame: the debug diff
+// and, unless loops are allowed, the source universes feeding u
+func (a *App) outputFrame(u config.Universe, data [512]byte) (diff string, logDiff bool, sources []config.Universe) {
+	if a.debug.Enabled() {
+		diff, logDiff = a.differ.Diff("->", u, data)
+	}
+	if !a.allowLoops {
+		sources = a.profiles.Active().Sources(u)
+	}
+	return diff, logDiff, sources
+}
+
+// sacnDriver multicasts each universe and unicasts it to the universe's sACN targets
+type sacnDriver struct {
+	a     *App
+	stats output.Counter
+}
+
+func (d *sacnDriver) Send(universe config.Universe, data [512]byte) {
+	a := d.a
+	diff, logDiff, sources := a.outputFrame(universe, data)
+	u := universe.Number
+	if logDiff && a.debug.Match(universe, nil) {
+		a.debugLog.Printf("[->sacn] universe=%d %s", u, diff)
+	}
+	a.dispatch("[->sacn]", universe, sacn.MulticastAddr(u), d.stats.Track(func() error {
+		return a.sacnSender.SendDMX(u, data[:])
+	}))
+	for _, target := range a.sacnTargets[u] {
+		if !a.health.Healthy(target) || a.looped("[->sacn]", universe, sources, target) {
+			continue
+		}
+		if logDiff && a.debug.Match(universe, target.IP) {
+			a.debugLog.Printf("[->sacn] unicast dst=%s universe=%d %s", target.IP, u, diff)
+		}
+		a.dispatch("[->sacn]", universe, target, d.stats.Track(func() error {
+			return a.sacnSender.SendDMXUnicast(target, u, data[:])
+		}))
+	}
+}
+
+func (d *sacnDriver) Close() error        { return d.a.sacnSender.Close() }
+func (d *sacnDriver) Stats() output.Stats { return d.stats.Stats() }
+
+// artnetDriver sends each universe to the first healthy stage of its fallback chain
+type artnetDriver struct {
+	a     *App
+	stats output.Counter
+}
+
+func (d *artnetDriver) Send(universe config.Universe, data [512]byte) {
+	a := d.a
+	diff, logDiff, sources := a.outputFrame(universe, data)
+	artU := universe.ArtNet()
+	chain := a.fallbackChain(universe)
+	stage, dests := fallback.Select(chain, func(stage string) []*net.UDPAddr {
+		return a.artnetStage(universe, stage)
+	}, a.health.Healthy)
+	if prev, changed := a.fallback.Use(universe, stage); changed && (prev != "" || stage != chain[0]) {
+		log.Printf("[->artnet] fallback universe=%s%s from=%s to=%s", universe, a.label(universe), cmp.Or(prev, "none"), stage)
+		a.metrics.Inc("output.fallbacks", 1)
+	}
+
+	for _, dst := range dests {
+		if a.looped("[->artnet]", universe, sources, dst) {
+			continue
+		}
+		if logDiff && a.debug.Match(universe, dst.IP) {
+			a.debugLog.Printf("[->artnet] dst=%s universe=%s %s", dst.IP, universe, diff)
+		}
+		a.dispatch("[->artnet]", universe, dst, d.stats.Track(func() error {
+			return a.artSender.SendDMX(dst, artU, data[:])
+		}))
+	}
+}
+
+func (d *artnetDriver) Close() error        { return d.a.artSender.Close() }
+func (d *artnetDriver) Stats() output.Stats { return d.stats.Stats() }
+
+// espnetDriver sends each universe to its ESP Net targets, or broadcasts it if it has none
+type espnetDriver struct {
+	a     *App
+	stats output.Counter
+}
+
+func (d *espnetDriver) Send(universe config.Universe, data [512]byte) {
+	a := d.a
+	diff, logDiff, sources := a.outputFrame(universe, data)
+	u := universe.Number
+	dests := a.espTargets[u]
+	if bcast := a.broadcast.Load(); len(dests) == 0 && bcast != nil {
+		dests = []*net.UDPAddr{{IP: bcast.IP, Port: espnet.Port}}
+	}
+	for _, dst := range dests {
+		if !a.health.Healthy(dst) || a.looped("[->espnet]", universe, sources, dst) {
+			continue
+		}
+		if logDiff && a.debug.Match(universe, dst.IP) {
+			a.debugLog.Printf("[->espnet] dst=%s universe=%d %s", dst.IP, u, diff)
+		}
+		a.dispatch("[->espnet]", universe, dst, d.stats.Track(func() error {
+			return a.espSender.SendDMX(dst, uint8(u), data[:])
+		}))
+	}
+}
+
+func (d *espnetDriver) Close() error        { return d.a.espSender.Close() }
+func (d *espnetDriver) Stats() output.Stats { return d.stats.Stats() }
+
+// uartDriver hands each universe to the serial port refreshing its device
+type uartDriver struct {
+	a     *App
+	stats output.Counter
+}
+
+func (d *uartDriver) Send(universe config.Universe, data [512]byte) {
+	a := d.a
+	port := a.uarts[universe.Device]
+	if port == nil {
+		return
+	}
+	if diff, logDiff, _ := a.outputFrame(universe, data); logDiff && a.debug.Match(universe, nil) {
+		a.debugLog.Printf("[->uart] device=%s %s", universe.Device, diff)
+	}
+	port.Update(data)
+	d.stats.Record(nil)
+}
+
+func (d *uartDriver) Close() error {
+	for _, port := range d.a.uarts {
+		port.Stop()
+	}
+	return nil
+}
+
+func (d *uartDriver) Stats() output.Stats { return d.stats.Stats() }
+
+// label formats the label of universe u for a log line, or returns "" if it has none
+func (a *App) label(u config.Universe) string {
+	if l, ok := a.labels[u]; ok {
+		return fmt.Sprintf(" label=%q", l)
+	}
+	return ""
+}
+
+// universeLabels returns the universe labels keyed by universe as formatted in the API
+func (a *App) universeLabels() map[string]string {
+	if len(a.labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(a.labels))
+	for u, l := range a.labels {
+		result[u.String()] = l
+	}
+	return result
+}
+
+// metricKey formats universe u as a metric path, using its label if it has one
+func (a *App) metricKey(u config.Universe) string {
+	if l, ok := a.labels[u]; ok {
+		return config.LabelKey(l)
+	}
+	return metrics.UniverseKey(u)
+}
+
+// looped reports whether dst is feeding one of the sources of output universe u,
+// so sending u to it would echo remapped data back to the console. Broadcast and
+// multicast destinations never match. Each loop is logged once.
+func (a *App) looped(tag string, u config.Universe, sources []config.Universe, dst *net.UDPAddr) bool {
+	for _, src := range sources {
+		if !a.senders.Feeding(src, dst.IP, remap.DataLossTimeout) {
+			continue
+		}
+		if _, logged := a.loops.LoadOrStore(u.String()+" "+dst.IP.String(), true); !logged {
+			log.Printf("%s loop suppressed: universe=%s%s dst=%s feeds source=%s", tag, u, a.label(u), dst.IP, src)
+		}
+		a.metrics.Inc("output.loops_suppressed", 1)
+		return true
+	}
+	return false
+}
+
+// fallbackChain returns the destination stages tried in order for ArtNet universe u
+func (a *App) fallbackChain(u config.Universe) []string {
+	if chain, ok := a.fallbacks[u]; ok {
+		return chain
+	}
+	return config.DefaultFallback
+}
+
+// artnetStage returns the addresses one fallback stage sends ArtNet universe u to
+func (a *App) artnetStage(u config.Universe, stage string) []*net.UDPAddr {
+	switch stage {
+	case config.FallbackStatic:
+		if target, ok := a.artTargets[u.Number]; ok {
+			return []*net.UDPAddr{target}
+		}
+	case config.FallbackDiscovered:
+		var result []*net.UDPAddr
+		for _, node := range a.discovery.GetNodesForUniverse(u.ArtNet()) {
+			result = append(result, &net.UDPAddr{IP: node.IP, Port: int(node.Port)})
+		}
+		return result
+	case config.FallbackBroadcast:
+		if bcast := a.broadcast.Load(); bcast != nil {
+			return []*net.UDPAddr{bcast}
+		}
+	}
+	return nil
+}
+
+// errQueueFull is recorded by the output watchdog when a send is dropped before reaching the socket
+var errQueueFull = errors.New("send queue full")
+
+// dispatch runs send of output universe u on the fan-out worker for dst and records the result
+func (a *App) dispatch(tag string, u config.Universe, dst *net.UDPAddr, send func() error) {
+	done := func(err error) {
+		a.recordSend(tag, dst, err)
+		a.watchdog.Sent(u, err)
+	}
+	if !a.fanout.Submit(dst.String(), func() { a.chaos.Send(dst, send, done) }) {
+		a.metrics.Inc("output.queue_dropped", 1)
+		a.watchdog.Sent(u, errQueueFull)
+	}
+}
+
+// watchedOutputs returns the live output universes the watchdog judges; UART
+// outputs are left out as they are written without a send result
+func (a *App) watchedOutputs() []config.Universe {
+	return slices.DeleteFunc(a.profiles.Active().LiveOutputs(), func(u config.Universe) bool {
+		return u.Protocol == config.ProtocolUART
+	})
+}
+
+// recordSend tracks per-destination send health, logging only the first error
+// of a run and transitions between healthy and unhealthy
+func (a *App) recordSend(tag string, dst *net.UDPAddr, err error) {
+	consecutive, changed := a.health.Record(dst, err)
+	if err != nil {
+		a.metrics.Inc("send_errors", 1)
+	}
+	switch {
+	case changed && err != nil:
+		log.Printf("%s unhealthy: dst=%s errors=%d err=%v", tag, dst, consecutive, err)
+	case changed:
+		log.Printf("%s recovered: dst=%s", tag, dst)
+	case err != nil && consecutive == 1:
+		log.Printf("%s error: dst=%s err=%v", tag, dst, err)
+	}
+}
+
+type statusResponse struct {
+	Targets   []config.Target                  `json:"targets"`
+	Mappings  []config.Mapping                 `json:"mappings"`
+	Outputs   []config.Output                  `json:"outputs"`
+	Senders   []senders.SenderInfo             `json:"senders"`
+	Health    []health.DestInfo                `json:"health"`
+	Universes []monitor.UniverseInfo           `json:"universes"`
+	Labels    map[string]string                `json:"labels,omitempty"` // universe labels by universe
+	Anomalies []anomaly.Event                  `json:"anomalies"`
+	Stalled   []watchdog.Event                 `json:"stalled_outputs"`
+	Conflicts []senders.Conflict               `json:"conflicts"`
+	Usage     []remap.MappingUsage             `json:"mapping_usage"`
+	Profile   string                           `json:"profile,omitempty"`
+	Domains   []domain.Stats                   `json:"domains,omitempty"`
+	Replies   artnetio.ReplyStats              `json:"poll_replies"`
+	Probes    []probe.Status                   `json:"probes,omitempty"`
+	ProbeLog  []probe.Event                    `json:"probe_events,omitempty"`
+	Parked    []remap.ParkedChannel            `json:"parked,omitempty"`
+	Traffic   []traffic.DestStats              `json:"traffic"`
+	Frames    []coalesce.Source                `json:"artnet_sources"`
+	Drivers   map[config.Protocol]output.Stats `json:"output_drivers"`
+	Paused    output.Paused                    `json:"paused_outputs"`
+	Datagrams []datagram.Stats                 `json:"dropped_datagrams"`
+}
+
+func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server", "artmap")
+	resp := statusResponse{
+		Targets:   a.cfg.Targets,
+		Mappings:  a.activeConfig().Mappings,
+		Outputs:   a.cfg.Outputs,
+		Senders:   a.senders.GetAll(),
+		Health:    a.health.GetAll(),
+		Universes: a.monitor.Universes(),
+		Labels:    a.universeLabels(),
+		Anomalies: a.anomalies.Active(),
+		Stalled:   a.watchdog.Active(),
+		Conflicts: a.senders.Conflicts(),
+		Usage:     a.profiles.Active().Usage(),
+		Profile:   a.profiles.Status().Active,
+		Replies:   a.discovery.ReplyStats(),
+		Parked:    a.profiles.Parked(),
+		Traffic:   a.traffic.Stats(),
+		Frames:    a.coalesce.Sources(),
+		Drivers:   a.outputs.Stats(),
+		Paused:    a.outputs.Paused(),
+		Datagrams: a.datagrams.Stats(),
+	}
+	names := map[string]string{}
+	for _, node := range a.discovery.GetAllNodes() {
+		names[node.IP.String()] = node.ShortName
+	}
+	for i, t := range resp.Traffic {
+		if host, _, err := net.SplitHostPort(t.Addr); err == nil {
+			resp.Traffic[i].Node = names[host]
+		}
+	}
+	for _, d := range a.domains {
+		resp.Domains = append(resp.Domains, d.Stats())
+	}
+	if a.probe != nil {
+		resp.Probes = a.probe.Statuses()
+		resp.ProbeLog = a.probe.Events()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (a *App) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server", "artmap")
+	json.NewEncoder(w).Encode(a.profiles.Active().Routes())
+}
+
+// handleTopology returns the live routing graph from senders through input
+// universes, mappings and output universes to destinations, as JSON or with
+// ?format=dot as Graphviz
+func (a *App) handleTopology(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+	g := a.topology()
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(g)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		g.WriteDOT(w)
+	default:
+		http.Error(w, "format must be json or dot", http.StatusBadRequest)
+	}
+}
+
+func (a *App) topology() *topology.Graph {
+	g := topology.New()
+	labels := a.cfg.Labels()
+	universe := func(kind, prefix string, u config.Universe) string {
+		label := u.String()
+		if name := labels[u]; name != "" {
+			label += "\n" + name
+		}
+		return g.AddNode(kind, prefix+u.String(), label, false)
+	}
+
+	for _, s := range a.senders.GetAll() {
+		src := g.AddNode(topology.KindSource, "src:"+s.IP, s.IP, false)
+		g.AddEdge(src, universe(topology.KindInput, "in:", s.Universe))
+	}
+	routed := map[config.Universe]bool{}
+	for _, m := range a.profiles.Active().Mappings() {
+		label := fmt.Sprintf("mapping %d", m.Index)
+		if mappings := a.activeConfig().Mappings; m.Index < len(mappings) {
+			cm := mappings[m.Index]
+			label = cmp.Or(cm.Label, cm.From.String()+" -> "+cm.To.String())
+		}
+		mapping := g.AddNode(topology.KindMapping, fmt.Sprintf("mapping:%d", m.Index), label, false)
+		out := universe(topology.KindOutput, "out:", m.To)
+		g.AddEdge(universe(topology.KindInput, "in:", m.From), mapping)
+		g.AddEdge(mapping, out)
+		if routed[m.To] {
+			continue
+		}
+		routed[m.To] = true
+		for _, d := range a.outputDestinations(m.To) {
+			label := d.Kind + " " + d.Address
+			if d.Name != "" {
+				label += "\n" + d.Name
+			}
+			g.AddEdge(out, g.AddNode(topology.KindDestination, "dst:"+d.Address, label, !d.Healthy))
+		}
+	}
+	return g
+}
+
+// handleTrace returns the hops ?channel= takes through the active mappings, with where each output goes
+func (a *App) handleTrace(w http.ResponseWriter, r *http.Request) {
+	u, ch, err := config.ParseChannelAddr(r.URL.Query().Get("channel"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hops := a.profiles.Active().Trace(u, ch)
+	dests := map[config.Universe][]remap.Destination{}
+	for i := range hops {
+		to := hops[i].To
+		if _, ok := dests[to]; !ok {
+			dests[to] = a.outputDestinations(to)
+		}
+		hops[i].Destinations = dests[to]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server", "artmap")
+	json.NewEncoder(w).Encode(hops)
+}
+
+// outputDestinations lists where frames for an output universe are currently sent, mirroring sendOutput
+func (a *App) outputDestinations(u config.Universe) []remap.Destination {
+	result := []remap.Destination{}
+	add := func(kind string, dst *net.UDPAddr, name string) {
+		result = append(result, remap.Destination{Kind: kind, Address: dst.String(), Name: name, Healthy: a.health.Healthy(dst)})
+	}
+
+	switch u.Protocol {
+	case config.ProtocolSACN:
+		add("multicast", sacn.MulticastAddr(u.Number), "")
+		for _, target := range a.sacnTargets[u.Number] {
+			add("target", target, "")
+		}
+
+	case config.ProtocolArtNet:
+		// Stages are listed up to the one in use, so skipped ones show as unhealthy
+		chain := a.fallbackChain(u)
+		selected, _ := fallback.Select(chain, func(stage string) []*net.UDPAddr {
+			return a.artnetStage(u, stage)
+		}, a.health.Healthy)
+		for _, stage := range chain {
+			if stage == config.FallbackDrop {
+				break
+			}
+			switch stage {
+			case config.FallbackStatic:
+				for _, target := range a.artnetStage(u, stage) {
+					add("target", target, "")
+				}
+			case config.FallbackDiscovered:
+				for _, node := range a.discovery.GetNodesForUniverse(u.ArtNet()) {
+					add("node", &net.UDPAddr{IP: node.IP, Port: int(node.Port)}, node.ShortName)
+				}
+			case config.FallbackBroadcast:
+				for _, bcast := range a.artnetStage(u, stage) {
+					add("broadcast", bcast, "")
+				}
+			}
+			if stage == selected {
+				break
+			}
+		}
+
+	case config.ProtocolESPNet:
+		if targets := a.espTargets[u.Number]; len(targets) > 0 {
+			for _, target := range targets {
+				add("target", target, "")
+			}
+		} else if bcast := a.broadcast.Load(); bcast != nil {
+			add("broadcast", &net.UDPAddr{IP: bcast.IP, Port: espnet.Port}, "")
+		}
+
+	case config.ProtocolUART:
+		if a.uarts[u.Device] != nil {
+			result = append(result, remap.Destination{Kind: "serial", Address: u.Device, Healthy: true})
+		}
+	}
+	return result
+}
+
+// handleDMX returns the last frame seen on ?universe= in ?direction= (input or output)
+func (a *App) handleDMX(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	u, err := config.ParseUniverse(r.URL.Query().Get("universe"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dir := monitor.Direction(r.URL.Query().Get("direction"))
+	if dir == "" {
+		dir = monitor.Output
+	}
+
+	data, ok := a.monitor.Frame(dir, u)
+	if !ok {
+		http.Error(w, "universe not seen", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(monitor.FrameInfo{Universe: u, Direction: dir, Data: data})
+}
+
+// handleActivity returns per-channel change counts of a universe; DELETE resets them
+func (a *App) handleActivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	u, err := config.ParseUniverse(r.URL.Query().Get("universe"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dir := monitor.Direction(r.URL.Query().Get("direction"))
+	if dir == "" {
+		dir = monitor.Output
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		if a.monitor.ResetActivity(dir, u) {
+			log.Printf("[api] activity reset universe=%s direction=%s", u, dir)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, ok := a.monitor.Activity(dir, u)
+	if !ok {
+		http.Error(w, "universe not seen", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+type artCommandRequest struct {
+	Text   string  `json:"text"`
+	Target string  `json:"target"` // node IP, empty to broadcast
+	ESTA   *uint16 `json:"esta"`   // manufacturer code, default all
+}
+
+type artCommandResponse struct {
+	Target string `json:"target"`
+	Text   string `json:"text"`
+}
+
+// handleArtCommand lists received ArtCommands; POST sends one to a node or the broadcast address
+func (a *App) handleArtCommand(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.artcmd.Recent())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if a.artReceiver == nil {
+		http.Error(w, "artcommand requires the artnet listener", http.StatusServiceUnavailable)
+		return
+	}
+	var req artCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	esta := artcmd.ESTAAll
+	if req.ESTA != nil {
+		esta = *req.ESTA
+	}
+	pkt, err := artcmd.Build(esta, req.Text)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dst := a.broadcast.Load()
+	if req.Target != "" {
+		ip := net.ParseIP(req.Target).To4()
+		if ip == nil {
+			http.Error(w, fmt.Sprintf("invalid target %q", req.Target), http.StatusBadRequest)
+			return
+		}
+		dst = &net.UDPAddr{IP: ip, Port: artnet.Port}
+	}
+	if dst == nil {
+		http.Error(w, "no broadcast address; give a target", http.StatusBadRequest)
+		return
+	}
+	if err := a.artReceiver.SendTo(pkt, dst); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("[artcommand] sent dst=%s text=%q", dst, req.Text)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(artCommandResponse{Target: dst.String(), Text: req.Text})
+}
+
+// handleSenders returns the last frame each sender sent on ?universe=
+func (a *App) handleSenders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	if !a.senders.KeepFrames() {
+		http.Error(w, "sender frames are not kept (start with --sender-frames)", http.StatusServiceUnavailable)
+		return
+	}
+	u, err := config.ParseUniverse(r.URL.Query().Get("universe"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	frames := a.senders.Frames(u)
+	if len(frames) == 0 {
+		http.Error(w, "no senders on universe", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frames)
+}
+
+type channelRequest struct {
+	Action   string `json:"action"` // set, park or release
+	Universe string `json:"universe"`
+	Channels string `json:"channels,omitempty"` // e.g. "10" or "1-3,10"; release defaults to all
+	Value    *int   `json:"value,omitempty"`    // park defaults to the current value
+}
+
+// handleChannels lists parked channels; POST sets, parks or releases output channels
+func (a *App) handleChannels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req channelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.applyChannelRequest(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[api] channels action=%s universe=%s channels=%s", req.Action, req.Universe, req.Channels)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.profiles.Parked())
+}
+
+func (a *App) applyChannelRequest(req channelRequest) error {
+	switch req.Action {
+	case "set", "park", "release":
+	default:
+		return fmt.Errorf("unknown action %q", req.Action)
+	}
+
+	u, err := config.ParseUniverse(req.Universe)
+	if err != nil {
+		return err
+	}
+
+	var channels []int
+	if req.Channels != "" {
+		ranges, err := config.ParseChannels(req.Channels)
+		if err != nil {
+			return err
+		}
+		for _, cr := range ranges {
+			for ch := cr.Start; ch <= cr.End; ch++ {
+				channels = append(channels, ch-1)
+			}
+		}
+	}
+	if req.Value != nil && (*req.Value < 0 || *req.Value > 255) {
+		return fmt.Errorf("value %d out of range (0-255)", *req.Value)
+	}
+
+	if req.Action == "release" {
+		err = a.profiles.Release(u, channels)
+	} else {
+		if channels == nil {
+			return fmt.Errorf("%s requires channels", req.Action)
+		}
+		current := a.profiles.Active().Frames()[u]
+		values := map[int]byte{}
+		for _, ch := range channels {
+			switch {
+			case req.Value != nil:
+				values[ch] = byte(*req.Value)
+			case req.Action == "park":
+				values[ch] = current[ch]
+			default:
+				return fmt.Errorf("%s requires a value", req.Action)
+			}
+		}
+		if req.Action == "set" {
+			err = a.profiles.Active().Set(u, values)
+		} else {
+			err = a.profiles.Park(u, values)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if req.Action != "set" {
+		a.saveState()
+	}
+
+	if a.senderHz == 0 {
+		a.flushOutputs()
+	}
+	return nil
+}
+
+// saveState writes the masters, parked channels and active profile to the state file
+func (a *App) saveState() {
+	if a.state == nil {
+		return
+	}
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	st := &state.State{Profile: a.profiles.Status().Active}
+	for _, m := range a.profiles.Masters().Levels() {
+		st.Masters = append(st.Masters, state.Master{Name: m.Name, Level: m.Level})
+	}
+	// A blackout parks every channel at zero; save the parks it restores instead,
+	// so a restart during one doesn't come back dark with nothing to restore
+	a.blackoutMu.Lock()
+	parked := a.parkedBefore
+	if !a.blackedOut {
+		parked = a.profiles.Parked()
+	}
+	a.blackoutMu.Unlock()
+	for _, p := range parked {
+		st.Parked = append(st.Parked, state.Parked{Universe: p.Universe.String(), Channel: p.Channel, Value: p.Value})
+	}
+	if err := a.state.Save(st); err != nil {
+		log.Printf("[state] write error: file=%s err=%v", a.state.Path(), err)
+	}
+}
+
+// restoreState reapplies the state file; entries the config no longer allows are logged and skipped
+func (a *App) restoreState() {
+	st, err := a.state.Load()
+	if err != nil {
+		log.Printf("[state] read error: file=%s err=%v", a.state.Path(), err)
+		return
+	}
+	if st.Profile != "" {
+		if err := a.profiles.Switch(st.Profile, 0); err != nil {
+			log.Printf("[state] skipped profile: %v", err)
+		}
+	}
+	for _, m := range st.Masters {
+		if err := a.profiles.Masters().Set(m.Name, m.Level); err != nil {
+			log.Printf("[state] skipped master: %v", err)
+		}
+	}
+	parked := map[config.Universe]map[int]byte{}
+	for _, p := range st.Parked {
+		u, err := config.ParseUniverse(p.Universe)
+		if err != nil || p.Channel < 1 || p.Channel > 512 {
+			log.Printf("[state] skipped parked channel: universe=%s channel=%d", p.Universe, p.Channel)
+			continue
+		}
+		if parked[u] == nil {
+			parked[u] = map[int]byte{}
+		}
+		parked[u][p.Channel-1] = p.Value
+	}
+	for u, values := range parked {
+		if err := a.profiles.Park(u, values); err != nil {
+			log.Printf("[state] skipped parked channels: %v", err)
+		}
+	}
+	log.Printf("[state] restored file=%s profile=%q masters=%d parked=%d", a.state.Path(), st.Profile, len(st.Masters), len(st.Parked))
+}
+
+type snapshotRequest struct {
+	Action string `json:"action"` // save, load or delete
+	Name   string `json:"name"`
+}
+
+// handleSnapshots lists snapshot names; POST saves, loads or deletes a snapshot of all outputs
+func (a *App) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req snapshotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		switch req.Action {
+		case "save":
+			a.snapshots.Save(req.Name, a.profiles.Active().Frames())
+		case "load":
+			if !a.recallSnapshot(req.Name) {
+				http.Error(w, "snapshot not found", http.StatusNotFound)
+				return
+			}
+		case "delete":
+			if !a.snapshots.Delete(req.Name) {
+				http.Error(w, "snapshot not found", http.StatusNotFound)
+				return
+			}
+		default:
+			http.Error(w, fmt.Sprintf("unknown action %q", req.Action), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[api] snapshot action=%s name=%s", req.Action, req.Name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.snapshots.Names())
+}
+
+// recallSnapshot writes a snapshot into the active outputs, reporting false if there is none by name
+func (a *App) recallSnapshot(name string) bool {
+	frames, ok := a.snapshots.Get(name)
+	if !ok {
+		return false
+	}
+	for u, data := range frames {
+		values := make(map[int]byte, len(data))
+		for ch, v := range data {
+			values[ch] = v
+		}
+		a.profiles.Active().Set(u, values)
+	}
+	if a.senderHz == 0 {
+		a.flushOutputs()
+	}
+	return true
+}
+
+type masterRequest struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+// handleMasters returns the grand and group master levels; POST sets one of them
+func (a *App) handleMasters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	masters := a.profiles.Masters()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req masterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Level < 0 || req.Level > 255 {
+			http.Error(w, "level must be 0-255", http.StatusBadRequest)
+			return
+		}
+		if err := masters.Set(req.Name, byte(req.Level)); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("[api] master name=%s level=%d", req.Name, req.Level)
+		a.saveState()
+		if a.senderHz == 0 {
+			a.flushOutputs()
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(masters.Levels())
+}
+
+type transmitRequest struct {
+	Target  string `json:"target"` // a protocol or an output universe
+	Enabled bool   `json:"enabled"`
+}
+
+// handleTransmit returns the paused protocols and universes; POST pauses or
+// resumes transmitting one, while input and state tracking carry on
+func (a *App) handleTransmit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req transmitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var match func(config.Universe) bool
+		if p := config.Protocol(req.Target); slices.Contains(a.outputs.Protocols(), p) {
+			a.outputs.PauseProtocol(p, !req.Enabled)
+			match = func(u config.Universe) bool { return u.Protocol == p }
+		} else {
+			target, err := config.ParseUniverse(req.Target)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("target must be a protocol or universe: %v", err), http.StatusBadRequest)
+				return
+			}
+			a.outputs.PauseUniverse(target, !req.Enabled)
+			match = func(u config.Universe) bool { return u == target }
+		}
+		log.Printf("[api] transmit target=%s enabled=%t", req.Target, req.Enabled)
+		if req.Enabled {
+			// Send the current look at once rather than at the next input frame
+			for u, data := range a.profiles.Active().Frames() {
+				if match(u) {
+					a.sendOutput(remap.Output{Universe: u, Data: data})
+				}
+			}
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.outputs.Paused())
+}
+
+type profileRequest struct {
+	Name   string `json:"name"`
+	FadeMS int    `json:"fade_ms"`
+}
+
+// handleProfile returns the mapping profiles; POST switches the active one
+func (a *App) handleProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req profileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		artReceiver, err := artnet.NewReceiver(addr, app)
-		if err != nil {
-			log.Fatalf("artnet receiver error: %v", err)
+		if req.FadeMS < 0 || req.FadeMS > 600000 {
+			http.Error(w, "fade_ms must be 0-600000", http.StatusBadRequest)
+			return
 		}
-		app.artReceiver = artReceiver
-		discovery.SetReceiver(artReceiver)
-		artReceiver.Start()
-		log.Printf("[artnet] listening addr=%s", addr)
+		fade := time.Duration(req.FadeMS) * time.Millisecond
+		if err := a.profiles.Switch(req.Name, fade); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("[profile] switch name=%s fade=%s", req.Name, fade)
+		a.saveState()
+		a.metrics.Inc("profile.switches", 1)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Create sACN receiver for all source universes
-	sacnUniverses := cfg.SACNSourceUniverses()
-	if len(sacnUniverses) > 0 {
-		var iface *net.Interface
-		if *sacnInterface != "" {
-			iface, _ = net.InterfaceByName(*sacnInterface)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.profiles.Status())
+}
+
+// loadStandby loads and validates the standby config, returning nil if it is
+// unusable so the running config starts on its own
+func loadStandby(path string, running *config.Config) *config.Config {
+	standby, err := config.Load(path)
+	if err != nil {
+		log.Printf("[standby] config=%s error: %v", path, err)
+		return nil
+	}
+	for _, name := range running.Profiles() {
+		if profile.IsStandby(name) {
+			log.Printf("[standby] config=%s ignored: running profile %q clashes with the standby profile names", path, name)
+			return nil
 		}
-		receiver, err := sacn.NewMultiUniverseReceiver(iface, sacnUniverses)
-		if err != nil {
-			log.Fatalf("[sacn] failed to create receiver: %v", err)
+	}
+	for _, w := range standby.Warnings {
+		log.Printf("[standby] warning: %s", w)
+	}
+	if !slices.Equal(standby.Targets, running.Targets) {
+		log.Printf("[standby] warning: targets differ from the running config, whose targets stay in use after a changeover")
+	}
+	for _, g := range standby.Groups {
+		if !slices.ContainsFunc(running.Groups, func(r config.Group) bool { return r.Name == g.Name }) {
+			log.Printf("[standby] warning: group %q is not in the running config; its mappings are not scaled", g.Name)
 		}
-		receiver.SetHandler(func(src *net.UDPAddr, pkt interface{}) {
-			if data, ok := pkt.(*sacn.DataPacket); ok {
-				app.HandleSACN(src, data)
-			}
-		})
-		app.sacnReceiver = receiver
-		receiver.Start()
-		log.Printf("[sacn] listening universes=%v", sacnUniverses)
 	}
+	log.Printf("[standby] preloaded config=%s mappings=%d profile=%s", path, len(standby.Mappings), profile.StandbyName(standby.Profile))
+	return standby
+}
 
-	// Start discovery only if we have ArtNet outputs
-	if len(destNums) > 0 || len(artTargets) > 0 {
-		discovery.Start()
+// activeConfig returns the config whose mappings are active: the standby's after a changeover
+func (a *App) activeConfig() *config.Config {
+	if a.standby != nil && a.onStandby() {
+		return a.standby
 	}
+	return a.cfg
+}
 
-	// Start HTTP API server
-	if *apiListen != "" {
-		go func() {
-			mux := http.NewServeMux()
-			mux.HandleFunc("/artmap/api/status", app.handleStatus)
-			server := &http.Server{
-				Addr:    *apiListen,
-				Handler: mux,
-			}
-			log.Printf("[api] listening addr=%s", *apiListen)
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Printf("[api] server error: %v", err)
-			}
-		}()
+func (a *App) onStandby() bool {
+	return profile.IsStandby(a.profiles.Status().Active)
+}
+
+// changeover switches to the standby config's startup profile, or back to the
+// running config's, crossfading over fade
+func (a *App) changeover(toStandby bool, fade time.Duration) error {
+	if a.standby == nil {
+		return fmt.Errorf("no standby config loaded")
 	}
+	name := a.cfg.Profile
+	if toStandby {
+		name = profile.StandbyName(a.standby.Profile)
+	}
+	if err := a.profiles.Switch(name, fade); err != nil {
+		return err
+	}
+	log.Printf("[standby] changeover profile=%q fade=%s", name, fade)
+	a.saveState()
+	a.metrics.Inc("profile.switches", 1)
+	return nil
+}
 
-	// Start stats printer
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C {
-			app.printStats()
-		}
-	}()
+type standbyRequest struct {
+	Action string `json:"action"` // activate or revert
+	FadeMS int    `json:"fade_ms"`
+}
 
-	// Start sender expiration
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C {
-			app.senders.Expire(30 * time.Second)
+type standbyStatus struct {
+	Loaded   bool   `json:"loaded"`
+	Profile  string `json:"profile,omitempty"` // the profile a changeover switches to
+	Mappings int    `json:"mappings"`
+	Active   bool   `json:"active"`
+}
+
+// handleStandby reports the preloaded standby config; POST changes over to it or back
+func (a *App) handleStandby(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req standbyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-	}()
+		if req.FadeMS < 0 || req.FadeMS > 600000 {
+			http.Error(w, "fade_ms must be 0-600000", http.StatusBadRequest)
+			return
+		}
+		if req.Action != "activate" && req.Action != "revert" {
+			http.Error(w, fmt.Sprintf("unknown action %q", req.Action), http.StatusBadRequest)
+			return
+		}
+		if err := a.changeover(req.Action == "activate", time.Duration(req.FadeMS)*time.Millisecond); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	// Start fixed-rate sender
-	if *senderHz > 0 {
-		log.Printf("[sender] starting at %dHz", *senderHz)
-		go func() {
-			ticker := time.NewTicker(time.Second / time.Duration(*senderHz))
-			defer ticker.Stop()
-			for range ticker.C {
-				app.sendOutputs(app.engine.GetDirtyOutputs())
-			}
-		}()
+	status := standbyStatus{Loaded: a.standby != nil}
+	if a.standby != nil {
+		status.Profile = profile.StandbyName(a.standby.Profile)
+		status.Mappings = len(a.standby.Mappings)
+		status.Active = a.onStandby()
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
 
-	// Wait for interrupt
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+// handleRDM runs RDM discovery on the nodes for ?universe= and returns the devices found
+func (a *App) handleRDM(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
 
-	log.Println("[main] shutting down")
-	if app.artReceiver != nil {
-		app.artReceiver.Stop()
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	if app.sacnReceiver != nil {
-		app.sacnReceiver.Stop()
+	if a.rdm == nil {
+		http.Error(w, "rdm requires the artnet listener", http.StatusServiceUnavailable)
+		return
+	}
+	u, err := config.ParseUniverse(r.URL.Query().Get("universe"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if u.Protocol != config.ProtocolArtNet {
+		http.Error(w, "rdm discovery needs an artnet universe", http.StatusBadRequest)
+		return
 	}
-	discovery.Stop()
-}
 
-// HandleDMX implements artnet.PacketHandler
-func (a *App) HandleDMX(src *net.UDPAddr, pkt *artnet.DMXPacket) {
-	if a.debug {
-		log.Printf("[<-artnet] src=%s universe=%s seq=%d len=%d",
-			src.IP, pkt.Universe, pkt.Sequence, pkt.Length)
+	artU := u.ArtNet()
+	var nodes []*net.UDPAddr
+	if target, ok := a.artTargets[u.Number]; ok {
+		nodes = append(nodes, target)
+	} else {
+		for _, node := range a.discovery.GetNodesForUniverse(artU) {
+			nodes = append(nodes, &net.UDPAddr{IP: node.IP, Port: artnet.Port})
+		}
 	}
-	u := config.Universe{Protocol: config.ProtocolArtNet, Number: uint16(pkt.Universe)}
-	a.senders.Record(u, src.IP)
-	a.engine.Remap(u, pkt.Data)
-	if a.senderHz == 0 {
-		a.sendOutputs(a.engine.GetDirtyOutputs())
+	if len(nodes) == 0 {
+		http.Error(w, fmt.Sprintf("no target or discovered node for %s", u), http.StatusNotFound)
+		return
 	}
-}
 
-// HandlePoll implements artnet.PacketHandler
-func (a *App) HandlePoll(src *net.UDPAddr, pkt *artnet.PollPacket) {
-	if a.debug {
-		log.Printf("[<-artnet] poll src=%s", src.IP)
+	log.Printf("[api] rdm discover universe=%s nodes=%d", u, len(nodes))
+	devices, err := a.rdm.Discover(artU, nodes, 3*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
 	}
-	a.discovery.HandlePoll(src)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
 }
 
-// HandlePollReply implements artnet.PacketHandler
-func (a *App) HandlePollReply(src *net.UDPAddr, pkt *artnet.PollReplyPacket) {
-	if a.debug {
-		log.Printf("[<-artnet] pollreply src=%s", src.IP)
+type inputRequest struct {
+	Address   string `json:"address"`
+	BindIndex int    `json:"bind_index"`
+	Disable   []int  `json:"disable"`
+}
+
+// handleInputs lists the node input states artmap enforces; POST sets one and sends ArtInput
+func (a *App) handleInputs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req inputRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := config.NodeInput{Address: req.Address, BindIndex: req.BindIndex, Disable: req.Disable}
+		if n.BindIndex < 0 || n.BindIndex > 255 {
+			http.Error(w, "bind_index must be 0-255", http.StatusBadRequest)
+			return
+		}
+		for _, port := range n.Disable {
+			if port < 1 || port > 4 {
+				http.Error(w, fmt.Sprintf("port %d out of range (1-4)", port), http.StatusBadRequest)
+				return
+			}
+		}
+		in := artnetio.NodeInput{Address: n.Address, BindIndex: uint8(n.BindIndex), Disabled: n.Disabled()}
+		if err := a.inputs.Set(in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[api] node input addr=%s bind=%d disable=%v", n.Address, n.BindIndex, n.Disable)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	a.discovery.HandlePollReply(src, pkt)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.inputs.List())
 }
 
-// HandleSACN handles incoming sACN DMX data
-func (a *App) HandleSACN(src *net.UDPAddr, pkt *sacn.DataPacket) {
-	if a.debug {
-		log.Printf("[<-sacn] src=%s universe=%d seq=%d", src.IP, pkt.Universe, pkt.Sequence)
+// handleLearn returns the captured input as [[static]] config; POST also writes it to the learn file
+func (a *App) handleLearn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
+
+	var buf bytes.Buffer
+	if err := a.learn.WriteTOML(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	u := config.Universe{Protocol: config.ProtocolSACN, Number: pkt.Universe}
-	a.senders.Record(u, src.IP)
-	a.engine.Remap(u, pkt.Data)
-	if a.senderHz == 0 {
-		a.sendOutputs(a.engine.GetDirtyOutputs())
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if a.learnFile == "" {
+			http.Error(w, "no --learn-file configured", http.StatusBadRequest)
+			return
+		}
+		if err := os.WriteFile(a.learnFile, buf.Bytes(), 0o644); err != nil {
+			log.Printf("[learn] write error: file=%s err=%v", a.learnFile, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[learn] wrote file=%s", a.learnFile)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/toml")
+	w.Write(buf.Bytes())
 }
 
-func (a *App) sendOutputs(outputs []remap.Output) {
-	for _, out := range outputs {
-		switch out.Universe.Protocol {
-		case config.ProtocolSACN:
-			u := out.Universe.Number
-			if a.debug {
-				log.Printf("[->sacn] universe=%d", u)
-			}
-			if err := a.sacnSender.SendDMX(u, out.Data[:]); err != nil {
-				log.Printf("[->sacn] error: universe=%d err=%v", u, err)
-			}
-			for _, target := range a.sacnTargets[u] {
-				if a.debug {
-					log.Printf("[->sacn] unicast dst=%s universe=%d", target.IP, u)
-				}
-				if err := a.sacnSender.SendDMXUnicast(target, u, out.Data[:]); err != nil {
-					log.Printf("[->sacn] error: dst=%s err=%v", target.IP, err)
-				}
-			}
+// handleCapture reports the packet capture; POST starts it (optionally ?file=) and DELETE stops it
+func (a *App) handleCapture(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "artmap")
 
-		case config.ProtocolArtNet:
-			u := out.Universe.Number
-			artU := artnet.Universe(u)
-			if target, ok := a.artTargets[u]; ok {
-				if a.debug {
-					log.Printf("[->artnet] dst=%s universe=%s", target.IP, out.Universe)
-				}
-				if err := a.artSender.SendDMX(target, artU, out.Data[:]); err != nil {
-					log.Printf("[->artnet] error: dst=%s err=%v", target.IP, err)
-				}
-			} else if nodes := a.discovery.GetNodesForUniverse(artU); len(nodes) > 0 {
-				for _, node := range nodes {
-					addr := &net.UDPAddr{
-						IP:   node.IP,
-						Port: int(node.Port),
-					}
-					if a.debug {
-						log.Printf("[->artnet] dst=%s universe=%s", node.IP, out.Universe)
-					}
-					if err := a.artSender.SendDMX(addr, artU, out.Data[:]); err != nil {
-						log.Printf("[->artnet] error: dst=%s err=%v", node.IP, err)
-					}
-				}
-			}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		file, err := a.captureTarget(r.URL.Query().Get("file"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		if err := a.capture.Start(file); err != nil {
+			log.Printf("[capture] error: file=%s err=%v", file, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[capture] writing file=%s", file)
+	case http.MethodDelete:
+		if err := a.capture.Stop(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[capture] stopped")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-}
 
-type statusResponse struct {
-	Targets  []config.Target      `json:"targets"`
-	Mappings []config.Mapping     `json:"mappings"`
-	Senders  []senders.SenderInfo `json:"senders"`
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.capture.Status())
 }
 
-func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Server", "artmap")
-	resp := statusResponse{
-		Targets:  a.cfg.Targets,
-		Mappings: a.cfg.Mappings,
-		Senders:  a.senders.GetAll(),
+// captureTarget returns the file a capture started over the API writes: name
+// inside --capture-dir, else the --capture-file or artmap.pcap in that directory.
+// Requests can't name a path, so they can't create or overwrite other files.
+func (a *App) captureTarget(name string) (string, error) {
+	if name == "" {
+		if a.captureFile != "" {
+			return a.captureFile, nil
+		}
+		name = "artmap.pcap"
+	} else if a.captureDir == "" {
+		return "", fmt.Errorf("choosing a capture file needs --capture-dir")
 	}
-	json.NewEncoder(w).Encode(resp)
+	if name != filepath.Base(name) || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("capture file %q must be a plain file name", name)
+	}
+	return filepath.Join(a.captureDir, name), nil
 }
 
 func (a *App) printStats() {
+	for ip, n := range a.flood.SwapDropped(30 * time.Second) {
+		log.Printf("[flood] src=%s dropped=%d (last 10s)", ip, n)
+	}
+	stats := a.chaos.SwapStats()
+	dsts := make([]string, 0, len(stats))
+	for dst := range stats {
+		dsts = append(dsts, dst)
+	}
+	sort.Strings(dsts)
+	for _, dst := range dsts {
+		st := stats[dst]
+		log.Printf("[chaos] dst=%s dropped=%d delayed=%d duplicated=%d reordered=%d (last 10s)",
+			dst, st.Dropped, st.Delayed, st.Duplicated, st.Reordered)
+	}
+	if n := a.fanout.SwapDropped(); n > 0 {
+		log.Printf("[sender] queue full dropped=%d (last 10s)", n)
+	}
+
 	if len(a.cfg.Mappings) == 0 {
 		return
 	}
-	counts := a.engine.SwapStats()
+	counts := a.profiles.Active().SwapStats()
 	log.Printf("[stats] mapping traffic (last 10s):")
 	for _, m := range a.cfg.Mappings {
-		log.Printf("[stats]   %s -> %s: %d packets", m.From, m.To, counts[m.From.Universe])
+		n := counts[m.From.Universe]
+		if m.From.Wildcard {
+			n = 0
+			for u, c := range counts {
+				if u.Protocol == m.From.Universe.Protocol {
+					n += c
+				}
+			}
+		}
+		if m.Label != "" {
+			log.Printf("[stats]   %s -> %s (%s): %d packets", m.From, m.To, m.Label, n)
+		} else {
+			log.Printf("[stats]   %s -> %s: %d packets", m.From, m.To, n)
+		}
 	}
 }
 
@@ -381,6 +2987,33 @@ func init() {
 	log.SetFlags(log.Ltime | log.Lmicroseconds)
 }
 
+// listenURL converts an HTTP listen address like ":8080" into a URL for connecting to it locally
+func listenURL(listen string, https bool) string {
+	scheme := "http://"
+	if https {
+		scheme = "https://"
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return scheme + listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return scheme + net.JoinHostPort(host, port)
+}
+
+// parseSwitch parses --artnet-switch as net.subnet, net 0-127 and sub-net 0-15
+func parseSwitch(s string) (uint8, uint8, error) {
+	n, sub, ok := strings.Cut(s, ".")
+	netSwitch, err1 := strconv.ParseUint(n, 10, 8)
+	subSwitch, err2 := strconv.ParseUint(sub, 10, 8)
+	if !ok || err1 != nil || err2 != nil || netSwitch > 127 || subSwitch > 15 {
+		return 0, 0, fmt.Errorf("invalid switch %q (want net.subnet, net 0-127, sub-net 0-15)", s)
+	}
+	return uint8(netSwitch), uint8(subSwitch), nil
+}
+
 // parseListenAddr parses listen address formats:
 // - "host:port" -> bind to specific host and port
 // - "host" -> bind to specific host, default port
@@ -421,9 +3054,158 @@ func parseListenAddr(s string) (*net.UDPAddr, error) {
 	return &net.UDPAddr{IP: ip, Port: port}, nil
 }
 
+// runAnalyze summarizes the DMX traffic of a pcap or pcapng capture
+func runAnalyze(path string, w io.Writer) error {
+	if path == "" {
+		return fmt.Errorf("usage: artmap analyze <capture.pcap|capture.pcapng>")
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := capture.NewReader(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+	rep, err := analyze.Analyze(r)
+	if err != nil {
+		return err
+	}
+	return rep.Write(w)
+}
+
+// runSimulate runs cfg offline against the input frames of a capture or
+// recording and reports how its outputs differ from a golden recording
+func runSimulate(cfg *config.Config, input, golden string, tolerance time.Duration, w io.Writer) error {
+	if input == "" || golden == "" {
+		return fmt.Errorf("usage: artmap --config new.toml --input show.pcapng --golden outputs.rec simulate")
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	inputs, err := simulate.OpenInputs(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+	sim := simulate.New(cfg)
+	if err := sim.Run(inputs); err != nil {
+		return err
+	}
+
+	g, err := os.Open(golden)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	r, err := recording.NewReader(bufio.NewReader(g))
+	if err != nil {
+		return err
+	}
+	rep, err := sim.Compare(r, tolerance)
+	if err != nil {
+		return err
+	}
+	if err := rep.Write(w); err != nil {
+		return err
+	}
+	if n := rep.Differing(); n > 0 {
+		return fmt.Errorf("%d universes differ", n)
+	}
+	return nil
+}
+
+// runSelftest binds the sockets the config and flags need, joins the sACN
+// groups, checks a route to every target and polls for nodes, without sending DMX
+func runSelftest(cfg *config.Config, artnetListen, sacnInterface, apiListen string, targets, poll []*net.UDPAddr, w io.Writer) error {
+	plan := selftest.Plan{
+		SACNUniverses: cfg.SACNSourceUniverses(),
+		SACNSources:   cfg.SACNSources(),
+		Targets:       targets,
+		Poll:          poll,
+		PollWait:      3 * time.Second,
+	}
+	if artnetListen != "" {
+		addr, err := parseListenAddr(artnetListen)
+		if err != nil {
+			return err
+		}
+		plan.ArtNetListen = addr
+	}
+	if sacnInterface != "" {
+		iface, err := net.InterfaceByName(sacnInterface)
+		if err != nil {
+			return err
+		}
+		plan.SACNInterface = iface
+	}
+	if apiListen != "" {
+		plan.Listen = append(plan.Listen, apiListen)
+	}
+	for _, d := range cfg.Domains {
+		addr, err := parseListenAddr(d.ArtNetListen)
+		if err != nil {
+			return err
+		}
+		plan.Domains = append(plan.Domains, addr)
+	}
+
+	rep := selftest.Run(plan)
+	if err := rep.Write(w); err != nil {
+		return err
+	}
+	if n := rep.Failed(); n > 0 {
+		return fmt.Errorf("%d checks failed", n)
+	}
+	return nil
+}
+
+// runExport converts an artmap recording to csv or pcapng
+func runExport(path, format, out string) error {
+	if path == "" {
+		return fmt.Errorf("usage: artmap [--export-format csv|pcapng] [--export-out file] export <recording>")
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := recording.NewReader(bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if out != "" {
+		if w, err = os.Create(out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	bw := bufio.NewWriter(w)
+
+	switch format {
+	case "csv":
+		err = recording.ExportCSV(r, bw)
+	case "pcapng":
+		err = recording.ExportPcapng(r, bw)
+	default:
+		return fmt.Errorf("unknown export format %q (expected csv or pcapng)", format)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
 func protocolPort(p config.Protocol) int {
-	if p == config.ProtocolSACN {
+	switch p {
+	case config.ProtocolSACN:
 		return sacn.Port
+	case config.ProtocolESPNet:
+		return espnet.Port
 	}
 	return artnet.Port
 }
@@ -455,6 +3237,31 @@ func parseTargetAddr(s string, defaultPort int) (*net.UDPAddr, error) {
 	return &net.UDPAddr{IP: ip, Port: port}, nil
 }
 
+// printInterfaces lists the network interfaces that --sacn-interface and
+// --artnet-egress accept, with the addresses that also select them
+func printInterfaces(w io.Writer) error {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	for _, iface := range ifaces {
+		state := "down"
+		if iface.Flags&net.FlagUp != 0 {
+			state = "up"
+		}
+		var addrs []string
+		if all, err := iface.Addrs(); err == nil {
+			for _, addr := range all {
+				if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+					addrs = append(addrs, ipnet.String())
+				}
+			}
+		}
+		fmt.Fprintf(w, "%-16s %-4s %-17s %s\n", iface.Name, state, iface.HardwareAddr, strings.Join(addrs, " "))
+	}
+	return nil
+}
+
 // detectLocalInterface returns local IP and MAC for an interface matching the broadcast address
 func detectLocalInterface(broadcast net.IP) (net.IP, net.HardwareAddr) {
 	ifaces, err := net.Interfaces()
@@ -501,8 +3308,27 @@ func detectLocalInterface(broadcast net.IP) (net.IP, net.HardwareAddr) {
 	return nil, nil
 }
 
-// detectBroadcastAddrs returns broadcast addresses for all network interfaces
-func detectBroadcastAddrs() []*net.UDPAddr {
+// chooseAutoInterface returns the interface "auto" selects: the one on subnet
+// if given, otherwise the one whose subnets hold the most configured targets
+func chooseAutoInterface(subnet string, targets []*net.UDPAddr) *net.Interface {
+	if subnet != "" {
+		iface, err := artnetio.ResolveInterface(subnet)
+		if err != nil {
+			log.Printf("[net] auto subnet error: %v", err)
+			return nil
+		}
+		return iface
+	}
+	ips := make([]net.IP, len(targets))
+	for i, t := range targets {
+		ips[i] = t.IP
+	}
+	return artnetio.InterfaceForTargets(ips)
+}
+
+// detectBroadcastAddrs returns broadcast addresses for all network interfaces,
+// with preferred's first so it becomes the primary broadcast
+func detectBroadcastAddrs(preferred *net.Interface) []*net.UDPAddr {
 	var addrs []*net.UDPAddr
 	seen := make(map[string]bool)
 
@@ -550,10 +3376,15 @@ func detectBroadcastAddrs() []*net.UDPAddr {
 			}
 			seen[key] = true
 
-			addrs = append(addrs, &net.UDPAddr{
+			addr := &net.UDPAddr{
 				IP:   broadcast,
 				Port: artnet.Port,
-			})
+			}
+			if preferred != nil && iface.Name == preferred.Name {
+				addrs = append([]*net.UDPAddr{addr}, addrs...)
+			} else {
+				addrs = append(addrs, addr)
+			}
 		}
 	}
 
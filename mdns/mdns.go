@@ -0,0 +1,254 @@
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	Service = "_artmap._tcp.local."
+
+	port          = 5353
+	servicesName  = "_services._dns-sd._udp.local."
+	serviceTTL    = 4500
+	hostTTL       = 120
+	classCacheIn  = dnsmessage.ClassINET | 0x8000 // cache-flush bit for records only we own
+	announceCount = 2
+)
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: port}
+
+// Responder advertises one instance of the admin API over multicast DNS and
+// answers queries for it, so clients can browse for _artmap._tcp
+type Responder struct {
+	conn     *net.UDPConn
+	pc       *ipv4.PacketConn
+	instance dnsmessage.Name
+	service  dnsmessage.Name
+	services dnsmessage.Name
+	host     dnsmessage.Name
+	port     uint16
+	txt      []string
+	done     chan struct{}
+}
+
+// New joins the mDNS group on every multicast-capable interface; instance is
+// the human-readable name shown when browsing, txt the key=value records
+func New(instance, hostname string, apiPort int, txt []string) (*Responder, error) {
+	// Shared with any other responder on the host, such as avahi
+	lc := net.ListenConfig{Control: reuse}
+	pconn, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	conn := pconn.(*net.UDPConn)
+	pc := ipv4.NewPacketConn(conn)
+	pc.SetMulticastTTL(255)
+	pc.SetMulticastLoopback(true)
+
+	joined := 0
+	ifaces, _ := net.Interfaces()
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagUp == 0 || ifaces[i].Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if pc.JoinGroup(&ifaces[i], group) == nil {
+			joined++
+		}
+	}
+	if joined == 0 {
+		conn.Close()
+		return nil, fmt.Errorf("no multicast interface joined %s", group.IP)
+	}
+
+	r := &Responder{
+		conn:     conn,
+		pc:       pc,
+		service:  dnsmessage.MustNewName(Service),
+		services: dnsmessage.MustNewName(servicesName),
+		port:     uint16(apiPort),
+		txt:      txt,
+		done:     make(chan struct{}),
+	}
+	if r.instance, err = dnsmessage.NewName(instanceLabel(instance) + "." + Service); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if r.host, err = dnsmessage.NewName(hostLabel(hostname) + ".local."); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Responder) Start() {
+	go r.loop()
+	go r.announce()
+}
+
+// Stop sends a goodbye so browsers drop the instance immediately, then closes the socket
+func (r *Responder) Stop() {
+	if r == nil {
+		return
+	}
+	close(r.done)
+	if msg, err := r.response(0, nil, 0); err == nil {
+		r.conn.WriteToUDP(msg, group)
+	}
+	r.conn.Close()
+}
+
+func (r *Responder) announce() {
+	for i := 0; i < announceCount; i++ {
+		if msg, err := r.response(0, nil, 1); err == nil {
+			if _, err := r.conn.WriteToUDP(msg, group); err != nil {
+				log.Printf("[mdns] announce error: %v", err)
+			}
+		}
+		select {
+		case <-r.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (r *Responder) loop() {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		var p dnsmessage.Parser
+		hdr, err := p.Start(buf[:n])
+		if err != nil || hdr.Response {
+			continue
+		}
+		questions, err := p.AllQuestions()
+		if err != nil || !r.matches(questions) {
+			continue
+		}
+
+		// One-shot resolvers query from an ephemeral port and expect a unicast
+		// reply that echoes the ID and questions
+		if src.Port != port {
+			if msg, err := r.response(hdr.ID, questions, 1); err == nil {
+				r.conn.WriteToUDP(msg, src)
+			}
+			continue
+		}
+		if msg, err := r.response(0, nil, 1); err == nil {
+			r.conn.WriteToUDP(msg, group)
+		}
+	}
+}
+
+func (r *Responder) matches(questions []dnsmessage.Question) bool {
+	for _, q := range questions {
+		name := strings.ToLower(q.Name.String())
+		for _, ours := range []dnsmessage.Name{r.services, r.service, r.instance, r.host} {
+			if name == strings.ToLower(ours.String()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// response builds the full record set; ttlScale 0 makes it a goodbye
+func (r *Responder) response(id uint16, questions []dnsmessage.Question, ttlScale uint32) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+
+	if len(questions) > 0 {
+		if err := b.StartQuestions(); err != nil {
+			return nil, err
+		}
+		for _, q := range questions {
+			if err := b.Question(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	shared := func(name dnsmessage.Name, ttl uint32) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl * ttlScale}
+	}
+	unique := func(name dnsmessage.Name, ttl uint32) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: classCacheIn, TTL: ttl * ttlScale}
+	}
+	if err := b.PTRResource(shared(r.services, serviceTTL), dnsmessage.PTRResource{PTR: r.service}); err != nil {
+		return nil, err
+	}
+	if err := b.PTRResource(shared(r.service, serviceTTL), dnsmessage.PTRResource{PTR: r.instance}); err != nil {
+		return nil, err
+	}
+	if err := b.SRVResource(unique(r.instance, hostTTL), dnsmessage.SRVResource{Port: r.port, Target: r.host}); err != nil {
+		return nil, err
+	}
+	txt := r.txt
+	if len(txt) == 0 {
+		txt = []string{""}
+	}
+	if err := b.TXTResource(unique(r.instance, serviceTTL), dnsmessage.TXTResource{TXT: txt}); err != nil {
+		return nil, err
+	}
+	for _, ip := range localIPv4s() {
+		var a [4]byte
+		copy(a[:], ip)
+		if err := b.AResource(unique(r.host, hostTTL), dnsmessage.AResource{A: a}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func localIPv4s() []net.IP {
+	var result []net.IP
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				result = append(result, ipnet.IP.To4())
+			}
+		}
+	}
+	return result
+}
+
+// instanceLabel keeps an instance name to one DNS label; dnsmessage has no escaping
+func instanceLabel(s string) string {
+	return strings.ReplaceAll(s, ".", "-")
+}
+
+// hostLabel reduces a hostname to its first label, the form used under .local
+func hostLabel(hostname string) string {
+	if i := strings.IndexByte(hostname, '.'); i >= 0 {
+		hostname = hostname[:i]
+	}
+	if hostname == "" {
+		return "artmap"
+	}
+	return hostname
+}
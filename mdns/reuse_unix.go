@@ -0,0 +1,21 @@
+//go:build unix
+
+package mdns
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reuse lets several sockets bind the mDNS port
+func reuse(network, address string, c syscall.RawConn) error {
+	var err error
+	c.Control(func(fd uintptr) {
+		if err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+			return
+		}
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	return err
+}
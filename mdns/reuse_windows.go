@@ -0,0 +1,18 @@
+//go:build windows
+
+package mdns
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// reuse lets several sockets bind the mDNS port; on Windows SO_REUSEADDR alone does
+func reuse(network, address string, c syscall.RawConn) error {
+	var err error
+	c.Control(func(fd uintptr) {
+		err = windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_REUSEADDR, 1)
+	})
+	return err
+}
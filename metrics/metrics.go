@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// TimingStats summarizes the observations of one timing metric over an interval
+type TimingStats struct {
+	Count uint64
+	Mean  time.Duration
+	Max   time.Duration
+}
+
+// Snapshot holds metric values accumulated since the previous flush
+type Snapshot struct {
+	Counters map[string]uint64
+	Timings  map[string]TimingStats
+}
+
+// Backend exports snapshots to an external metrics system
+type Backend interface {
+	Name() string
+	Export(s Snapshot) error
+}
+
+type timingAgg struct {
+	count uint64
+	sum   time.Duration
+	max   time.Duration
+}
+
+// Collector aggregates counters and timings in memory and periodically exports them.
+// All methods are safe to call on a nil collector.
+type Collector struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+	timings  map[string]*timingAgg
+	backends []Backend
+	done     chan struct{}
+}
+
+func New(backends ...Backend) *Collector {
+	return &Collector{
+		counters: map[string]uint64{},
+		timings:  map[string]*timingAgg{},
+		backends: backends,
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *Collector) Inc(name string, n uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.counters[name] += n
+	c.mu.Unlock()
+}
+
+func (c *Collector) Observe(name string, d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	t := c.timings[name]
+	if t == nil {
+		t = &timingAgg{}
+		c.timings[name] = t
+	}
+	t.count++
+	t.sum += d
+	t.max = max(t.max, d)
+	c.mu.Unlock()
+}
+
+func (c *Collector) Start(interval time.Duration) {
+	if c == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				c.Flush()
+			}
+		}
+	}()
+}
+
+func (c *Collector) Stop() {
+	if c == nil {
+		return
+	}
+	close(c.done)
+	c.Flush()
+}
+
+// Flush exports and resets everything accumulated since the previous flush
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	snap := Snapshot{
+		Counters: c.counters,
+		Timings:  make(map[string]TimingStats, len(c.timings)),
+	}
+	for name, t := range c.timings {
+		snap.Timings[name] = TimingStats{
+			Count: t.count,
+			Mean:  t.sum / time.Duration(t.count),
+			Max:   t.max,
+		}
+	}
+	c.counters = map[string]uint64{}
+	c.timings = map[string]*timingAgg{}
+	c.mu.Unlock()
+
+	for _, b := range c.backends {
+		if err := b.Export(snap); err != nil {
+			log.Printf("[metrics] %s export error: %v", b.Name(), err)
+		}
+	}
+}
+
+var universeKeyReplacer = strings.NewReplacer(":", ".", ".", "-")
+
+// UniverseKey formats a universe as a metric path, e.g. artnet.0-0-1
+func UniverseKey(u config.Universe) string {
+	return universeKeyReplacer.Replace(u.String())
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// maxDatagram keeps StatsD packets under a typical Ethernet MTU
+const maxDatagram = 1432
+
+// StatsD exports snapshots as StatsD counters and gauges over UDP
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+}
+
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && prefix[len(prefix)-1] != '.' {
+		prefix += "."
+	}
+	return &StatsD{conn: conn, prefix: prefix}, nil
+}
+
+func (s *StatsD) Name() string {
+	return "statsd"
+}
+
+func (s *StatsD) Export(snap Snapshot) error {
+	var lines []string
+	for name, v := range snap.Counters {
+		lines = append(lines, fmt.Sprintf("%s%s:%d|c", s.prefix, name, v))
+	}
+	for name, t := range snap.Timings {
+		lines = append(lines,
+			fmt.Sprintf("%s%s.count:%d|c", s.prefix, name, t.Count),
+			fmt.Sprintf("%s%s.mean:%g|ms", s.prefix, name, float64(t.Mean.Microseconds())/1000),
+			fmt.Sprintf("%s%s.max:%g|ms", s.prefix, name, float64(t.Max.Microseconds())/1000),
+		)
+	}
+	sort.Strings(lines)
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxDatagram {
+			if _, err := s.conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		_, err := s.conn.Write(buf.Bytes())
+		return err
+	}
+	return nil
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+type Direction string
+
+const (
+	Input  Direction = "input"
+	Output Direction = "output"
+)
+
+// staleAfter is how long a universe may be silent before its rate reads zero
+const staleAfter = 2 * time.Second
+
+// UniverseInfo reports the frame rate of one input or output universe
+type UniverseInfo struct {
+	Universe  config.Universe `json:"universe"`
+	Direction Direction       `json:"direction"`
+	FPS       float64         `json:"fps"`
+	LastSeen  time.Time       `json:"last_seen"`
+}
+
+type key struct {
+	dir Direction
+	u   config.Universe
+}
+
+type entry struct {
+	data        [512]byte
+	firstSeen   time.Time
+	lastSeen    time.Time
+	changes     [512]uint64
+	lastChanged [512]int64 // unix milliseconds
+	windowStart time.Time
+	windowCount int
+	fps         float64
+}
+
+// Monitor tracks the latest frame and frame rate of every universe seen
+type Monitor struct {
+	mu      sync.Mutex
+	entries map[key]*entry
+}
+
+func New() *Monitor {
+	return &Monitor{
+		entries: map[key]*entry{},
+	}
+}
+
+func (m *Monitor) Record(dir Direction, u config.Universe, data [512]byte) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := key{dir, u}
+	e := m.entries[k]
+	if e == nil {
+		e = &entry{firstSeen: now, windowStart: now, data: data}
+		m.entries[k] = e
+	}
+	for ch := range data {
+		if data[ch] != e.data[ch] {
+			e.changes[ch]++
+			e.lastChanged[ch] = now.UnixMilli()
+		}
+	}
+	e.data = data
+	e.lastSeen = now
+	e.windowCount++
+	if elapsed := now.Sub(e.windowStart); elapsed >= time.Second {
+		e.fps = float64(e.windowCount) / elapsed.Seconds()
+		e.windowStart = now
+		e.windowCount = 0
+	}
+}
+
+// Frame returns the last frame seen on a universe
+func (m *Monitor) Frame(dir Direction, u config.Universe) ([512]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key{dir, u}]
+	if e == nil {
+		return [512]byte{}, false
+	}
+	return e.data, true
+}
+
+// Universes returns every universe seen, inputs first, sorted by universe
+func (m *Monitor) Universes() []UniverseInfo {
+	now := time.Now()
+
+	m.mu.Lock()
+	result := make([]UniverseInfo, 0, len(m.entries))
+	for k, e := range m.entries {
+		fps := e.fps
+		if now.Sub(e.lastSeen) > staleAfter {
+			fps = 0
+		}
+		result = append(result, UniverseInfo{
+			Universe:  k.u,
+			Direction: k.dir,
+			FPS:       fps,
+			LastSeen:  e.lastSeen,
+		})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Direction != result[j].Direction {
+			return result[i].Direction == Input
+		}
+		return result[i].Universe.Compare(result[j].Universe) < 0
+	})
+	return result
+}
+
+// FrameInfo is the API representation of the last frame on a universe
+type FrameInfo struct {
+	Universe  config.Universe `json:"universe"`
+	Direction Direction       `json:"direction"`
+	Data      [512]byte       `json:"data"`
+}
+
+// ActivityInfo counts, per channel of a universe, the value changes since Since
+// and when each channel last changed, for rendering as a heatmap
+type ActivityInfo struct {
+	Universe    config.Universe `json:"universe"`
+	Direction   Direction       `json:"direction"`
+	Since       time.Time       `json:"since"`
+	Changes     [512]uint64     `json:"changes"`
+	LastChanged [512]int64      `json:"last_changed"` // unix milliseconds, 0 if unchanged since Since
+}
+
+// Activity returns the per-channel change counts of a universe
+func (m *Monitor) Activity(dir Direction, u config.Universe) (ActivityInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key{dir, u}]
+	if e == nil {
+		return ActivityInfo{}, false
+	}
+	return ActivityInfo{Universe: u, Direction: dir, Since: e.firstSeen, Changes: e.changes, LastChanged: e.lastChanged}, true
+}
+
+// ResetActivity clears the change counts of a universe, starting them again from now
+func (m *Monitor) ResetActivity(dir Direction, u config.Universe) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entries[key{dir, u}]
+	if e == nil {
+		return false
+	}
+	e.firstSeen = time.Now()
+	e.changes = [512]uint64{}
+	e.lastChanged = [512]int64{}
+	return true
+}
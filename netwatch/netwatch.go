@@ -0,0 +1,76 @@
+package netwatch
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Watcher polls interface addresses and reports when the set changes, e.g.
+// after a cable re-plug or DHCP renew
+type Watcher struct {
+	interval time.Duration
+	onChange func()
+	last     string
+	done     chan struct{}
+}
+
+func New(interval time.Duration, onChange func()) *Watcher {
+	return &Watcher{
+		interval: interval,
+		onChange: onChange,
+		last:     snapshot(),
+		done:     make(chan struct{}),
+	}
+}
+
+func (w *Watcher) Start() {
+	go w.loop()
+}
+
+func (w *Watcher) Stop() {
+	close(w.done)
+}
+
+func (w *Watcher) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		if s := snapshot(); s != w.last {
+			w.last = s
+			w.onChange()
+		}
+	}
+}
+
+// snapshot returns the up interfaces' IPv4 addresses in a comparable form
+func snapshot() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var entries []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				entries = append(entries, iface.Name+"="+ipnet.String())
+			}
+		}
+	}
+	slices.Sort(entries)
+	return strings.Join(entries, ",")
+}
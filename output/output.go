@@ -0,0 +1,164 @@
+package output
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Driver sends the output universes of one protocol
+type Driver interface {
+	// Send queues or writes one frame of universe u; failures show in Stats
+	Send(u config.Universe, data [512]byte)
+	Close() error
+	Stats() Stats
+}
+
+// Stats counts the sends of one driver; a frame to several destinations counts once per destination
+type Stats struct {
+	Sent   uint64 `json:"sent"`
+	Errors uint64 `json:"errors"`
+}
+
+// Counter keeps Stats for a driver and is safe for concurrent use
+type Counter struct {
+	sent   atomic.Uint64
+	errors atomic.Uint64
+}
+
+// Record counts the result of one send
+func (c *Counter) Record(err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.sent.Add(1)
+}
+
+// Track wraps send so its result is counted
+func (c *Counter) Track(send func() error) func() error {
+	return func() error {
+		err := send()
+		c.Record(err)
+		return err
+	}
+}
+
+func (c *Counter) Stats() Stats {
+	return Stats{Sent: c.sent.Load(), Errors: c.errors.Load()}
+}
+
+// Registry routes each output universe to the driver registered for its
+// protocol, holding back frames of paused protocols and universes
+type Registry struct {
+	drivers map[config.Protocol]Driver
+
+	mu        sync.RWMutex
+	protocols map[config.Protocol]bool
+	universes map[config.Universe]bool
+}
+
+// Paused lists the protocols and universes that are not transmitted
+type Paused struct {
+	Protocols []config.Protocol `json:"protocols"`
+	Universes []config.Universe `json:"universes"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		drivers:   map[config.Protocol]Driver{},
+		protocols: map[config.Protocol]bool{},
+		universes: map[config.Universe]bool{},
+	}
+}
+
+// Register sets the driver of a protocol; it must be called before the registry is in use
+func (r *Registry) Register(p config.Protocol, d Driver) {
+	r.drivers[p] = d
+}
+
+// Send hands a frame to the driver of u's protocol, reporting false if there
+// is none; frames of paused protocols and universes are dropped
+func (r *Registry) Send(u config.Universe, data [512]byte) bool {
+	d, ok := r.drivers[u.Protocol]
+	if !ok {
+		return false
+	}
+	r.mu.RLock()
+	paused := r.protocols[u.Protocol] || r.universes[u]
+	r.mu.RUnlock()
+	if !paused {
+		d.Send(u, data)
+	}
+	return true
+}
+
+// PauseProtocol stops or resumes transmitting every universe of p
+func (r *Registry) PauseProtocol(p config.Protocol, paused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if paused {
+		r.protocols[p] = true
+	} else {
+		delete(r.protocols, p)
+	}
+}
+
+// PauseUniverse stops or resumes transmitting universe u
+func (r *Registry) PauseUniverse(u config.Universe, paused bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if paused {
+		r.universes[u] = true
+	} else {
+		delete(r.universes, u)
+	}
+}
+
+// Paused returns the paused protocols and universes, sorted
+func (r *Registry) Paused() Paused {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := Paused{Protocols: []config.Protocol{}, Universes: []config.Universe{}}
+	for p := range r.protocols {
+		result.Protocols = append(result.Protocols, p)
+	}
+	for u := range r.universes {
+		result.Universes = append(result.Universes, u)
+	}
+	slices.Sort(result.Protocols)
+	slices.SortFunc(result.Universes, config.Universe.Compare)
+	return result
+}
+
+// Protocols returns the protocols with a driver, sorted
+func (r *Registry) Protocols() []config.Protocol {
+	result := make([]config.Protocol, 0, len(r.drivers))
+	for p := range r.drivers {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Stats returns the stats of every driver by protocol
+func (r *Registry) Stats() map[config.Protocol]Stats {
+	result := make(map[config.Protocol]Stats, len(r.drivers))
+	for p, d := range r.drivers {
+		result[p] = d.Stats()
+	}
+	return result
+}
+
+// Close closes every driver, returning the errors joined
+func (r *Registry) Close() error {
+	var errs []error
+	for _, p := range r.Protocols() {
+		errs = append(errs, r.drivers[p].Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package privilege
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Capability is a Linux capability artmap can use when not running as root
+type Capability int
+
+// Capability numbers as in linux/capability.h
+const (
+	// NetBindService allows listening on ports below 1024
+	NetBindService Capability = 10
+	// NetRaw allows the pcap handles the multicast library sends and hears IGMP with
+	NetRaw Capability = 13
+)
+
+func (c Capability) String() string {
+	switch c {
+	case NetBindService:
+		return "cap_net_bind_service"
+	case NetRaw:
+		return "cap_net_raw"
+	}
+	return fmt.Sprintf("cap_%d", int(c))
+}
+
+// Hint returns the setcap command that grants caps to the running binary
+func Hint(caps ...Capability) string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "artmap"
+	}
+	names := make([]string, len(caps))
+	for i, c := range caps {
+		names[i] = c.String()
+	}
+	return fmt.Sprintf("sudo setcap %s+ep %s", strings.Join(names, ","), exe)
+}
+
+// Explain adds the capability that would have allowed it to a permission error
+func Explain(err error, c Capability) error {
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		return fmt.Errorf("%w (needs %s: run as root or grant it with: %s)", err, c, Hint(c))
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package privilege
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Has reports whether the process holds c in its effective set
+func Has(c Capability) bool {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false
+	}
+	return data[c/32].Effective&(1<<(uint(c)%32)) != 0
+}
+
+// Drop switches the process to the named user (or numeric uid), its primary
+// group and its supplementary groups. Changing from root to another uid clears
+// every capability, so sockets and devices must be open first. Dropping to
+// the user the process already runs as, e.g. after a restart, does nothing.
+func Drop(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return nil, fmt.Errorf("unknown user %q", name)
+		}
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: uid %q is not numeric", u.Username, u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: gid %q is not numeric", u.Username, u.Gid)
+	}
+	if uid == 0 {
+		return nil, fmt.Errorf("user %s is root", u.Username)
+	}
+	if os.Getuid() == uid && os.Geteuid() == uid {
+		return u, nil
+	}
+
+	var groups []int
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("user %s: groups: %w", u.Username, err)
+	}
+	for _, id := range ids {
+		if g, err := strconv.Atoi(id); err == nil {
+			groups = append(groups, g)
+		}
+	}
+
+	if err := syscall.Setgroups(groups); err != nil {
+		return nil, fmt.Errorf("setgroups: %w (dropping privileges needs root)", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return nil, fmt.Errorf("setgid %d: %w", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return nil, fmt.Errorf("setuid %d: %w", uid, err)
+	}
+	if os.Getuid() != uid || os.Geteuid() != uid || syscall.Setuid(0) == nil {
+		return nil, fmt.Errorf("still able to regain root after switching to %s", u.Username)
+	}
+	return u, nil
+}
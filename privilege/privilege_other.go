@@ -0,0 +1,19 @@
+//go:build !linux
+
+package privilege
+
+import (
+	"fmt"
+	"os/user"
+	"runtime"
+)
+
+// Has reports true: capabilities are Linux only, so none is withheld elsewhere
+func Has(c Capability) bool {
+	return true
+}
+
+// Drop is not supported outside Linux
+func Drop(name string) (*user.User, error) {
+	return nil, fmt.Errorf("dropping privileges is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,82 @@
+package probe
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+)
+
+func FuzzProber(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 0, 0, 1, 0})
+	f.Add([]byte{0, 0, 0, 0, 2, 0, 0, 0, 0})
+	f.Add([]byte{2, 1, 0, 2, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		const interval = time.Second
+		fake := clock.NewFake(time.Unix(1000, 0))
+		polled := map[string]int{}
+		p := New(interval, func(ip net.IP) error {
+			polled[ip.String()]++
+			return nil
+		})
+		p.SetClock(fake)
+		var changes int
+		p.SetOnChange(func(net.IP, bool) { changes++ })
+
+		artpoll, ping := net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)
+		p.Add(artpoll, MethodArtPoll)
+		p.Add(ping, MethodICMP)
+		p.Add(artpoll, MethodICMP)
+		if p.Len() != 2 {
+			t.Fatalf("duplicate address added: %d targets", p.Len())
+		}
+		p.started = fake.Now()
+
+		lastReply := map[string]time.Time{}
+		for _, op := range ops {
+			switch op % 3 {
+			case 0:
+				fake.Advance(interval)
+				p.tick()
+			case 1:
+				p.Reply(artpoll, MethodArtPoll)
+				lastReply[artpoll.String()] = fake.Now()
+			case 2:
+				p.Reply(ping, MethodICMP)
+				lastReply[ping.String()] = fake.Now()
+			}
+
+			now := fake.Now()
+			for _, st := range p.Statuses() {
+				last, replied := lastReply[st.Address]
+				if st.Up && !replied {
+					t.Fatalf("%s up without a reply", st.Address)
+				}
+				if op%3 != 0 {
+					continue
+				}
+				fresh := replied && now.Sub(last) <= interval*missedProbes
+				if now.Sub(p.started) >= interval*missedProbes && st.Up != fresh {
+					t.Fatalf("%s up=%v, last reply %s ago", st.Address, st.Up, now.Sub(last))
+				}
+			}
+		}
+
+		if polled[ping.String()] != 0 {
+			t.Fatalf("icmp target was sent %d artpolls", polled[ping.String()])
+		}
+		events := p.Events()
+		if len(events) != min(changes, recentEvents) {
+			t.Fatalf("%d events but %d change callbacks", len(events), changes)
+		}
+		state := map[string]bool{}
+		for _, e := range events {
+			if prev, ok := state[e.Address]; ok && prev == e.Up {
+				t.Fatalf("repeated event %+v", e)
+			}
+			state[e.Address] = e.Up
+		}
+	})
+}
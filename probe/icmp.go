@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"net"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// pinger sends ICMP echo requests and reports the addresses that answer
+type pinger struct {
+	conn *icmp.PacketConn
+	raw  bool // raw sockets see every echo reply, so replies are matched on id
+	id   int
+	seq  atomic.Uint32
+}
+
+// openPinger prefers an unprivileged datagram ICMP socket, falling back to a raw one
+func openPinger() (*pinger, error) {
+	id := os.Getpid() & 0xffff
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err == nil {
+		return &pinger{conn: conn, id: id}, nil
+	}
+	conn, rawErr := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if rawErr != nil {
+		return nil, err
+	}
+	return &pinger{conn: conn, raw: true, id: id}, nil
+}
+
+func (pg *pinger) send(ip net.IP) error {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: pg.id, Seq: int(pg.seq.Add(1) & 0xffff), Data: []byte("artmap")},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if pg.raw {
+		dst = &net.IPAddr{IP: ip}
+	}
+	_, err = pg.conn.WriteTo(data, dst)
+	return err
+}
+
+// run calls reply with the source of each echo reply until the socket is closed
+func (pg *pinger) run(reply func(ip net.IP)) {
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := pg.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg, err := icmp.ParseMessage(1, buf[:n])
+		if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		if echo, ok := msg.Body.(*icmp.Echo); !ok || (pg.raw && echo.ID != pg.id) {
+			continue
+		}
+		switch addr := peer.(type) {
+		case *net.UDPAddr:
+			reply(addr.IP)
+		case *net.IPAddr:
+			reply(addr.IP)
+		}
+	}
+}
+
+func (pg *pinger) close() {
+	pg.conn.Close()
+}
@@ -0,0 +1,258 @@
+package probe
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+)
+
+const (
+	// MethodArtPoll probes with unicast ArtPoll, pinging when polls go unanswered
+	MethodArtPoll = "artpoll"
+	// MethodICMP probes with ICMP echo only, for gateways that do not speak ArtNet
+	MethodICMP = "icmp"
+
+	// missedProbes is how many probe intervals may pass without a reply before a target is down
+	missedProbes = 3
+
+	// recentEvents is how many up/down events are kept for the API
+	recentEvents = 100
+)
+
+// Status is the probed state of one target as reported by the API
+type Status struct {
+	Address   string    `json:"address"`
+	Method    string    `json:"method"`
+	Up        bool      `json:"up"`
+	Since     time.Time `json:"since,omitzero"` // when up last changed, zero until the first verdict
+	LastReply time.Time `json:"last_reply,omitzero"`
+	LastVia   string    `json:"last_via,omitempty"` // artpoll or icmp
+}
+
+// Event is a target going up or down
+type Event struct {
+	Time    time.Time `json:"time"`
+	Address string    `json:"address"`
+	Up      bool      `json:"up"`
+}
+
+type target struct {
+	ip        net.IP
+	method    string
+	up        bool
+	known     bool
+	since     time.Time
+	lastReply time.Time
+	lastPoll  time.Time // last ArtPollReply, to decide when to fall back to ICMP
+	via       string
+}
+
+// Prober periodically probes static targets and tracks whether each is answering,
+// so a powered-off gateway is noticed before its fixtures are needed
+type Prober struct {
+	interval time.Duration
+	clock    clock.Clock
+	poll     func(ip net.IP) error
+	pinger   *pinger
+	onChange func(ip net.IP, up bool)
+	started  time.Time
+	done     chan struct{}
+
+	mu      sync.Mutex
+	targets []*target
+	events  []Event
+}
+
+// New creates a prober sending ArtPolls with poll every interval
+func New(interval time.Duration, poll func(ip net.IP) error) *Prober {
+	return &Prober{
+		interval: interval,
+		clock:    clock.Real,
+		poll:     poll,
+		done:     make(chan struct{}),
+	}
+}
+
+// SetClock replaces the clock driving probes and timeouts; call before Start
+func (p *Prober) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// SetOnChange registers fn to be called when a target goes up or down; call before Start
+func (p *Prober) SetOnChange(fn func(ip net.IP, up bool)) {
+	p.onChange = fn
+}
+
+// Add probes ip with method; an address already added keeps its first method. Call before Start.
+func (p *Prober) Add(ip net.IP, method string) {
+	for _, t := range p.targets {
+		if t.ip.Equal(ip) {
+			return
+		}
+	}
+	p.targets = append(p.targets, &target{ip: ip, method: method})
+}
+
+// Len returns the number of probed targets
+func (p *Prober) Len() int {
+	return len(p.targets)
+}
+
+// Start opens the ICMP socket, if the system allows one, and begins probing
+func (p *Prober) Start() {
+	pg, err := openPinger()
+	if err != nil {
+		log.Printf("[probe] icmp unavailable, artpoll only: err=%v", err)
+	} else {
+		p.pinger = pg
+		go pg.run(func(ip net.IP) { p.Reply(ip, MethodICMP) })
+	}
+	p.started = p.clock.Now()
+	go p.loop()
+}
+
+func (p *Prober) Stop() {
+	close(p.done)
+	if p.pinger != nil {
+		p.pinger.close()
+	}
+}
+
+func (p *Prober) loop() {
+	p.tick()
+
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C():
+			p.tick()
+		}
+	}
+}
+
+// tick judges each target on the replies so far, then sends the next probes
+func (p *Prober) tick() {
+	now := p.clock.Now()
+	deadline := p.interval * missedProbes
+
+	type change struct {
+		ip net.IP
+		up bool
+	}
+	var changes []change
+	var polls, pings []net.IP
+
+	p.mu.Lock()
+	for _, t := range p.targets {
+		up := !t.lastReply.IsZero() && now.Sub(t.lastReply) <= deadline
+		// A target that has never answered is only judged down once it has had a full deadline
+		waiting := !t.known && now.Sub(p.started) < deadline
+		if (up || !waiting) && p.judge(t, up, now) {
+			changes = append(changes, change{t.ip, up})
+		}
+
+		switch {
+		case t.method == MethodICMP:
+			pings = append(pings, t.ip)
+		case t.lastPoll.IsZero() || now.Sub(t.lastPoll) > p.interval:
+			polls = append(polls, t.ip)
+			pings = append(pings, t.ip)
+		default:
+			polls = append(polls, t.ip)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range changes {
+		p.changed(c.ip, c.up)
+	}
+	for _, ip := range polls {
+		if err := p.poll(ip); err != nil {
+			log.Printf("[probe] artpoll error: dst=%s err=%v", ip, err)
+		}
+	}
+	if p.pinger != nil {
+		for _, ip := range pings {
+			p.pinger.send(ip)
+		}
+	}
+}
+
+// judge records up as t's verdict at now, reporting whether it changed; p.mu must be held
+func (p *Prober) judge(t *target, up bool, now time.Time) bool {
+	if t.known && t.up == up {
+		return false
+	}
+	t.known, t.up, t.since = true, up, now
+	p.events = append(p.events, Event{Time: now, Address: t.ip.String(), Up: up})
+	if len(p.events) > recentEvents {
+		p.events = p.events[len(p.events)-recentEvents:]
+	}
+	return true
+}
+
+func (p *Prober) changed(ip net.IP, up bool) {
+	if up {
+		log.Printf("[probe] up: dst=%s", ip)
+	} else {
+		log.Printf("[probe] down: dst=%s no reply for %s", ip, p.interval*missedProbes)
+	}
+	if p.onChange != nil {
+		p.onChange(ip, up)
+	}
+}
+
+// Reply records an answer from ip received via method (artpoll or icmp).
+// A target that was down is marked up immediately.
+func (p *Prober) Reply(ip net.IP, via string) {
+	now := p.clock.Now()
+	p.mu.Lock()
+	var up bool
+	for _, t := range p.targets {
+		if !t.ip.Equal(ip) {
+			continue
+		}
+		t.lastReply, t.via = now, via
+		if via == MethodArtPoll {
+			t.lastPoll = now
+		}
+		up = p.judge(t, true, now)
+	}
+	p.mu.Unlock()
+	if up {
+		p.changed(ip, true)
+	}
+}
+
+// Statuses returns every probed target in the order they were added
+func (p *Prober) Statuses() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make([]Status, 0, len(p.targets))
+	for _, t := range p.targets {
+		result = append(result, Status{
+			Address:   t.ip.String(),
+			Method:    t.method,
+			Up:        t.up,
+			Since:     t.since,
+			LastReply: t.lastReply,
+			LastVia:   t.via,
+		})
+	}
+	return result
+}
+
+// Events returns recent up/down events, oldest first
+func (p *Prober) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make([]Event, len(p.events))
+	copy(result, p.events)
+	return result
+}
@@ -0,0 +1,133 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/remap"
+)
+
+// newSwitcher returns a switcher on a fake clock whose profiles a and b patch
+// artnet:0.0.1 to 0.0.2 and 0.0.3 respectively, with a active
+func newSwitcher(t *testing.T) (*Switcher, *clock.Fake) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	toml := `profile = "a"
+
+[[mapping]]
+from = "artnet:0.0.1"
+to = "artnet:0.0.2"
+profile = "a"
+
+[[mapping]]
+from = "artnet:0.0.1"
+to = "artnet:0.0.3"
+profile = "b"
+`
+	if err := os.WriteFile(path, []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(cfg)
+	fake := clock.NewFake(time.Unix(0, 0))
+	s.SetClock(fake)
+	return s, fake
+}
+
+func outputsByUniverse(outputs []remap.Output) map[config.Universe][512]byte {
+	result := map[config.Universe][512]byte{}
+	for _, o := range outputs {
+		result[o.Universe] = o.Data
+	}
+	return result
+}
+
+var (
+	in   = config.ArtNetUniverse(1)
+	outA = config.ArtNetUniverse(2)
+	outB = config.ArtNetUniverse(3)
+)
+
+func TestSwitchZeroesOrphans(t *testing.T) {
+	s, _ := newSwitcher(t)
+	var data [512]byte
+	data[0] = 200
+	s.Remap(in, data)
+	if got := outputsByUniverse(s.GetDirtyOutputs()); got[outA][0] != 200 {
+		t.Fatalf("outputs before switch = %v", got)
+	}
+
+	if err := s.Switch("b", 0); err != nil {
+		t.Fatal(err)
+	}
+	got := outputsByUniverse(s.GetDirtyOutputs())
+	if len(got) != 2 || got[outB][0] != 200 {
+		t.Fatalf("outputs after switch = %v", got)
+	}
+	if zero, ok := got[outA]; !ok || zero != [512]byte{} {
+		t.Fatalf("orphaned %v not zeroed", outA)
+	}
+
+	// The final zero frame is sent once
+	s.Remap(in, data)
+	if got := outputsByUniverse(s.GetDirtyOutputs()); len(got) != 1 || got[outB][0] != 200 {
+		t.Fatalf("outputs after the zero frame = %v", got)
+	}
+}
+
+func TestSwitchFadesOrphans(t *testing.T) {
+	s, fake := newSwitcher(t)
+	var data [512]byte
+	data[0] = 200
+	s.Remap(in, data)
+	s.GetDirtyOutputs()
+
+	if err := s.Switch("b", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(50 * time.Millisecond)
+	got := outputsByUniverse(s.GetDirtyOutputs())
+	if got[outA][0] != 100 || got[outB][0] != 100 {
+		t.Fatalf("halfway outputs: %v=%d %v=%d, want 100 each", outA, got[outA][0], outB, got[outB][0])
+	}
+	if !s.Status().Fading {
+		t.Fatal("not fading halfway")
+	}
+
+	fake.Advance(60 * time.Millisecond)
+	got = outputsByUniverse(s.GetDirtyOutputs())
+	if len(got) != 2 || got[outA] != [512]byte{} || got[outB][0] != 200 {
+		t.Fatalf("outputs after fade: %v=%d %v=%d", outA, got[outA][0], outB, got[outB][0])
+	}
+	if s.Status().Fading {
+		t.Fatal("still fading")
+	}
+
+	// Switching back orphans outB instead
+	if err := s.Switch("a", 0); err != nil {
+		t.Fatal(err)
+	}
+	got = outputsByUniverse(s.GetDirtyOutputs())
+	if len(got) != 2 || got[outA][0] != 200 || got[outB] != [512]byte{} {
+		t.Fatalf("outputs after switching back = %v", got)
+	}
+}
+
+func TestSwitchUnknown(t *testing.T) {
+	s, _ := newSwitcher(t)
+	if err := s.Switch("c", 0); err == nil {
+		t.Fatal("unknown profile accepted")
+	}
+	if err := s.Switch("a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetDirtyOutputs(); len(got) != 0 {
+		t.Fatalf("switch to the active profile produced %d outputs", len(got))
+	}
+}
@@ -0,0 +1,397 @@
+package profile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/remap"
+)
+
+// fadeInterval is the minimum time between frames sent while crossfading
+const fadeInterval = 20 * time.Millisecond
+
+// Status is the switcher state reported by the API
+type Status struct {
+	Active   string   `json:"active"`
+	Profiles []string `json:"profiles"`
+	Fading   bool     `json:"fading"`
+}
+
+// Switcher holds one remap engine per mapping profile. Input goes to every
+// engine so each stays current; only the active one is output, crossfading
+// from the previous profile after a switch.
+type Switcher struct {
+	names   []string
+	engines []*remap.Engine
+	masters *remap.Masters
+	clock   clock.Clock
+
+	mu        sync.Mutex
+	active    int
+	prev      *remap.Engine // engine being faded from, nil when not fading
+	fadeStart time.Time
+	fade      time.Duration
+	lastStep  time.Time
+	full      bool              // send every active frame on the next call
+	orphans   []config.Universe // universes previous profiles output and the active one does not
+}
+
+// New builds an engine for each profile in cfg, or a single engine when it has none
+func New(cfg *config.Config) *Switcher {
+	s := &Switcher{masters: remap.NewMasters(cfg.Groups), clock: clock.Real}
+	s.add(cfg, func(name string) string { return name })
+	if i := slices.Index(s.names, cfg.Profile); i >= 0 {
+		s.active = i
+	}
+	return s
+}
+
+// StandbyName returns the switcher profile of a standby config's profile
+func StandbyName(profile string) string {
+	if profile == "" {
+		return "standby"
+	}
+	return "standby:" + profile
+}
+
+// IsStandby reports whether a switcher profile belongs to a standby config
+func IsStandby(name string) bool {
+	return name == "standby" || strings.HasPrefix(name, "standby:")
+}
+
+// AddStandby builds an engine for each profile of a preloaded standby config
+// next to the running ones, named by StandbyName. Input feeds them like any
+// profile, so switching to one is a changeover with its outputs already
+// current. Its groups share the running config's masters. It must be called
+// before the switcher is in use.
+func (s *Switcher) AddStandby(cfg *config.Config) {
+	s.add(cfg, StandbyName)
+}
+
+// add builds an engine for each profile in cfg, or a single one when it has none
+func (s *Switcher) add(cfg *config.Config, name func(string) string) {
+	profiles := cfg.Profiles()
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+	for _, p := range profiles {
+		e := remap.NewEngine(cfg.NormalizeProfile(p))
+		for u, d := range cfg.MinIntervals() {
+			e.SetMinInterval(u, d)
+		}
+		for u, values := range cfg.DefaultValues() {
+			e.SetDefaults(u, values)
+		}
+		for u, d := range cfg.SamplePeriods() {
+			e.SetSampling(u, d)
+		}
+		e.SetMasters(s.masters)
+		s.names = append(s.names, name(p))
+		s.engines = append(s.engines, e)
+	}
+}
+
+// SetClock replaces the clock of the switcher and every engine, e.g. to run a
+// config offline. It must be called before the switcher is in use.
+func (s *Switcher) SetClock(c clock.Clock) {
+	s.clock = c
+	for _, e := range s.engines {
+		e.SetClock(c)
+	}
+}
+
+// Active returns the engine of the active profile
+func (s *Switcher) Active() *remap.Engine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.engines[s.active]
+}
+
+// Masters returns the grand and group masters shared by every profile
+func (s *Switcher) Masters() *remap.Masters {
+	return s.masters
+}
+
+// Status returns the active profile and all profile names
+func (s *Switcher) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Status{Active: s.names[s.active], Profiles: s.names, Fading: s.prev != nil}
+}
+
+// Switch makes the named profile active, crossfading outputs over fade
+func (s *Switcher) Switch(name string, fade time.Duration) error {
+	i := slices.Index(s.names, name)
+	if i < 0 {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i == s.active {
+		return nil
+	}
+	prev := s.engines[s.active]
+	s.active = i
+	s.orphans = orphaned(s.orphans, prev, s.engines[i])
+	if fade <= 0 {
+		s.prev = nil
+		s.full = true
+		return nil
+	}
+	s.prev = prev
+	s.fadeStart = s.clock.Now()
+	s.fade = fade
+	s.lastStep = time.Time{}
+	return nil
+}
+
+// Observe registers fn for the active profile's remapped frames. Blended frames
+// sent while fading are not observed. It must be called before the switcher is in use.
+func (s *Switcher) Observe(fn remap.Observer) {
+	for _, e := range s.engines {
+		e.Observe(func(u config.Universe, data [512]byte, changed remap.Mask) {
+			if s.Active() == e {
+				fn(u, data, changed)
+			}
+		})
+	}
+}
+
+// Remap feeds an input frame to every profile's engine
+func (s *Switcher) Remap(src config.Universe, data [512]byte) {
+	for _, e := range s.engines {
+		e.Remap(src, data)
+	}
+}
+
+// RemapFiltered feeds an input frame sent from a physical port of the console
+// (-1 for none) and delivered by cast ("" if unknown) to every profile's engine
+func (s *Switcher) RemapFiltered(src config.Universe, physical int, cast config.Cast, data [512]byte) {
+	for _, e := range s.engines {
+		e.RemapFiltered(src, physical, cast, data)
+	}
+}
+
+// orphaned returns the universes in orphans or output by prev that next does
+// not output
+func orphaned(orphans []config.Universe, prev, next *remap.Engine) []config.Universe {
+	frames := next.Frames()
+	var result []config.Universe
+	for _, u := range orphans {
+		if _, ok := frames[u]; !ok {
+			result = append(result, u)
+		}
+	}
+	for u := range prev.Frames() {
+		if _, ok := frames[u]; !ok && !slices.Contains(result, u) {
+			result = append(result, u)
+		}
+	}
+	slices.SortFunc(result, config.Universe.Compare)
+	return result
+}
+
+// Buffer holds an ArtDmx frame from a source in synchronous mode in every
+// profile's engine until Sync
+func (s *Switcher) Buffer(src config.Universe, physical uint8, cast config.Cast, data [512]byte) {
+	for _, e := range s.engines {
+		e.Buffer(src, physical, cast, data)
+	}
+}
+
+// Sync remaps the buffered frames in every profile's engine
+func (s *Switcher) Sync() {
+	for _, e := range s.engines {
+		e.Sync()
+	}
+}
+
+// GetDirtyOutputs returns the active engine's dirty outputs, every frame of the
+// active profile right after a switch, or blended frames while fading.
+// Universes only previous profiles output fade to zero with the crossfade and
+// get one final zero frame, so fixtures they fed don't hold the old look.
+func (s *Switcher) GetDirtyOutputs() []remap.Output {
+	now := s.clock.Now()
+	s.mu.Lock()
+	active, prev := s.engines[s.active], s.prev
+	step := -1.0
+	if prev != nil {
+		elapsed := now.Sub(s.fadeStart)
+		switch {
+		case elapsed >= s.fade:
+			s.prev, prev = nil, nil
+			s.full = true
+		case now.Sub(s.lastStep) >= fadeInterval:
+			s.lastStep = now
+			step = float64(elapsed) / float64(s.fade)
+		}
+	}
+	full := s.full
+	s.full = false
+	orphans := s.orphans
+	if full {
+		s.orphans = nil
+	}
+	s.mu.Unlock()
+
+	// Inactive engines are drained too, so their delays and data-loss defaults keep running
+	var result []remap.Output
+	for _, e := range s.engines {
+		outputs := e.GetDirtyOutputs()
+		if e == active {
+			result = outputs
+		}
+	}
+
+	switch {
+	case full:
+		result = result[:0]
+		for u, data := range active.Frames() {
+			result = append(result, remap.Output{Universe: u, Data: data})
+		}
+		for _, u := range orphans {
+			result = append(result, remap.Output{Universe: u})
+		}
+	case prev != nil:
+		if step < 0 {
+			return nil
+		}
+		from := prev.Frames()
+		result = result[:0]
+		for u, to := range active.Frames() {
+			result = append(result, remap.Output{Universe: u, Data: blend(from[u], to, step)})
+		}
+		for _, u := range orphans {
+			if data, ok := from[u]; ok {
+				result = append(result, remap.Output{Universe: u, Data: blend(data, [512]byte{}, step)})
+			}
+		}
+	}
+	return result
+}
+
+// blend mixes two frames, t=0 giving a and t=1 giving b
+func blend(a, b [512]byte, t float64) [512]byte {
+	var result [512]byte
+	for i := range result {
+		result[i] = byte(float64(a[i]) + (float64(b[i])-float64(a[i]))*t + 0.5)
+	}
+	return result
+}
+
+// NextDue returns the earliest time GetDirtyOutputs may return outputs without
+// new input: a switch's full frames, the next crossfade step or any engine's next due time
+func (s *Switcher) NextDue() (time.Time, bool) {
+	var next time.Time
+	ok := false
+	for _, e := range s.engines {
+		if due, pending := e.NextDue(); pending && (!ok || due.Before(next)) {
+			next, ok = due, true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due time.Time
+	switch {
+	case s.full:
+		due = s.clock.Now()
+	case s.prev != nil:
+		due = s.lastStep.Add(fadeInterval)
+		if end := s.fadeStart.Add(s.fade); end.Before(due) {
+			due = end
+		}
+	default:
+		return next, ok
+	}
+	if !ok || due.Before(next) {
+		next, ok = due, true
+	}
+	return next, ok
+}
+
+// HasDeferredOutputs reports whether outputs can become ready without new input
+func (s *Switcher) HasDeferredOutputs() bool {
+	if len(s.engines) > 1 {
+		return true
+	}
+	return s.engines[0].HasDeferredOutputs()
+}
+
+// Park parks channels in every profile that outputs the universe, so they stay
+// parked across switches
+func (s *Switcher) Park(u config.Universe, values map[int]byte) error {
+	return s.each(func(e *remap.Engine) error { return e.Park(u, values) })
+}
+
+// Release unparks channels in every profile that outputs the universe
+func (s *Switcher) Release(u config.Universe, channels []int) error {
+	return s.each(func(e *remap.Engine) error { return e.Release(u, channels) })
+}
+
+// Parked lists the parked channels of every profile, sorted by universe and channel
+func (s *Switcher) Parked() []remap.ParkedChannel {
+	var result []remap.ParkedChannel
+	for _, e := range s.engines {
+		for _, p := range e.Parked() {
+			if !slices.ContainsFunc(result, func(q remap.ParkedChannel) bool {
+				return q.Universe == p.Universe && q.Channel == p.Channel
+			}) {
+				result = append(result, p)
+			}
+		}
+	}
+	slices.SortFunc(result, func(a, b remap.ParkedChannel) int {
+		if c := a.Universe.Compare(b.Universe); c != 0 {
+			return c
+		}
+		return a.Channel - b.Channel
+	})
+	return result
+}
+
+// each calls fn on every engine, failing only if it fails on all of them
+func (s *Switcher) each(fn func(e *remap.Engine) error) error {
+	var err error
+	ok := false
+	for _, e := range s.engines {
+		if e2 := fn(e); e2 != nil {
+			err = e2
+		} else {
+			ok = true
+		}
+	}
+	if ok {
+		return nil
+	}
+	return err
+}
+
+// SourceUniverses returns the sorted source universes of proto across all profiles
+func (s *Switcher) SourceUniverses(proto config.Protocol) []config.Universe {
+	return s.union(func(e *remap.Engine) []config.Universe { return e.SourceUniverses(proto) })
+}
+
+// DestUniverses returns the sorted destination universes of proto across all profiles
+func (s *Switcher) DestUniverses(proto config.Protocol) []config.Universe {
+	return s.union(func(e *remap.Engine) []config.Universe { return e.DestUniverses(proto) })
+}
+
+func (s *Switcher) union(fn func(*remap.Engine) []config.Universe) []config.Universe {
+	var result []config.Universe
+	for _, e := range s.engines {
+		for _, u := range fn(e) {
+			if !slices.Contains(result, u) {
+				result = append(result, u)
+			}
+		}
+	}
+	slices.SortFunc(result, config.Universe.Compare)
+	return result
+}
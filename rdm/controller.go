@@ -0,0 +1,209 @@
+package rdm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artnet"
+)
+
+const getTimeout = time.Second
+
+// Device is one responder found by discovery, with what GET requests returned
+type Device struct {
+	UID               string `json:"uid"`
+	Node              string `json:"node"`
+	ManufacturerID    uint16 `json:"manufacturer_id"`
+	ManufacturerLabel string `json:"manufacturer_label,omitempty"`
+	ModelID           uint16 `json:"model_id"`
+	ModelDescription  string `json:"model_description,omitempty"`
+	Label             string `json:"label,omitempty"`
+	Category          uint16 `json:"category"`
+	SoftwareVersion   uint32 `json:"software_version"`
+	Footprint         uint16 `json:"footprint"`
+	StartAddress      uint16 `json:"start_address"`
+	Error             string `json:"error,omitempty"`
+}
+
+// Controller drives ArtTodControl/ArtTodRequest discovery and ArtRdm GETs
+// through send, with replies delivered to Handle
+type Controller struct {
+	send func(data []byte, dst *net.UDPAddr) error
+	uid  UID
+
+	run sync.Mutex // one discovery at a time
+
+	mu       sync.Mutex
+	universe artnet.Universe
+	tods     map[string]map[UID]bool // node address -> UIDs, nil when not collecting
+	addrs    map[string]*net.UDPAddr
+	tn       byte
+	pending  map[byte]chan Response
+}
+
+func NewController(send func(data []byte, dst *net.UDPAddr) error) *Controller {
+	c := &Controller{
+		send:    send,
+		pending: map[byte]chan Response{},
+	}
+	// 0x7FF0-0x7FF7 are the ESTA manufacturer ids reserved for prototyping
+	binary.BigEndian.PutUint16(c.uid[:], 0x7FF0)
+	rand.Read(c.uid[2:])
+	return c
+}
+
+// Handle takes an incoming ArtTodData or ArtRdm packet
+func (c *Controller) Handle(src *net.UDPAddr, opCode uint16, data []byte) {
+	switch opCode {
+	case artnet.OpTodData:
+		u, uids, err := ParseTodData(data)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		if c.tods != nil && u == c.universe {
+			key := src.String()
+			if c.tods[key] == nil {
+				c.tods[key] = map[UID]bool{}
+				c.addrs[key] = src
+			}
+			for _, uid := range uids {
+				c.tods[key][uid] = true
+			}
+		}
+		c.mu.Unlock()
+
+	case artnet.OpRdm:
+		_, resp, err := ParseArtRdm(data)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[resp.TN]
+		c.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- resp:
+			default:
+			}
+		}
+	}
+}
+
+// Discover flushes and collects the device tables of nodes on universe for
+// wait, then reads device info from every responder found
+func (c *Controller) Discover(u artnet.Universe, nodes []*net.UDPAddr, wait time.Duration) ([]Device, error) {
+	c.run.Lock()
+	defer c.run.Unlock()
+
+	c.mu.Lock()
+	c.universe = u
+	c.tods = map[string]map[UID]bool{}
+	c.addrs = map[string]*net.UDPAddr{}
+	c.mu.Unlock()
+
+	for _, node := range nodes {
+		if err := c.send(BuildTodFlush(u), node); err != nil {
+			return nil, err
+		}
+	}
+	// Nodes that ignore the flush still answer a request with their current table
+	time.Sleep(wait / 2)
+	for _, node := range nodes {
+		c.send(BuildTodRequest(u), node)
+	}
+	time.Sleep(wait / 2)
+
+	c.mu.Lock()
+	tods, addrs := c.tods, c.addrs
+	c.tods, c.addrs = nil, nil
+	c.mu.Unlock()
+
+	var devices []Device
+	for key, uids := range tods {
+		node := addrs[key]
+		for uid := range uids {
+			devices = append(devices, c.describe(u, node, uid))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Node != devices[j].Node {
+			return devices[i].Node < devices[j].Node
+		}
+		return devices[i].UID < devices[j].UID
+	})
+	return devices, nil
+}
+
+func (c *Controller) describe(u artnet.Universe, node *net.UDPAddr, uid UID) Device {
+	d := Device{UID: uid.String(), Node: node.IP.String(), ManufacturerID: uid.Manufacturer()}
+
+	info, err := c.get(u, node, uid, PIDDeviceInfo)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	if len(info) >= 19 {
+		d.ModelID = binary.BigEndian.Uint16(info[2:])
+		d.Category = binary.BigEndian.Uint16(info[4:])
+		d.SoftwareVersion = binary.BigEndian.Uint32(info[6:])
+		d.Footprint = binary.BigEndian.Uint16(info[10:])
+		d.StartAddress = binary.BigEndian.Uint16(info[14:])
+	}
+	// Labels are optional PIDs; a NACK just leaves them empty
+	if label, err := c.get(u, node, uid, PIDManufacturerLabel); err == nil {
+		d.ManufacturerLabel = cleanLabel(label)
+	}
+	if label, err := c.get(u, node, uid, PIDDeviceModelDescription); err == nil {
+		d.ModelDescription = cleanLabel(label)
+	}
+	if label, err := c.get(u, node, uid, PIDDeviceLabel); err == nil {
+		d.Label = cleanLabel(label)
+	}
+	return d
+}
+
+// get sends one GET and waits for the matching response's parameter data
+func (c *Controller) get(u artnet.Universe, node *net.UDPAddr, uid UID, pid uint16) ([]byte, error) {
+	ch := make(chan Response, 1)
+	c.mu.Lock()
+	c.tn++
+	tn := c.tn
+	c.pending[tn] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, tn)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(BuildArtRdm(u, BuildGet(uid, c.uid, tn, pid)), node); err != nil {
+		return nil, err
+	}
+
+	timeout := time.After(getTimeout)
+	for {
+		select {
+		case resp := <-ch:
+			if resp.Src != uid || resp.PID != pid {
+				continue
+			}
+			if resp.ResponseType != ResponseAck {
+				return nil, fmt.Errorf("pid 0x%04X response type %d", pid, resp.ResponseType)
+			}
+			return resp.Data, nil
+		case <-timeout:
+			return nil, fmt.Errorf("pid 0x%04X timed out", pid)
+		}
+	}
+}
+
+func cleanLabel(b []byte) string {
+	return strings.TrimRight(string(b), "\x00 ")
+}
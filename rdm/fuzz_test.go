@@ -0,0 +1,60 @@
+package rdm
+
+import (
+	"testing"
+
+	"github.com/gopatchy/artnet"
+)
+
+func FuzzParseTodData(f *testing.F) {
+	tod := artHeader(artnet.OpTodData, 28+12)
+	tod[21] = 1
+	tod[23] = 0x23
+	tod[27] = 2
+	f.Add(tod)
+	f.Add(tod[:27])
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		u, uids, err := ParseTodData(data)
+		if err != nil {
+			return
+		}
+		if u > 0x7FFF {
+			t.Fatalf("universe %d out of range", u)
+		}
+		if len(uids) != int(data[27]) {
+			t.Fatalf("got %d uids, header says %d", len(uids), data[27])
+		}
+	})
+}
+
+func FuzzParseResponse(f *testing.F) {
+	src := UID{0x12, 0x34, 0, 0, 0, 1}
+	resp := BuildGet(UID{0x7F, 0xF0, 1, 2, 3, 4}, src, 7, PIDDeviceLabel)
+	resp[20] = ccGetResp
+	resp[24], resp[25] = 0, 0
+	sum := checksum(resp[:24])
+	resp[24], resp[25] = byte(sum>>8), byte(sum)
+	f.Add(resp)
+	f.Add(resp[:25])
+	f.Add([]byte{startCode, subStartCode})
+
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		r, err := ParseResponse(packet)
+		if err != nil {
+			return
+		}
+		if len(r.Data) != int(packet[23]) {
+			t.Fatalf("data length %d, header says %d", len(r.Data), packet[23])
+		}
+		wrapped := BuildArtRdm(0x123, packet)
+		u, r2, err := ParseArtRdm(wrapped)
+		if err != nil {
+			t.Fatalf("ArtRdm round trip failed: %v", err)
+		}
+		if u != 0x123 || r2.TN != r.TN || r2.PID != r.PID || r2.Src != r.Src {
+			t.Fatalf("ArtRdm round trip mismatch: %+v != %+v", r2, r)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package rdm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gopatchy/artnet"
+)
+
+const (
+	OpTodRequest uint16 = 0x8000
+
+	todFull  = 0x00
+	atcFlush = 0x01
+
+	startCode    = 0xCC
+	subStartCode = 0x01
+	ccGet        = 0x20
+	ccGetResp    = 0x21
+
+	ResponseAck         = 0x00
+	ResponseAckTimer    = 0x01
+	ResponseNack        = 0x02
+	ResponseAckOverflow = 0x03
+
+	PIDDeviceInfo             uint16 = 0x0060
+	PIDDeviceModelDescription uint16 = 0x0080
+	PIDManufacturerLabel      uint16 = 0x0081
+	PIDDeviceLabel            uint16 = 0x0082
+
+	// artHeaderLen covers ID, OpCode, ProtVer, two fillers and seven spares, up to the Net field
+	artHeaderLen = 21
+)
+
+var errShort = errors.New("packet too short")
+
+// UID is an RDM unique id: 16-bit manufacturer id then 32-bit device id
+type UID [6]byte
+
+func (u UID) Manufacturer() uint16 {
+	return binary.BigEndian.Uint16(u[:2])
+}
+
+func (u UID) String() string {
+	return fmt.Sprintf("%04X:%08X", u.Manufacturer(), binary.BigEndian.Uint32(u[2:]))
+}
+
+func artHeader(op uint16, size int) []byte {
+	buf := make([]byte, size)
+	copy(buf, artnet.ID[:])
+	binary.LittleEndian.PutUint16(buf[8:], op)
+	binary.BigEndian.PutUint16(buf[10:], artnet.ProtocolVersion)
+	return buf
+}
+
+// BuildTodRequest asks nodes for their table of devices on universe
+func BuildTodRequest(u artnet.Universe) []byte {
+	buf := artHeader(OpTodRequest, artHeaderLen+4)
+	buf[21] = u.Net()
+	buf[22] = todFull
+	buf[23] = 1
+	buf[24] = byte(u)
+	return buf
+}
+
+// BuildTodFlush makes nodes rerun full RDM discovery on universe and resend their table
+func BuildTodFlush(u artnet.Universe) []byte {
+	buf := artHeader(artnet.OpTodControl, artHeaderLen+3)
+	buf[21] = u.Net()
+	buf[22] = atcFlush
+	buf[23] = byte(u)
+	return buf
+}
+
+// ParseTodData returns the universe and UIDs in one ArtTodData block
+func ParseTodData(data []byte) (artnet.Universe, []UID, error) {
+	if len(data) < 28 {
+		return 0, nil, errShort
+	}
+	u := artnet.Universe(uint16(data[21]&0x7F)<<8 | uint16(data[23]))
+	count := int(data[27])
+	if len(data) < 28+6*count {
+		return 0, nil, errShort
+	}
+	uids := make([]UID, count)
+	for i := range uids {
+		copy(uids[i][:], data[28+6*i:])
+	}
+	return u, uids, nil
+}
+
+// BuildGet builds an RDM GET request for pid, including start code and checksum
+func BuildGet(dst, src UID, tn byte, pid uint16) []byte {
+	buf := make([]byte, 24+2)
+	buf[0] = startCode
+	buf[1] = subStartCode
+	buf[2] = 24
+	copy(buf[3:], dst[:])
+	copy(buf[9:], src[:])
+	buf[15] = tn
+	buf[16] = 1 // port id
+	buf[20] = ccGet
+	binary.BigEndian.PutUint16(buf[21:], pid)
+	binary.BigEndian.PutUint16(buf[24:], checksum(buf[:24]))
+	return buf
+}
+
+func checksum(data []byte) uint16 {
+	var sum uint16
+	for _, b := range data {
+		sum += uint16(b)
+	}
+	return sum
+}
+
+// BuildArtRdm wraps an RDM packet for universe; Art-Net carries it without the start code
+func BuildArtRdm(u artnet.Universe, packet []byte) []byte {
+	buf := artHeader(artnet.OpRdm, artHeaderLen+3+len(packet)-1)
+	buf[12] = 1 // RDM version
+	buf[21] = u.Net()
+	buf[23] = byte(u)
+	copy(buf[24:], packet[1:])
+	return buf
+}
+
+// Response is a parsed RDM response message
+type Response struct {
+	Src          UID
+	TN           byte
+	ResponseType byte
+	PID          uint16
+	Data         []byte
+}
+
+// ParseArtRdm extracts and validates the RDM response carried in an ArtRdm packet
+func ParseArtRdm(data []byte) (artnet.Universe, Response, error) {
+	if len(data) < 24 {
+		return 0, Response{}, errShort
+	}
+	u := artnet.Universe(uint16(data[21]&0x7F)<<8 | uint16(data[23]))
+	packet := append([]byte{startCode}, data[24:]...)
+	resp, err := ParseResponse(packet)
+	return u, resp, err
+}
+
+// ParseResponse validates an RDM GET response, including start code and checksum
+func ParseResponse(packet []byte) (Response, error) {
+	if len(packet) < 26 {
+		return Response{}, errShort
+	}
+	if packet[0] != startCode || packet[1] != subStartCode {
+		return Response{}, errors.New("not an RDM packet")
+	}
+	length := int(packet[2])
+	if length < 24 || len(packet) < length+2 {
+		return Response{}, errShort
+	}
+	pdl := int(packet[23])
+	if 24+pdl != length {
+		return Response{}, errors.New("bad parameter data length")
+	}
+	if binary.BigEndian.Uint16(packet[length:]) != checksum(packet[:length]) {
+		return Response{}, errors.New("bad checksum")
+	}
+	if packet[20] != ccGetResp {
+		return Response{}, fmt.Errorf("unexpected command class 0x%02X", packet[20])
+	}
+	var resp Response
+	copy(resp.Src[:], packet[9:15])
+	resp.TN = packet[15]
+	resp.ResponseType = packet[16]
+	resp.PID = binary.BigEndian.Uint16(packet[21:])
+	resp.Data = packet[24:length]
+	return resp, nil
+}
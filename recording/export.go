@@ -0,0 +1,108 @@
+package recording
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gopatchy/artmap/capture"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/espnet"
+	"github.com/gopatchy/artnet"
+	"github.com/gopatchy/sacn"
+)
+
+// ExportCSV writes one row per frame: time, direction, universe, source and
+// channels 1-512 (missing slots are empty)
+func ExportCSV(r *Reader, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := make([]string, 4+512)
+	row[0], row[1], row[2], row[3] = "time", "direction", "universe", "source"
+	for i := 0; i < 512; i++ {
+		row[4+i] = strconv.Itoa(i + 1)
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		row[0] = f.Time.UTC().Format(time.RFC3339Nano)
+		row[1] = string(f.Direction)
+		row[2] = f.Universe.String()
+		row[3] = ""
+		if f.Source != nil {
+			row[3] = f.Source.String()
+		}
+		for i := 0; i < 512; i++ {
+			row[4+i] = ""
+			if i < len(f.Data) {
+				row[4+i] = strconv.Itoa(int(f.Data[i]))
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportPcapng writes each frame as the protocol packet it corresponds to, in
+// synthesized IPv4/UDP headers: inputs from their recorded source, outputs from
+// 0.0.0.0, to the protocol's broadcast or multicast destination
+func ExportPcapng(r *Reader, w io.Writer) error {
+	pw, err := capture.NewPcapngWriter(w)
+	if err != nil {
+		return err
+	}
+
+	seqs := map[config.Universe]uint8{}
+	var cid [16]byte
+	copy(cid[:], "artmap-export")
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		seq := seqs[f.Universe] + 1
+		if seq == 0 {
+			seq = 1
+		}
+		seqs[f.Universe] = seq
+
+		var payload []byte
+		var dst *net.UDPAddr
+		switch f.Universe.Protocol {
+		case config.ProtocolArtNet:
+			payload = artnet.BuildDMXPacket(f.Universe.ArtNet(), seq, f.Data)
+			dst = &net.UDPAddr{IP: net.IPv4bcast, Port: artnet.Port}
+		case config.ProtocolSACN:
+			payload = sacn.BuildDataPacket(f.Universe.Number, seq, "artmap", cid, f.Data)
+			dst = sacn.MulticastAddr(f.Universe.Number)
+		case config.ProtocolESPNet:
+			payload = espnet.BuildDataPacket(uint8(f.Universe.Number), f.Data)
+			dst = &net.UDPAddr{IP: net.IPv4bcast, Port: espnet.Port}
+		default:
+			return fmt.Errorf("unknown protocol %q", f.Universe.Protocol)
+		}
+
+		src := &net.UDPAddr{IP: f.Source, Port: dst.Port}
+		if err := pw.WritePacket(f.Time, src, dst, payload); err != nil {
+			return err
+		}
+	}
+}
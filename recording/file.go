@@ -0,0 +1,79 @@
+package recording
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/monitor"
+)
+
+// File appends frames to a recording on disk; a nil File records nothing
+type File struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *Writer
+	buf     *bufio.Writer
+	flushed time.Time
+}
+
+func Create(path string) (*File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	w, err := NewWriter(buf)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &File{file: f, w: w, buf: buf}, nil
+}
+
+// Record appends one frame; write errors stop the recording
+func (f *File) Record(dir monitor.Direction, u config.Universe, src net.IP, data [512]byte) {
+	// Serial outputs have no network form to record or export
+	if f == nil || u.Protocol == config.ProtocolUART {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return
+	}
+	now := time.Now()
+	if err := f.w.WriteFrame(Frame{Time: now, Direction: dir, Universe: u, Source: src, Data: data[:]}); err != nil {
+		f.closeLocked()
+		return
+	}
+	if now.Sub(f.flushed) >= time.Second {
+		f.buf.Flush()
+		f.flushed = now
+	}
+}
+
+func (f *File) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeLocked()
+}
+
+func (f *File) closeLocked() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.buf.Flush()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	f.file = nil
+	return err
+}
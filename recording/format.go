@@ -0,0 +1,163 @@
+// Package recording defines the artmap DMX recording container.
+//
+// A recording is a file header followed by frame records. All integers are big-endian.
+//
+//	File header (16 bytes):
+//	  magic     [8]byte  "ARTMAPRC"
+//	  version   uint16   1
+//	  reserved  [6]byte  zero
+//
+//	Frame record (20-byte header + data):
+//	  time      int64    unix nanoseconds
+//	  direction uint8    0 = input, 1 = output
+//	  protocol  uint8    0 = artnet, 1 = sacn, 2 = espnet
+//	  universe  uint16   15-bit ArtNet port-address, sACN or ESP Net universe number
+//	  source    [4]byte  IPv4 source of an input frame, zero for outputs
+//	  length    uint16   number of DMX slots that follow (0-512)
+//	  reserved  [2]byte  zero
+//	  data      [length]byte
+//
+// Readers must reject files with an unknown version. Unknown protocol or
+// direction values should be skipped, not treated as fatal.
+package recording
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/monitor"
+)
+
+const (
+	Version = 1
+
+	fileHeaderLen  = 16
+	frameHeaderLen = 20
+)
+
+var magic = [8]byte{'A', 'R', 'T', 'M', 'A', 'P', 'R', 'C'}
+
+var (
+	ErrBadMagic   = errors.New("not an artmap recording")
+	ErrBadVersion = errors.New("unsupported recording version")
+)
+
+var protocols = []config.Protocol{config.ProtocolArtNet, config.ProtocolSACN, config.ProtocolESPNet}
+
+// Frame is one recorded DMX frame
+type Frame struct {
+	Time      time.Time
+	Direction monitor.Direction
+	Universe  config.Universe
+	Source    net.IP
+	Data      []byte
+}
+
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter writes the file header and returns a writer for frames
+func NewWriter(w io.Writer) (*Writer, error) {
+	hdr := make([]byte, fileHeaderLen)
+	copy(hdr, magic[:])
+	binary.BigEndian.PutUint16(hdr[8:], Version)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+func (w *Writer) WriteFrame(f Frame) error {
+	_, err := w.w.Write(encodeFrame(f))
+	return err
+}
+
+func encodeFrame(f Frame) []byte {
+	data := f.Data
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	buf := make([]byte, frameHeaderLen+len(data))
+	binary.BigEndian.PutUint64(buf[0:], uint64(f.Time.UnixNano()))
+	if f.Direction == monitor.Output {
+		buf[8] = 1
+	}
+	for i, p := range protocols {
+		if p == f.Universe.Protocol {
+			buf[9] = byte(i)
+		}
+	}
+	binary.BigEndian.PutUint16(buf[10:], f.Universe.Number)
+	if ip4 := f.Source.To4(); ip4 != nil {
+		copy(buf[12:16], ip4)
+	}
+	binary.BigEndian.PutUint16(buf[16:], uint16(len(data)))
+	copy(buf[frameHeaderLen:], data)
+	return buf
+}
+
+type Reader struct {
+	r   io.Reader
+	hdr [frameHeaderLen]byte
+}
+
+// NewReader validates the file header and returns a reader for frames
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [fileHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if [8]byte(hdr[:8]) != magic {
+		return nil, ErrBadMagic
+	}
+	if v := binary.BigEndian.Uint16(hdr[8:]); v != Version {
+		return nil, fmt.Errorf("%w: %d", ErrBadVersion, v)
+	}
+	return &Reader{r: r}, nil
+}
+
+// Next returns the next frame, or io.EOF at the end of the recording
+func (r *Reader) Next() (Frame, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Frame{}, fmt.Errorf("truncated frame header")
+			}
+			return Frame{}, err
+		}
+		length := int(binary.BigEndian.Uint16(r.hdr[16:]))
+		if length > 512 {
+			return Frame{}, fmt.Errorf("frame length %d exceeds 512", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			return Frame{}, fmt.Errorf("truncated frame data")
+		}
+
+		dir, proto := r.hdr[8], int(r.hdr[9])
+		if dir > 1 || proto >= len(protocols) {
+			continue
+		}
+		f := Frame{
+			Time:      time.Unix(0, int64(binary.BigEndian.Uint64(r.hdr[0:]))),
+			Direction: monitor.Input,
+			Universe:  config.Universe{Protocol: protocols[proto], Number: binary.BigEndian.Uint16(r.hdr[10:])},
+			Data:      data,
+		}
+		if dir == 1 {
+			f.Direction = monitor.Output
+		} else {
+			f.Source = net.IPv4(r.hdr[12], r.hdr[13], r.hdr[14], r.hdr[15])
+		}
+		return f, nil
+	}
+}
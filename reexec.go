@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"syscall"
+)
+
+// canReexec reports whether reexec can restart the process in place
+const canReexec = true
+
+// reexec replaces the process with a fresh copy of itself, which loads the config again
+func reexec() {
+	exe, err := os.Executable()
+	if err == nil {
+		log.Printf("[main] restarting")
+		err = syscall.Exec(exe, os.Args, os.Environ())
+	}
+	log.Fatalf("[main] restart error: %v", err)
+}
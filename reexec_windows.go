@@ -0,0 +1,12 @@
+package main
+
+import "log"
+
+// canReexec reports whether reexec can restart the process in place; Windows
+// has no exec, so Reload is refused there
+const canReexec = false
+
+// reexec is never reached on Windows, as Reload is refused before shutting down
+func reexec() {
+	log.Fatalf("[main] restart error: not supported on windows")
+}
@@ -0,0 +1,93 @@
+package remap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// delayedFrame is a source frame waiting to be applied through a plan
+type delayedFrame struct {
+	due  time.Time
+	plan *outputPlan
+	data [512]byte
+}
+
+// delayQueue is a FIFO of frames sharing one delay, so due times are monotonic
+type delayQueue struct {
+	delay  time.Duration
+	mu     sync.Mutex
+	frames []delayedFrame
+}
+
+func (q *delayQueue) push(now time.Time, p *outputPlan, data [512]byte) {
+	q.mu.Lock()
+	q.frames = append(q.frames, delayedFrame{due: now.Add(q.delay), plan: p, data: data})
+	q.mu.Unlock()
+}
+
+func (q *delayQueue) popDue(now time.Time) []delayedFrame {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for n < len(q.frames) && !q.frames[n].due.After(now) {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	due := make([]delayedFrame, n)
+	copy(due, q.frames[:n])
+	q.frames = append(q.frames[:0], q.frames[n:]...)
+	return due
+}
+
+// next returns when the oldest queued frame comes due
+func (q *delayQueue) next() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.frames) == 0 {
+		return time.Time{}, false
+	}
+	return q.frames[0].due, true
+}
+
+// delayedPlan is an output plan whose frames wait in a delay queue before being applied
+type delayedPlan struct {
+	queue *delayQueue
+	plan  outputPlan
+}
+
+// compileDelayed builds one plan per delay and output buffer, keeping mapping order
+func (e *Engine) compileDelayed(mappings []config.NormalizedMapping) []delayedPlan {
+	byDelay := map[time.Duration][]config.NormalizedMapping{}
+	var order []time.Duration
+	for _, m := range mappings {
+		if _, ok := byDelay[m.Delay]; !ok {
+			order = append(order, m.Delay)
+		}
+		byDelay[m.Delay] = append(byDelay[m.Delay], m)
+	}
+
+	var result []delayedPlan
+	for _, d := range order {
+		for _, p := range compilePlan(byDelay[d], e.outputs, e.usage) {
+			result = append(result, delayedPlan{queue: e.delays[d], plan: p})
+		}
+	}
+	return result
+}
+
+func (e *Engine) flushDelayed(now time.Time) {
+	obs := e.observation()
+	for _, q := range e.delays {
+		for _, f := range q.popDue(now) {
+			f.plan.apply(&f.data, now, obs)
+			if obs != nil {
+				e.notify(f.plan.universe, obs)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package remap
+
+import "time"
+
+// DueTimer flushes outputs deferred by delays, rate limits, sampling holds and
+// data-loss timeouts when they come due, for senders that otherwise only send on input
+type DueTimer struct {
+	wake chan struct{}
+}
+
+// NewDueTimer calls flush at each time next reports, until done is closed
+func NewDueTimer(next func() (time.Time, bool), flush func(), done <-chan struct{}) *DueTimer {
+	t := &DueTimer{wake: make(chan struct{}, 1)}
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				flush()
+			case <-t.wake:
+			}
+			if due, ok := next(); ok {
+				timer.Reset(time.Until(due))
+			} else {
+				timer.Stop()
+			}
+		}
+	}()
+	return t
+}
+
+// Wake makes the timer look again for the next due time, after input or an API
+// change may have deferred a new output. It is a no-op on a nil DueTimer.
+func (t *DueTimer) Wake() {
+	if t == nil {
+		return
+	}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
@@ -1,9 +1,13 @@
 package remap
 
 import (
+	"log"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/gopatchy/artmap/clock"
 	"github.com/gopatchy/artmap/config"
 )
 
@@ -13,150 +17,645 @@ type Output struct {
 	Data     [512]byte
 }
 
-// sourceEntry holds mappings and stats for a source universe
+// sourceEntry holds the compiled mappings and stats for a source universe
 type sourceEntry struct {
 	mappings []config.NormalizedMapping
+	direct   bool
+	blocks   []*blockEntry
+	plans    []outputPlan
+	delayed  []delayedPlan
+	users    []*mappingUsage
 	counter  atomic.Uint64
+
+	sample    time.Duration
+	lastInput atomic.Int64 // unix nanoseconds, 0 before the first frame
+}
+
+// filterKey is a source universe as sent from one physical port of a console
+// (anyPhysical for any port) and delivered by one cast ("" for any)
+type filterKey struct {
+	u        config.Universe
+	physical int
+	cast     config.Cast
+}
+
+// anyPhysical is the port of a filterKey that matches frames from every port
+const anyPhysical = -1
+
+// key returns the filterKey of the entry holding mapping m
+func key(m config.NormalizedMapping) filterKey {
+	k := filterKey{m.From, anyPhysical, m.Cast}
+	if m.Physical != nil {
+		k.physical = int(*m.Physical)
+	}
+	return k
 }
 
+// blockEntry holds a mapping that applies to a span of consecutive source universes
+type blockEntry struct {
+	mapping config.NormalizedMapping
+	counter atomic.Uint64
+}
+
+// DataLossTimeout is how long an output universe may go without input before defaults are restored
+const DataLossTimeout = 2500 * time.Millisecond
+
 // universeBuffer holds per-output-universe state with its own lock
 type universeBuffer struct {
-	mu    sync.Mutex
-	data  [512]byte
-	dirty bool
+	mu          sync.Mutex
+	data        [512]byte
+	dirty       bool
+	minInterval time.Duration
+	lastSent    time.Time
+	defaults    map[int]byte
+	live        bool
+	lastInput   time.Time
+	parked      map[int]byte
+	holdUntil   time.Time
+	masters     *Masters
+	groups      *[512]uint64      // level groups of each channel, nil when none are grouped
+	sources     []config.Universe // universes mapped into this one; written under Engine.mu
+}
+
+// frame returns the buffer data with group masters and then parked channels applied
+func (buf *universeBuffer) frame() [512]byte {
+	data := buf.data
+	if buf.groups != nil {
+		buf.masters.apply(&data, buf.groups)
+	}
+	for ch, v := range buf.parked {
+		data[ch] = v
+	}
+	return data
+}
+
+// hold keeps the buffer from being output before until
+func (buf *universeBuffer) hold(until time.Time) {
+	buf.mu.Lock()
+	if until.After(buf.holdUntil) {
+		buf.holdUntil = until
+	}
+	buf.mu.Unlock()
+}
+
+// group marks count channels from ch (0-indexed) as members of the group at bit
+func (buf *universeBuffer) group(masters *Masters, ch, count int, bit uint) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if buf.groups == nil {
+		buf.groups = &[512]uint64{}
+	}
+	buf.masters = masters
+	for i := ch; i < ch+count; i++ {
+		buf.groups[i] |= 1 << bit
+	}
+	buf.dirty = true
+}
+
+func (buf *universeBuffer) applyDefaults() {
+	for ch, v := range buf.defaults {
+		buf.data[ch] = v
+	}
+	buf.dirty = true
 }
 
 // Engine handles DMX channel remapping
 type Engine struct {
-	mappings []config.NormalizedMapping
+	mappings  []config.NormalizedMapping
+	wildcards []config.NormalizedMapping
+	blocks    []*blockEntry
+	delays    map[time.Duration]*delayQueue
+	deferred  bool
+	clock     clock.Clock
+	usage     []mappingUsage
+	observers []Observer
+	masters   *Masters
+	synced    syncBuffer
+
+	// mu guards bySource, outputs and resolved, which grow as wildcard sources arrive
+	mu       sync.RWMutex
 	bySource map[config.Universe]*sourceEntry
+	// byFilter holds mappings limited to one ArtDmx physical port or one cast; it never grows
+	byFilter map[filterKey]*sourceEntry
 	outputs  map[config.Universe]*universeBuffer
+	resolved []config.NormalizedMapping
+
+	// limits and defaults of universes that only wildcards may output
+	limits   map[config.Universe]time.Duration
+	defaults map[config.Universe]map[int]byte
+	sampling map[config.Universe]time.Duration
 }
 
 // NewEngine creates a new remapping engine
 func NewEngine(mappings []config.NormalizedMapping) *Engine {
 	bySource := map[config.Universe]*sourceEntry{}
-	for _, m := range mappings {
-		entry := bySource[m.From]
+	entryFor := func(u config.Universe) *sourceEntry {
+		entry := bySource[u]
 		if entry == nil {
 			entry = &sourceEntry{}
-			bySource[m.From] = entry
+			bySource[u] = entry
+		}
+		return entry
+	}
+	byFilter := map[filterKey]*sourceEntry{}
+
+	// Direct mappings apply before block mappings for the same source universe
+	var static, wildcards []config.NormalizedMapping
+	var blocks []*blockEntry
+	for _, m := range mappings {
+		if m.Wildcard {
+			wildcards = append(wildcards, m)
+			continue
+		}
+		static = append(static, m)
+		if m.Physical != nil || m.Cast != "" {
+			// The universe still gets an entry, so it never reaches the wildcards
+			entryFor(m.From)
+			k := key(m)
+			if byFilter[k] == nil {
+				byFilter[k] = &sourceEntry{}
+			}
+			byFilter[k].direct = true
+			byFilter[k].mappings = append(byFilter[k].mappings, m)
+			continue
 		}
+		if m.Span > 1 {
+			blocks = append(blocks, &blockEntry{mapping: m})
+			continue
+		}
+		entry := entryFor(m.From)
+		entry.direct = true
 		entry.mappings = append(entry.mappings, m)
 	}
+	for _, b := range blocks {
+		for _, m := range b.mapping.Expand() {
+			entry := entryFor(m.From)
+			entry.blocks = append(entry.blocks, b)
+			entry.mappings = append(entry.mappings, m)
+		}
+	}
 
 	outputs := map[config.Universe]*universeBuffer{}
+	for _, m := range expand(static) {
+		buf := outputs[m.To]
+		if buf == nil {
+			buf = &universeBuffer{}
+			outputs[m.To] = buf
+		}
+		if !slices.Contains(buf.sources, m.From) {
+			buf.sources = append(buf.sources, m.From)
+		}
+	}
+
+	delays := map[time.Duration]*delayQueue{}
 	for _, m := range mappings {
-		if _, ok := outputs[m.To]; !ok {
-			outputs[m.To] = &universeBuffer{}
+		if m.Delay > 0 && delays[m.Delay] == nil {
+			delays[m.Delay] = &delayQueue{delay: m.Delay}
+		}
+	}
+
+	e := &Engine{
+		mappings:  static,
+		wildcards: wildcards,
+		bySource:  bySource,
+		byFilter:  byFilter,
+		blocks:    blocks,
+		outputs:   outputs,
+		delays:    delays,
+		clock:     clock.Real,
+		usage:     newUsage(mappings),
+		limits:    map[config.Universe]time.Duration{},
+		defaults:  map[config.Universe]map[int]byte{},
+		sampling:  map[config.Universe]time.Duration{},
+	}
+	for _, entry := range bySource {
+		e.compile(entry)
+	}
+	for _, entry := range byFilter {
+		e.compile(entry)
+	}
+	return e
+}
+
+// compile builds the copy plans of a source entry from its mappings
+func (e *Engine) compile(entry *sourceEntry) {
+	entry.users = e.usersOf(entry.mappings)
+	var immediate, delayed []config.NormalizedMapping
+	for _, m := range entry.mappings {
+		if m.Delay > 0 {
+			delayed = append(delayed, m)
+		} else {
+			immediate = append(immediate, m)
+		}
+	}
+	entry.plans = compilePlan(immediate, e.outputs, e.usage)
+	entry.delayed = e.compileDelayed(delayed)
+	if e.masters != nil {
+		e.group(entry)
+	}
+}
+
+// group marks the output channels written by the entry's grouped mappings
+func (e *Engine) group(entry *sourceEntry) {
+	for _, m := range entry.mappings {
+		if m.Group == "" {
+			continue
 		}
+		i, ok := e.masters.index(m.Group)
+		step, fits := compileStep(m)
+		if !ok || !fits {
+			continue
+		}
+		count := step.count
+		if step.transform != nil {
+			count = step.transform.OutputCount()
+		}
+		e.outputs[m.To].group(e.masters, step.to, count, uint(i))
+	}
+}
+
+// resolve applies the wildcard mappings to a source universe seen for the first
+// time. Its entry is stored even if none apply, so each universe resolves once.
+// Universes with mappings of their own never reach the wildcards.
+func (e *Engine) resolve(src config.Universe) *sourceEntry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if entry := e.bySource[src]; entry != nil {
+		return entry
+	}
+
+	entry := &sourceEntry{sample: e.sampling[src]}
+	for _, w := range e.wildcards {
+		m, ok, err := w.Resolve(src)
+		if err != nil {
+			log.Printf("[remap] wildcard not routed: %v", err)
+		}
+		if !ok {
+			continue
+		}
+		entry.direct = true
+		entry.mappings = append(entry.mappings, m)
+		buf := e.outputs[m.To]
+		if buf == nil {
+			buf = e.newBuffer(m.To)
+			e.outputs[m.To] = buf
+		}
+		if !slices.Contains(buf.sources, src) {
+			buf.sources = append(slices.Clip(buf.sources), src)
+		}
+	}
+	e.resolved = append(e.resolved, entry.mappings...)
+	e.compile(entry)
+	e.bySource[src] = entry
+	return entry
+}
+
+// newBuffer creates the output buffer of a universe first written by a wildcard
+func (e *Engine) newBuffer(u config.Universe) *universeBuffer {
+	buf := &universeBuffer{minInterval: e.limits[u]}
+	if values := e.defaults[u]; len(values) > 0 {
+		buf.defaults = values
+		buf.applyDefaults()
 	}
+	return buf
+}
+
+// allMappings returns the static mappings followed by those resolved from wildcards so far
+func (e *Engine) allMappings() []config.NormalizedMapping {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append(slices.Clip(e.mappings), e.resolved...)
+}
+
+// Mappings returns the single-universe mappings in use, including those
+// resolved from wildcards so far
+func (e *Engine) Mappings() []config.NormalizedMapping {
+	return expand(e.allMappings())
+}
 
-	return &Engine{
-		mappings: mappings,
-		bySource: bySource,
-		outputs:  outputs,
+func expand(mappings []config.NormalizedMapping) []config.NormalizedMapping {
+	var result []config.NormalizedMapping
+	for _, m := range mappings {
+		result = append(result, m.Expand()...)
 	}
+	return result
 }
 
-// Remap applies mappings to incoming DMX data and marks affected outputs dirty
+// Remap applies the source universe's precompiled copy plan to incoming DMX data
+// and marks affected outputs dirty
 func (e *Engine) Remap(src config.Universe, srcData [512]byte) {
+	e.mu.RLock()
 	entry := e.bySource[src]
+	e.mu.RUnlock()
 	if entry == nil {
+		if len(e.wildcards) == 0 {
+			return
+		}
+		entry = e.resolve(src)
+	}
+	e.apply(entry, srcData)
+}
+
+// RemapFiltered is Remap for a frame sent from a physical port of the console
+// (-1 for none) and delivered by cast ("" if unknown), also applying the
+// mappings limited to that port, that cast or both
+func (e *Engine) RemapFiltered(src config.Universe, physical int, cast config.Cast, srcData [512]byte) {
+	e.Remap(src, srcData)
+	if len(e.byFilter) == 0 {
 		return
 	}
-	entry.counter.Add(1)
+	var keys []filterKey
+	if physical != anyPhysical {
+		keys = append(keys, filterKey{src, physical, ""})
+	}
+	if cast != "" {
+		keys = append(keys, filterKey{src, anyPhysical, cast})
+		if physical != anyPhysical {
+			keys = append(keys, filterKey{src, physical, cast})
+		}
+	}
+	for _, k := range keys {
+		if entry := e.byFilter[k]; entry != nil {
+			e.apply(entry, srcData)
+		}
+	}
+}
 
-	for _, m := range entry.mappings {
-		e.applyMapping(m, srcData)
+// apply runs a source entry's plans on one input frame
+func (e *Engine) apply(entry *sourceEntry, srcData [512]byte) {
+	if entry.direct {
+		entry.counter.Add(1)
+	}
+	for _, b := range entry.blocks {
+		b.counter.Add(1)
+	}
+	for _, u := range entry.users {
+		u.frames.Add(1)
+	}
+
+	now := e.clock.Now()
+	if entry.sample > 0 {
+		e.sample(entry, now)
+	}
+	obs := e.observation()
+	for i := range entry.plans {
+		p := &entry.plans[i]
+		p.apply(&srcData, now, obs)
+		if obs != nil {
+			e.notify(p.universe, obs)
+		}
+	}
+	if len(entry.delayed) > 0 {
+		for i := range entry.delayed {
+			d := &entry.delayed[i]
+			d.queue.push(now, &d.plan, srcData)
+		}
 	}
 }
 
-func (e *Engine) applyMapping(m config.NormalizedMapping, srcData [512]byte) {
-	buf := e.outputs[m.To]
-	buf.mu.Lock()
-	defer buf.mu.Unlock()
+// sample holds the outputs a source feeds for its sampling period when its input
+// starts, at the first frame or after DataLossTimeout without one
+func (e *Engine) sample(entry *sourceEntry, now time.Time) {
+	last := entry.lastInput.Swap(now.UnixNano())
+	if last != 0 && now.Sub(time.Unix(0, last)) <= DataLossTimeout {
+		return
+	}
+	until := now.Add(entry.sample)
+	for i := range entry.plans {
+		entry.plans[i].buf.hold(until)
+	}
+	for i := range entry.delayed {
+		entry.delayed[i].plan.buf.hold(until)
+	}
+}
+
+// SetClock replaces the clock used for delays, rate limits and data-loss timeouts.
+// It must be called before the engine is in use.
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = c
+}
 
-	for i := 0; i < m.Count; i++ {
-		srcChan := m.FromChan + i
-		dstChan := m.ToChan + i
-		if srcChan < 512 && dstChan < 512 {
-			buf.data[dstChan] = srcData[srcChan]
+// SetMinInterval limits how often an output universe is returned by GetDirtyOutputs.
+// It must be called before the engine is in use.
+func (e *Engine) SetMinInterval(u config.Universe, d time.Duration) {
+	if buf := e.outputs[u]; buf != nil {
+		buf.minInterval = d
+		e.deferred = true
+	} else if len(e.wildcards) > 0 {
+		e.limits[u] = d
+		e.deferred = true
+	}
+}
+
+// SetDefaults sets channel values (0-indexed) output before any input arrives and
+// restored after DataLossTimeout without input. It must be called before the engine is in use.
+func (e *Engine) SetDefaults(u config.Universe, values map[int]byte) {
+	if len(values) == 0 {
+		return
+	}
+	if buf := e.outputs[u]; buf != nil {
+		buf.defaults = values
+		buf.applyDefaults()
+		e.deferred = true
+	} else if len(e.wildcards) > 0 {
+		e.defaults[u] = values
+		e.deferred = true
+	}
+}
+
+// SetSampling holds the outputs fed by source universe u for d whenever its input
+// starts, so they are first sent with every other source merged in rather than
+// half-filled. It must be called before the engine is in use.
+func (e *Engine) SetSampling(u config.Universe, d time.Duration) {
+	e.sampling[u] = d
+	for k, entry := range e.byFilter {
+		if k.u == u {
+			entry.sample = d
+			e.deferred = true
 		}
 	}
-	buf.dirty = true
+	if entry := e.bySource[u]; entry != nil {
+		entry.sample = d
+		e.deferred = true
+	} else if len(e.wildcards) > 0 {
+		e.deferred = true
+	}
 }
 
-// GetDirtyOutputs returns outputs that have been modified since last call
+// SetMasters scales the channels written by grouped mappings by the levels of m,
+// marking them dirty whenever a level changes. It must be called before the engine is in use.
+func (e *Engine) SetMasters(m *Masters) {
+	e.masters = m
+	for _, entry := range e.bySource {
+		e.group(entry)
+	}
+	for _, entry := range e.byFilter {
+		e.group(entry)
+	}
+	m.watch(e.markGrouped)
+}
+
+// markGrouped marks every output with grouped channels dirty so new master levels are sent
+func (e *Engine) markGrouped() {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, buf := range e.outputs {
+		buf.mu.Lock()
+		if buf.groups != nil {
+			buf.dirty = true
+		}
+		buf.mu.Unlock()
+	}
+}
+
+// HasDeferredOutputs reports whether outputs can become ready without new input,
+// due to delayed mappings, rate-limited universes, data-loss defaults or sampling
+func (e *Engine) HasDeferredOutputs() bool {
+	return len(e.delays) > 0 || e.deferred
+}
+
+// NextDue returns the earliest time GetDirtyOutputs may return an output without
+// new input: a delayed frame coming due, a rate limit or sampling hold ending on
+// a dirty output, or a live output reaching DataLossTimeout
+func (e *Engine) NextDue() (time.Time, bool) {
+	var next time.Time
+	ok := false
+	earliest := func(t time.Time) {
+		if !ok || t.Before(next) {
+			next, ok = t, true
+		}
+	}
+
+	for _, q := range e.delays {
+		if due, pending := q.next(); pending {
+			earliest(due)
+		}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, buf := range e.outputs {
+		buf.mu.Lock()
+		if buf.dirty {
+			due := buf.holdUntil
+			if sent := buf.lastSent.Add(buf.minInterval); buf.minInterval > 0 && sent.After(due) {
+				due = sent
+			}
+			earliest(due)
+		}
+		if buf.live {
+			earliest(buf.lastInput.Add(DataLossTimeout + time.Nanosecond))
+		}
+		buf.mu.Unlock()
+	}
+	return next, ok
+}
+
+// GetDirtyOutputs returns outputs that have been modified since last call,
+// after applying any delayed frames that have come due. Rate-limited outputs
+// stay dirty until their minimum interval has elapsed.
 func (e *Engine) GetDirtyOutputs() []Output {
+	now := e.clock.Now()
+	e.flushDelayed(now)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	var result []Output
 	for u, buf := range e.outputs {
-		if out, ok := e.getDirtyOutput(u, buf); ok {
+		if out, ok := e.getDirtyOutput(u, buf, now); ok {
 			result = append(result, out)
 		}
 	}
 	return result
 }
 
-func (e *Engine) getDirtyOutput(u config.Universe, buf *universeBuffer) (Output, bool) {
+func (e *Engine) getDirtyOutput(u config.Universe, buf *universeBuffer, now time.Time) (Output, bool) {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 
-	if !buf.dirty {
+	if buf.live && now.Sub(buf.lastInput) > DataLossTimeout {
+		buf.live = false
+		buf.applyDefaults()
+	}
+
+	if !buf.dirty || now.Before(buf.holdUntil) {
 		return Output{}, false
 	}
+	if buf.minInterval > 0 {
+		if now.Sub(buf.lastSent) < buf.minInterval {
+			return Output{}, false
+		}
+		buf.lastSent = now
+	}
 	buf.dirty = false
-	return Output{Universe: u, Data: buf.data}, true
+	return Output{Universe: u, Data: buf.frame()}, true
 }
 
 // SwapStats returns packet counts per source universe since last call and resets them
 func (e *Engine) SwapStats() map[config.Universe]uint64 {
 	result := map[config.Universe]uint64{}
+	e.mu.RLock()
 	for u, entry := range e.bySource {
-		result[u] = entry.counter.Swap(0)
+		if entry.direct {
+			result[u] += entry.counter.Swap(0)
+		}
 	}
-	return result
-}
-
-// SourceArtNetUniverses returns source ArtNet universe numbers (for discovery)
-func (e *Engine) SourceArtNetUniverses() []uint16 {
-	seen := make(map[uint16]bool)
-	for _, m := range e.mappings {
-		if m.From.Protocol == config.ProtocolArtNet {
-			seen[m.From.Number] = true
+	// Filtered frames are already counted when the universe has unlimited mappings too
+	for k, entry := range e.byFilter {
+		if n := entry.counter.Swap(0); e.bySource[k.u] == nil || !e.bySource[k.u].direct {
+			result[k.u] += n
 		}
 	}
-	result := make([]uint16, 0, len(seen))
-	for u := range seen {
-		result = append(result, u)
+	e.mu.RUnlock()
+	for _, b := range e.blocks {
+		result[b.mapping.From] += b.counter.Swap(0)
 	}
 	return result
 }
 
-func (e *Engine) DestArtNetUniverses() []uint16 {
-	seen := make(map[uint16]bool)
-	for _, m := range e.mappings {
-		if m.To.Protocol == config.ProtocolArtNet {
-			seen[m.To.Number] = true
-		}
+// Sources returns the universes mapped into output universe u
+func (e *Engine) Sources(u config.Universe) []config.Universe {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if buf := e.outputs[u]; buf != nil {
+		return buf.sources
 	}
-	result := make([]uint16, 0, len(seen))
-	for u := range seen {
-		result = append(result, u)
+	return nil
+}
+
+// LiveOutputs returns the output universes that received input within DataLossTimeout
+func (e *Engine) LiveOutputs() []config.Universe {
+	now := e.clock.Now()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var result []config.Universe
+	for u, buf := range e.outputs {
+		buf.mu.Lock()
+		if now.Sub(buf.lastInput) <= DataLossTimeout {
+			result = append(result, u)
+		}
+		buf.mu.Unlock()
 	}
 	return result
 }
 
-func (e *Engine) DestSACNUniverses() []uint16 {
-	seen := make(map[uint16]bool)
-	for _, m := range e.mappings {
-		if m.To.Protocol == config.ProtocolSACN {
-			seen[m.To.Number] = true
+// SourceUniverses returns the sorted source universes of proto (e.g. ArtNet ones for discovery)
+func (e *Engine) SourceUniverses(proto config.Protocol) []config.Universe {
+	return e.universes(proto, func(m config.NormalizedMapping) config.Universe { return m.From })
+}
+
+// DestUniverses returns the sorted destination universes of proto
+func (e *Engine) DestUniverses(proto config.Protocol) []config.Universe {
+	return e.universes(proto, func(m config.NormalizedMapping) config.Universe { return m.To })
+}
+
+func (e *Engine) universes(proto config.Protocol, side func(config.NormalizedMapping) config.Universe) []config.Universe {
+	var result []config.Universe
+	for _, m := range expand(e.allMappings()) {
+		if u := side(m); u.Protocol == proto && !slices.Contains(result, u) {
+			result = append(result, u)
 		}
 	}
-	result := make([]uint16, 0, len(seen))
-	for u := range seen {
-		result = append(result, u)
-	}
+	slices.SortFunc(result, config.Universe.Compare)
 	return result
 }
@@ -2,7 +2,9 @@ package remap
 
 import (
 	"testing"
+	"time"
 
+	"github.com/gopatchy/artmap/clock"
 	"github.com/gopatchy/artmap/config"
 )
 
@@ -138,3 +140,499 @@ func FuzzRemapUnmatchedUniverse(f *testing.F) {
 		}
 	})
 }
+
+func FuzzRemapUniverseBlock(f *testing.F) {
+	f.Add(uint16(101), uint16(64), uint16(256), uint16(101))
+	f.Add(uint16(101), uint16(64), uint16(256), uint16(164))
+	f.Add(uint16(101), uint16(64), uint16(256), uint16(165))
+	f.Add(uint16(1), uint16(2), uint16(0), uint16(0))
+
+	f.Fuzz(func(t *testing.T, fromStart, span, toStart, input uint16) {
+		if span < 2 || span > 512 {
+			return
+		}
+		srcU, err := config.NewUniverse(config.ProtocolSACN, fromStart)
+		if err != nil {
+			return
+		}
+		dstU, err := config.NewUniverse(config.ProtocolArtNet, toStart)
+		if err != nil {
+			return
+		}
+		if int(fromStart)+int(span)-1 > 63999 || int(toStart)+int(span)-1 > 0x7FFF {
+			return
+		}
+		inputU, err := config.NewUniverse(config.ProtocolSACN, input)
+		if err != nil {
+			return
+		}
+
+		engine := NewEngine([]config.NormalizedMapping{{
+			From: srcU, FromChan: 0, To: dstU, ToChan: 0, Count: 512, Span: int(span),
+		}})
+
+		var srcData [512]byte
+		srcData[0] = 42
+		engine.Remap(inputU, srcData)
+		outputs := engine.GetDirtyOutputs()
+
+		inBlock := input >= fromStart && int(input) < int(fromStart)+int(span)
+		if !inBlock {
+			if len(outputs) != 0 {
+				t.Fatalf("expected 0 outputs for universe outside block, got %d", len(outputs))
+			}
+			return
+		}
+		if len(outputs) != 1 {
+			t.Fatalf("expected 1 output, got %d", len(outputs))
+		}
+		want := dstU.Offset(int(input - fromStart))
+		if outputs[0].Universe != want {
+			t.Fatalf("expected output %s, got %s", want, outputs[0].Universe)
+		}
+		if outputs[0].Data[0] != 42 {
+			t.Fatalf("channel mismatch: %d", outputs[0].Data[0])
+		}
+	})
+}
+
+func FuzzRemapWildcard(f *testing.F) {
+	f.Add(uint16(1), int16(-1), uint16(1))
+	f.Add(uint16(1), int16(0), uint16(0))
+	f.Add(uint16(100), int16(-200), uint16(5))
+	f.Add(uint16(32768), int16(0), uint16(0))
+
+	f.Fuzz(func(t *testing.T, input uint16, offset int16, static uint16) {
+		inputU, err := config.NewUniverse(config.ProtocolSACN, input)
+		if err != nil {
+			return
+		}
+		staticU, err := config.NewUniverse(config.ProtocolSACN, static)
+		if err != nil {
+			staticU = config.SACNUniverse(1)
+		}
+		engine := NewEngine([]config.NormalizedMapping{
+			{From: staticU, To: config.SACNUniverse(63999), Count: 512},
+			{From: config.Universe{Protocol: config.ProtocolSACN}, To: config.Universe{Protocol: config.ProtocolArtNet},
+				Count: 512, Wildcard: true, Offset: int(offset), Index: 1},
+		})
+
+		var srcData [512]byte
+		srcData[0] = 42
+		engine.Remap(inputU, srcData)
+		engine.Remap(inputU, srcData)
+		outputs := engine.GetDirtyOutputs()
+
+		n := int(input) + int(offset)
+		if inputU == staticU || n < 0 || n > 0x7FFF {
+			if inputU != staticU && len(outputs) != 0 {
+				t.Fatalf("expected 0 outputs for unresolvable universe, got %d", len(outputs))
+			}
+			return
+		}
+		if len(outputs) != 1 {
+			t.Fatalf("expected 1 output, got %d", len(outputs))
+		}
+		if want, _ := config.NewUniverse(config.ProtocolArtNet, uint16(n)); outputs[0].Universe != want {
+			t.Fatalf("expected output %s, got %s", want, outputs[0].Universe)
+		}
+		if outputs[0].Data[0] != 42 {
+			t.Fatalf("channel mismatch: %d", outputs[0].Data[0])
+		}
+		if len(engine.DestUniverses(config.ProtocolArtNet)) != 1 {
+			t.Fatalf("resolved universe missing from destinations")
+		}
+	})
+}
+
+func FuzzObserve(f *testing.F) {
+	f.Add([]byte{1, 2, 3}, []byte{1, 5, 3}, uint16(0), uint16(2))
+	f.Add([]byte{}, []byte{255}, uint16(10), uint16(0))
+	f.Add([]byte{7}, []byte{7}, uint16(511), uint16(511))
+
+	f.Fuzz(func(t *testing.T, first, second []byte, toChan, parked uint16) {
+		if toChan > 511 || parked > 511 {
+			return
+		}
+		src, dst := config.SACNUniverse(1), config.ArtNetUniverse(0)
+		engine := NewEngine([]config.NormalizedMapping{{From: src, To: dst, ToChan: int(toChan), Count: 512}})
+		engine.Park(dst, map[int]byte{int(parked): 99})
+
+		var calls int
+		var gotFrame [512]byte
+		var gotMask Mask
+		engine.Observe(func(u config.Universe, data [512]byte, changed Mask) {
+			if u != dst {
+				t.Fatalf("observed %s, want %s", u, dst)
+			}
+			calls++
+			gotFrame, gotMask = data, changed
+		})
+
+		var a, b [512]byte
+		copy(a[:], first)
+		copy(b[:], second)
+		engine.Remap(src, a)
+		before := engine.Frames()[dst]
+		engine.Remap(src, b)
+		after := engine.Frames()[dst]
+
+		if calls != 2 {
+			t.Fatalf("expected 2 observations, got %d", calls)
+		}
+		if gotFrame != after {
+			t.Fatalf("observed frame differs from output frame")
+		}
+		for ch := range after {
+			if gotMask.Has(ch) != (before[ch] != after[ch]) {
+				t.Fatalf("channel %d: mask %v, before %d after %d", ch, gotMask.Has(ch), before[ch], after[ch])
+			}
+		}
+		if gotMask.Has(int(parked)) {
+			t.Fatalf("parked channel %d marked changed", parked)
+		}
+	})
+}
+
+func FuzzCopyPlan(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 10, 1, 10, 1, 11, 1, 11, 1, 12})
+	f.Add([]byte{0, 100, 20, 0, 50, 10, 0, 0, 255, 255})
+
+	f.Fuzz(func(t *testing.T, spec []byte) {
+		srcU, _ := config.NewUniverse(config.ProtocolArtNet, 0)
+		dstU, _ := config.NewUniverse(config.ProtocolArtNet, 1)
+
+		// Each 3 bytes describe one mapping: from, to and count (1-indexed sizes scaled into 512)
+		var mappings []config.NormalizedMapping
+		for i := 0; i+2 < len(spec) && len(mappings) < 32; i += 3 {
+			from, to, count := int(spec[i])*2, int(spec[i+1])*2, int(spec[i+2])%64+1
+			mappings = append(mappings, config.NormalizedMapping{
+				From: srcU, FromChan: from, To: dstU, ToChan: to, Count: count,
+			})
+		}
+		if len(mappings) == 0 {
+			return
+		}
+
+		var srcData [512]byte
+		for i := range srcData {
+			srcData[i] = byte(i*7 + 1)
+		}
+
+		var want [512]byte
+		for _, m := range mappings {
+			for i := 0; i < m.Count; i++ {
+				if m.FromChan+i < 512 && m.ToChan+i < 512 {
+					want[m.ToChan+i] = srcData[m.FromChan+i]
+				}
+			}
+		}
+
+		engine := NewEngine(mappings)
+		engine.Remap(srcU, srcData)
+		outputs := engine.GetDirtyOutputs()
+		if len(outputs) != 1 {
+			t.Fatalf("expected 1 output, got %d", len(outputs))
+		}
+		if outputs[0].Data != want {
+			t.Fatalf("plan output differs from per-channel reference")
+		}
+	})
+}
+
+func FuzzDataLossTimeout(f *testing.F) {
+	f.Add(uint16(0))
+	f.Add(uint16(2500))
+	f.Add(uint16(2501))
+	f.Add(uint16(60000))
+
+	f.Fuzz(func(t *testing.T, elapsedMS uint16) {
+		srcU, _ := config.NewUniverse(config.ProtocolArtNet, 0)
+		dstU, _ := config.NewUniverse(config.ProtocolArtNet, 1)
+
+		clk := clock.NewFake(time.Unix(0, 0))
+		engine := NewEngine([]config.NormalizedMapping{{
+			From: srcU, FromChan: 0, To: dstU, ToChan: 0, Count: 1,
+		}})
+		engine.SetClock(clk)
+		engine.SetDefaults(dstU, map[int]byte{0: 7})
+		engine.GetDirtyOutputs()
+
+		var srcData [512]byte
+		srcData[0] = 200
+		engine.Remap(srcU, srcData)
+		engine.GetDirtyOutputs()
+
+		clk.Advance(time.Duration(elapsedMS) * time.Millisecond)
+		outputs := engine.GetDirtyOutputs()
+
+		lost := time.Duration(elapsedMS)*time.Millisecond > DataLossTimeout
+		if !lost {
+			if len(outputs) != 0 {
+				t.Fatalf("unexpected output after %dms", elapsedMS)
+			}
+			return
+		}
+		if len(outputs) != 1 || outputs[0].Data[0] != 7 {
+			t.Fatalf("defaults not restored after %dms: %v", elapsedMS, outputs)
+		}
+	})
+}
+
+func FuzzSampling(f *testing.F) {
+	f.Add(uint16(500), uint16(100), uint16(600), uint16(0))
+	f.Add(uint16(500), uint16(600), uint16(0), uint16(0))
+	f.Add(uint16(1000), uint16(0), uint16(3000), uint16(200))
+
+	f.Fuzz(func(t *testing.T, sampleMS, firstMS, gapMS, secondMS uint16) {
+		if sampleMS == 0 {
+			return
+		}
+		a, b := config.SACNUniverse(1), config.SACNUniverse(2)
+		dst := config.ArtNetUniverse(0)
+		sample := time.Duration(sampleMS) * time.Millisecond
+
+		clk := clock.NewFake(time.Unix(1000, 0))
+		engine := NewEngine([]config.NormalizedMapping{
+			{From: a, To: dst, Count: 1},
+			{From: b, To: dst, FromChan: 1, ToChan: 1, Count: 1},
+		})
+		engine.SetClock(clk)
+		engine.SetSampling(a, sample)
+		if !engine.HasDeferredOutputs() {
+			t.Fatalf("sampling engine reports no deferred outputs")
+		}
+
+		// Input on a starts sampling; b arrives within or after the window
+		var frame [512]byte
+		frame[0], frame[1] = 10, 20
+		engine.Remap(a, frame)
+		clk.Advance(time.Duration(firstMS) * time.Millisecond)
+		engine.Remap(b, frame)
+		outputs := engine.GetDirtyOutputs()
+		if held := time.Duration(firstMS)*time.Millisecond < sample; held != (len(outputs) == 0) {
+			t.Fatalf("after %dms of %dms sampling got %d outputs", firstMS, sampleMS, len(outputs))
+		}
+		clk.Advance(sample)
+		outputs = append(outputs, engine.GetDirtyOutputs()...)
+		if len(outputs) != 1 || outputs[0].Data[0] != 10 || outputs[0].Data[1] != 20 {
+			t.Fatalf("expected one merged frame, got %v", outputs)
+		}
+
+		// a resuming after data loss samples again; a steady a does not
+		clk.Advance(time.Duration(gapMS) * time.Millisecond)
+		engine.Remap(a, frame)
+		clk.Advance(time.Duration(secondMS) * time.Millisecond)
+		outputs = engine.GetDirtyOutputs()
+		resumed := (time.Duration(firstMS)+time.Duration(gapMS))*time.Millisecond+sample > DataLossTimeout
+		held := resumed && time.Duration(secondMS)*time.Millisecond < sample
+		if held != (len(outputs) == 0) {
+			t.Fatalf("gap %dms then %dms: got %d outputs, held=%v", gapMS, secondMS, len(outputs), held)
+		}
+	})
+}
+
+func FuzzNextDue(f *testing.F) {
+	f.Add(uint16(0), uint16(25), uint8(3))
+	f.Add(uint16(100), uint16(0), uint8(1))
+	f.Add(uint16(40), uint16(25), uint8(4))
+
+	f.Fuzz(func(t *testing.T, delayMS, intervalMS uint16, frames uint8) {
+		src, dst := config.ArtNetUniverse(0), config.ArtNetUniverse(1)
+		clk := clock.NewFake(time.Unix(1000, 0))
+		engine := NewEngine([]config.NormalizedMapping{{
+			From: src, To: dst, Count: 1, Delay: time.Duration(delayMS) * time.Millisecond,
+		}})
+		engine.SetClock(clk)
+		engine.SetMinInterval(dst, time.Duration(intervalMS)*time.Millisecond)
+
+		// Frames arrive 1ms apart, sent on input as they would be with --sender-hz 0
+		var last, sent byte
+		for i := range int(frames%8) + 1 {
+			last = byte(i + 1)
+			engine.Remap(src, [512]byte{last})
+			for _, out := range engine.GetDirtyOutputs() {
+				sent = out.Data[0]
+			}
+			clk.Advance(time.Millisecond)
+		}
+
+		// Waking only at NextDue must still deliver the last frame, and nothing may come out early
+		for range 100 {
+			due, ok := engine.NextDue()
+			if !ok {
+				break
+			}
+			if wait := due.Sub(clk.Now()); wait > 0 {
+				clk.Advance(wait - time.Nanosecond)
+				if outputs := engine.GetDirtyOutputs(); len(outputs) != 0 {
+					t.Fatalf("output %v before its due time", outputs)
+				}
+				clk.Advance(time.Nanosecond)
+			}
+			for _, out := range engine.GetDirtyOutputs() {
+				sent = out.Data[0]
+			}
+		}
+		if _, ok := engine.NextDue(); ok {
+			t.Fatalf("outputs still due after 100 wakeups")
+		}
+		if sent != last {
+			t.Fatalf("last frame %d never sent, got %d", last, sent)
+		}
+	})
+}
+
+func FuzzMasters(f *testing.F) {
+	f.Add([]byte{255, 128, 1}, byte(255), byte(128), byte(0))
+	f.Add([]byte{200}, byte(0), byte(255), byte(255))
+	f.Add([]byte{}, byte(17), byte(3), byte(250))
+
+	f.Fuzz(func(t *testing.T, input []byte, grand, levelA, levelB byte) {
+		src, dst := config.SACNUniverse(1), config.ArtNetUniverse(0)
+		engine := NewEngine([]config.NormalizedMapping{
+			{From: src, To: dst, ToChan: 0, Count: 256, Group: "a"},
+			{From: src, To: dst, ToChan: 128, Count: 256, Group: "b"},
+			{From: src, To: dst, ToChan: 400, Count: 100},
+		})
+		masters := NewMasters([]config.Group{{Name: "a"}, {Name: "b"}})
+		engine.SetMasters(masters)
+
+		var data [512]byte
+		copy(data[:], input)
+		engine.Remap(src, data)
+		engine.GetDirtyOutputs()
+
+		for _, set := range []struct {
+			name  string
+			level byte
+		}{{config.GrandMaster, grand}, {"a", levelA}, {"b", levelB}} {
+			if err := masters.Set(set.name, set.level); err != nil {
+				t.Fatalf("set %s: %v", set.name, err)
+			}
+		}
+		if err := masters.Set("c", 0); err == nil {
+			t.Fatalf("unknown group accepted")
+		}
+		if outputs := engine.GetDirtyOutputs(); len(outputs) != 1 {
+			t.Fatalf("expected master change to dirty the output, got %d outputs", len(outputs))
+		}
+
+		got := engine.Frames()[dst]
+		for ch := range got {
+			var raw byte
+			var level int
+			switch {
+			case ch < 128:
+				raw, level = data[ch], int(levelA)
+			case ch < 256:
+				raw, level = data[ch-128], max(int(levelA), int(levelB))
+			case ch < 384:
+				raw, level = data[ch-128], int(levelB)
+			case ch >= 400 && ch < 500:
+				raw, level = data[ch-400], -1
+			}
+			want := raw
+			if level >= 0 {
+				want = byte((int(raw)*int(grand)*level + 255*255/2) / (255 * 255))
+			}
+			if got[ch] != want {
+				t.Fatalf("channel %d: got %d want %d (raw %d grand %d level %d)", ch, got[ch], want, raw, grand, level)
+			}
+		}
+	})
+}
+
+func FuzzSync(f *testing.F) {
+	f.Add([]byte{1, 2, 3}, []byte{4, 5, 6}, []byte{7, 8, 9})
+	f.Add([]byte{}, []byte{255}, []byte{0})
+
+	f.Fuzz(func(t *testing.T, first, second, other []byte) {
+		srcA, _ := config.NewUniverse(config.ProtocolArtNet, 0)
+		srcB, _ := config.NewUniverse(config.ProtocolArtNet, 1)
+		dstA, _ := config.NewUniverse(config.ProtocolArtNet, 10)
+		dstB, _ := config.NewUniverse(config.ProtocolArtNet, 11)
+		engine := NewEngine([]config.NormalizedMapping{
+			{From: srcA, FromChan: 0, To: dstA, ToChan: 0, Count: 512},
+			{From: srcB, FromChan: 0, To: dstB, ToChan: 0, Count: 512},
+		})
+		engine.GetDirtyOutputs()
+
+		var a1, a2, b [512]byte
+		copy(a1[:], first)
+		copy(a2[:], second)
+		copy(b[:], other)
+		engine.Buffer(srcA, 0, "", a1)
+		engine.Buffer(srcB, 0, "", b)
+		engine.Buffer(srcA, 0, "", a2)
+
+		if outputs := engine.GetDirtyOutputs(); len(outputs) != 0 {
+			t.Fatalf("expected no outputs before sync, got %d", len(outputs))
+		}
+
+		engine.Sync()
+		got := map[config.Universe][512]byte{}
+		for _, out := range engine.GetDirtyOutputs() {
+			got[out.Universe] = out.Data
+		}
+		if a, ok := got[dstA]; !ok || a != a2 {
+			t.Fatalf("universe %s: expected the last buffered frame", dstA)
+		}
+		if bb, ok := got[dstB]; !ok || bb != b {
+			t.Fatalf("universe %s: expected the buffered frame", dstB)
+		}
+
+		engine.Sync()
+		if outputs := engine.GetDirtyOutputs(); len(outputs) != 0 {
+			t.Fatalf("expected no outputs from an empty sync, got %d", len(outputs))
+		}
+	})
+}
+
+func FuzzRemapCast(f *testing.F) {
+	f.Add([]byte{1, 2, 3}, []byte{4, 5, 6}, true)
+	f.Add([]byte{255}, []byte{0}, false)
+
+	f.Fuzz(func(t *testing.T, broadcast, unicast []byte, physical bool) {
+		src, _ := config.NewUniverse(config.ProtocolArtNet, 0)
+		dstAll, _ := config.NewUniverse(config.ProtocolArtNet, 10)
+		dstUnicast, _ := config.NewUniverse(config.ProtocolArtNet, 11)
+		port := -1
+		filtered := config.NormalizedMapping{From: src, FromChan: 0, To: dstUnicast, ToChan: 0, Count: 512, Cast: config.CastUnicast}
+		if physical {
+			port = 1
+			p := uint8(port)
+			filtered.Physical = &p
+		}
+		engine := NewEngine([]config.NormalizedMapping{
+			{From: src, FromChan: 0, To: dstAll, ToChan: 0, Count: 512},
+			filtered,
+		})
+		engine.GetDirtyOutputs()
+
+		var b, u [512]byte
+		copy(b[:], broadcast)
+		copy(u[:], unicast)
+
+		engine.RemapFiltered(src, port, config.CastBroadcast, b)
+		got := map[config.Universe][512]byte{}
+		for _, out := range engine.GetDirtyOutputs() {
+			got[out.Universe] = out.Data
+		}
+		if got[dstAll] != b {
+			t.Fatalf("universe %s: expected the broadcast frame", dstAll)
+		}
+		if _, ok := got[dstUnicast]; ok {
+			t.Fatalf("universe %s: broadcast frame passed the unicast filter", dstUnicast)
+		}
+
+		engine.RemapFiltered(src, port, config.CastUnicast, u)
+		got = map[config.Universe][512]byte{}
+		for _, out := range engine.GetDirtyOutputs() {
+			got[out.Universe] = out.Data
+		}
+		if got[dstUnicast] != u {
+			t.Fatalf("universe %s: expected the unicast frame", dstUnicast)
+		}
+	})
+}
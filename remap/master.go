@@ -0,0 +1,101 @@
+package remap
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// MasterLevel is the current level of a master as reported by the API
+type MasterLevel struct {
+	Name  string `json:"name"`
+	Level byte   `json:"level"`
+}
+
+// Masters holds the grand master and the level group masters. A channel written
+// by grouped mappings is scaled by the highest master of its groups (highest
+// takes precedence) times the grand master; ungrouped channels are left alone.
+// One Masters is shared by every profile's engine so levels survive switches.
+type Masters struct {
+	names    []string
+	grand    atomic.Uint32
+	levels   []atomic.Uint32
+	watchers []func()
+}
+
+// NewMasters creates masters for the config groups at their startup levels, with the grand master full
+func NewMasters(groups []config.Group) *Masters {
+	m := &Masters{levels: make([]atomic.Uint32, len(groups))}
+	m.grand.Store(255)
+	for i, g := range groups {
+		m.names = append(m.names, g.Name)
+		m.levels[i].Store(uint32(g.Level()))
+	}
+	return m
+}
+
+// Set changes the level of the named group master, or of the grand master
+func (m *Masters) Set(name string, level byte) error {
+	if name == config.GrandMaster {
+		m.grand.Store(uint32(level))
+	} else if i, ok := m.index(name); ok {
+		m.levels[i].Store(uint32(level))
+	} else {
+		return fmt.Errorf("unknown group %q", name)
+	}
+	for _, fn := range m.watchers {
+		fn()
+	}
+	return nil
+}
+
+// Levels returns the grand master followed by the group masters in config order
+func (m *Masters) Levels() []MasterLevel {
+	result := []MasterLevel{{Name: config.GrandMaster, Level: byte(m.grand.Load())}}
+	for i, name := range m.names {
+		result = append(result, MasterLevel{Name: name, Level: byte(m.levels[i].Load())})
+	}
+	return result
+}
+
+func (m *Masters) index(name string) (int, bool) {
+	for i, n := range m.names {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// watch registers fn to be called after every level change; it must be called before the masters are in use
+func (m *Masters) watch(fn func()) {
+	m.watchers = append(m.watchers, fn)
+}
+
+// apply scales each channel of data by the masters of the groups marked for it in groups
+func (m *Masters) apply(data *[512]byte, groups *[512]uint64) {
+	grand := m.grand.Load()
+	var lastMask uint64
+	var scale uint32
+	for ch, mask := range groups {
+		if mask == 0 {
+			continue
+		}
+		if mask != lastMask {
+			lastMask, scale = mask, grand*m.highest(mask)
+		}
+		data[ch] = byte((uint32(data[ch])*scale + 255*255/2) / (255 * 255))
+	}
+}
+
+// highest returns the highest level among the groups in mask
+func (m *Masters) highest(mask uint64) uint32 {
+	var level uint32
+	for i := range m.levels {
+		if mask&(1<<i) != 0 {
+			level = max(level, m.levels[i].Load())
+		}
+	}
+	return level
+}
@@ -0,0 +1,71 @@
+package remap
+
+import (
+	"math/bits"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Mask marks changed channels of a universe, bit i for channel i (0-indexed)
+type Mask [8]uint64
+
+// Set marks channel ch (0-indexed)
+func (m *Mask) Set(ch int) {
+	m[ch/64] |= 1 << (ch % 64)
+}
+
+// Has reports whether channel ch (0-indexed) is marked
+func (m Mask) Has(ch int) bool {
+	return m[ch/64]&(1<<(ch%64)) != 0
+}
+
+// Count returns the number of marked channels
+func (m Mask) Count() int {
+	n := 0
+	for _, w := range m {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// diffMask marks the channels that differ between a and b
+func diffMask(a, b *[512]byte) Mask {
+	var m Mask
+	for i := range a {
+		if a[i] != b[i] {
+			m.Set(i)
+		}
+	}
+	return m
+}
+
+// Observer is called with an output universe's frame, parked channels applied,
+// and the channels that changed each time a source frame is remapped into it.
+// It runs on the input path, so it must be quick and must not block.
+type Observer func(u config.Universe, data [512]byte, changed Mask)
+
+// observation is the result of one plan application handed to observers
+type observation struct {
+	frame   [512]byte
+	changed Mask
+}
+
+// Observe registers fn to be called after each remap, including delayed frames
+// as they come due. It must be called before the engine is in use.
+func (e *Engine) Observe(fn Observer) {
+	e.observers = append(e.observers, fn)
+}
+
+// observation returns a buffer for plan results, or nil when nothing observes the engine
+func (e *Engine) observation() *observation {
+	if len(e.observers) == 0 {
+		return nil
+	}
+	return &observation{}
+}
+
+func (e *Engine) notify(u config.Universe, obs *observation) {
+	for _, fn := range e.observers {
+		fn(u, obs.frame, obs.changed)
+	}
+}
@@ -0,0 +1,110 @@
+package remap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// ParkedChannel is an output channel held at a fixed value
+type ParkedChannel struct {
+	Universe config.Universe `json:"universe"`
+	Channel  int             `json:"channel"` // 1-indexed
+	Value    byte            `json:"value"`
+}
+
+func (e *Engine) output(u config.Universe) (*universeBuffer, error) {
+	e.mu.RLock()
+	buf := e.outputs[u]
+	e.mu.RUnlock()
+	if buf == nil {
+		return nil, fmt.Errorf("%s is not an output universe", u)
+	}
+	return buf, nil
+}
+
+// Set writes channel values (0-indexed) into an output universe until input overwrites them
+func (e *Engine) Set(u config.Universe, values map[int]byte) error {
+	buf, err := e.output(u)
+	if err != nil {
+		return err
+	}
+	buf.mu.Lock()
+	for ch, v := range values {
+		buf.data[ch] = v
+	}
+	buf.dirty = true
+	buf.mu.Unlock()
+	return nil
+}
+
+// Park holds channels (0-indexed) of an output universe at fixed values, overriding all mappings
+func (e *Engine) Park(u config.Universe, values map[int]byte) error {
+	buf, err := e.output(u)
+	if err != nil {
+		return err
+	}
+	buf.mu.Lock()
+	if buf.parked == nil {
+		buf.parked = map[int]byte{}
+	}
+	for ch, v := range values {
+		buf.parked[ch] = v
+	}
+	buf.dirty = true
+	buf.mu.Unlock()
+	return nil
+}
+
+// Release unparks channels (0-indexed) of an output universe; nil releases all of them
+func (e *Engine) Release(u config.Universe, channels []int) error {
+	buf, err := e.output(u)
+	if err != nil {
+		return err
+	}
+	buf.mu.Lock()
+	if channels == nil {
+		buf.parked = nil
+	}
+	for _, ch := range channels {
+		delete(buf.parked, ch)
+	}
+	buf.dirty = true
+	buf.mu.Unlock()
+	return nil
+}
+
+// Parked lists all parked channels sorted by universe and channel
+func (e *Engine) Parked() []ParkedChannel {
+	var result []ParkedChannel
+	e.mu.RLock()
+	for u, buf := range e.outputs {
+		buf.mu.Lock()
+		for ch, v := range buf.parked {
+			result = append(result, ParkedChannel{Universe: u, Channel: ch + 1, Value: v})
+		}
+		buf.mu.Unlock()
+	}
+	e.mu.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Universe != result[j].Universe {
+			return result[i].Universe.Compare(result[j].Universe) < 0
+		}
+		return result[i].Channel < result[j].Channel
+	})
+	return result
+}
+
+// Frames returns the current data of every output universe, with parked channels applied
+func (e *Engine) Frames() map[config.Universe][512]byte {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result := make(map[config.Universe][512]byte, len(e.outputs))
+	for u, buf := range e.outputs {
+		buf.mu.Lock()
+		result[u] = buf.frame()
+		buf.mu.Unlock()
+	}
+	return result
+}
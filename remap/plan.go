@@ -0,0 +1,129 @@
+package remap
+
+import (
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/transform"
+)
+
+// copyStep copies or transforms one contiguous channel range (0-indexed), already clipped to the universe
+type copyStep struct {
+	from      int
+	to        int
+	count     int
+	transform transform.Transform
+	usage     *mappingUsage
+}
+
+// compileStep converts a single-universe mapping into a copy step, reporting false if nothing fits
+func compileStep(m config.NormalizedMapping) (copyStep, bool) {
+	if m.Transform != nil {
+		if m.FromChan+m.Count > 512 || m.ToChan+m.OutputCount() > 512 {
+			return copyStep{}, false
+		}
+		return copyStep{from: m.FromChan, to: m.ToChan, count: m.Count, transform: m.Transform}, true
+	}
+	count := min(m.Count, 512-m.FromChan, 512-m.ToChan)
+	if count <= 0 {
+		return copyStep{}, false
+	}
+	return copyStep{from: m.FromChan, to: m.ToChan, count: count}, true
+}
+
+// apply writes the step into dst and returns how many destination channels changed
+func (s copyStep) apply(dst *[512]byte, src *[512]byte) int {
+	if s.transform != nil {
+		out := dst[s.to : s.to+s.transform.OutputCount()]
+		var before [512]byte
+		copy(before[:], out)
+		s.transform.Apply(out, src[s.from:s.from+s.count])
+		return countChanged(before[:len(out)], out)
+	}
+	out := dst[s.to : s.to+s.count]
+	in := src[s.from : s.from+s.count]
+	changed := countChanged(out, in)
+	copy(out, in)
+	return changed
+}
+
+func countChanged(a, b []byte) int {
+	n := 0
+	for i := range a {
+		if a[i] != b[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// outputPlan is the steps a source universe writes into one output buffer, in mapping order
+type outputPlan struct {
+	universe config.Universe
+	buf      *universeBuffer
+	steps    []copyStep
+}
+
+// compilePlan groups mappings by output buffer and merges copies of the same config
+// mapping that continue the previous step in both source and destination. Order
+// within a buffer is kept so later mappings still win where destinations overlap.
+func compilePlan(mappings []config.NormalizedMapping, outputs map[config.Universe]*universeBuffer, usage []mappingUsage) []outputPlan {
+	var plans []outputPlan
+	index := map[*universeBuffer]int{}
+	for _, m := range mappings {
+		step, ok := compileStep(m)
+		if !ok {
+			continue
+		}
+		if m.Index < len(usage) {
+			step.usage = &usage[m.Index]
+		}
+		buf := outputs[m.To]
+		i, exists := index[buf]
+		if !exists {
+			i = len(plans)
+			index[buf] = i
+			plans = append(plans, outputPlan{universe: m.To, buf: buf})
+		}
+
+		p := &plans[i]
+		if n := len(p.steps); n > 0 && step.transform == nil {
+			last := &p.steps[n-1]
+			if last.transform == nil && last.usage == step.usage && last.from+last.count == step.from && last.to+last.count == step.to {
+				last.count += step.count
+				continue
+			}
+		}
+		p.steps = append(p.steps, step)
+	}
+	return plans
+}
+
+// apply runs the steps against the output buffer under a single lock,
+// filling obs with the resulting frame and changed channels when it is not nil
+func (p *outputPlan) apply(src *[512]byte, now time.Time, obs *observation) {
+	buf := p.buf
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+
+	var before [512]byte
+	if obs != nil {
+		before = buf.frame()
+	}
+
+	buf.lastInput = now
+	if buf.defaults != nil {
+		buf.live = true
+	}
+	for _, s := range p.steps {
+		if changed := s.apply(&buf.data, src); changed > 0 && s.usage != nil {
+			s.usage.changed.Add(uint64(changed))
+			s.usage.lastChange.Store(now.UnixNano())
+		}
+	}
+	buf.dirty = true
+	if obs != nil {
+		obs.frame = buf.frame()
+		obs.changed = diffMask(&before, &obs.frame)
+	}
+}
@@ -0,0 +1,67 @@
+package remap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Route is a single source channel to destination channel path (1-indexed channels)
+type Route struct {
+	From      config.Universe `json:"from"`
+	FromChan  int             `json:"from_channel"`
+	To        config.Universe `json:"to"`
+	ToChan    int             `json:"to_channel"`
+	Transform string          `json:"transform,omitempty"`
+}
+
+func (r Route) String() string {
+	if r.Transform != "" {
+		return fmt.Sprintf("%s:%d -> %s:%d [%s]", r.From, r.FromChan, r.To, r.ToChan, r.Transform)
+	}
+	return fmt.Sprintf("%s:%d -> %s:%d", r.From, r.FromChan, r.To, r.ToChan)
+}
+
+// Routes returns the fully expanded routing table, one entry per channel path
+func (e *Engine) Routes() []Route {
+	var result []Route
+	for _, m := range expand(e.allMappings()) {
+		if m.Transform != nil {
+			for d := 0; d < m.OutputCount(); d++ {
+				for _, s := range m.Transform.Sources(d) {
+					result = append(result, Route{
+						From:      m.From,
+						FromChan:  m.FromChan + s + 1,
+						To:        m.To,
+						ToChan:    m.ToChan + d + 1,
+						Transform: m.Transform.Name(),
+					})
+				}
+			}
+			continue
+		}
+		for i := 0; i < m.Count; i++ {
+			result = append(result, Route{
+				From:     m.From,
+				FromChan: m.FromChan + i + 1,
+				To:       m.To,
+				ToChan:   m.ToChan + i + 1,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.From != b.From {
+			return a.From.Compare(b.From) < 0
+		}
+		if a.FromChan != b.FromChan {
+			return a.FromChan < b.FromChan
+		}
+		if a.To != b.To {
+			return a.To.Compare(b.To) < 0
+		}
+		return a.ToChan < b.ToChan
+	})
+	return result
+}
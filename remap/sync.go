@@ -0,0 +1,45 @@
+package remap
+
+import (
+	"sync"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// syncBuffer holds ArtDmx frames from sources in synchronous mode until their
+// ArtSync arrives, latest frame per source universe, physical port and cast
+type syncBuffer struct {
+	mu     sync.Mutex
+	frames map[filterKey][512]byte
+	order  []filterKey // arrival order of the first frame of each key
+}
+
+// Buffer holds an ArtDmx frame from a source in synchronous mode; it is
+// remapped by the next Sync, replacing any frame buffered for the same universe,
+// physical port and cast
+func (e *Engine) Buffer(src config.Universe, physical uint8, cast config.Cast, srcData [512]byte) {
+	b := &e.synced
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.frames == nil {
+		b.frames = map[filterKey][512]byte{}
+	}
+	k := filterKey{src, int(physical), cast}
+	if _, ok := b.frames[k]; !ok {
+		b.order = append(b.order, k)
+	}
+	b.frames[k] = srcData
+}
+
+// Sync remaps every buffered frame, so the outputs they change become dirty together
+func (e *Engine) Sync() {
+	b := &e.synced
+	b.mu.Lock()
+	frames, order := b.frames, b.order
+	b.frames, b.order = nil, nil
+	b.mu.Unlock()
+
+	for _, k := range order {
+		e.RemapFiltered(k.u, k.physical, k.cast, frames[k])
+	}
+}
@@ -0,0 +1,53 @@
+package remap
+
+import "github.com/gopatchy/artmap/config"
+
+// Destination is a concrete address an output universe is sent to
+type Destination struct {
+	Kind    string `json:"kind"` // target, node, multicast or broadcast
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+	Healthy bool   `json:"healthy"`
+}
+
+// TraceHop is one path a source channel takes to an output channel
+type TraceHop struct {
+	Route
+	Delay        string        `json:"delay,omitempty"`
+	Parked       *byte         `json:"parked,omitempty"`
+	Destinations []Destination `json:"destinations"`
+}
+
+// Trace returns every route leaving channel ch (1-indexed) of universe u, noting
+// parked destination channels. Destinations are left for the caller to fill in.
+func (e *Engine) Trace(u config.Universe, ch int) []TraceHop {
+	delays := map[config.Universe]map[int]string{}
+	for _, m := range expand(e.allMappings()) {
+		if m.From != u || m.Delay <= 0 {
+			continue
+		}
+		if delays[m.To] == nil {
+			delays[m.To] = map[int]string{}
+		}
+		for d := 0; d < m.OutputCount(); d++ {
+			delays[m.To][m.ToChan+d+1] = m.Delay.String()
+		}
+	}
+
+	var result []TraceHop
+	for _, r := range e.Routes() {
+		if r.From != u || r.FromChan != ch {
+			continue
+		}
+		hop := TraceHop{Route: r, Delay: delays[r.To][r.ToChan]}
+		if buf, err := e.output(r.To); err == nil {
+			buf.mu.Lock()
+			if v, ok := buf.parked[r.ToChan-1]; ok {
+				hop.Parked = &v
+			}
+			buf.mu.Unlock()
+		}
+		result = append(result, hop)
+	}
+	return result
+}
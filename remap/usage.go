@@ -0,0 +1,59 @@
+package remap
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// mappingUsage counts what one config mapping has contributed since the engine was built
+type mappingUsage struct {
+	frames     atomic.Uint64
+	changed    atomic.Uint64
+	lastChange atomic.Int64 // unix nanoseconds
+}
+
+// MappingUsage reports how much a config mapping has been used, to find stale ones
+type MappingUsage struct {
+	Index      int        `json:"index"`
+	Frames     uint64     `json:"frames"`
+	Changed    uint64     `json:"changed_channels"`
+	LastChange *time.Time `json:"last_change,omitempty"`
+}
+
+func newUsage(mappings []config.NormalizedMapping) []mappingUsage {
+	n := 0
+	for _, m := range mappings {
+		n = max(n, m.Index+1)
+	}
+	return make([]mappingUsage, n)
+}
+
+// usersOf returns the distinct usage counters of mappings, in first-seen order
+func (e *Engine) usersOf(mappings []config.NormalizedMapping) []*mappingUsage {
+	var result []*mappingUsage
+	seen := map[int]bool{}
+	for _, m := range mappings {
+		if seen[m.Index] {
+			continue
+		}
+		seen[m.Index] = true
+		result = append(result, &e.usage[m.Index])
+	}
+	return result
+}
+
+// Usage returns per-mapping frame and changed-channel totals, indexed like the config's mappings
+func (e *Engine) Usage() []MappingUsage {
+	result := make([]MappingUsage, len(e.usage))
+	for i := range e.usage {
+		u := &e.usage[i]
+		result[i] = MappingUsage{Index: i, Frames: u.frames.Load(), Changed: u.changed.Load()}
+		if ns := u.lastChange.Load(); ns != 0 {
+			t := time.Unix(0, ns)
+			result[i].LastChange = &t
+		}
+	}
+	return result
+}
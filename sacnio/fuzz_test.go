@@ -0,0 +1,56 @@
+package sacnio
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSenderFromConn(t *testing.T) {
+	loopback := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp4", loopback)
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+	buf := make([]byte, 1024)
+
+	// The receiver stopping must not close the socket the sender writes on
+	recv, dst := listen(), listen()
+	s, err := NewSenderFromConn(recv, "test", "")
+	if err != nil {
+		t.Fatalf("NewSenderFromConn: %v", err)
+	}
+	recv.Close()
+	if err := s.SendDMXUnicast(dst.LocalAddr().(*net.UDPAddr), 1, make([]byte, 512)); err != nil {
+		t.Fatalf("send after receiver closed: %v", err)
+	}
+	_, src, err := dst.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if src.Port != recv.LocalAddr().(*net.UDPAddr).Port {
+		t.Errorf("sent from port %d, want the receiver's %d", src.Port, recv.LocalAddr().(*net.UDPAddr).Port)
+	}
+	s.Close()
+
+	// The sender closing must not close the receiver's socket
+	recv, peer := listen(), listen()
+	s, err = NewSenderFromConn(recv, "test", "")
+	if err != nil {
+		t.Fatalf("NewSenderFromConn: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := peer.WriteToUDP([]byte("ping"), recv.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n, _, err := recv.ReadFromUDP(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("receiver read after sender closed: %q %v", buf[:n], err)
+	}
+}
@@ -0,0 +1,306 @@
+package sacnio
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/datagram"
+	"github.com/gopatchy/artmap/input"
+	"github.com/gopatchy/multicast"
+	"github.com/gopatchy/sacn"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// rebindErrors consecutive read errors mean the socket is dead (interface
+	// bounce, address removed) rather than a transient failure
+	rebindErrors = 10
+	rebindDelay  = time.Second
+)
+
+type Receiver struct {
+	iface     *net.Interface
+	universes []uint16
+	sources   map[uint16][]net.IP
+	allowed   map[string]map[string]bool // group IP -> source IPs accepted, for source-specific universes
+	groups    map[string]bool            // group IPs of the joined universes
+	wanted    map[uint16]bool
+	filtered  func() // non-nil when packets for other universes are dropped unparsed
+	ignore    func(cid [16]byte) bool
+	onSize    func(src *net.UDPAddr, n int, fault datagram.Fault)
+	conn      atomic.Pointer[multicast.Conn]
+	handler   func(src *net.UDPAddr, pkt interface{})
+	emit      func(input.Frame)
+	tap       func(src, dst *net.UDPAddr, data []byte)
+	forced    atomic.Bool
+	onRebind  func(err error)
+	done      chan struct{}
+}
+
+// NewMultiUniverseReceiver joins the multicast group of each universe. A
+// universe with sources is joined per source (IGMPv3 source-specific
+// multicast) and multicast from any other source to its group is dropped,
+// since another socket's any-source join can still deliver it to this one.
+func NewMultiUniverseReceiver(iface *net.Interface, universes []uint16, sources map[uint16][]net.IP) (*Receiver, error) {
+	c, err := listen(iface, universes, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		iface:     iface,
+		universes: universes,
+		sources:   sources,
+		allowed:   map[string]map[string]bool{},
+		groups:    map[string]bool{},
+		wanted:    map[uint16]bool{},
+		done:      make(chan struct{}),
+	}
+	for _, u := range universes {
+		r.groups[sacn.MulticastAddr(u).IP.String()] = true
+		r.wanted[u] = true
+	}
+	for u, srcs := range sources {
+		group := sacn.MulticastAddr(u).IP.String()
+		r.allowed[group] = map[string]bool{}
+		for _, src := range srcs {
+			r.allowed[group][src.String()] = true
+		}
+	}
+	r.conn.Store(c)
+	return r, nil
+}
+
+func listen(iface *net.Interface, universes []uint16, sources map[uint16][]net.IP) (*multicast.Conn, error) {
+	c, err := multicast.ListenMulticastUDPPort("udp4", iface, sacn.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range universes {
+		group := sacn.MulticastAddr(u)
+		if len(sources[u]) == 0 {
+			err = c.JoinGroup(group)
+		}
+		// Joined on the socket directly: the library re-advertises its groups
+		// with IGMPv2 reports, which would turn these into any-source joins
+		for _, src := range sources[u] {
+			if err = c.JoinSourceSpecificGroup(iface, group, &net.UDPAddr{IP: src}); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	// Report the destination group so taps see where each packet was sent
+	c.SetControlMessage(ipv4.FlagDst, true)
+	return c, nil
+}
+
+func (r *Receiver) SetHandler(fn func(src *net.UDPAddr, pkt interface{})) {
+	r.handler = fn
+}
+
+// SetTap registers a function called with every raw packet received; it must
+// be set before Start
+func (r *Receiver) SetTap(fn func(src, dst *net.UDPAddr, data []byte)) {
+	r.tap = fn
+}
+
+// SetOnSize registers a function called with each datagram dropped for being
+// larger or smaller than any E1.31 packet; it must be set before Start
+func (r *Receiver) SetOnSize(fn func(src *net.UDPAddr, n int, fault datagram.Fault)) {
+	r.onSize = fn
+}
+
+// SetOnRebind registers a function called after the socket is re-created and
+// its groups re-joined following persistent read errors; it must be set before Start
+func (r *Receiver) SetOnRebind(fn func(err error)) {
+	r.onRebind = fn
+}
+
+// SetGroupFilter drops packets for universes the receiver did not join before
+// they are tapped or parsed: multicast by the destination group of the
+// packet, unicast by the universe in its framing layer. The socket is bound
+// to all of 5568, so without it every group joined by any socket on the host
+// is parsed here. onFiltered is called for each dropped packet; it must be
+// set before Start.
+func (r *Receiver) SetGroupFilter(onFiltered func()) {
+	r.filtered = onFiltered
+}
+
+// framingUniverse is the offset of the universe in an E1.31 data packet
+const framingUniverse = 113
+
+// want reports whether a packet sent to dst is for one of the joined universes
+func (r *Receiver) want(dst net.IP, data []byte) bool {
+	if dst != nil && dst.IsMulticast() {
+		return r.groups[dst.String()]
+	}
+	if len(data) < framingUniverse+2 {
+		return false
+	}
+	return r.wanted[binary.BigEndian.Uint16(data[framingUniverse:])]
+}
+
+// SetIgnore registers a function deciding which source CIDs to drop, such as
+// our own multicast looped back; it must be set before Start
+func (r *Receiver) SetIgnore(fn func(cid [16]byte) bool) {
+	r.ignore = fn
+}
+
+func (r *Receiver) UDPConn() *net.UDPConn {
+	conn, _ := r.conn.Load().RawConn().(*net.UDPConn)
+	return conn
+}
+
+func (r *Receiver) Start() {
+	go r.receiveLoop()
+}
+
+// Run makes the receiver an input.Source: it starts it, emitting sACN data
+// frames instead of passing them to the handler, and stops it when ctx is done
+func (r *Receiver) Run(ctx context.Context, emit func(input.Frame)) error {
+	r.emit = emit
+	r.Start()
+	<-ctx.Done()
+	r.Stop()
+	return nil
+}
+
+func (r *Receiver) Stop() {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	r.conn.Load().Close()
+}
+
+// Rebind re-creates the socket, e.g. after the address it is bound to has changed
+func (r *Receiver) Rebind() {
+	r.forced.Store(true)
+	r.conn.Load().Close()
+}
+
+func (r *Receiver) receiveLoop() {
+	buf := datagram.Buffer(datagram.SACNMax)
+	errors := 0
+
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		conn := r.conn.Load()
+		conn.RawConn().SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, cm, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			errors++
+			if errors >= rebindErrors || r.forced.Swap(false) {
+				r.rebind(err)
+				errors = 0
+			}
+			continue
+		}
+		errors = 0
+
+		if cm != nil && len(r.allowed) > 0 {
+			if allowed := r.allowed[cm.Dst.String()]; allowed != nil && !allowed[src.(*net.UDPAddr).IP.String()] {
+				continue
+			}
+		}
+
+		if r.filtered != nil {
+			var dst net.IP
+			if cm != nil {
+				dst = cm.Dst
+			}
+			if !r.want(dst, buf[:n]) {
+				r.filtered()
+				continue
+			}
+		}
+
+		if r.tap != nil {
+			dst := &net.UDPAddr{Port: sacn.Port}
+			if cm != nil {
+				dst.IP = cm.Dst
+			}
+			r.tap(src.(*net.UDPAddr), dst, buf[:n])
+		}
+		if fault := datagram.Check(n, datagram.SACNMin, datagram.SACNMax); fault != "" {
+			if r.onSize != nil {
+				r.onSize(src.(*net.UDPAddr), n, fault)
+			}
+			continue
+		}
+
+		pkt, err := sacn.ParsePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		if data, ok := pkt.(*sacn.DataPacket); ok && r.ignore != nil && r.ignore(data.CID) {
+			continue
+		}
+
+		if data, ok := pkt.(*sacn.DataPacket); ok && r.emit != nil {
+			var dst net.IP
+			if cm != nil {
+				dst = cm.Dst
+			}
+			r.emit(input.Frame{Universe: config.SACNUniverse(data.Universe), Src: src.(*net.UDPAddr), Physical: input.NoPhysical,
+				Cast: input.CastOf(dst), Sequence: data.Sequence, Length: data.DataLen, Data: data.Data})
+			continue
+		}
+		if r.handler != nil {
+			r.handler(src.(*net.UDPAddr), pkt)
+		}
+	}
+}
+
+// rebind replaces the socket and re-joins the universe groups, retrying until
+// it succeeds or the receiver stops
+func (r *Receiver) rebind(cause error) {
+	r.conn.Load().Close()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-time.After(rebindDelay):
+		}
+		c, err := listen(r.iface, r.universes, r.sources)
+		if err != nil {
+			continue
+		}
+		r.conn.Store(c)
+		// Stop may have closed the old socket while this one was being bound
+		select {
+		case <-r.done:
+			c.Close()
+			return
+		default:
+		}
+		if r.onRebind != nil {
+			r.onRebind(cause)
+		}
+		return
+	}
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package sacnio
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// reusePort lets the sender bind 5568 alongside the receiver; on Windows SO_REUSEADDR alone does
+func reusePort(network, address string, c syscall.RawConn) error {
+	var err error
+	c.Control(func(fd uintptr) {
+		err = windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_REUSEADDR, 1)
+	})
+	return err
+}
+
+// ownConn binds a socket of the sender's own to the receiver's address, as
+// Windows can't duplicate a socket through a file; each side closes only its own
+func ownConn(conn *net.UDPConn) (*net.UDPConn, error) {
+	lc := net.ListenConfig{Control: reusePort}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	return pc.(*net.UDPConn), nil
+}
@@ -0,0 +1,248 @@
+// Package sacnio owns artmap's E1.31 sockets: binding, multicast joins,
+// filtering and rebinding. Every packet is encoded and parsed by
+// github.com/gopatchy/sacn.
+package sacnio
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/sacn"
+	"golang.org/x/net/ipv4"
+)
+
+// priorityOffset is the framing layer priority byte in an E1.31 data packet
+const priorityOffset = 108
+
+type Sender struct {
+	conn       atomic.Pointer[net.UDPConn]
+	connMu     sync.Mutex // serializes SetConn and Close, which close the replaced socket
+	ifaceName  string
+	sourceName string
+	cid        [16]byte
+	sequences  map[uint16]uint8
+	priorities map[uint16]uint8
+	seqMu      sync.Mutex
+	universes  map[uint16]bool
+	tap        func(src, dst *net.UDPAddr, data []byte)
+	done       chan struct{}
+	clock      clock.Clock
+}
+
+func NewSender(sourceName string, ifaceName string, port int) (*Sender, error) {
+	var lc net.ListenConfig
+	if port != 0 {
+		// Shared with the receiver, which binds the same port
+		lc.Control = reusePort
+	}
+
+	pc, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	conn := pc.(*net.UDPConn)
+
+	s, err := newSender(conn, sourceName, ifaceName)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSenderFromConn sends from the port of a receiver's socket. The sender gets
+// a socket of its own for it, so closing either side leaves the other working.
+func NewSenderFromConn(conn *net.UDPConn, sourceName string, ifaceName string) (*Sender, error) {
+	own, err := ownConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSender(own, sourceName, ifaceName)
+	if err != nil {
+		own.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSender makes a sender that owns and eventually closes conn
+func newSender(conn *net.UDPConn, sourceName string, ifaceName string) (*Sender, error) {
+	if err := setMulticastInterface(conn, ifaceName); err != nil {
+		return nil, err
+	}
+
+	var cid [16]byte
+	rand.Read(cid[:])
+
+	s := &Sender{
+		ifaceName:  ifaceName,
+		sourceName: sourceName,
+		cid:        cid,
+		sequences:  map[uint16]uint8{},
+		priorities: map[uint16]uint8{},
+		universes:  map[uint16]bool{},
+		done:       make(chan struct{}),
+		clock:      clock.Real,
+	}
+	s.conn.Store(conn)
+	return s, nil
+}
+
+// SetConn moves a sender made by NewSenderFromConn to the receiver's replacement
+// socket, closing its copy of the old one
+func (s *Sender) SetConn(conn *net.UDPConn) error {
+	own, err := ownConn(conn)
+	if err != nil {
+		return err
+	}
+	if err := setMulticastInterface(own, s.ifaceName); err != nil {
+		own.Close()
+		return err
+	}
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	select {
+	case <-s.done:
+		return own.Close()
+	default:
+	}
+	return s.conn.Swap(own).Close()
+}
+
+func setMulticastInterface(conn *net.UDPConn, ifaceName string) error {
+	if ifaceName == "" {
+		return nil
+	}
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return err
+	}
+	return ipv4.NewPacketConn(conn).SetMulticastInterface(iface)
+}
+
+// SetTap registers a function called with every raw packet sent
+func (s *Sender) SetTap(fn func(src, dst *net.UDPAddr, data []byte)) {
+	s.tap = fn
+}
+
+func (s *Sender) writeTo(pkt []byte, addr *net.UDPAddr) error {
+	conn := s.conn.Load()
+	if s.tap != nil {
+		s.tap(conn.LocalAddr().(*net.UDPAddr), addr, pkt)
+	}
+	_, err := conn.WriteToUDP(pkt, addr)
+	return err
+}
+
+func (s *Sender) CID() [16]byte {
+	return s.cid
+}
+
+func (s *Sender) LocalAddr() net.Addr {
+	return s.conn.Load().LocalAddr()
+}
+
+// SetPriority sets the priority sent for a universe instead of the default 100
+func (s *Sender) SetPriority(universe uint16, priority uint8) {
+	s.seqMu.Lock()
+	s.priorities[universe] = priority
+	s.seqMu.Unlock()
+}
+
+// buildDMX builds the next data packet for universe, with its sequence and priority
+func (s *Sender) buildDMX(universe uint16, data []byte) []byte {
+	s.seqMu.Lock()
+	seq := s.sequences[universe]
+	s.sequences[universe] = seq + 1
+	priority, ok := s.priorities[universe]
+	s.seqMu.Unlock()
+
+	pkt := sacn.BuildDataPacket(universe, seq, s.sourceName, s.cid, data)
+	if ok {
+		pkt[priorityOffset] = priority
+	}
+	return pkt
+}
+
+func (s *Sender) SendDMX(universe uint16, data []byte) error {
+	return s.writeTo(s.buildDMX(universe, data), sacn.MulticastAddr(universe))
+}
+
+func (s *Sender) SendDMXUnicast(addr *net.UDPAddr, universe uint16, data []byte) error {
+	return s.writeTo(s.buildDMX(universe, data), addr)
+}
+
+func (s *Sender) RegisterUniverse(universe uint16) {
+	s.seqMu.Lock()
+	s.universes[universe] = true
+	s.seqMu.Unlock()
+}
+
+// SetClock replaces the clock driving universe discovery keepalives; call before StartDiscovery
+func (s *Sender) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *Sender) StartDiscovery() {
+	go s.discoveryLoop()
+}
+
+func (s *Sender) Close() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+	return s.conn.Load().Close()
+}
+
+func (s *Sender) discoveryLoop() {
+	ticker := s.clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	s.sendDiscovery()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C():
+			s.sendDiscovery()
+		}
+	}
+}
+
+func (s *Sender) sendDiscovery() {
+	s.seqMu.Lock()
+	universes := make([]uint16, 0, len(s.universes))
+	for u := range s.universes {
+		universes = append(universes, u)
+	}
+	s.seqMu.Unlock()
+
+	if len(universes) == 0 {
+		return
+	}
+
+	sort.Slice(universes, func(i, j int) bool { return universes[i] < universes[j] })
+
+	const maxPerPage = 512
+	totalPages := (len(universes) + maxPerPage - 1) / maxPerPage
+
+	for page := 0; page < totalPages; page++ {
+		start := page * maxPerPage
+		end := min(start+maxPerPage, len(universes))
+		pkt := sacn.BuildDiscoveryPacket(s.sourceName, s.cid, uint8(page), uint8(totalPages-1), universes[start:end])
+		s.writeTo(pkt, sacn.DiscoveryAddr)
+	}
+}
@@ -0,0 +1,156 @@
+package selftest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopatchy/artmap/artnetio"
+	"github.com/gopatchy/artmap/sacnio"
+	"github.com/gopatchy/artnet"
+)
+
+// Plan is what a self-test checks; empty fields are skipped
+type Plan struct {
+	ArtNetListen  *net.UDPAddr   // ArtNet receive address; polls are sent and answered from it
+	SACNInterface *net.Interface // nil for the default multicast interface
+	SACNUniverses []uint16       // universes whose multicast groups are joined
+	SACNSources   map[uint16][]net.IP
+	Listen        []string       // TCP addresses, such as the API's
+	Domains       []*net.UDPAddr // routing domain listen addresses
+	Targets       []*net.UDPAddr // unicast destinations that must have a route
+	Poll          []*net.UDPAddr // where the ArtPoll goes
+	PollWait      time.Duration  // how long to collect ArtPollReplies
+}
+
+// Check is the result of one step
+type Check struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+// Report lists every check in the order it ran
+type Report struct {
+	Checks []Check
+}
+
+// Failed returns the number of failed checks
+func (r *Report) Failed() int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Write prints one line per check and a pass/fail summary
+func (r *Report) Write(w io.Writer) error {
+	for _, c := range r.Checks {
+		line := "ok   " + c.Name
+		if c.Err != nil {
+			line = fmt.Sprintf("FAIL %s: %v", c.Name, c.Err)
+		} else if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	result := "PASS"
+	if r.Failed() > 0 {
+		result = "FAIL"
+	}
+	_, err := fmt.Fprintf(w, "%s: %d checks, %d failed\n", result, len(r.Checks), r.Failed())
+	return err
+}
+
+// Run binds every socket of p, joins its multicast groups, finds a route to
+// every target and polls for nodes, closing everything afterwards. It sends
+// no DMX.
+func Run(p Plan) *Report {
+	r := &Report{}
+	add := func(name, detail string, err error) {
+		r.Checks = append(r.Checks, Check{Name: name, Detail: detail, Err: err})
+	}
+
+	var receiver *artnetio.Receiver
+	replies := &replyCounter{}
+	if p.ArtNetListen != nil {
+		var err error
+		receiver, err = artnetio.NewReceiver(p.ArtNetListen, replies)
+		add("artnet listen "+p.ArtNetListen.String(), "", err)
+		if receiver != nil {
+			receiver.Start()
+			defer receiver.Stop()
+		}
+	}
+
+	if len(p.SACNUniverses) > 0 {
+		rcv, err := sacnio.NewMultiUniverseReceiver(p.SACNInterface, p.SACNUniverses, p.SACNSources)
+		add("sacn join", fmt.Sprintf("%d universes", len(p.SACNUniverses)), err)
+		if rcv != nil {
+			rcv.Stop()
+		}
+	}
+
+	for _, addr := range p.Domains {
+		conn, err := net.ListenUDP("udp4", addr)
+		add("domain listen "+addr.String(), "", err)
+		if conn != nil {
+			conn.Close()
+		}
+	}
+
+	for _, addr := range p.Listen {
+		ln, err := net.Listen("tcp", addr)
+		add("listen "+addr, "", err)
+		if ln != nil {
+			ln.Close()
+		}
+	}
+
+	// A connected UDP socket sends nothing but needs a route to its peer
+	for _, addr := range p.Targets {
+		conn, err := net.DialUDP("udp4", nil, addr)
+		detail := ""
+		if conn != nil {
+			detail = "via " + conn.LocalAddr().(*net.UDPAddr).IP.String()
+			conn.Close()
+		}
+		add("route "+addr.String(), detail, err)
+	}
+
+	if receiver != nil && len(p.Poll) > 0 {
+		var err error
+		for _, addr := range p.Poll {
+			if err = receiver.SendTo(artnet.BuildPollPacket(), addr); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			time.Sleep(p.PollWait)
+			if replies.n.Load() == 0 {
+				err = fmt.Errorf("no ArtPollReply within %s", p.PollWait)
+			}
+		}
+		add("artnet poll", fmt.Sprintf("%d replies", replies.n.Load()), err)
+	}
+	return r
+}
+
+// replyCounter counts the ArtPollReplies answering the self-test's poll
+type replyCounter struct {
+	n atomic.Uint64
+}
+
+func (c *replyCounter) HandleDMX(*net.UDPAddr, *artnet.DMXPacket)   {}
+func (c *replyCounter) HandlePoll(*net.UDPAddr, *artnet.PollPacket) {}
+
+func (c *replyCounter) HandlePollReply(*net.UDPAddr, *artnet.PollReplyPacket) {
+	c.n.Add(1)
+}
@@ -2,6 +2,8 @@ package senders
 
 import (
 	"net"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,45 +13,181 @@ import (
 type SenderInfo struct {
 	Universe config.Universe `json:"universe"`
 	IP       string          `json:"ip"`
+	Cast     config.Cast     `json:"cast,omitempty"` // how its last frame was delivered
+}
+
+// Frame is the most recent frame one sender sent on a universe
+type Frame struct {
+	Universe config.Universe `json:"universe"`
+	IP       string          `json:"ip"`
+	Time     time.Time       `json:"time"`
+	Data     [512]byte       `json:"data"`
+}
+
+// Conflict reports two or more IPs feeding one input universe within the
+// conflict window being raised (Active) or cleared
+type Conflict struct {
+	Time     time.Time       `json:"time"`
+	Universe config.Universe `json:"universe"`
+	Sources  []string        `json:"sources"`
+	Active   bool            `json:"active"`
 }
 
 type senderKey struct {
-	protocol config.Protocol
-	universe uint16
-	ip       string
+	u  config.Universe
+	ip string
+}
+
+type senderEntry struct {
+	last time.Time
+	cast config.Cast
+	data [512]byte
 }
 
 type UniverseSenders struct {
-	mu      sync.Mutex
-	entries map[senderKey]time.Time
+	mu             sync.Mutex
+	entries        map[senderKey]*senderEntry
+	keepFrames     bool
+	conflictWindow time.Duration
+	conflicts      map[config.Universe]*Conflict
+	onConflict     func(Conflict)
 }
 
 func New() *UniverseSenders {
 	return &UniverseSenders{
-		entries: map[senderKey]time.Time{},
+		entries:   map[senderKey]*senderEntry{},
+		conflicts: map[config.Universe]*Conflict{},
 	}
 }
 
-func (s *UniverseSenders) Record(u config.Universe, ip net.IP) {
-	key := senderKey{
-		protocol: u.Protocol,
-		universe: u.Number,
-		ip:       ip.String(),
-	}
+// SetKeepFrames retains the last frame of every sender for Frames; call before Record
+func (s *UniverseSenders) SetKeepFrames(keep bool) {
+	s.keepFrames = keep
+}
+
+// KeepFrames reports whether frames are retained
+func (s *UniverseSenders) KeepFrames() bool {
+	return s.keepFrames
+}
+
+// SetConflictDetection raises a Conflict when different IPs send the same
+// universe within window (0 = off); call before Record
+func (s *UniverseSenders) SetConflictDetection(window time.Duration, fn func(Conflict)) {
+	s.conflictWindow = window
+	s.onConflict = fn
+}
+
+// Record notes a frame from ip on u, delivered by cast ("" if unknown)
+func (s *UniverseSenders) Record(u config.Universe, ip net.IP, cast config.Cast, data *[512]byte) {
+	key := senderKey{u: u, ip: ip.String()}
+	now := time.Now()
 	s.mu.Lock()
-	s.entries[key] = time.Now()
+	e := s.entries[key]
+	if e == nil {
+		e = &senderEntry{}
+		s.entries[key] = e
+	}
+	e.last = now
+	e.cast = cast
+	if s.keepFrames {
+		e.data = *data
+	}
+	var event *Conflict
+	if s.conflictWindow > 0 {
+		event = s.checkConflict(u, now)
+	}
 	s.mu.Unlock()
+
+	if event != nil && s.onConflict != nil {
+		s.onConflict(*event)
+	}
+}
+
+// checkConflict updates the conflict state of u and returns an event if it was
+// raised, changed sources or cleared. Called with mu held.
+func (s *UniverseSenders) checkConflict(u config.Universe, now time.Time) *Conflict {
+	cutoff := now.Add(-s.conflictWindow)
+	var sources []string
+	for k, e := range s.entries {
+		if k.u == u && !e.last.Before(cutoff) {
+			sources = append(sources, k.ip)
+		}
+	}
+	sort.Strings(sources)
+
+	c := s.conflicts[u]
+	switch {
+	case len(sources) > 1 && (c == nil || !slices.Equal(c.Sources, sources)):
+		c = &Conflict{Time: now, Universe: u, Sources: sources, Active: true}
+		s.conflicts[u] = c
+		event := *c
+		return &event
+	case len(sources) <= 1 && c != nil:
+		delete(s.conflicts, u)
+		return &Conflict{Time: now, Universe: u, Sources: c.Sources}
+	}
+	return nil
+}
+
+// Conflicts returns the active source conflicts sorted by universe
+func (s *UniverseSenders) Conflicts() []Conflict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Conflict, 0, len(s.conflicts))
+	for _, c := range s.conflicts {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Universe.Compare(result[j].Universe) < 0 })
+	return result
+}
+
+// Frames returns the last frame from each sender on u, sorted by IP
+func (s *UniverseSenders) Frames(u config.Universe) []Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Frame
+	for k, e := range s.entries {
+		if k.u == u {
+			result = append(result, Frame{Universe: u, IP: k.ip, Time: e.last, Data: e.data})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IP < result[j].IP })
+	return result
+}
+
+// Feeding reports whether ip sent on u within the last window
+func (s *UniverseSenders) Feeding(u config.Universe, ip net.IP, window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[senderKey{u: u, ip: ip.String()}]
+	return e != nil && time.Since(e.last) < window
 }
 
 func (s *UniverseSenders) Expire(maxAge time.Duration) {
-	cutoff := time.Now().Add(-maxAge)
+	now := time.Now()
+	cutoff := now.Add(-maxAge)
 	s.mu.Lock()
-	for k, t := range s.entries {
-		if t.Before(cutoff) {
+	for k, e := range s.entries {
+		if e.last.Before(cutoff) {
 			delete(s.entries, k)
 		}
 	}
+	// Conflicts whose sources went quiet are only noticed here, since nothing is recorded
+	var events []Conflict
+	for u := range s.conflicts {
+		if event := s.checkConflict(u, now); event != nil {
+			events = append(events, *event)
+		}
+	}
 	s.mu.Unlock()
+
+	if s.onConflict != nil {
+		for _, event := range events {
+			s.onConflict(event)
+		}
+	}
 }
 
 func (s *UniverseSenders) GetAll() []SenderInfo {
@@ -57,10 +195,11 @@ func (s *UniverseSenders) GetAll() []SenderInfo {
 	defer s.mu.Unlock()
 
 	result := make([]SenderInfo, 0, len(s.entries))
-	for k := range s.entries {
+	for k, e := range s.entries {
 		result = append(result, SenderInfo{
-			Universe: config.Universe{Protocol: k.protocol, Number: k.universe},
+			Universe: k.u,
 			IP:       k.ip,
+			Cast:     e.cast,
 		})
 	}
 	return result
@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"testing"
+)
+
+func FuzzParseLine(f *testing.F) {
+	f.Add("set artnet:0.0.1 ch 10 @ 255")
+	f.Add("park sacn:1 ch 1-8")
+	f.Add("release sacn:1")
+	f.Add("trace artnet:0.0.1:57")
+	f.Add("rdm discover artnet:0.0.1")
+	f.Add("profile show 2.5")
+	f.Add("snapshot save foo")
+	f.Add("snapshot list")
+	f.Add("parked")
+	f.Add("artcommand 10.0.0.5 SwoutText=Playback&")
+	f.Add("artcommand broadcast Message=hello world&")
+	f.Add("masters audience 128")
+	f.Add("masters grand 300")
+	f.Add("transmit off sacn")
+	f.Add("transmit on artnet:0.0.1")
+	f.Add("topology dot")
+	f.Add("standby go 1.5")
+	f.Add("set")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		req, err := parseLine(line)
+		if err != nil {
+			return
+		}
+		if req.method == "" || req.path == "" {
+			t.Fatalf("incomplete request for %q: %+v", line, req)
+		}
+	})
+}
@@ -0,0 +1,560 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopatchy/artmap/artcmd"
+	"github.com/gopatchy/artmap/output"
+	"github.com/gopatchy/artmap/profile"
+	"github.com/gopatchy/artmap/rdm"
+	"github.com/gopatchy/artmap/remap"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	// RDM discovery waits for node tables, then reads each device in turn
+	rdmTimeout = 2 * time.Minute
+)
+
+const help = `commands:
+  set <universe> ch <channels> @ <value>     write output channels until input overwrites them
+  park <universe> ch <channels> [@ <value>]  hold output channels (default: at their current value)
+  release <universe> [ch <channels>]         unpark channels (default: all in the universe)
+  parked                                     list parked channels
+  snapshot save|load|delete <name>           capture, restore or remove all outputs
+  snapshot list                              list snapshots
+  trace <universe>:<channel>                 show where an input channel is routed and sent
+  rdm discover <universe>                    list RDM devices behind the universe's nodes
+  profile [<name> [<fade seconds>]]          show or switch the active mapping profile
+  standby [go|back [<fade seconds>]]         show the preloaded standby config, or change over to it or back
+  masters [<group>|grand <level>]            show or set the grand and group master levels (0-255)
+  transmit [on|off <protocol>|<universe>]    show paused outputs, or pause or resume sending a protocol or universe
+  artcommand [<ip>|broadcast <text>]         list received ArtCommands or send one, e.g. SwoutText=Playback&
+  topology [json|dot]                        print the live routing graph, as JSON or Graphviz DOT
+  help                                       show this help
+  quit                                       leave the shell
+channels are 1-indexed, e.g. 10, 1-8 or 1-3,10`
+
+type channelRequest struct {
+	Action   string `json:"action"`
+	Universe string `json:"universe"`
+	Channels string `json:"channels,omitempty"`
+	Value    *int   `json:"value,omitempty"`
+}
+
+type profileRequest struct {
+	Name   string `json:"name"`
+	FadeMS int    `json:"fade_ms,omitempty"`
+}
+
+type standbyRequest struct {
+	Action string `json:"action"`
+	FadeMS int    `json:"fade_ms,omitempty"`
+}
+
+type masterRequest struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+type transmitRequest struct {
+	Target  string `json:"target"`
+	Enabled bool   `json:"enabled"`
+}
+
+type artCommandRequest struct {
+	Text   string `json:"text"`
+	Target string `json:"target,omitempty"`
+}
+
+type snapshotRequest struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+// request is one API call produced by a shell command
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	body    any
+	timeout time.Duration
+}
+
+// Run reads commands from in and executes them against the API at baseURL until EOF or quit.
+// A non-empty token is sent as a bearer token and tlsConfig is used for https URLs.
+func Run(baseURL, token string, tlsConfig *tls.Config, in io.Reader, out io.Writer) error {
+	client := newClient(tlsConfig)
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	scanner := bufio.NewScanner(in)
+
+	fmt.Fprintf(out, "connected to %s (type help for commands)\n", baseURL)
+	for {
+		fmt.Fprint(out, "artmap> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "help", "?":
+			fmt.Fprintln(out, help)
+			continue
+		case "quit", "exit":
+			return nil
+		}
+
+		req, err := parseLine(line)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if err := do(client, baseURL, token, req, out); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+}
+
+// Exec runs a single shell command, e.g. "trace artnet:0.0.1:57"
+func Exec(baseURL, token string, tlsConfig *tls.Config, line string, out io.Writer) error {
+	req, err := parseLine(line)
+	if err != nil {
+		return err
+	}
+	return do(newClient(tlsConfig), strings.TrimSuffix(baseURL, "/"), token, req, out)
+}
+
+func newClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+func parseLine(line string) (request, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return request{}, fmt.Errorf("empty command")
+	}
+	switch fields[0] {
+	case "set", "park", "release":
+		return parseChannelCommand(fields)
+	case "parked":
+		return request{method: http.MethodGet, path: "/artmap/api/channels"}, nil
+	case "trace":
+		if len(fields) != 2 {
+			return request{}, fmt.Errorf("usage: trace <universe>:<channel>")
+		}
+		return request{method: http.MethodGet, path: "/artmap/api/trace", query: url.Values{"channel": {fields[1]}}}, nil
+	case "rdm":
+		if len(fields) != 3 || fields[1] != "discover" {
+			return request{}, fmt.Errorf("usage: rdm discover <universe>")
+		}
+		return request{method: http.MethodPost, path: "/artmap/api/rdm", query: url.Values{"universe": {fields[2]}}, timeout: rdmTimeout}, nil
+	case "profile":
+		switch len(fields) {
+		case 1:
+			return request{method: http.MethodGet, path: "/artmap/api/profile"}, nil
+		case 2, 3:
+			req := profileRequest{Name: fields[1]}
+			if len(fields) == 3 {
+				secs, err := strconv.ParseFloat(fields[2], 64)
+				if err != nil || secs < 0 || secs > 600 {
+					return request{}, fmt.Errorf("invalid fade %q (0-600 seconds)", fields[2])
+				}
+				req.FadeMS = int(secs * 1000)
+			}
+			return request{method: http.MethodPost, path: "/artmap/api/profile", body: req}, nil
+		}
+		return request{}, fmt.Errorf("usage: profile [<name> [<fade seconds>]]")
+	case "standby":
+		if len(fields) == 1 {
+			return request{method: http.MethodGet, path: "/artmap/api/standby"}, nil
+		}
+		actions := map[string]string{"go": "activate", "back": "revert"}
+		if action, ok := actions[fields[1]]; ok && len(fields) <= 3 {
+			req := standbyRequest{Action: action}
+			if len(fields) == 3 {
+				secs, err := strconv.ParseFloat(fields[2], 64)
+				if err != nil || secs < 0 || secs > 600 {
+					return request{}, fmt.Errorf("invalid fade %q (0-600 seconds)", fields[2])
+				}
+				req.FadeMS = int(secs * 1000)
+			}
+			return request{method: http.MethodPost, path: "/artmap/api/standby", body: req}, nil
+		}
+		return request{}, fmt.Errorf("usage: standby [go|back [<fade seconds>]]")
+	case "masters":
+		switch len(fields) {
+		case 1:
+			return request{method: http.MethodGet, path: "/artmap/api/masters"}, nil
+		case 3:
+			level, err := strconv.Atoi(fields[2])
+			if err != nil || level < 0 || level > 255 {
+				return request{}, fmt.Errorf("invalid level %q (0-255)", fields[2])
+			}
+			return request{method: http.MethodPost, path: "/artmap/api/masters", body: masterRequest{Name: fields[1], Level: level}}, nil
+		}
+		return request{}, fmt.Errorf("usage: masters [<group>|grand <level>]")
+	case "transmit":
+		switch {
+		case len(fields) == 1:
+			return request{method: http.MethodGet, path: "/artmap/api/transmit"}, nil
+		case len(fields) == 3 && (fields[1] == "on" || fields[1] == "off"):
+			return request{method: http.MethodPost, path: "/artmap/api/transmit", body: transmitRequest{Target: fields[2], Enabled: fields[1] == "on"}}, nil
+		}
+		return request{}, fmt.Errorf("usage: transmit [on|off <protocol>|<universe>]")
+	case "artcommand":
+		if len(fields) == 1 {
+			return request{method: http.MethodGet, path: "/artmap/api/artcommand"}, nil
+		}
+		if len(fields) < 3 {
+			return request{}, fmt.Errorf("usage: artcommand [<ip>|broadcast <text>]")
+		}
+		req := artCommandRequest{Text: strings.Join(fields[2:], " ")}
+		if fields[1] != "broadcast" {
+			req.Target = fields[1]
+		}
+		return request{method: http.MethodPost, path: "/artmap/api/artcommand", body: req}, nil
+	case "topology":
+		switch {
+		case len(fields) == 1:
+			return request{method: http.MethodGet, path: "/artmap/api/topology"}, nil
+		case len(fields) == 2 && (fields[1] == "json" || fields[1] == "dot"):
+			return request{method: http.MethodGet, path: "/artmap/api/topology", query: url.Values{"format": {fields[1]}}}, nil
+		}
+		return request{}, fmt.Errorf("usage: topology [json|dot]")
+	case "snapshot":
+		if len(fields) == 2 && fields[1] == "list" {
+			return request{method: http.MethodGet, path: "/artmap/api/snapshots"}, nil
+		}
+		if len(fields) != 3 {
+			return request{}, fmt.Errorf("usage: snapshot save|load|delete <name>")
+		}
+		switch fields[1] {
+		case "save", "load", "delete":
+		default:
+			return request{}, fmt.Errorf("unknown snapshot action %q", fields[1])
+		}
+		return request{
+			method: http.MethodPost,
+			path:   "/artmap/api/snapshots",
+			body:   snapshotRequest{Action: fields[1], Name: fields[2]},
+		}, nil
+	}
+	return request{}, fmt.Errorf("unknown command %q (type help)", fields[0])
+}
+
+// parseChannelCommand parses "<action> <universe> [ch <channels>] [@ <value>]"
+func parseChannelCommand(fields []string) (request, error) {
+	action := fields[0]
+	usage := fmt.Errorf("usage: %s <universe> ch <channels> @ <value>", action)
+	if len(fields) < 2 {
+		return request{}, usage
+	}
+
+	req := channelRequest{Action: action, Universe: fields[1]}
+	rest := fields[2:]
+	if len(rest) >= 2 && (rest[0] == "ch" || rest[0] == "channel") {
+		req.Channels = rest[1]
+		rest = rest[2:]
+	}
+	if len(rest) == 2 && rest[0] == "@" {
+		v, err := strconv.Atoi(rest[1])
+		if err != nil {
+			return request{}, fmt.Errorf("invalid value %q", rest[1])
+		}
+		req.Value = &v
+		rest = nil
+	}
+	if len(rest) > 0 {
+		return request{}, usage
+	}
+
+	switch {
+	case action != "release" && req.Channels == "":
+		return request{}, usage
+	case action == "set" && req.Value == nil:
+		return request{}, usage
+	case action == "release" && req.Value != nil:
+		return request{}, fmt.Errorf("usage: release <universe> [ch <channels>]")
+	}
+
+	return request{method: http.MethodPost, path: "/artmap/api/channels", body: req}, nil
+}
+
+func do(client *http.Client, baseURL, token string, req request, out io.Writer) error {
+	var body io.Reader
+	if req.body != nil {
+		b, err := json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	target := baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(req.timeout, defaultTimeout))
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", strings.TrimSpace(string(respBody)))
+	}
+
+	switch req.path {
+	case "/artmap/api/channels":
+		return printParked(respBody, out)
+	case "/artmap/api/snapshots":
+		return printSnapshots(respBody, out)
+	case "/artmap/api/trace":
+		return printTrace(req.query.Get("channel"), respBody, out)
+	case "/artmap/api/rdm":
+		return printRDM(req.query.Get("universe"), respBody, out)
+	case "/artmap/api/profile":
+		return printProfile(respBody, out)
+	case "/artmap/api/masters":
+		return printMasters(respBody, out)
+	case "/artmap/api/transmit":
+		return printPaused(respBody, out)
+	case "/artmap/api/standby":
+		return printStandby(respBody, out)
+	case "/artmap/api/topology":
+		_, err := out.Write(respBody)
+		return err
+	case "/artmap/api/artcommand":
+		if req.method == http.MethodPost {
+			var sent struct{ Target string }
+			if err := json.Unmarshal(respBody, &sent); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "sent to %s\n", sent.Target)
+			return nil
+		}
+		return printArtCommands(respBody, out)
+	}
+	return nil
+}
+
+func printParked(body []byte, out io.Writer) error {
+	var parked []remap.ParkedChannel
+	if err := json.Unmarshal(body, &parked); err != nil {
+		return err
+	}
+	if len(parked) == 0 {
+		fmt.Fprintln(out, "no parked channels")
+		return nil
+	}
+	for _, p := range parked {
+		fmt.Fprintf(out, "parked %s ch %d @ %d\n", p.Universe, p.Channel, p.Value)
+	}
+	return nil
+}
+
+func printProfile(body []byte, out io.Writer) error {
+	var st profile.Status
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	if len(st.Profiles) <= 1 && st.Active == "" {
+		fmt.Fprintln(out, "no profiles configured")
+		return nil
+	}
+	for _, name := range st.Profiles {
+		// The unnamed profile of a config without profiles, next to a standby's
+		shown := cmp.Or(name, "(default)")
+		switch {
+		case name != st.Active:
+			fmt.Fprintf(out, "  %s\n", shown)
+		case st.Fading:
+			fmt.Fprintf(out, "* %s (fading in)\n", shown)
+		default:
+			fmt.Fprintf(out, "* %s\n", shown)
+		}
+	}
+	return nil
+}
+
+func printStandby(body []byte, out io.Writer) error {
+	var st struct {
+		Loaded   bool
+		Profile  string
+		Mappings int
+		Active   bool
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	switch {
+	case !st.Loaded:
+		fmt.Fprintln(out, "no standby config loaded")
+	case st.Active:
+		fmt.Fprintf(out, "on standby %s (%d mappings)\n", st.Profile, st.Mappings)
+	default:
+		fmt.Fprintf(out, "standby %s ready (%d mappings)\n", st.Profile, st.Mappings)
+	}
+	return nil
+}
+
+func printMasters(body []byte, out io.Writer) error {
+	var levels []remap.MasterLevel
+	if err := json.Unmarshal(body, &levels); err != nil {
+		return err
+	}
+	for _, l := range levels {
+		fmt.Fprintf(out, "%-12s %3d\n", l.Name, l.Level)
+	}
+	return nil
+}
+
+func printPaused(body []byte, out io.Writer) error {
+	var paused output.Paused
+	if err := json.Unmarshal(body, &paused); err != nil {
+		return err
+	}
+	if len(paused.Protocols) == 0 && len(paused.Universes) == 0 {
+		fmt.Fprintln(out, "transmitting everything")
+		return nil
+	}
+	for _, p := range paused.Protocols {
+		fmt.Fprintf(out, "paused %s\n", p)
+	}
+	for _, u := range paused.Universes {
+		fmt.Fprintf(out, "paused %s\n", u)
+	}
+	return nil
+}
+
+func printArtCommands(body []byte, out io.Writer) error {
+	var received []artcmd.Received
+	if err := json.Unmarshal(body, &received); err != nil {
+		return err
+	}
+	if len(received) == 0 {
+		fmt.Fprintln(out, "no artcommands received")
+		return nil
+	}
+	for _, r := range received {
+		fmt.Fprintf(out, "%s %s esta=%04X %q\n", r.Time.Format("15:04:05"), r.Source, r.ESTA, r.Text)
+	}
+	return nil
+}
+
+func printSnapshots(body []byte, out io.Writer) error {
+	var names []string
+	if err := json.Unmarshal(body, &names); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(out, "no snapshots")
+		return nil
+	}
+	fmt.Fprintln(out, "snapshots:", strings.Join(names, " "))
+	return nil
+}
+
+func printTrace(channel string, body []byte, out io.Writer) error {
+	var hops []remap.TraceHop
+	if err := json.Unmarshal(body, &hops); err != nil {
+		return err
+	}
+	if len(hops) == 0 {
+		fmt.Fprintf(out, "%s is not mapped\n", channel)
+		return nil
+	}
+	for _, h := range hops {
+		line := fmt.Sprintf("%s:%d -> %s:%d", h.From, h.FromChan, h.To, h.ToChan)
+		if h.Transform != "" {
+			line += " transform=" + h.Transform
+		}
+		if h.Delay != "" {
+			line += " delay=" + h.Delay
+		}
+		if h.Parked != nil {
+			line += fmt.Sprintf(" parked@%d", *h.Parked)
+		}
+		fmt.Fprintln(out, line)
+		if len(h.Destinations) == 0 {
+			fmt.Fprintln(out, "    (no destinations: no target or discovered node)")
+		}
+		for _, d := range h.Destinations {
+			line := fmt.Sprintf("    %s %s", d.Kind, d.Address)
+			if d.Name != "" {
+				line += " (" + d.Name + ")"
+			}
+			if !d.Healthy {
+				line += " unhealthy"
+			}
+			fmt.Fprintln(out, line)
+		}
+	}
+	return nil
+}
+
+func printRDM(universe string, body []byte, out io.Writer) error {
+	var devices []rdm.Device
+	if err := json.Unmarshal(body, &devices); err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Fprintf(out, "no RDM devices found on %s\n", universe)
+		return nil
+	}
+	for _, d := range devices {
+		line := fmt.Sprintf("%s node=%s", d.UID, d.Node)
+		if d.Error != "" {
+			fmt.Fprintf(out, "%s error=%s\n", line, d.Error)
+			continue
+		}
+		maker := fmt.Sprintf("0x%04X", d.ManufacturerID)
+		if d.ManufacturerLabel != "" {
+			maker = d.ManufacturerLabel + " (" + maker + ")"
+		}
+		model := fmt.Sprintf("0x%04X", d.ModelID)
+		if d.ModelDescription != "" {
+			model = d.ModelDescription + " (" + model + ")"
+		}
+		line += fmt.Sprintf(" %s %s address=%d footprint=%d", maker, model, d.StartAddress, d.Footprint)
+		if d.Label != "" {
+			line += fmt.Sprintf(" label=%q", d.Label)
+		}
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package simulate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gopatchy/artmap/capture"
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/coalesce"
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/input"
+	"github.com/gopatchy/artmap/monitor"
+	"github.com/gopatchy/artmap/profile"
+	"github.com/gopatchy/artmap/recording"
+	"github.com/gopatchy/artmap/remap"
+	"github.com/gopatchy/artnet"
+	"github.com/gopatchy/sacn"
+)
+
+// Tick is how often the simulation collects outputs between input frames,
+// matching the default --sender-hz
+const Tick = 25 * time.Millisecond
+
+// maxListed is the number of differing channels printed per universe
+const maxListed = 8
+
+// noPhysical is the physical port of input frames without one
+const noPhysical = -1
+
+// Input is one DMX input frame
+type Input struct {
+	Time     time.Time
+	Universe config.Universe
+	Source   net.IP
+	Physical int         // ArtDmx physical port, or -1
+	Cast     config.Cast // how the frame was delivered, "" if unknown
+	Length   int         // channels in the frame
+	Data     [512]byte
+}
+
+// Inputs yields input frames in time order, returning io.EOF after the last
+type Inputs interface {
+	Next() (Input, error)
+}
+
+// OpenInputs reads input frames from a pcap or pcapng capture, or from the
+// input frames of an artmap recording. Recordings do not keep the ArtDmx
+// physical port, so from_physical mappings only see captured frames.
+func OpenInputs(r *bufio.Reader) (Inputs, error) {
+	if hdr, _ := r.Peek(16); len(hdr) == 16 {
+		if _, err := recording.NewReader(bytes.NewReader(hdr)); err == nil {
+			rr, err := recording.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return recordingInputs{rr}, nil
+		}
+	}
+	cr, err := capture.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return captureInputs{cr}, nil
+}
+
+type recordingInputs struct {
+	r *recording.Reader
+}
+
+func (in recordingInputs) Next() (Input, error) {
+	for {
+		f, err := in.r.Next()
+		if err != nil {
+			return Input{}, err
+		}
+		if f.Direction != monitor.Input {
+			continue
+		}
+		i := Input{Time: f.Time, Universe: f.Universe, Source: f.Source, Physical: noPhysical, Length: len(f.Data)}
+		copy(i.Data[:], f.Data)
+		return i, nil
+	}
+}
+
+// captureInputs decodes ArtDmx and sACN data packets with the parsers artmap receives with
+type captureInputs struct {
+	r *capture.Reader
+}
+
+func (in captureInputs) Next() (Input, error) {
+	for {
+		p, err := in.r.Next()
+		if err != nil {
+			return Input{}, err
+		}
+		if op, pkt, err := artnet.ParsePacket(p.Payload); err == nil {
+			if dmx, ok := pkt.(*artnet.DMXPacket); ok && op == artnet.OpDmx {
+				return Input{Time: p.Time, Universe: config.ArtNetUniverse(dmx.Universe), Source: p.Src.IP,
+					Physical: int(dmx.Physical), Cast: castOf(p), Length: int(min(dmx.Length, 512)), Data: dmx.Data}, nil
+			}
+			continue
+		}
+		if pkt, err := sacn.ParsePacket(p.Payload); err == nil {
+			if data, ok := pkt.(*sacn.DataPacket); ok {
+				return Input{Time: p.Time, Universe: config.SACNUniverse(data.Universe), Source: p.Src.IP,
+					Physical: noPhysical, Cast: castOf(p), Length: data.DataLen, Data: data.Data}, nil
+			}
+		}
+	}
+}
+
+// castOf tells how a captured packet was delivered, trusting the link layer
+// for broadcasts to subnets the host running the simulation is not on
+func castOf(p capture.Packet) config.Cast {
+	if p.Broadcast {
+		return config.CastBroadcast
+	}
+	return input.CastOf(p.Dst.IP)
+}
+
+type frame struct {
+	time time.Time
+	data [512]byte
+}
+
+// Simulator runs a config's engines offline on a clock driven by the input
+// timestamps, keeping every output frame
+type Simulator struct {
+	cfg      *config.Config
+	profiles *profile.Switcher
+	coalesce *coalesce.Coalescer
+	mirrors  map[config.Universe]config.Universe
+	clock    *clock.Fake
+	begin    time.Time
+	outputs  map[config.Universe][]frame
+	inputs   int
+}
+
+func New(cfg *config.Config) *Simulator {
+	return &Simulator{
+		cfg:      cfg,
+		coalesce: coalesce.New(cfg.HeldShortFrames()),
+		mirrors:  cfg.Mirrors(),
+		outputs:  map[config.Universe][]frame{},
+	}
+}
+
+// Run feeds every input frame to the engines, then runs on for
+// DataLossTimeout so delayed frames and data-loss defaults come out too
+func (s *Simulator) Run(in Inputs) error {
+	for {
+		i, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if s.clock == nil {
+			s.start(i.Time)
+		}
+		s.advance(i.Time)
+		// Recorded frames are kept after coalescing, so only captured ArtDmx is filled
+		if i.Universe.Protocol == config.ProtocolArtNet && i.Physical != noPhysical {
+			s.coalesce.Fill(i.Universe, i.Source, uint8(i.Physical), i.Length, &i.Data)
+		}
+		s.profiles.RemapFiltered(i.Universe, i.Physical, i.Cast, i.Data)
+		s.inputs++
+		s.collect()
+	}
+	if s.clock != nil {
+		s.advance(s.clock.Now().Add(remap.DataLossTimeout + Tick))
+	}
+	return nil
+}
+
+func (s *Simulator) start(t time.Time) {
+	s.clock, s.begin = clock.NewFake(t), t
+	s.profiles = profile.New(s.cfg)
+	s.profiles.SetClock(s.clock)
+	for _, st := range s.cfg.Statics {
+		data, _ := st.Data()
+		s.profiles.Remap(st.Universe, data)
+	}
+	s.collect()
+}
+
+// advance moves the clock to t a tick at a time, collecting outputs after each
+func (s *Simulator) advance(t time.Time) {
+	for d := t.Sub(s.clock.Now()); d > 0; d = t.Sub(s.clock.Now()) {
+		s.clock.Advance(min(d, Tick))
+		s.collect()
+	}
+}
+
+func (s *Simulator) collect() {
+	now := s.clock.Now()
+	for _, out := range s.profiles.GetDirtyOutputs() {
+		s.outputs[out.Universe] = append(s.outputs[out.Universe], frame{now, out.Data})
+		if mirror, ok := s.mirrors[out.Universe]; ok {
+			s.outputs[mirror] = append(s.outputs[mirror], frame{now, out.Data})
+		}
+	}
+}
+
+// ChannelDiff is one channel that differs between a golden and a simulated frame
+type ChannelDiff struct {
+	Channel   int // 1-indexed
+	Golden    byte
+	Simulated byte
+}
+
+// UniverseDiff compares the golden output frames of one universe with the simulation
+type UniverseDiff struct {
+	Universe   config.Universe
+	Golden     int // golden frames compared
+	Mismatched int
+	First      time.Time     // time of the first mismatched golden frame
+	Channels   []ChannelDiff // differences at the first mismatch
+	Missing    bool          // the simulation never output the universe
+	Extra      bool          // the simulation output a universe missing from the golden recording
+}
+
+func (d *UniverseDiff) Differs() bool {
+	return d.Mismatched > 0 || d.Missing || d.Extra
+}
+
+// Report is the result of comparing a simulation with a golden recording
+type Report struct {
+	Start     time.Time
+	Inputs    int
+	Universes []*UniverseDiff // sorted by universe
+}
+
+// Differing returns the number of universes that differ
+func (r *Report) Differing() int {
+	n := 0
+	for _, d := range r.Universes {
+		if d.Differs() {
+			n++
+		}
+	}
+	return n
+}
+
+// Compare checks each output frame of the golden recording against the
+// simulated output of its universe. Live and simulated timing never line up
+// exactly, so a golden frame matches if the simulation held the same frame at
+// any point within tolerance of it. Golden channels past a short frame are not compared.
+func (s *Simulator) Compare(golden *recording.Reader, tolerance time.Duration) (*Report, error) {
+	rep := &Report{Start: s.begin, Inputs: s.inputs}
+	diffs := map[config.Universe]*UniverseDiff{}
+	for {
+		f, err := golden.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Direction != monitor.Output {
+			continue
+		}
+		if rep.Start.IsZero() || f.Time.Before(rep.Start) {
+			rep.Start = f.Time
+		}
+		d := diffs[f.Universe]
+		if d == nil {
+			d = &UniverseDiff{Universe: f.Universe}
+			diffs[f.Universe] = d
+		}
+		d.Golden++
+		frames := s.outputs[f.Universe]
+		if len(frames) == 0 {
+			d.Missing = true
+			continue
+		}
+		held := held(frames, f.Time, tolerance)
+		if slices.ContainsFunc(held, func(sim [512]byte) bool { return bytes.Equal(sim[:len(f.Data)], f.Data) }) {
+			continue
+		}
+		d.Mismatched++
+		if d.Mismatched == 1 {
+			d.First = f.Time
+			sim := held[len(held)-1]
+			for ch, v := range f.Data {
+				if sim[ch] != v {
+					d.Channels = append(d.Channels, ChannelDiff{Channel: ch + 1, Golden: v, Simulated: sim[ch]})
+				}
+			}
+		}
+	}
+	for u := range s.outputs {
+		if diffs[u] == nil {
+			diffs[u] = &UniverseDiff{Universe: u, Extra: true}
+		}
+	}
+
+	for _, d := range diffs {
+		rep.Universes = append(rep.Universes, d)
+	}
+	sort.Slice(rep.Universes, func(i, j int) bool { return rep.Universes[i].Universe.Compare(rep.Universes[j].Universe) < 0 })
+	return rep, nil
+}
+
+// held returns the simulated frames of a universe output between t-tolerance
+// and t+tolerance, including the one already output at t-tolerance; an
+// all-zero frame stands in for the state before the first output
+func held(frames []frame, t time.Time, tolerance time.Duration) [][512]byte {
+	from := sort.Search(len(frames), func(i int) bool { return frames[i].time.After(t.Add(-tolerance)) })
+	to := sort.Search(len(frames), func(i int) bool { return frames[i].time.After(t.Add(tolerance)) })
+	var result [][512]byte
+	if from == 0 {
+		result = append(result, [512]byte{})
+	} else {
+		from--
+	}
+	for _, f := range frames[from:to] {
+		result = append(result, f.data)
+	}
+	return result
+}
+
+// Write prints one line per universe, listing the differing channels of the first mismatch
+func (r *Report) Write(w io.Writer) error {
+	fmt.Fprintf(w, "%d input frames, %d of %d universes differ\n", r.Inputs, r.Differing(), len(r.Universes))
+	for _, d := range r.Universes {
+		var line string
+		switch {
+		case d.Missing:
+			line = fmt.Sprintf("missing: %d golden frames but never output by the simulation", d.Golden)
+		case d.Extra:
+			line = "extra: output by the simulation but not in the golden recording"
+		case d.Mismatched == 0:
+			line = fmt.Sprintf("ok: %d golden frames match", d.Golden)
+		default:
+			line = fmt.Sprintf("differs: %d of %d golden frames, first at +%s:", d.Mismatched, d.Golden, d.First.Sub(r.Start).Round(time.Millisecond))
+			var chans []string
+			for _, c := range d.Channels[:min(len(d.Channels), maxListed)] {
+				chans = append(chans, fmt.Sprintf("ch %d golden=%d simulated=%d", c.Channel, c.Golden, c.Simulated))
+			}
+			if n := len(d.Channels) - maxListed; n > 0 {
+				chans = append(chans, fmt.Sprintf("%d more", n))
+			}
+			line += " " + strings.Join(chans, ", ")
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", d.Universe, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package snapshot
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gopatchy/artmap/config"
+)
+
+// Store keeps named captures of the output universes for later recall
+type Store struct {
+	mu    sync.Mutex
+	snaps map[string]map[config.Universe][512]byte
+}
+
+func New() *Store {
+	return &Store{
+		snaps: map[string]map[config.Universe][512]byte{},
+	}
+}
+
+func (s *Store) Save(name string, frames map[config.Universe][512]byte) {
+	s.mu.Lock()
+	s.snaps[name] = frames
+	s.mu.Unlock()
+}
+
+func (s *Store) Get(name string) (map[config.Universe][512]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frames, ok := s.snaps[name]
+	return frames, ok
+}
+
+func (s *Store) Delete(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.snaps[name]
+	delete(s.snaps, name)
+	return ok
+}
+
+func (s *Store) Names() []string {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.snaps))
+	for name := range s.snaps {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
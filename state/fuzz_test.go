@@ -0,0 +1,55 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "state.json"))
+
+	st, err := f.Load()
+	if err != nil || !reflect.DeepEqual(st, &State{}) {
+		t.Fatalf("load before save = %+v, %v; want empty state", st, err)
+	}
+
+	want := &State{
+		Profile: "show",
+		Masters: []Master{{Name: "grand", Level: 200}, {Name: "wash", Level: 0}},
+		Parked:  []Parked{{Universe: "artnet:0.0.1", Channel: 1, Value: 255}, {Universe: "sacn:7", Channel: 512, Value: 0}},
+	}
+	if err := f.Save(want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := f.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip = %+v, want %+v", got, want)
+	}
+
+	// A later save replaces the state and leaves no temporary file behind
+	if err := f.Save(&State{Profile: "rehearsal"}); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+	got, err = f.Load()
+	if err != nil || !reflect.DeepEqual(got, &State{Profile: "rehearsal"}) {
+		t.Fatalf("after second save = %+v, %v", got, err)
+	}
+	if _, err := os.Stat(f.Path() + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path).Load(); err == nil {
+		t.Fatalf("corrupt state loaded without error")
+	}
+}
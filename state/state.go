@@ -0,0 +1,96 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// State is the runtime overrides made through the API, kept so a restart does
+// not silently revert them
+type State struct {
+	Profile string   `json:"profile,omitempty"`
+	Masters []Master `json:"masters,omitempty"`
+	Parked  []Parked `json:"parked,omitempty"`
+}
+
+// Master is the level of the grand master or a group master
+type Master struct {
+	Name  string `json:"name"`
+	Level byte   `json:"level"`
+}
+
+// Parked is a parked output channel; the universe is kept as text so the file
+// survives a change of --universe-format
+type Parked struct {
+	Universe string `json:"universe"`
+	Channel  int    `json:"channel"` // 1-indexed
+	Value    byte   `json:"value"`
+}
+
+// File reads and writes the state at one path
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load reads the saved state, returning an empty state if none was saved yet
+func (f *File) Load() (*State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &State{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Save replaces the saved state, syncing a temporary file before renaming it
+// over the old one so a power loss leaves either the old or the new state
+func (f *File) Save(st *State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tmp := f.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,98 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Node kinds, in the order data flows through them
+const (
+	KindSource      = "source"      // a sender of input frames
+	KindInput       = "input"       // an input universe
+	KindMapping     = "mapping"     // a config mapping
+	KindOutput      = "output"      // an output universe
+	KindDestination = "destination" // a target, node, multicast group or broadcast address
+)
+
+type Node struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	Down  bool   `json:"down,omitempty"` // an unhealthy destination
+}
+
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the routing graph from senders to the destinations of each output
+// universe; nodes and edges keep the order they were first added
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	nodes map[string]bool
+	edges map[Edge]bool
+}
+
+func New() *Graph {
+	return &Graph{Nodes: []Node{}, Edges: []Edge{}, nodes: map[string]bool{}, edges: map[Edge]bool{}}
+}
+
+// AddNode adds a node unless one with id exists, and returns id
+func (g *Graph) AddNode(kind, id, label string, down bool) string {
+	if !g.nodes[id] {
+		g.nodes[id] = true
+		g.Nodes = append(g.Nodes, Node{ID: id, Kind: kind, Label: label, Down: down})
+	}
+	return id
+}
+
+// AddEdge adds an edge unless it exists
+func (g *Graph) AddEdge(from, to string) {
+	e := Edge{From: from, To: to}
+	if !g.edges[e] {
+		g.edges[e] = true
+		g.Edges = append(g.Edges, e)
+	}
+}
+
+var shapes = map[string]string{
+	KindSource:      "box",
+	KindInput:       "ellipse",
+	KindMapping:     "diamond",
+	KindOutput:      "ellipse",
+	KindDestination: "box",
+}
+
+// WriteDOT writes the graph in Graphviz DOT, left to right with one rank per kind
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph artmap {\n\trankdir=LR;\n")
+	for _, kind := range []string{KindSource, KindInput, KindMapping, KindOutput, KindDestination} {
+		fmt.Fprintf(&b, "\tsubgraph %s {\n\t\trank=same;\n", quote(kind))
+		for _, n := range g.Nodes {
+			if n.Kind != kind {
+				continue
+			}
+			style := ""
+			if n.Down {
+				style = ", style=dashed, color=red"
+			}
+			fmt.Fprintf(&b, "\t\t%s [label=%s, shape=%s%s];\n", quote(n.ID), quote(n.Label), shapes[kind], style)
+		}
+		b.WriteString("\t}\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s;\n", quote(e.From), quote(e.To))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote returns s as a DOT string; unlike %q it leaves non-ASCII as is
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
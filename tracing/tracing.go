@@ -0,0 +1,262 @@
+// Package tracing records artmap's own packet-path spans and posts them to a
+// collector's /v1/traces endpoint in the OTLP JSON encoding. It is a local
+// exporter, not the OpenTelemetry SDK: it neither reads nor sends W3C trace
+// context, so every trace starts in artmap and can't join a trace begun by a
+// controller or an API client.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindProducer SpanKind = 4
+	KindConsumer SpanKind = 5
+)
+
+const maxBatch = 512
+
+// Tracer samples root spans and posts finished spans to a collector
+type Tracer struct {
+	endpoint  string
+	service   string
+	threshold uint64
+	client    *http.Client
+	mu        sync.Mutex
+	batch     []*Span
+	dropped   uint64
+	done      chan struct{}
+}
+
+// Span is a timed operation; all methods are safe to call on a nil (unsampled) span
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+}
+
+// New creates a tracer exporting to endpoint (e.g. http://collector:4318),
+// sampling ratio (0-1) of root spans
+func New(endpoint, service string, ratio float64) *Tracer {
+	ratio = math.Max(0, math.Min(1, ratio))
+	t := &Tracer{
+		endpoint:  endpoint,
+		service:   service,
+		threshold: uint64(ratio * math.MaxUint64),
+		client:    &http.Client{Timeout: 5 * time.Second},
+		done:      make(chan struct{}),
+	}
+	if ratio >= 1 {
+		t.threshold = math.MaxUint64
+	}
+	return t
+}
+
+func (t *Tracer) Start() {
+	go t.exportLoop()
+}
+
+func (t *Tracer) Stop() {
+	close(t.done)
+	t.flush()
+}
+
+// StartSpan starts a sampled root span, or returns nil if the trace is not sampled
+func (t *Tracer) StartSpan(name string, kind SpanKind) *Span {
+	if t == nil {
+		return nil
+	}
+	// Decided before allocating, since most packets are not sampled
+	if t.threshold != math.MaxUint64 && mrand.Uint64() > t.threshold {
+		return nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	rand.Read(s.traceID[:])
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// Child starts a span under s in the same trace
+func (s *Span) Child(name string, kind SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+	c := &Span{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID, name: name, kind: kind, start: time.Now()}
+	rand.Read(c.spanID[:])
+	return c
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = map[string]any{}
+	}
+	s.attrs[key] = value
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.enqueue(s)
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.batch) >= maxBatch*4 {
+		t.dropped++
+		return
+	}
+	t.batch = append(t.batch, s)
+}
+
+func (t *Tracer) exportLoop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+func (t *Tracer) flush() {
+	t.mu.Lock()
+	spans := t.batch
+	t.batch = nil
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("[tracing] dropped %d spans (export backlog full)", dropped)
+	}
+
+	for len(spans) > 0 {
+		n := min(len(spans), maxBatch)
+		if err := t.export(spans[:n]); err != nil {
+			log.Printf("[tracing] export error: endpoint=%s err=%v", t.endpoint, err)
+			return
+		}
+		spans = spans[n:]
+	}
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint+"/v1/traces", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         SpanKind   `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+}
+
+func (t *Tracer) encode(spans []*Span) any {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		o := otlpSpan{
+			TraceID: hex.EncodeToString(s.traceID[:]),
+			SpanID:  hex.EncodeToString(s.spanID[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for k, v := range s.attrs {
+			o.Attributes = append(o.Attributes, attr(k, v))
+		}
+		out[i] = o
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttr{attr("service.name", t.service)},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "artmap"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func attr(key string, value any) otlpAttr {
+	var v otlpValue
+	switch x := value.(type) {
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case uint16:
+		s := strconv.Itoa(int(x))
+		v.IntValue = &s
+	case string:
+		v.StringValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return otlpAttr{Key: key, Value: v}
+}
@@ -0,0 +1,104 @@
+package traffic
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DestStats is the traffic sent to one destination address
+type DestStats struct {
+	Addr    string `json:"addr"`
+	Node    string `json:"node,omitempty"` // short name of the discovered node at the address
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+type counter struct {
+	packets atomic.Uint64
+	bytes   atomic.Uint64
+}
+
+// Counter counts packets and bytes sent per destination. Uneven counts across
+// nodes that should carry the same load usually point at a mapping mistake.
+type Counter struct {
+	mu    sync.RWMutex
+	dests map[netip.AddrPort]*counter
+}
+
+func New() *Counter {
+	return &Counter{dests: map[netip.AddrPort]*counter{}}
+}
+
+// Packet counts one packet of data sent to dst; its signature matches the sender taps
+func (c *Counter) Packet(_, dst *net.UDPAddr, data []byte) {
+	key := dst.AddrPort()
+	key = netip.AddrPortFrom(key.Addr().Unmap(), key.Port())
+
+	c.mu.RLock()
+	d := c.dests[key]
+	c.mu.RUnlock()
+	if d == nil {
+		c.mu.Lock()
+		if d = c.dests[key]; d == nil {
+			d = &counter{}
+			c.dests[key] = d
+		}
+		c.mu.Unlock()
+	}
+	d.packets.Add(1)
+	d.bytes.Add(uint64(len(data)))
+}
+
+// Stats returns the counts of every destination sorted by address
+func (c *Counter) Stats() []DestStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]netip.AddrPort, 0, len(c.dests))
+	for key := range c.dests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Compare(keys[j]) < 0 })
+	result := make([]DestStats, 0, len(keys))
+	for _, key := range keys {
+		d := c.dests[key]
+		result = append(result, DestStats{Addr: key.String(), Packets: d.packets.Load(), Bytes: d.bytes.Load()})
+	}
+	return result
+}
+
+// Report summarizes the counts in a line short enough for an ArtPollReply
+// NodeReport: the number of destinations, total packets and bytes, and how many
+// times more packets the busiest destination got than the quietest
+func (c *Counter) Report() string {
+	stats := c.Stats()
+	if len(stats) == 0 {
+		return "tx idle"
+	}
+	var packets, bytes, lo, hi uint64
+	for i, s := range stats {
+		packets += s.Packets
+		bytes += s.Bytes
+		if i == 0 || s.Packets < lo {
+			lo = s.Packets
+		}
+		hi = max(hi, s.Packets)
+	}
+	return fmt.Sprintf("tx %d dst %s pkt %sB spread %.1fx", len(stats), short(packets), short(bytes), float64(hi)/float64(max(lo, 1)))
+}
+
+// short formats n with a k, M or G suffix
+func short(n uint64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fG", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,61 @@
+package transform
+
+import (
+	"sort"
+	"strings"
+)
+
+// Chain applies transforms in order, feeding each output to the next input
+type Chain []Transform
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, t := range c {
+		names[i] = t.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (c Chain) InputCount() int {
+	return c[0].InputCount()
+}
+
+func (c Chain) OutputCount() int {
+	return c[len(c)-1].OutputCount()
+}
+
+func (c Chain) Apply(dst, src []byte) {
+	var a, b [512]byte
+	in := src
+	for i, t := range c {
+		if i == len(c)-1 {
+			t.Apply(dst, in)
+			return
+		}
+		out := a[:t.OutputCount()]
+		if i%2 == 1 {
+			out = b[:t.OutputCount()]
+		}
+		t.Apply(out, in)
+		in = out
+	}
+}
+
+func (c Chain) Sources(d int) []int {
+	offsets := []int{d}
+	for i := len(c) - 1; i >= 0; i-- {
+		seen := map[int]bool{}
+		var next []int
+		for _, o := range offsets {
+			for _, s := range c[i].Sources(o) {
+				if !seen[s] {
+					seen[s] = true
+					next = append(next, s)
+				}
+			}
+		}
+		offsets = next
+	}
+	sort.Ints(offsets)
+	return offsets
+}
@@ -0,0 +1,74 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+)
+
+type CurveMode string
+
+const (
+	CurveSquare CurveMode = "square"
+	CurveSqrt   CurveMode = "sqrt"
+	CurveGamma  CurveMode = "gamma"
+	CurveInvert CurveMode = "invert"
+)
+
+// Curve maps each channel's level through a response curve, such as gamma
+// correction for LED fixtures that look too bright at low levels
+type Curve struct {
+	mode     CurveMode
+	channels int
+	table    [256]byte
+}
+
+// NewCurve creates a curve over channels channels. gamma is the exponent and
+// only used in gamma mode.
+func NewCurve(mode CurveMode, channels int, gamma float64) (*Curve, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("curve channel count must be positive")
+	}
+	var f func(x float64) float64
+	switch mode {
+	case CurveSquare:
+		f = func(x float64) float64 { return x * x }
+	case CurveSqrt:
+		f = math.Sqrt
+	case CurveGamma:
+		if !(gamma > 0 && gamma <= 10) {
+			return nil, fmt.Errorf("gamma must be above 0 and at most 10")
+		}
+		f = func(x float64) float64 { return math.Pow(x, gamma) }
+	case CurveInvert:
+		f = func(x float64) float64 { return 1 - x }
+	default:
+		return nil, fmt.Errorf("unknown curve %q (expected square, sqrt, gamma or invert)", mode)
+	}
+	c := &Curve{mode: mode, channels: channels}
+	for v := range c.table {
+		c.table[v] = byte(math.Round(255 * f(float64(v)/255)))
+	}
+	return c, nil
+}
+
+func (c *Curve) Name() string {
+	return fmt.Sprintf("curve-%s", c.mode)
+}
+
+func (c *Curve) InputCount() int {
+	return c.channels
+}
+
+func (c *Curve) OutputCount() int {
+	return c.channels
+}
+
+func (c *Curve) Apply(dst, src []byte) {
+	for i, v := range src[:c.channels] {
+		dst[i] = c.table[v]
+	}
+}
+
+func (c *Curve) Sources(d int) []int {
+	return []int{d}
+}
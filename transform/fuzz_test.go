@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"testing"
+)
+
+func FuzzResample(f *testing.F) {
+	f.Add(true, 60, 90, 3, make([]byte, 180))
+	f.Add(false, 90, 60, 3, make([]byte, 270))
+	f.Add(true, 1, 10, 3, []byte{1, 2, 3})
+	f.Add(true, 10, 1, 1, make([]byte, 10))
+	f.Add(false, 5, 5, 4, make([]byte, 20))
+
+	f.Fuzz(func(t *testing.T, linear bool, srcPixels, dstPixels, pixelSize int, src []byte) {
+		if srcPixels < 1 || dstPixels < 1 || pixelSize < 1 || pixelSize > 8 {
+			return
+		}
+		if srcPixels*pixelSize > 512 || dstPixels*pixelSize > 512 || len(src) < srcPixels*pixelSize {
+			return
+		}
+		mode := ResampleNearest
+		if linear {
+			mode = ResampleLinear
+		}
+		r, err := NewResample(mode, srcPixels, dstPixels, pixelSize)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		dst := make([]byte, r.OutputCount())
+		r.Apply(dst, src[:r.InputCount()])
+
+		for d := range dst {
+			srcs := r.Sources(d)
+			if len(srcs) == 0 {
+				t.Fatalf("dst %d has no sources", d)
+			}
+			lo, hi := byte(255), byte(0)
+			for _, s := range srcs {
+				if s < 0 || s >= r.InputCount() {
+					t.Fatalf("dst %d source %d out of range", d, s)
+				}
+				lo, hi = min(lo, src[s]), max(hi, src[s])
+			}
+			if dst[d] < lo || dst[d] > hi {
+				t.Fatalf("dst %d = %d outside source range %d-%d", d, dst[d], lo, hi)
+			}
+		}
+
+		if srcPixels == dstPixels {
+			for i := range dst {
+				if dst[i] != src[i] {
+					t.Fatalf("identity resample mismatch at %d: %d != %d", i, dst[i], src[i])
+				}
+			}
+		}
+	})
+}
+
+func FuzzRGBWMin(f *testing.F) {
+	f.Add([]byte{255, 255, 255})
+	f.Add([]byte{255, 0, 0, 10, 20, 30})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		pixels := len(src) / 3
+		if pixels < 1 || pixels > 128 {
+			return
+		}
+		r, err := NewRGBW(RGBWMin, pixels, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dst := make([]byte, r.OutputCount())
+		r.Apply(dst, src[:r.InputCount()])
+		for p := 0; p < pixels; p++ {
+			for c := 0; c < 3; c++ {
+				if int(dst[p*4+c])+int(dst[p*4+3]) != int(src[p*3+c]) {
+					t.Fatalf("pixel %d channel %d: %d + %d != %d", p, c, dst[p*4+c], dst[p*4+3], src[p*3+c])
+				}
+			}
+		}
+	})
+}
+
+func FuzzCurve(f *testing.F) {
+	f.Add(uint8(0), 2.2, []byte{0, 1, 128, 255})
+	f.Add(uint8(1), 1.0, []byte{64})
+	f.Add(uint8(3), 0.5, make([]byte, 512))
+
+	f.Fuzz(func(t *testing.T, mode uint8, gamma float64, src []byte) {
+		modes := []CurveMode{CurveSquare, CurveSqrt, CurveGamma, CurveInvert}
+		m := modes[int(mode)%len(modes)]
+		if len(src) < 1 || len(src) > 512 {
+			return
+		}
+		c, err := NewCurve(m, len(src), gamma)
+		if err != nil {
+			if m == CurveGamma {
+				return
+			}
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dst := make([]byte, c.OutputCount())
+		c.Apply(dst, src)
+		for i, v := range src {
+			if m == CurveInvert {
+				if dst[i] != 255-v {
+					t.Fatalf("invert %d = %d", v, dst[i])
+				}
+				continue
+			}
+			// Every other curve keeps black and full and never reverses order
+			if (v == 0 && dst[i] != 0) || (v == 255 && dst[i] != 255) {
+				t.Fatalf("%s maps %d to %d", m, v, dst[i])
+			}
+			if v > 0 && c.table[v] < c.table[v-1] {
+				t.Fatalf("%s is not monotonic at %d", m, v)
+			}
+		}
+	})
+}
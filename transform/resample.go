@@ -0,0 +1,97 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+)
+
+type ResampleMode string
+
+const (
+	ResampleNearest ResampleMode = "nearest"
+	ResampleLinear  ResampleMode = "linear"
+)
+
+// resampleWeight describes one destination pixel as a blend of two source pixels
+type resampleWeight struct {
+	i0, i1 int
+	w1     uint16 // weight of i1 out of 256
+}
+
+// Resample stretches or compresses a run of pixels of pixelSize channels each
+type Resample struct {
+	mode      ResampleMode
+	srcPixels int
+	dstPixels int
+	pixelSize int
+	weights   []resampleWeight
+}
+
+func NewResample(mode ResampleMode, srcPixels, dstPixels, pixelSize int) (*Resample, error) {
+	if mode != ResampleNearest && mode != ResampleLinear {
+		return nil, fmt.Errorf("unknown resample mode %q (expected nearest or linear)", mode)
+	}
+	if srcPixels < 1 || dstPixels < 1 || pixelSize < 1 {
+		return nil, fmt.Errorf("resample pixel counts and size must be positive")
+	}
+
+	weights := make([]resampleWeight, dstPixels)
+	for j := range weights {
+		var x float64
+		if dstPixels > 1 {
+			x = float64(j) * float64(srcPixels-1) / float64(dstPixels-1)
+		}
+		if mode == ResampleNearest {
+			i := int(math.Round(x))
+			weights[j] = resampleWeight{i0: i, i1: i}
+			continue
+		}
+		i0 := int(math.Floor(x))
+		i1 := min(i0+1, srcPixels-1)
+		w1 := uint16(math.Round((x - float64(i0)) * 256))
+		if w1 == 0 || i1 == i0 {
+			i1, w1 = i0, 0
+		}
+		weights[j] = resampleWeight{i0: i0, i1: i1, w1: w1}
+	}
+
+	return &Resample{
+		mode:      mode,
+		srcPixels: srcPixels,
+		dstPixels: dstPixels,
+		pixelSize: pixelSize,
+		weights:   weights,
+	}, nil
+}
+
+func (r *Resample) Name() string {
+	return fmt.Sprintf("resample-%s(%d->%d)", r.mode, r.srcPixels, r.dstPixels)
+}
+
+func (r *Resample) InputCount() int {
+	return r.srcPixels * r.pixelSize
+}
+
+func (r *Resample) OutputCount() int {
+	return r.dstPixels * r.pixelSize
+}
+
+func (r *Resample) Apply(dst, src []byte) {
+	k := r.pixelSize
+	for j, w := range r.weights {
+		for c := 0; c < k; c++ {
+			a := uint16(src[w.i0*k+c])
+			b := uint16(src[w.i1*k+c])
+			dst[j*k+c] = byte((a*(256-w.w1) + b*w.w1 + 128) >> 8)
+		}
+	}
+}
+
+func (r *Resample) Sources(d int) []int {
+	w := r.weights[d/r.pixelSize]
+	c := d % r.pixelSize
+	if w.i0 == w.i1 {
+		return []int{w.i0*r.pixelSize + c}
+	}
+	return []int{w.i0*r.pixelSize + c, w.i1*r.pixelSize + c}
+}
@@ -0,0 +1,81 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+)
+
+type RGBWMode string
+
+const (
+	RGBWMin    RGBWMode = "min"
+	RGBWMatrix RGBWMode = "matrix"
+)
+
+// RGBW converts RGB triples into RGBW quads
+type RGBW struct {
+	mode   RGBWMode
+	pixels int
+	matrix [4][3]float64
+}
+
+// NewRGBW creates a converter for pixels RGB triples. matrix maps RGB to RGBW
+// and is only used in matrix mode.
+func NewRGBW(mode RGBWMode, pixels int, matrix [][]float64) (*RGBW, error) {
+	if pixels < 1 {
+		return nil, fmt.Errorf("rgbw pixel count must be positive")
+	}
+	r := &RGBW{mode: mode, pixels: pixels}
+	switch mode {
+	case RGBWMin:
+	case RGBWMatrix:
+		if len(matrix) != 4 {
+			return nil, fmt.Errorf("rgbw_matrix must have 4 rows (R, G, B, W)")
+		}
+		for i, row := range matrix {
+			if len(row) != 3 {
+				return nil, fmt.Errorf("rgbw_matrix row %d must have 3 columns (R, G, B)", i)
+			}
+			copy(r.matrix[i][:], row)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rgbw mode %q (expected min or matrix)", mode)
+	}
+	return r, nil
+}
+
+func (r *RGBW) Name() string {
+	return fmt.Sprintf("rgbw-%s", r.mode)
+}
+
+func (r *RGBW) InputCount() int {
+	return r.pixels * 3
+}
+
+func (r *RGBW) OutputCount() int {
+	return r.pixels * 4
+}
+
+func (r *RGBW) Apply(dst, src []byte) {
+	for p := 0; p < r.pixels; p++ {
+		in := src[p*3 : p*3+3]
+		out := dst[p*4 : p*4+4]
+		if r.mode == RGBWMin {
+			w := min(in[0], in[1], in[2])
+			out[0] = in[0] - w
+			out[1] = in[1] - w
+			out[2] = in[2] - w
+			out[3] = w
+			continue
+		}
+		for i, row := range r.matrix {
+			v := row[0]*float64(in[0]) + row[1]*float64(in[1]) + row[2]*float64(in[2])
+			out[i] = byte(math.Round(math.Max(0, math.Min(255, v))))
+		}
+	}
+}
+
+func (r *RGBW) Sources(d int) []int {
+	p := d / 4
+	return []int{p * 3, p*3 + 1, p*3 + 2}
+}
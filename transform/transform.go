@@ -0,0 +1,15 @@
+package transform
+
+// Transform converts a block of source channels into a block of destination channels
+type Transform interface {
+	// Name identifies the transform in logs and route dumps
+	Name() string
+	// InputCount is the number of source channels consumed
+	InputCount() int
+	// OutputCount is the number of destination channels produced
+	OutputCount() int
+	// Apply writes OutputCount channels to dst from InputCount channels of src
+	Apply(dst, src []byte)
+	// Sources returns the source channel offsets that feed destination offset d
+	Sources(d int) []int
+}
@@ -0,0 +1,332 @@
+package tui
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gopatchy/artmap/config"
+	"github.com/gopatchy/artmap/health"
+	"github.com/gopatchy/artmap/monitor"
+	"github.com/gopatchy/artmap/senders"
+	"golang.org/x/term"
+)
+
+const refreshInterval = 500 * time.Millisecond
+
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyQuit
+)
+
+type status struct {
+	Targets   []config.Target        `json:"targets"`
+	Senders   []senders.SenderInfo   `json:"senders"`
+	Health    []health.DestInfo      `json:"health"`
+	Universes []monitor.UniverseInfo `json:"universes"`
+}
+
+type ui struct {
+	baseURL  string
+	token    string
+	client   *http.Client
+	status   status
+	frame    *monitor.FrameInfo
+	err      error
+	selected int
+	channel  int
+	scroll   int
+	width    int
+	height   int
+}
+
+// Run shows a live monitor of the artmap instance serving its API at baseURL until q is pressed.
+// A non-empty token is sent as a bearer token and tlsConfig is used for https URLs.
+func Run(baseURL, token string, tlsConfig *tls.Config) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui requires a terminal")
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	// Alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	u := &ui{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Timeout:   2 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}
+
+	keys := make(chan key)
+	go readKeys(keys)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	u.refresh()
+	u.render()
+	for {
+		select {
+		case k := <-keys:
+			if k == keyQuit {
+				return nil
+			}
+			u.handleKey(k)
+		case <-ticker.C:
+			u.refresh()
+		}
+		u.render()
+	}
+}
+
+func readKeys(keys chan<- key) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			keys <- keyQuit
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch {
+		case b[0] == 'q' || b[0] == 3:
+			keys = append(keys, keyQuit)
+		case b[0] == 'k':
+			keys = append(keys, keyUp)
+		case b[0] == 'j':
+			keys = append(keys, keyDown)
+		case b[0] == 'h':
+			keys = append(keys, keyLeft)
+		case b[0] == 'l':
+			keys = append(keys, keyRight)
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[':
+			seq := 3
+			switch b[2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'C':
+				keys = append(keys, keyRight)
+			case 'D':
+				keys = append(keys, keyLeft)
+			case '5', '6':
+				if len(b) >= 4 && b[3] == '~' {
+					seq = 4
+					if b[2] == '5' {
+						keys = append(keys, keyPageUp)
+					} else {
+						keys = append(keys, keyPageDown)
+					}
+				}
+			}
+			b = b[seq:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+func (u *ui) handleKey(k key) {
+	perRow := u.channelsPerRow()
+	switch k {
+	case keyUp:
+		u.selected--
+	case keyDown:
+		u.selected++
+	case keyLeft:
+		u.channel--
+	case keyRight:
+		u.channel++
+	case keyPageUp:
+		u.channel -= perRow * 4
+	case keyPageDown:
+		u.channel += perRow * 4
+	}
+	u.selected = max(0, min(u.selected, len(u.status.Universes)-1))
+	u.channel = max(0, min(u.channel, 511))
+	if k == keyUp || k == keyDown {
+		u.fetchFrame()
+	}
+}
+
+func (u *ui) refresh() {
+	u.width, u.height = 80, 24
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		u.width, u.height = w, h
+	}
+
+	var s status
+	if u.err = u.get("/artmap/api/status", nil, &s); u.err != nil {
+		return
+	}
+	u.status = s
+	u.selected = max(0, min(u.selected, len(s.Universes)-1))
+	u.fetchFrame()
+}
+
+func (u *ui) fetchFrame() {
+	u.frame = nil
+	if u.selected >= len(u.status.Universes) {
+		return
+	}
+	info := u.status.Universes[u.selected]
+	q := url.Values{
+		"universe":  {info.Universe.String()},
+		"direction": {string(info.Direction)},
+	}
+	var f monitor.FrameInfo
+	if u.err = u.get("/artmap/api/dmx", q, &f); u.err == nil {
+		u.frame = &f
+	}
+}
+
+func (u *ui) get(path string, q url.Values, v any) error {
+	target := u.baseURL + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (u *ui) channelsPerRow() int {
+	n := (u.width - 6) / 4 / 8 * 8
+	return max(8, min(n, 32))
+}
+
+func (u *ui) render() {
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("\x1b[1martmap\x1b[0m  %s  %s", u.baseURL, time.Now().Format("15:04:05"))
+	add("↑/↓ universe  ←/→ channel  PgUp/PgDn page  q quit")
+	if u.err != nil {
+		add("\x1b[31merror: %v\x1b[0m", u.err)
+	}
+
+	add("")
+	add("\x1b[1mINPUT SOURCES\x1b[0m")
+	for _, s := range u.status.Senders {
+		add("  %-20s %s", s.Universe, s.IP)
+	}
+
+	add("\x1b[1mOUTPUT TARGETS\x1b[0m")
+	for _, t := range u.status.Targets {
+		add("  %-20s %s", t.Universe, t.Address)
+	}
+	for _, d := range u.status.Health {
+		state := "ok"
+		if !d.Healthy {
+			state = "\x1b[31munhealthy\x1b[0m"
+		}
+		add("  %-20s %s errors=%d", d.Addr, state, d.Errors)
+	}
+
+	add("\x1b[1mUNIVERSES\x1b[0m")
+	for i, info := range u.status.Universes {
+		cursor := " "
+		if i == u.selected {
+			cursor = ">"
+		}
+		add("%s %-7s %-20s %6.1f fps", cursor, info.Direction, info.Universe, info.FPS)
+	}
+
+	if u.frame != nil {
+		add("")
+		add("\x1b[1mCHANNELS %s %s\x1b[0m  ch %d = %d",
+			u.frame.Direction, u.frame.Universe, u.channel+1, u.frame.Data[u.channel])
+		lines = append(lines, u.gridLines(u.height-len(lines))...)
+	}
+
+	if len(lines) > u.height {
+		lines = lines[:u.height]
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(lines, "\r\n"))
+	fmt.Print(b.String())
+}
+
+// gridLines renders up to maxRows rows of the channel grid, scrolled to keep the cursor visible
+func (u *ui) gridLines(maxRows int) []string {
+	perRow := u.channelsPerRow()
+	totalRows := (512 + perRow - 1) / perRow
+	rows := max(1, min(maxRows, totalRows))
+
+	cursorRow := u.channel / perRow
+	if cursorRow < u.scroll {
+		u.scroll = cursorRow
+	}
+	if cursorRow >= u.scroll+rows {
+		u.scroll = cursorRow - rows + 1
+	}
+	u.scroll = max(0, min(u.scroll, totalRows-rows))
+
+	var lines []string
+	for row := u.scroll; row < u.scroll+rows; row++ {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%4d ", row*perRow+1)
+		for col := 0; col < perRow; col++ {
+			ch := row*perRow + col
+			if ch >= 512 {
+				break
+			}
+			if ch == u.channel {
+				fmt.Fprintf(&b, " \x1b[7m%3d\x1b[0m", u.frame.Data[ch])
+			} else {
+				fmt.Fprintf(&b, " %3d", u.frame.Data[ch])
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
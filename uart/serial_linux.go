@@ -0,0 +1,76 @@
+//go:build linux
+
+package uart
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	baudRate = 250000
+
+	// E1.11 minimums are 92us break and 12us mark-after-break; sleeps overshoot
+	// on a busy system, which receivers tolerate
+	breakTime = 110 * time.Microsecond
+	markTime  = 16 * time.Microsecond
+)
+
+type serial struct {
+	f  *os.File
+	fd int
+}
+
+// openSerial opens device raw at 250000 baud 8N2, the DMX512 line format
+func openSerial(device string) (*serial, error) {
+	f, err := os.OpenFile(device, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS2)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("get termios: %w", err)
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CRTSCTS | unix.CBAUD
+	t.Cflag |= unix.CS8 | unix.CSTOPB | unix.CLOCAL | unix.CREAD | unix.BOTHER
+	t.Ispeed = baudRate
+	t.Ospeed = baudRate
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS2, t); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("set termios: %w", err)
+	}
+	return &serial{f: f, fd: fd}, nil
+}
+
+// writeFrame sends break, mark-after-break, then start code 0 and the slots
+func (s *serial) writeFrame(data []byte) error {
+	if err := unix.IoctlSetInt(s.fd, unix.TIOCSBRK, 0); err != nil {
+		return fmt.Errorf("break: %w", err)
+	}
+	time.Sleep(breakTime)
+	if err := unix.IoctlSetInt(s.fd, unix.TIOCCBRK, 0); err != nil {
+		return fmt.Errorf("break: %w", err)
+	}
+	time.Sleep(markTime)
+
+	buf := make([]byte, 1+len(data))
+	copy(buf[1:], data)
+	if _, err := s.f.Write(buf); err != nil {
+		return err
+	}
+	// Wait for the shift register to empty so the next break does not cut the frame
+	return unix.IoctlSetInt(s.fd, unix.TCSBRK, 1)
+}
+
+func (s *serial) close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package uart
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// serial is unavailable: DMX512 timing needs the Linux termios2 and break ioctls
+type serial struct{}
+
+func openSerial(device string) (*serial, error) {
+	return nil, fmt.Errorf("uart outputs are not supported on %s", runtime.GOOS)
+}
+
+func (s *serial) writeFrame(data []byte) error {
+	return fmt.Errorf("uart outputs are not supported on %s", runtime.GOOS)
+}
+
+func (s *serial) close() error {
+	return nil
+}
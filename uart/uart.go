@@ -0,0 +1,92 @@
+package uart
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// A full 513-slot frame takes about 23ms on the wire, so 40Hz leaves idle time between frames
+	refreshInterval = 25 * time.Millisecond
+	reopenDelay     = 5 * time.Second
+)
+
+// Output drives one serial device (e.g. the Raspberry Pi's /dev/ttyAMA0 through
+// an RS-485 transceiver) as a native DMX512 port
+type Output struct {
+	device string
+
+	mu    sync.Mutex
+	frame [512]byte
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(device string) *Output {
+	return &Output{
+		device: device,
+		done:   make(chan struct{}),
+	}
+}
+
+func (o *Output) Start() {
+	o.wg.Add(1)
+	go o.run()
+}
+
+func (o *Output) Stop() {
+	close(o.done)
+	o.wg.Wait()
+}
+
+// Update stores the frame to send; the port refreshes continuously as DMX512 requires
+func (o *Output) Update(data [512]byte) {
+	o.mu.Lock()
+	o.frame = data
+	o.mu.Unlock()
+}
+
+func (o *Output) run() {
+	defer o.wg.Done()
+	for {
+		if err := o.stream(); err != nil {
+			log.Printf("[uart] device=%s error=%v", o.device, err)
+		}
+		select {
+		case <-o.done:
+			return
+		case <-time.After(reopenDelay):
+		}
+	}
+}
+
+func (o *Output) stream() error {
+	s, err := openSerial(o.device)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	log.Printf("[uart] opened device=%s", o.device)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	var frame [512]byte
+	for {
+		select {
+		case <-o.done:
+			return nil
+		case <-ticker.C:
+		}
+
+		o.mu.Lock()
+		frame = o.frame
+		o.mu.Unlock()
+
+		if err := s.writeFrame(frame[:]); err != nil {
+			return err
+		}
+	}
+}
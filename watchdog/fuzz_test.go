@@ -0,0 +1,100 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+)
+
+func TestWatchdog(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	w := New(2 * time.Second)
+	w.SetClock(fake)
+	var events []Event
+	w.SetOnEvent(func(e Event) { events = append(events, e) })
+
+	u := config.ArtNetUniverse(1)
+	live := []config.Universe{u}
+
+	// Sending within the window keeps the output healthy
+	for range 5 {
+		w.Sent(u, nil)
+		w.Evaluate(live)
+		fake.Advance(time.Second)
+	}
+	if len(events) != 0 {
+		t.Fatalf("events while sending = %+v", events)
+	}
+
+	// Failing sends raise SendErrors once the window passes
+	for range 3 {
+		w.Sent(u, errors.New("down"))
+		w.Evaluate(live)
+		fake.Advance(time.Second)
+	}
+	w.Evaluate(live)
+	if len(events) != 1 || !events[0].Active || events[0].Reason != SendErrors || events[0].Universe != u {
+		t.Fatalf("events after failures = %+v", events)
+	}
+	if a := w.Active(); len(a) != 1 || a[0].Errors != 3 {
+		t.Fatalf("active = %+v, want one with 3 errors", a)
+	}
+
+	// A successful send clears it
+	w.Sent(u, nil)
+	w.Evaluate(live)
+	if len(events) != 2 || events[1].Active {
+		t.Fatalf("events after recovery = %+v", events)
+	}
+	if a := w.Active(); len(a) != 0 {
+		t.Fatalf("active after recovery = %+v", a)
+	}
+}
+
+func TestWatchdogNoSends(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	w := New(time.Second)
+	w.SetClock(fake)
+	var events []Event
+	w.SetOnEvent(func(e Event) { events = append(events, e) })
+
+	u := config.ArtNetUniverse(2)
+	w.Evaluate([]config.Universe{u})
+	fake.Advance(2 * time.Second)
+	w.Evaluate([]config.Universe{u})
+	if len(events) != 1 || events[0].Reason != NoSends {
+		t.Fatalf("events = %+v, want one NoSends", events)
+	}
+
+	// Input going away clears the event and forgets the output
+	w.Evaluate(nil)
+	if len(events) != 2 || events[1].Active {
+		t.Fatalf("events after input stopped = %+v", events)
+	}
+	if len(w.outputs) != 0 {
+		t.Fatalf("outputs kept = %d", len(w.outputs))
+	}
+
+	// Input returning restarts the window rather than raising at once
+	w.Evaluate([]config.Universe{u})
+	if len(events) != 2 {
+		t.Fatalf("events on input return = %+v", events)
+	}
+}
+
+func TestWatchdogOff(t *testing.T) {
+	w := New(0)
+	if w != nil {
+		t.Fatal("New(0) != nil")
+	}
+	w.SetClock(clock.Real)
+	w.SetOnEvent(func(Event) { t.Fatal("event from nil watchdog") })
+	w.Sent(config.ArtNetUniverse(1), nil)
+	w.Evaluate([]config.Universe{config.ArtNetUniverse(1)})
+	if a := w.Active(); a != nil {
+		t.Fatalf("active = %+v", a)
+	}
+}
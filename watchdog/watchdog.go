@@ -0,0 +1,169 @@
+package watchdog
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopatchy/artmap/clock"
+	"github.com/gopatchy/artmap/config"
+)
+
+type Reason string
+
+const (
+	// NoSends means nothing was sent for the output, e.g. every destination is gone
+	NoSends Reason = "no_sends"
+	// SendErrors means sends were attempted but all of them failed
+	SendErrors Reason = "send_errors"
+)
+
+// Event reports an output universe with live input stalling (Active) or sending again
+type Event struct {
+	Time     time.Time       `json:"time"`
+	Universe config.Universe `json:"universe"`
+	Reason   Reason          `json:"reason"`
+	Active   bool            `json:"active"`
+	Errors   uint64          `json:"errors"` // failed sends while stalled
+}
+
+type output struct {
+	liveSince time.Time // zero while the output has no live input
+	lastOK    time.Time
+	errors    uint64 // failures since the last successful send
+	active    *Event
+}
+
+// Watchdog raises an event when an output universe whose sources are live has
+// had no successful send for its window, catching dead sockets and routing
+// breaks that leave the engine busy but nothing on the wire
+type Watchdog struct {
+	mu      sync.Mutex
+	window  time.Duration
+	outputs map[config.Universe]*output
+	onEvent func(Event)
+	clock   clock.Clock
+}
+
+// New returns a watchdog with the given window, or nil (which ignores all calls) if it is 0
+func New(window time.Duration) *Watchdog {
+	if window <= 0 {
+		return nil
+	}
+	return &Watchdog{window: window, outputs: map[config.Universe]*output{}, clock: clock.Real}
+}
+
+// SetClock replaces the clock sends and windows are timed with; call before use
+func (w *Watchdog) SetClock(c clock.Clock) {
+	if w != nil {
+		w.clock = c
+	}
+}
+
+// SetOnEvent registers a callback for raised and cleared events; it must be set before use
+func (w *Watchdog) SetOnEvent(fn func(Event)) {
+	if w != nil {
+		w.onEvent = fn
+	}
+}
+
+func (w *Watchdog) output(u config.Universe) *output {
+	o := w.outputs[u]
+	if o == nil {
+		o = &output{}
+		w.outputs[u] = o
+	}
+	return o
+}
+
+// Sent records the result of one send of output universe u
+func (w *Watchdog) Sent(u config.Universe, err error) {
+	if w == nil {
+		return
+	}
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	o := w.output(u)
+	if err != nil {
+		o.errors++
+		return
+	}
+	o.lastOK = now
+	o.errors = 0
+}
+
+// Evaluate judges every output against live, the outputs currently fed by
+// input, raising and clearing events; it should be called about once per second
+func (w *Watchdog) Evaluate(live []config.Universe) {
+	if w == nil {
+		return
+	}
+	now := w.clock.Now()
+	isLive := make(map[config.Universe]bool, len(live))
+	for _, u := range live {
+		isLive[u] = true
+	}
+
+	var events []Event
+	w.mu.Lock()
+	for _, u := range live {
+		o := w.output(u)
+		if o.liveSince.IsZero() {
+			o.liveSince = now
+		}
+	}
+	for u, o := range w.outputs {
+		if !isLive[u] {
+			o.liveSince = time.Time{}
+		}
+		since := o.lastOK
+		if o.liveSince.After(since) {
+			since = o.liveSince
+		}
+		stalled := !o.liveSince.IsZero() && now.Sub(since) > w.window
+		switch {
+		case stalled && o.active == nil:
+			reason := NoSends
+			if o.errors > 0 {
+				reason = SendErrors
+			}
+			o.active = &Event{Time: now, Universe: u, Reason: reason, Active: true, Errors: o.errors}
+			events = append(events, *o.active)
+		case stalled:
+			o.active.Errors = o.errors
+		case o.active != nil:
+			e := *o.active
+			e.Time, e.Active = now, false
+			o.active = nil
+			events = append(events, e)
+		}
+		if o.liveSince.IsZero() && o.active == nil {
+			delete(w.outputs, u)
+		}
+	}
+	w.mu.Unlock()
+
+	if w.onEvent != nil {
+		for _, e := range events {
+			w.onEvent(e)
+		}
+	}
+}
+
+// Active returns the currently stalled outputs sorted by universe
+func (w *Watchdog) Active() []Event {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	var result []Event
+	for _, o := range w.outputs {
+		if o.active != nil {
+			result = append(result, *o.active)
+		}
+	}
+	w.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool { return result[i].Universe.Compare(result[j].Universe) < 0 })
+	return result
+}